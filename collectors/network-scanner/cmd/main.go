@@ -3,10 +3,12 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"net/http"
 	"os"
 	"os/signal"
+	"runtime/debug"
 	"syscall"
 	"time"
 
@@ -17,6 +19,20 @@ import (
 	"go.uber.org/zap"
 )
 
+// Version and Commit identify the build, set with
+// -ldflags "-X main.Version=... -X main.Commit=...".
+var (
+	Version = "dev"
+	Commit  = ""
+)
+
+// Shutdown grace periods: scans get drainTimeout to finish in-flight hosts
+// and report completion, then the HTTP server gets httpShutdownTimeout.
+const (
+	drainTimeout        = 30 * time.Second
+	httpShutdownTimeout = 10 * time.Second
+)
+
 func main() {
 	// Initialize logger
 	logger, err := zap.NewProduction()
@@ -41,18 +57,98 @@ func main() {
 		"rate_limit", cfg.Scanner.RateLimit,
 	)
 
-	// Initialize RabbitMQ publisher
-	pub, err := publisher.New(cfg.RabbitMQ.URL, sugar)
+	// Initialize event publisher
+	var pub publisher.EventPublisher
+	switch cfg.Output.Mode {
+	case config.OutputNATS:
+		pub, err = publisher.NewNATS(publisher.NATSOptions{
+			URL:           cfg.NATS.URL,
+			SubjectPrefix: cfg.NATS.SubjectPrefix,
+			Stream:        cfg.NATS.Stream,
+			CredsFile:     cfg.NATS.CredsFile,
+			TokenFile:     cfg.NATS.TokenFile,
+		}, sugar)
+	case config.OutputKafka:
+		pub, err = publisher.NewKafka(publisher.KafkaOptions{
+			Brokers: cfg.Kafka.Brokers,
+			Topic:   cfg.Kafka.Topic,
+		}, sugar)
+	case config.OutputHTTP:
+		pub, err = newHTTPSink(cfg.HTTPSink, sugar)
+	case config.OutputFile:
+		pub, err = newFileSink(cfg.FileSink, sugar)
+	case config.OutputNone:
+		pub = publisher.Nop()
+	default:
+		var rabbit *publisher.Publisher
+		rabbitTLS := publisher.TLSOptions{
+			CACert:     cfg.RabbitMQ.TLSCACert,
+			ClientCert: cfg.RabbitMQ.TLSClientCert,
+			ClientKey:  cfg.RabbitMQ.TLSClientKey,
+			SkipVerify: cfg.RabbitMQ.TLSSkipVerify,
+		}
+		if rabbit, err = publisher.NewTLS(cfg.RabbitMQ.URL, rabbitTLS, sugar); err == nil {
+			rabbit.SetReconnectBuffer(cfg.RabbitMQ.ReconnectBufferSize)
+			rabbit.SetBatching(cfg.RabbitMQ.BatchSize, time.Duration(cfg.RabbitMQ.BatchWindowMS)*time.Millisecond)
+			rabbit.SetRoutingKeyTemplate(cfg.RabbitMQ.RoutingKeyTemplate, cfg.RabbitMQ.Environment)
+			if err = rabbit.SetExchange(cfg.RabbitMQ.Exchange, cfg.RabbitMQ.DeclareExchange); err == nil {
+				err = rabbit.SetConfirmMode(cfg.RabbitMQ.ConfirmMode)
+			}
+			pub = rabbit
+		}
+	}
+	if err == nil && cfg.Output.Mode != config.OutputHTTP && cfg.HTTPSink.URL != "" {
+		var sink publisher.EventPublisher
+		if sink, err = newHTTPSink(cfg.HTTPSink, sugar); err == nil {
+			pub = publisher.Fanout(sugar, pub, sink)
+		}
+	}
+	if err == nil && cfg.Output.Mode != config.OutputFile && cfg.FileSink.Path != "" {
+		var sink publisher.EventPublisher
+		if sink, err = newFileSink(cfg.FileSink, sugar); err == nil {
+			pub = publisher.Fanout(sugar, pub, sink)
+		}
+	}
 	if err != nil {
 		sugar.Fatalf("Failed to initialize publisher: %v", err)
 	}
 	defer func() { _ = pub.Close() }()
+	pub.SetCategoryRouting(cfg.RabbitMQ.CategoryRoutingKeys)
+	pub.SetVantagePoint(cfg.Scanner.VantagePoint)
 
 	// Initialize scanner
-	scan := scanner.New(cfg.Scanner, pub, sugar)
+	scan, err := scanner.New(cfg.Scanner, pub, sugar)
+	if err != nil {
+		sugar.Fatalf("Failed to initialize scanner: %v", err)
+	}
+
+	// Apply config file changes, on write or SIGHUP, that are safe to
+	// make without a restart
+	reload := config.Watch(func(newCfg *config.Config, err error) {
+		if err != nil {
+			sugar.Errorf("Failed to reload configuration: %v", err)
+			return
+		}
+		if _, err := scan.Reload(newCfg.Scanner); err != nil {
+			sugar.Warnf("Configuration reload rejected: %v", err)
+		}
+	})
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	go func() {
+		for range hup {
+			sugar.Info("Received SIGHUP, reloading configuration")
+			reload()
+		}
+	}()
 
 	// Initialize API server
 	server := api.New(cfg.Server, scan, sugar)
+	server.SetBuildInfo(api.BuildInfo{
+		Version:  Version,
+		Commit:   buildCommit(),
+		Features: features(cfg),
+	})
 
 	// Create HTTP server
 	httpServer := &http.Server{
@@ -78,17 +174,82 @@ func main() {
 
 	sugar.Info("Shutting down server...")
 
-	// Graceful shutdown with timeout
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
-	defer cancel()
-
-	// Stop scanner
-	scan.Stop()
+	// Let in-flight hosts finish and scans report completion. The API keeps
+	// serving status meanwhile but refuses to start or resume scans.
+	drainCtx, cancelDrain := context.WithTimeout(context.Background(), drainTimeout)
+	defer cancelDrain()
+	if err := scan.Drain(drainCtx); err != nil && !errors.Is(err, scanner.ErrNoScanRunning) {
+		sugar.Errorf("Failed to drain scanner: %v", err)
+	}
 
-	// Shutdown HTTP server
-	if err := httpServer.Shutdown(ctx); err != nil {
+	// Shutdown HTTP server with its own grace period, so a drain that ran
+	// out of time doesn't cut off in-flight requests
+	shutdownCtx, cancelShutdown := context.WithTimeout(context.Background(), httpShutdownTimeout)
+	defer cancelShutdown()
+	if err := httpServer.Shutdown(shutdownCtx); err != nil {
 		sugar.Errorf("Server forced to shutdown: %v", err)
 	}
 
 	sugar.Info("Server stopped")
 }
+
+// newHTTPSink creates the CloudEvents HTTP sink publisher.
+func newHTTPSink(cfg config.HTTPSinkConfig, logger *zap.SugaredLogger) (publisher.EventPublisher, error) {
+	sink, err := publisher.NewHTTPSink(publisher.HTTPSinkOptions{
+		URL:     cfg.URL,
+		Timeout: time.Duration(cfg.TimeoutMS) * time.Millisecond,
+	}, logger)
+	if err != nil {
+		return nil, err
+	}
+	return sink, nil
+}
+
+func newFileSink(cfg config.FileSinkConfig, logger *zap.SugaredLogger) (publisher.EventPublisher, error) {
+	sink, err := publisher.NewFileSink(publisher.FileSinkOptions{
+		Path:       cfg.Path,
+		MaxBytes:   int64(cfg.MaxSizeMB) << 20,
+		MaxBackups: cfg.MaxBackups,
+	}, logger)
+	if err != nil {
+		return nil, err
+	}
+	return sink, nil
+}
+
+// buildCommit returns Commit, or the VCS revision go build stamped into the
+// binary when it wasn't set.
+func buildCommit() string {
+	if Commit != "" {
+		return Commit
+	}
+	if info, ok := debug.ReadBuildInfo(); ok {
+		for _, setting := range info.Settings {
+			if setting.Key == "vcs.revision" {
+				return setting.Value
+			}
+		}
+	}
+	return "unknown"
+}
+
+// features reports the optional capabilities cfg enables, for GET /api/v1/version.
+func features(cfg *config.Config) map[string]bool {
+	return map[string]bool{
+		"udp_enabled":             cfg.Scanner.EnableUDP,
+		"tls_grab_enabled":        len(cfg.Scanner.TLSPorts) > 0,
+		"cloud_detection_enabled": cfg.Scanner.EnableCloudDetection,
+		"http_enrichment_enabled": cfg.Scanner.HTTPEnrichment,
+		"active_probes_enabled":   len(cfg.Scanner.ActiveProbes) > 0,
+		"ping_enabled":            cfg.Scanner.EnablePing,
+		"host_events":             cfg.Scanner.HostEvents,
+		"streaming_only":          cfg.Scanner.StreamingOnly,
+		"checkpoints_enabled":     cfg.Scanner.CheckpointDir != "",
+		"schedule_enabled":        cfg.Scanner.Schedule.Cron != "",
+		"sign_callbacks":          cfg.Scanner.SignCallbacks,
+		"confirm_mode":            cfg.RabbitMQ.ConfirmMode,
+		"http_sink_enabled":       cfg.HTTPSink.URL != "",
+		"file_sink_enabled":       cfg.FileSink.Path != "",
+		"api_keys_required":       len(cfg.Server.APIKeys) > 0,
+	}
+}
@@ -2,20 +2,28 @@
 package api
 
 import (
+	"errors"
+	"fmt"
+	"io"
 	"net/http"
+	"runtime"
+	"time"
 
 	"github.com/aiforce-discovery-agent/collectors/network-scanner/internal/config"
 	"github.com/aiforce-discovery-agent/collectors/network-scanner/internal/scanner"
 	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"go.uber.org/zap"
 )
 
 // Server represents the HTTP API server.
 type Server struct {
-	config  config.ServerConfig
-	scanner *scanner.Scanner
-	logger  *zap.SugaredLogger
-	router  *gin.Engine
+	config    config.ServerConfig
+	scanner   *scanner.Scanner
+	logger    *zap.SugaredLogger
+	router    *gin.Engine
+	buildInfo BuildInfo
 }
 
 // New creates a new API server.
@@ -23,16 +31,31 @@ func New(cfg config.ServerConfig, scan *scanner.Scanner, logger *zap.SugaredLogg
 	gin.SetMode(gin.ReleaseMode)
 
 	s := &Server{
-		config:  cfg,
-		scanner: scan,
-		logger:  logger,
-		router:  gin.New(),
+		config:    cfg,
+		scanner:   scan,
+		logger:    logger,
+		router:    gin.New(),
+		buildInfo: BuildInfo{Version: "dev", Commit: "unknown", GoVersion: runtime.Version()},
+	}
+
+	// Without trusted proxies X-Forwarded-For is ignored, so clients can't
+	// pick their own address for rate limiting
+	if err := s.router.SetTrustedProxies(cfg.TrustedProxies); err != nil {
+		logger.Errorw("Invalid trusted proxies, trusting none", "error", err)
+		_ = s.router.SetTrustedProxies(nil)
 	}
 
 	s.setupRoutes()
 	return s
 }
 
+// SetBuildInfo sets what GET /version and /api/v1/version report. The Go
+// version is filled in from the runtime.
+func (s *Server) SetBuildInfo(info BuildInfo) {
+	info.GoVersion = runtime.Version()
+	s.buildInfo = info
+}
+
 // Router returns the gin router.
 func (s *Server) Router() *gin.Engine {
 	return s.router
@@ -42,25 +65,42 @@ func (s *Server) setupRoutes() {
 	// Middleware
 	s.router.Use(gin.Recovery())
 	s.router.Use(s.loggingMiddleware())
+	s.router.Use(s.corsMiddleware())
 
 	// Health endpoints
 	s.router.GET("/health", s.healthHandler)
 	s.router.GET("/ready", s.readyHandler)
+	s.router.GET("/version", s.versionHandler)
 
 	// API v1
-	v1 := s.router.Group("/api/v1")
+	v1 := s.router.Group("/api/v1", s.v1Middleware()...)
 	{
 		// Scanner control
 		v1.POST("/scan/start", s.startScanHandler)
 		v1.POST("/scan/stop", s.stopScanHandler)
+		v1.POST("/scan/cancel", s.cancelScanHandler)
+		v1.POST("/scan/resume", s.resumeScanHandler)
 		v1.GET("/scan/status", s.scanStatusHandler)
+		v1.GET("/scan/results", s.scanResultsHandler)
+		v1.GET("/scan/:scan_id/results", s.scanResultsPageHandler)
+		v1.GET("/scan/:scan_id/stream", s.scanStreamHandler)
+		v1.GET("/scans", s.listScansHandler)
 
 		// Target scanning
 		v1.POST("/scan/target", s.scanTargetHandler)
+
+		// Build identity with the enabled features
+		v1.GET("/version", s.featuresHandler)
 	}
 
-	// Metrics endpoint (placeholder)
-	s.router.GET("/metrics", s.metricsHandler)
+	// Prometheus metrics
+	s.router.GET("/metrics", gin.WrapH(promhttp.Handler()))
+}
+
+// v1Middleware returns the /api/v1 middleware: rate limiting first, so
+// unauthenticated floods are throttled, then API key checks.
+func (s *Server) v1Middleware() []gin.HandlerFunc {
+	return []gin.HandlerFunc{s.rateLimitMiddleware(), s.apiKeyMiddleware()}
 }
 
 func (s *Server) loggingMiddleware() gin.HandlerFunc {
@@ -85,25 +125,83 @@ func (s *Server) healthHandler(c *gin.Context) {
 	})
 }
 
-// Readiness check handler
+// Readiness check handler - not ready while discoveries can't be published
 func (s *Server) readyHandler(c *gin.Context) {
-	// Check if scanner is operational
+	if !s.scanner.PublisherConnected() {
+		c.JSON(http.StatusServiceUnavailable, gin.H{
+			"status":  "not_ready",
+			"service": "network-scanner",
+			"reason":  "message broker connection is down",
+		})
+		return
+	}
+
 	c.JSON(http.StatusOK, gin.H{
 		"status":  "ready",
 		"service": "network-scanner",
 	})
 }
 
+// Version handler - build identity only, for fleet upgrades. The enabled
+// features tell whether auth and signing are on, so they're left to the
+// authenticated /api/v1/version.
+func (s *Server) versionHandler(c *gin.Context) {
+	info := s.buildInfo
+	info.Features = nil
+	c.JSON(http.StatusOK, info)
+}
+
+// Features handler - build identity and enabled features
+func (s *Server) featuresHandler(c *gin.Context) {
+	c.JSON(http.StatusOK, s.buildInfo)
+}
+
 // Start scan handler - supports both legacy (no body) and autonomous (with body) modes
 func (s *Server) startScanHandler(c *gin.Context) {
 	var req StartScanRequest
 
 	// Try to parse request body for autonomous mode (ADR-007)
-	if err := c.ShouldBindJSON(&req); err == nil && req.ScanID != "" {
+	err := c.ShouldBindJSON(&req)
+	if err == nil && req.ScanID == "" && s.scanner.AutoScanID() {
+		req.ScanID = uuid.New().String()
+	}
+	if err == nil && req.ScanID != "" {
+		// Reject oversized requests before doing any per-entry work
+		if n, limit := len(req.Subnets)+len(req.Targets), s.scanner.MaxSubnetsPerScan(); limit > 0 && n > limit {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": fmt.Sprintf("too many subnets and targets: %d exceeds the limit of %d per scan; "+
+					"cover contiguous addresses with a CIDR or start-end range, or list them "+
+					"under scanner.subnets and scanner.targets in the config file", n, limit),
+			})
+			return
+		}
+
+		if len(req.Subnets) == 0 && len(req.Targets) == 0 {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": "at least one subnet or target is required",
+			})
+			return
+		}
+
+		if err := s.scanner.ValidateTargets(req.Targets); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": err.Error(),
+			})
+			return
+		}
+
+		if err := s.scanner.ValidateSubnets(req.Subnets); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": err.Error(),
+			})
+			return
+		}
+
 		// Autonomous mode - start with custom config
 		cfg := scanner.AutonomousScanConfig{
 			ScanID:             req.ScanID,
 			Subnets:            req.Subnets,
+			Targets:            req.Targets,
 			PortRanges:         req.PortRanges,
 			RateLimitPPS:       req.RateLimitPPS,
 			TimeoutMS:          req.TimeoutMS,
@@ -111,27 +209,39 @@ func (s *Server) startScanHandler(c *gin.Context) {
 			DeadHostThreshold:  req.DeadHostThreshold,
 			ProgressURL:        req.ProgressURL,
 			CompleteURL:        req.CompleteURL,
-			APIKey:             c.GetHeader("X-Internal-API-Key"),
+			HeartbeatURL:       req.HeartbeatURL,
+			ScanTimeoutSeconds: req.ScanTimeoutSeconds,
+			APIKey:             c.GetHeader(apiKeyHeader),
+		}
+
+		var tuning *scanner.ScanTuning
+		if req.Tuning == scanner.TuningAuto {
+			derived := s.scanner.AutoTune(&cfg)
+			tuning = &derived
 		}
 
 		if err := s.scanner.StartAutonomous(cfg); err != nil {
-			c.JSON(http.StatusConflict, gin.H{
+			c.JSON(startErrorStatus(err), gin.H{
 				"error": err.Error(),
 			})
 			return
 		}
 
-		c.JSON(http.StatusOK, gin.H{
+		resp := gin.H{
 			"status":  "started",
 			"message": "Autonomous network scan started",
 			"scan_id": req.ScanID,
-		})
+		}
+		if tuning != nil {
+			resp["tuning"] = tuning
+		}
+		c.JSON(http.StatusOK, resp)
 		return
 	}
 
 	// Legacy mode - start with configured defaults
 	if err := s.scanner.Start(); err != nil {
-		c.JSON(http.StatusConflict, gin.H{
+		c.JSON(startErrorStatus(err), gin.H{
 			"error": err.Error(),
 		})
 		return
@@ -143,32 +253,296 @@ func (s *Server) startScanHandler(c *gin.Context) {
 	})
 }
 
+// startErrorStatus maps an error starting or resuming a scan to a status:
+// 503 while the service shuts down, 400 when the request lacks the key to
+// sign its callbacks, 409 otherwise.
+func startErrorStatus(err error) int {
+	if errors.Is(err, scanner.ErrDraining) {
+		return http.StatusServiceUnavailable
+	}
+	if errors.Is(err, scanner.ErrNoSigningKey) {
+		return http.StatusBadRequest
+	}
+	return http.StatusConflict
+}
+
 // Stop scan handler
 func (s *Server) stopScanHandler(c *gin.Context) {
-	// Check for scan_id in request body (ADR-007)
+	// Check for scan_id in request body (ADR-007). Without a body whatever
+	// is running is stopped.
 	var req StopScanRequest
-	if err := c.ShouldBindJSON(&req); err == nil && req.ScanID != "" {
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+	if req.ScanID != "" {
 		s.logger.Infow("Stop scan requested", "scan_id", req.ScanID)
 	}
 
-	s.scanner.Stop()
+	if err := s.scanner.Stop(req.ScanID); err != nil {
+		status := http.StatusConflict
+		if errors.Is(err, scanner.ErrNoScanRunning) {
+			status = http.StatusNotFound
+		}
+		c.JSON(status, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
 	c.JSON(http.StatusOK, gin.H{
 		"status":  "stopped",
 		"message": "Network scan stopped",
 	})
 }
 
-// Scan status handler
+// Cancel scan handler - immediate or graceful cancellation without blocking
+func (s *Server) cancelScanHandler(c *gin.Context) {
+	var req CancelScanRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+	if req.Mode == "" {
+		req.Mode = scanner.CancelImmediate
+	}
+
+	s.logger.Infow("Cancel scan requested", "scan_id", req.ScanID, "mode", req.Mode)
+
+	if err := s.scanner.Cancel(req.ScanID, req.Mode); err != nil {
+		c.JSON(cancelErrorStatus(err), gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{
+		"status":  "cancelling",
+		"mode":    req.Mode,
+		"message": "Network scan cancellation requested",
+	})
+}
+
+// cancelErrorStatus maps an error cancelling a scan to a status: 400 for a
+// request the scanner can't act on as given (an unknown mode, or no scan
+// ID while several scans run), 409 when no matching scan is running or it
+// is already finishing.
+func cancelErrorStatus(err error) int {
+	if errors.Is(err, scanner.ErrUnknownCancelMode) || errors.Is(err, scanner.ErrScanIDRequired) {
+		return http.StatusBadRequest
+	}
+	return http.StatusConflict
+}
+
+// Resume scan handler - continues a stopped scan from its checkpoint
+func (s *Server) resumeScanHandler(c *gin.Context) {
+	var req ResumeScanRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	s.logger.Infow("Resume scan requested", "scan_id", req.ScanID)
+
+	if err := s.scanner.ResumeScan(req.ScanID); err != nil {
+		status := startErrorStatus(err)
+		if errors.Is(err, scanner.ErrNoCheckpoint) {
+			status = http.StatusNotFound
+		}
+		c.JSON(status, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":  "resumed",
+		"message": "Autonomous network scan resumed from checkpoint",
+		"scan_id": req.ScanID,
+	})
+}
+
+// Scan status handler - the scan named by the scan_id query parameter, or
+// the most recently started scan
 func (s *Server) scanStatusHandler(c *gin.Context) {
-	running := s.scanner.IsRunning()
+	scan := s.scanner.ScanStatus()
+	if scanID := c.Query("scan_id"); scanID != "" {
+		var ok bool
+		if scan, ok = s.scanner.ScanStatusByID(scanID); !ok {
+			c.JSON(http.StatusNotFound, gin.H{
+				"error": "no such scan",
+			})
+			return
+		}
+	}
 	status := "idle"
-	if running {
+	if scan.Running {
 		status = "running"
 	}
 
+	resp := gin.H{
+		"status":       status,
+		"running":      scan.Running,
+		"active_scans": s.scanner.ActiveScanIDs(),
+	}
+	if next, ok := s.scanner.NextScheduledScan(); ok {
+		resp["next_scheduled_scan"] = next.UTC().Format(time.RFC3339)
+	}
+
+	if scan.ScanID != "" {
+		addScanProgress(resp, scan)
+	}
+
+	c.JSON(http.StatusOK, resp)
+}
+
+// addScanProgress adds a scan's progress fields to a status response or
+// stream frame.
+func addScanProgress(resp gin.H, scan scanner.ScanStatus) {
+	resp["scan_id"] = scan.ScanID
+	resp["phase"] = scan.Phase
+	resp["percent_complete"] = scan.PercentDone
+	resp["hosts_scanned"] = scan.ScannedHosts
+	resp["hosts_total"] = scan.TotalHosts
+	resp["open_ports_found"] = scan.OpenPorts
+	resp["elapsed_seconds"] = int64(scan.Elapsed.Seconds())
+	if scan.ETAAvailable {
+		resp["eta_seconds"] = int64(scan.ETA.Seconds())
+	}
+}
+
+// Scan stream handler - pushes a scan's progress as server-sent events:
+// "progress" frames as it changes, then a single "complete" frame
+func (s *Server) scanStreamHandler(c *gin.Context) {
+	updates, unsubscribe, err := s.scanner.SubscribeProgress(c.Param("scan_id"))
+	if err != nil {
+		status := http.StatusNotFound
+		if errors.Is(err, scanner.ErrTooManySubscribers) {
+			status = http.StatusServiceUnavailable
+		}
+		c.JSON(status, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+	defer unsubscribe()
+
+	// The stream lasts as long as the scan, well past the write timeout
+	if err := http.NewResponseController(c.Writer).SetWriteDeadline(time.Time{}); err != nil {
+		s.logger.Debugw("Failed to clear write deadline for progress stream", "error", err)
+	}
+	c.Header("Cache-Control", "no-cache")
+	c.Header("X-Accel-Buffering", "no")
+
+	c.Stream(func(io.Writer) bool {
+		select {
+		case update, ok := <-updates:
+			if !ok {
+				return false
+			}
+			frame := gin.H{}
+			addScanProgress(frame, update.Status)
+			if update.Final {
+				frame["status"] = update.Status.Phase
+				c.SSEvent("complete", frame)
+				return false
+			}
+			c.SSEvent("progress", frame)
+			return true
+		case <-c.Request.Context().Done():
+			return false
+		}
+	})
+}
+
+// Scan results handler - returns a snapshot of results published for a scan
+func (s *Server) scanResultsHandler(c *gin.Context) {
+	scanID := c.Query("scan_id")
+	if scanID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "scan_id query parameter required",
+		})
+		return
+	}
+
+	results, ok := s.scanner.Results(scanID)
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "no results for scan",
+		})
+		return
+	}
+
 	c.JSON(http.StatusOK, gin.H{
-		"status":  status,
-		"running": running,
+		"scan_id": scanID,
+		"results": results,
+		"count":   len(results),
+	})
+}
+
+// defaultResultsPageSize is the page size when a results request sets no limit.
+const defaultResultsPageSize = 100
+
+// Scan results page handler - a page of the results retained for a scan
+func (s *Server) scanResultsPageHandler(c *gin.Context) {
+	var query ResultsPageQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+	if query.Limit == 0 {
+		query.Limit = defaultResultsPageSize
+	}
+
+	scanID := c.Param("scan_id")
+	results, total, ok := s.scanner.ResultsPage(scanID, query.Offset, query.Limit)
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "no results for scan",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"scan_id": scanID,
+		"results": results,
+		"count":   len(results),
+		"total":   total,
+		"offset":  query.Offset,
+		"limit":   query.Limit,
+	})
+}
+
+// List scans handler - recent scans whose results are retained
+func (s *Server) listScansHandler(c *gin.Context) {
+	records := s.scanner.RecentScans()
+	scans := make([]gin.H, 0, len(records))
+	for _, record := range records {
+		scan := gin.H{
+			"scan_id":      record.ScanID,
+			"status":       record.Status,
+			"started_at":   record.StartedAt,
+			"result_count": record.ResultCount,
+		}
+		if !record.FinishedAt.IsZero() {
+			scan["finished_at"] = record.FinishedAt
+		}
+		if record.Truncated {
+			scan["truncated"] = true
+		}
+		scans = append(scans, scan)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"scans": scans,
+		"count": len(scans),
 	})
 }
 
@@ -185,7 +559,9 @@ func (s *Server) scanTargetHandler(c *gin.Context) {
 		return
 	}
 
-	results, err := s.scanner.ScanTarget(req.Target)
+	// Request-scoped so ad-hoc scans work while idle, even after a Stop has
+	// cancelled the scanner's base context
+	results, err := s.scanner.ScanTargetContext(c.Request.Context(), req.Target)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"error": err.Error(),
@@ -199,9 +575,3 @@ func (s *Server) scanTargetHandler(c *gin.Context) {
 		"count":   len(results),
 	})
 }
-
-// Metrics handler (placeholder for Prometheus metrics)
-func (s *Server) metricsHandler(c *gin.Context) {
-	// Placeholder - will be implemented with Prometheus client
-	c.String(http.StatusOK, "# Metrics placeholder\n")
-}
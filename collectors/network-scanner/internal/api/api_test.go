@@ -0,0 +1,602 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"github.com/aiforce-discovery-agent/collectors/network-scanner/internal/config"
+	"github.com/aiforce-discovery-agent/collectors/network-scanner/internal/publisher"
+	"github.com/aiforce-discovery-agent/collectors/network-scanner/internal/scanner"
+)
+
+func TestStartErrorStatus(t *testing.T) {
+	tests := []struct {
+		err  error
+		want int
+	}{
+		{err: scanner.ErrDraining, want: http.StatusServiceUnavailable},
+		{err: fmt.Errorf("resume: %w", scanner.ErrDraining), want: http.StatusServiceUnavailable},
+		{err: scanner.ErrTooManyScans, want: http.StatusConflict},
+		{err: scanner.ErrNoSigningKey, want: http.StatusBadRequest},
+		{err: errors.New("scanner already running"), want: http.StatusConflict},
+	}
+	for _, tt := range tests {
+		if got := startErrorStatus(tt.err); got != tt.want {
+			t.Errorf("startErrorStatus(%v) = %d, want %d", tt.err, got, tt.want)
+		}
+	}
+}
+
+func TestVersionHidesFeatures(t *testing.T) {
+	s := New(config.ServerConfig{APIKeys: []string{"key"}}, nil, zap.NewNop().Sugar())
+	s.SetBuildInfo(BuildInfo{Version: "1.4.0", Commit: "abc123", Features: map[string]bool{"sign_callbacks": true}})
+
+	tests := []struct {
+		name         string
+		path         string
+		key          string
+		wantStatus   int
+		wantFeatures bool
+	}{
+		{name: "public", path: "/version", wantStatus: http.StatusOK},
+		{name: "api without key", path: "/api/v1/version", wantStatus: http.StatusUnauthorized},
+		{name: "api", path: "/api/v1/version", key: "key", wantStatus: http.StatusOK, wantFeatures: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			if tt.key != "" {
+				req.Header.Set(apiKeyHeader, tt.key)
+			}
+			w := httptest.NewRecorder()
+			s.router.ServeHTTP(w, req)
+
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", w.Code, tt.wantStatus)
+			}
+			if w.Code != http.StatusOK {
+				return
+			}
+			var body map[string]interface{}
+			if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+				t.Fatalf("decoding %s: %v", w.Body, err)
+			}
+			if body["version"] != "1.4.0" || body["commit"] != "abc123" || body["go_version"] == nil {
+				t.Errorf("body = %v, want the build identity", body)
+			}
+			if _, ok := body["features"]; ok != tt.wantFeatures {
+				t.Errorf("features in body = %v, want %v", ok, tt.wantFeatures)
+			}
+		})
+	}
+}
+
+// brokerPublisher is a publisher whose broker connection is up or down.
+type brokerPublisher struct {
+	publisher.EventPublisher
+	connected bool
+}
+
+func (p brokerPublisher) IsConnected() bool { return p.connected }
+
+func TestReadiness(t *testing.T) {
+	tests := []struct {
+		name       string
+		connected  bool
+		path       string
+		wantStatus int
+	}{
+		{name: "ready", connected: true, path: "/ready", wantStatus: http.StatusOK},
+		{name: "broker down", path: "/ready", wantStatus: http.StatusServiceUnavailable},
+		{name: "liveness ignores the broker", path: "/health", wantStatus: http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			scan, err := scanner.New(config.ScannerConfig{}, brokerPublisher{publisher.Nop(), tt.connected}, zap.NewNop().Sugar())
+			if err != nil {
+				t.Fatalf("scanner.New() error = %v", err)
+			}
+			s := New(config.ServerConfig{}, scan, zap.NewNop().Sugar())
+
+			w := httptest.NewRecorder()
+			s.router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, tt.path, nil))
+
+			if w.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d: %s", w.Code, tt.wantStatus, w.Body)
+			}
+			if w.Code == http.StatusServiceUnavailable && !strings.Contains(w.Body.String(), "reason") {
+				t.Errorf("503 without a reason: %s", w.Body)
+			}
+		})
+	}
+}
+
+func TestStartScanSubnetLimit(t *testing.T) {
+	callbacks := httptest.NewServer(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}))
+	defer callbacks.Close()
+
+	tests := []struct {
+		name       string
+		subnets    []string
+		targets    []string
+		wantStatus int
+	}{
+		{name: "at the limit", subnets: []string{"192.0.2.0/30"}, targets: []string{"192.0.2.9"}, wantStatus: http.StatusOK},
+		{name: "subnets over the limit", subnets: []string{"192.0.2.0/30", "192.0.2.4/30", "192.0.2.8/30"}, wantStatus: http.StatusBadRequest},
+		{name: "subnets and targets over the limit", subnets: []string{"192.0.2.0/30", "192.0.2.4/30"}, targets: []string{"192.0.2.9"}, wantStatus: http.StatusBadRequest},
+		{name: "targets over the limit", targets: []string{"192.0.2.9", "192.0.2.10", "192.0.2.11"}, wantStatus: http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			scan, err := scanner.New(config.ScannerConfig{MaxSubnetsPerScan: 2, MaxConcurrentScans: 1, RateLimit: 100},
+				publisher.Nop(), zap.NewNop().Sugar())
+			if err != nil {
+				t.Fatalf("scanner.New() error = %v", err)
+			}
+			t.Cleanup(func() { _ = scan.Stop("") })
+			s := New(config.ServerConfig{}, scan, zap.NewNop().Sugar())
+
+			body, _ := json.Marshal(StartScanRequest{
+				ScanID:      "3b8e2f6a-1c4d-4a9e-8f7b-5d2c0e6a9b14",
+				Subnets:     tt.subnets,
+				Targets:     tt.targets,
+				PortRanges:  []string{"9"},
+				TimeoutMS:   10,
+				ProgressURL: callbacks.URL,
+				CompleteURL: callbacks.URL,
+			})
+			w := httptest.NewRecorder()
+			s.router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/api/v1/scan/start", bytes.NewReader(body)))
+
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.wantStatus, w.Body)
+			}
+			if w.Code == http.StatusBadRequest && !strings.Contains(w.Body.String(), "exceeds the limit of 2") {
+				t.Errorf("body = %s, want the limit named", w.Body)
+			}
+		})
+	}
+}
+
+func TestStartScanTargets(t *testing.T) {
+	callbacks := httptest.NewServer(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}))
+	defer callbacks.Close()
+
+	tests := []struct {
+		name       string
+		targets    []string
+		wantStatus int
+		wantError  string
+	}{
+		{name: "targets only", targets: []string{"192.0.2.9", "192.0.2.10:8443"}, wantStatus: http.StatusOK},
+		{name: "bad port", targets: []string{"192.0.2.10:70000"}, wantStatus: http.StatusBadRequest, wantError: "192.0.2.10:70000"},
+		{name: "range too large", targets: []string{"10.0.0.0-10.2.0.0"}, wantStatus: http.StatusBadRequest, wantError: "list it under subnets"},
+		{name: "nothing to scan", wantStatus: http.StatusBadRequest, wantError: "at least one subnet or target"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			scan, err := scanner.New(config.ScannerConfig{MaxConcurrentScans: 1, RateLimit: 100},
+				publisher.Nop(), zap.NewNop().Sugar())
+			if err != nil {
+				t.Fatalf("scanner.New() error = %v", err)
+			}
+			t.Cleanup(func() { _ = scan.Stop("") })
+			s := New(config.ServerConfig{}, scan, zap.NewNop().Sugar())
+
+			body, _ := json.Marshal(StartScanRequest{
+				ScanID:      "8c7b6a59-4d3e-4f2a-9b1c-0d9e8f7a6b5c",
+				Targets:     tt.targets,
+				PortRanges:  []string{"9"},
+				TimeoutMS:   10,
+				ProgressURL: callbacks.URL,
+				CompleteURL: callbacks.URL,
+			})
+			w := httptest.NewRecorder()
+			s.router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/api/v1/scan/start", bytes.NewReader(body)))
+
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.wantStatus, w.Body)
+			}
+			if !strings.Contains(w.Body.String(), tt.wantError) {
+				t.Errorf("body = %s, want %q", w.Body, tt.wantError)
+			}
+		})
+	}
+}
+
+func TestStartScanAutoScanID(t *testing.T) {
+	// Closed after the scan is stopped, so its callbacks don't retry
+	callbacks := httptest.NewServer(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}))
+	t.Cleanup(callbacks.Close)
+
+	scan, err := scanner.New(config.ScannerConfig{MaxConcurrentScans: 1, RateLimit: 1, Timeout: 10, AutoScanID: true},
+		publisher.Nop(), zap.NewNop().Sugar())
+	if err != nil {
+		t.Fatalf("scanner.New() error = %v", err)
+	}
+	t.Cleanup(func() { _ = scan.Stop("") })
+	s := New(config.ServerConfig{}, scan, zap.NewNop().Sugar())
+
+	body, _ := json.Marshal(StartScanRequest{
+		Subnets:     []string{"192.0.2.0/28"},
+		PortRanges:  []string{"9"},
+		ProgressURL: callbacks.URL,
+		CompleteURL: callbacks.URL,
+	})
+	w := httptest.NewRecorder()
+	s.router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/api/v1/scan/start", bytes.NewReader(body)))
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d: %s", w.Code, http.StatusOK, w.Body)
+	}
+
+	var resp struct {
+		ScanID string `json:"scan_id"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decoding %s: %v", w.Body, err)
+	}
+	if _, err := uuid.Parse(resp.ScanID); err != nil {
+		t.Fatalf("scan_id %q is not a UUID: %v", resp.ScanID, err)
+	}
+	// The scan runs under the generated ID
+	if status, ok := scan.ScanStatusByID(resp.ScanID); !ok || status.ScanID != resp.ScanID {
+		t.Errorf("ScanStatusByID(%s) = %+v, %v; want the started scan", resp.ScanID, status, ok)
+	}
+}
+
+func TestStartScanAutoTuning(t *testing.T) {
+	callbacks := httptest.NewServer(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}))
+	t.Cleanup(callbacks.Close)
+
+	scan, err := scanner.New(config.ScannerConfig{MaxConcurrentScans: 1, RateLimit: 1, Timeout: 10, AutoTuneMaxRatePPS: 1},
+		publisher.Nop(), zap.NewNop().Sugar())
+	if err != nil {
+		t.Fatalf("scanner.New() error = %v", err)
+	}
+	t.Cleanup(func() { _ = scan.Stop("") })
+	s := New(config.ServerConfig{}, scan, zap.NewNop().Sugar())
+
+	body, _ := json.Marshal(StartScanRequest{
+		ScanID:      "7e6d5c4b-3a29-4180-9f7e-6d5c4b3a2918",
+		Subnets:     []string{"192.0.2.0/28"},
+		PortRanges:  []string{"9"},
+		Tuning:      scanner.TuningAuto,
+		ProgressURL: callbacks.URL,
+		CompleteURL: callbacks.URL,
+	})
+	w := httptest.NewRecorder()
+	s.router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/api/v1/scan/start", bytes.NewReader(body)))
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d: %s", w.Code, http.StatusOK, w.Body)
+	}
+
+	var resp struct {
+		Tuning *scanner.ScanTuning `json:"tuning"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decoding %s: %v", w.Body, err)
+	}
+	want := scanner.ScanTuning{Hosts: 16, MaxConcurrentHosts: 16, RateLimitPPS: 1}
+	if resp.Tuning == nil || *resp.Tuning != want {
+		t.Errorf("tuning = %+v, want %+v", resp.Tuning, want)
+	}
+}
+
+func TestCancelErrorStatus(t *testing.T) {
+	tests := []struct {
+		err  error
+		want int
+	}{
+		{err: fmt.Errorf("%w %q", scanner.ErrUnknownCancelMode, "later"), want: http.StatusBadRequest},
+		{err: scanner.ErrScanIDRequired, want: http.StatusBadRequest},
+		{err: scanner.ErrNoScanRunning, want: http.StatusConflict},
+		{err: fmt.Errorf("%w: %s", scanner.ErrScanIDMismatch, "id"), want: http.StatusConflict},
+	}
+	for _, tt := range tests {
+		if got := cancelErrorStatus(tt.err); got != tt.want {
+			t.Errorf("cancelErrorStatus(%v) = %d, want %d", tt.err, got, tt.want)
+		}
+	}
+}
+
+func TestCancelScanHandler(t *testing.T) {
+	const (
+		first  = "0a6f3e2d-9c1b-4b7a-8e5d-3f2c1b0a9e8d"
+		second = "7d4c2b1a-0e9f-4c8d-b7a6-5e4d3c2b1a0f"
+		other  = "e1f2a3b4-c5d6-4e7f-8a9b-0c1d2e3f4a5b"
+	)
+	callbacks := httptest.NewServer(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}))
+	defer callbacks.Close()
+
+	tests := []struct {
+		name       string
+		running    []string
+		body       string
+		wantStatus int
+	}{
+		{name: "unknown mode", running: []string{first}, body: `{"mode":"later"}`, wantStatus: http.StatusBadRequest},
+		{name: "malformed scan ID", running: []string{first}, body: `{"scan_id":"first"}`, wantStatus: http.StatusBadRequest},
+		{name: "several scans without an ID", running: []string{first, second}, body: `{}`, wantStatus: http.StatusBadRequest},
+		{name: "no scan running", body: `{}`, wantStatus: http.StatusConflict},
+		{name: "other scan ID", running: []string{first}, body: `{"scan_id":"` + other + `"}`, wantStatus: http.StatusConflict},
+		{name: "immediate", running: []string{first}, body: `{}`, wantStatus: http.StatusAccepted},
+		{name: "graceful by ID", running: []string{first, second}, body: `{"scan_id":"` + second + `","mode":"graceful"}`, wantStatus: http.StatusAccepted},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// At 1 probe a second the scans are still running when cancelled
+			scan, err := scanner.New(config.ScannerConfig{MaxConcurrentScans: 2, RateLimit: 1, Timeout: 10},
+				publisher.Nop(), zap.NewNop().Sugar())
+			if err != nil {
+				t.Fatalf("scanner.New() error = %v", err)
+			}
+			t.Cleanup(func() { _ = scan.Stop("") })
+			for _, id := range tt.running {
+				if err := scan.StartAutonomous(scanner.AutonomousScanConfig{
+					ScanID:      id,
+					Subnets:     []string{"192.0.2.0/24"},
+					PortRanges:  []string{"9"},
+					ProgressURL: callbacks.URL,
+					CompleteURL: callbacks.URL,
+				}); err != nil {
+					t.Fatalf("StartAutonomous() error = %v", err)
+				}
+			}
+			s := New(config.ServerConfig{}, scan, zap.NewNop().Sugar())
+
+			w := httptest.NewRecorder()
+			s.router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/api/v1/scan/cancel", strings.NewReader(tt.body)))
+
+			if w.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d: %s", w.Code, tt.wantStatus, w.Body)
+			}
+		})
+	}
+}
+
+func TestStopScanHandler(t *testing.T) {
+	const (
+		first  = "3b2a1908-7f6e-4d5c-8b4a-29180f7e6d5c"
+		second = "c5d4e3f2-a1b0-4c9d-8e7f-6a5b4c3d2e1f"
+		other  = "8e7f6a5b-4c3d-4e2f-9a0b-1c2d3e4f5a6b"
+	)
+	callbacks := httptest.NewServer(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}))
+	defer callbacks.Close()
+
+	tests := []struct {
+		name        string
+		running     []string
+		body        string
+		wantStatus  int
+		wantStopped []string
+	}{
+		{name: "no scan running", wantStatus: http.StatusNotFound},
+		{name: "malformed scan ID", running: []string{first}, body: `{"scan_id":"first"}`, wantStatus: http.StatusBadRequest},
+		{name: "other scan ID", running: []string{first}, body: `{"scan_id":"` + other + `"}`, wantStatus: http.StatusConflict},
+		{name: "by ID", running: []string{first, second}, body: `{"scan_id":"` + second + `"}`, wantStatus: http.StatusOK, wantStopped: []string{second}},
+		{name: "without a body", running: []string{first, second}, wantStatus: http.StatusOK, wantStopped: []string{first, second}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// At 1 probe a second the scans are still running when stopped
+			scan, err := scanner.New(config.ScannerConfig{MaxConcurrentScans: 2, RateLimit: 1, Timeout: 10},
+				publisher.Nop(), zap.NewNop().Sugar())
+			if err != nil {
+				t.Fatalf("scanner.New() error = %v", err)
+			}
+			t.Cleanup(func() { _ = scan.Stop("") })
+			for _, id := range tt.running {
+				if err := scan.StartAutonomous(scanner.AutonomousScanConfig{
+					ScanID:      id,
+					Subnets:     []string{"192.0.2.0/24"},
+					PortRanges:  []string{"9"},
+					ProgressURL: callbacks.URL,
+					CompleteURL: callbacks.URL,
+				}); err != nil {
+					t.Fatalf("StartAutonomous() error = %v", err)
+				}
+			}
+			s := New(config.ServerConfig{}, scan, zap.NewNop().Sugar())
+
+			w := httptest.NewRecorder()
+			s.router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/api/v1/scan/stop", strings.NewReader(tt.body)))
+
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.wantStatus, w.Body)
+			}
+			// Only the named scan stops; a stale or mismatched ID leaves the
+			// running scan alone
+			stopped := make(map[string]bool)
+			for _, id := range tt.wantStopped {
+				stopped[id] = true
+			}
+			for _, id := range tt.running {
+				deadline := time.Now().Add(5 * time.Second)
+				for stopped[id] && scan.IsRunning(id) && time.Now().Before(deadline) {
+					time.Sleep(time.Millisecond)
+				}
+				if running := scan.IsRunning(id); running == stopped[id] {
+					t.Errorf("scan %s running = %v, want %v", id, running, !stopped[id])
+				}
+			}
+		})
+	}
+}
+
+func TestScanStatusHandler(t *testing.T) {
+	const scanID = "2b3c4d5e-6f7a-4b8c-9d0e-1f2a3b4c5d6e"
+	callbacks := httptest.NewServer(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}))
+	defer callbacks.Close()
+
+	scan, err := scanner.New(config.ScannerConfig{MaxConcurrentScans: 1, RateLimit: 1, Timeout: 10},
+		publisher.Nop(), zap.NewNop().Sugar())
+	if err != nil {
+		t.Fatalf("scanner.New() error = %v", err)
+	}
+	t.Cleanup(func() { _ = scan.Stop("") })
+	s := New(config.ServerConfig{}, scan, zap.NewNop().Sugar())
+
+	get := func(path string) (int, map[string]interface{}) {
+		w := httptest.NewRecorder()
+		s.router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		var body map[string]interface{}
+		if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+			t.Fatalf("decoding %s: %v", w.Body, err)
+		}
+		return w.Code, body
+	}
+
+	if code, body := get("/api/v1/scan/status"); code != http.StatusOK || body["status"] != "idle" || body["scan_id"] != nil {
+		t.Errorf("before a scan: %d %v, want idle without progress", code, body)
+	}
+
+	// At 1 probe a second the scan is still running when queried
+	if err := scan.StartAutonomous(scanner.AutonomousScanConfig{
+		ScanID:      scanID,
+		Subnets:     []string{"192.0.2.0/24"},
+		PortRanges:  []string{"9"},
+		ProgressURL: callbacks.URL,
+		CompleteURL: callbacks.URL,
+	}); err != nil {
+		t.Fatalf("StartAutonomous() error = %v", err)
+	}
+
+	// The scan counts its hosts once it is under way
+	for deadline := time.Now().Add(5 * time.Second); ; time.Sleep(5 * time.Millisecond) {
+		if _, body := get("/api/v1/scan/status"); body["hosts_total"] == 256.0 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("hosts_total never reached 256")
+		}
+	}
+
+	for _, path := range []string{"/api/v1/scan/status", "/api/v1/scan/status?scan_id=" + scanID} {
+		code, body := get(path)
+		if code != http.StatusOK || body["status"] != "running" || body["scan_id"] != scanID {
+			t.Fatalf("%s: %d %v, want scan %s running", path, code, body, scanID)
+		}
+		for _, key := range []string{"percent_complete", "hosts_scanned", "elapsed_seconds"} {
+			if _, ok := body[key]; !ok {
+				t.Errorf("%s: no %s in %v", path, key, body)
+			}
+		}
+		// Estimated once 5% of the hosts are in
+		scanned, _ := body["hosts_scanned"].(float64)
+		if _, ok := body["eta_seconds"]; ok != (scanned >= 0.05*256) {
+			t.Errorf("%s: eta_seconds reported = %v after %v hosts", path, ok, scanned)
+		}
+	}
+
+	if code, _ := get("/api/v1/scan/status?scan_id=e1f2a3b4-c5d6-4e7f-8a9b-0c1d2e3f4a5b"); code != http.StatusNotFound {
+		t.Errorf("unknown scan: status = %d, want 404", code)
+	}
+}
+
+// metricValue returns the value of an unlabelled sample in a scrape.
+func metricValue(t *testing.T, s *Server, name string) float64 {
+	t.Helper()
+	w := httptest.NewRecorder()
+	s.router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("GET /metrics status = %d", w.Code)
+	}
+	for _, line := range strings.Split(w.Body.String(), "\n") {
+		if value, ok := strings.CutPrefix(line, name+" "); ok {
+			v, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				t.Fatalf("sample %q: %v", line, err)
+			}
+			return v
+		}
+	}
+	t.Fatalf("metric %s not exported", name)
+	return 0
+}
+
+func TestMetricsEndpoint(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = ln.Close() }()
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			_ = conn.Close()
+		}
+	}()
+
+	completed := make(chan struct{})
+	var once sync.Once
+	callbacks := httptest.NewServer(http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/complete" {
+			once.Do(func() { close(completed) })
+		}
+	}))
+	defer callbacks.Close()
+
+	scan, err := scanner.New(config.ScannerConfig{MaxConcurrentScans: 1, RateLimit: 100000},
+		publisher.Nop(), zap.NewNop().Sugar())
+	if err != nil {
+		t.Fatalf("scanner.New() error = %v", err)
+	}
+	t.Cleanup(func() { _ = scan.Stop("") })
+	s := New(config.ServerConfig{}, scan, zap.NewNop().Sugar())
+
+	// Scans stopped by earlier tests finish in the background
+	for deadline := time.Now().Add(5 * time.Second); metricValue(t, s, "scanner_active_scans") != 0; time.Sleep(5 * time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatal("scans from earlier tests still active")
+		}
+	}
+
+	names := []string{"scanner_ports_scanned_total", "scanner_open_ports_total", "scanner_scan_duration_seconds_count"}
+	before := make(map[string]float64)
+	for _, name := range names {
+		before[name] = metricValue(t, s, name)
+	}
+
+	body, _ := json.Marshal(StartScanRequest{
+		ScanID:      "5b4a3c2d-1e0f-4a9b-8c7d-6e5f4a3b2c1d",
+		Targets:     []string{ln.Addr().String()},
+		TimeoutMS:   1000,
+		ProgressURL: callbacks.URL + "/progress",
+		CompleteURL: callbacks.URL + "/complete",
+	})
+	w := httptest.NewRecorder()
+	s.router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/api/v1/scan/start", bytes.NewReader(body)))
+	if w.Code != http.StatusOK {
+		t.Fatalf("start status = %d: %s", w.Code, w.Body)
+	}
+	select {
+	case <-completed:
+	case <-time.After(10 * time.Second):
+		t.Fatal("scan did not complete")
+	}
+
+	for _, name := range names {
+		if got := metricValue(t, s, name); got <= before[name] {
+			t.Errorf("%s = %v after the scan, want more than %v", name, got, before[name])
+		}
+	}
+	if got := metricValue(t, s, "scanner_active_scans"); got != 0 {
+		t.Errorf("scanner_active_scans = %v after the scan, want 0", got)
+	}
+}
@@ -0,0 +1,57 @@
+package api
+
+import (
+	"crypto/subtle"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// apiKeyHeader carries the internal API key on control requests. The same
+// key is passed on to autonomous scan callbacks.
+const apiKeyHeader = "X-Internal-API-Key"
+
+// apiKeyMiddleware rejects requests without a valid API key. Several keys
+// may be valid at once so they can be rotated without downtime. With no
+// keys configured every request is let through.
+func (s *Server) apiKeyMiddleware() gin.HandlerFunc {
+	keys := s.apiKeys()
+	if len(keys) == 0 {
+		s.logger.Warn("No API keys configured; scanner control endpoints are unauthenticated")
+		return func(c *gin.Context) { c.Next() }
+	}
+
+	return func(c *gin.Context) {
+		if !validAPIKey(keys, []byte(c.GetHeader(apiKeyHeader))) {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{
+				"error": "missing or invalid API key",
+			})
+			return
+		}
+		c.Next()
+	}
+}
+
+// apiKeys returns the configured non-empty API keys.
+func (s *Server) apiKeys() [][]byte {
+	var keys [][]byte
+	for _, key := range s.config.APIKeys {
+		if key != "" {
+			keys = append(keys, []byte(key))
+		}
+	}
+	return keys
+}
+
+// validAPIKey compares got against every key in constant time, so timing
+// reveals neither the key nor which one matched.
+func validAPIKey(keys [][]byte, got []byte) bool {
+	if len(got) == 0 {
+		return false
+	}
+	valid := 0
+	for _, key := range keys {
+		valid |= subtle.ConstantTimeCompare(key, got)
+	}
+	return valid == 1
+}
@@ -0,0 +1,43 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/aiforce-discovery-agent/collectors/network-scanner/internal/config"
+)
+
+func TestAPIKeyMiddleware(t *testing.T) {
+	tests := []struct {
+		name string
+		keys []string
+		path string
+		key  string
+		want int
+	}{
+		{name: "accepted", keys: []string{"current"}, path: "/api/v1/test", key: "current", want: http.StatusOK},
+		{name: "rotated key accepted", keys: []string{"current", "previous"}, path: "/api/v1/test", key: "previous", want: http.StatusOK},
+		{name: "rejected", keys: []string{"current"}, path: "/api/v1/test", key: "guess", want: http.StatusUnauthorized},
+		{name: "missing", keys: []string{"current"}, path: "/api/v1/test", want: http.StatusUnauthorized},
+		{name: "empty configured key never matches", keys: []string{"", "current"}, path: "/api/v1/test", want: http.StatusUnauthorized},
+		{name: "no keys configured", path: "/api/v1/test", want: http.StatusOK},
+		{name: "health stays open", keys: []string{"current"}, path: "/health", want: http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newRateLimitedServer(config.ServerConfig{APIKeys: tt.keys})
+
+			req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			if tt.key != "" {
+				req.Header.Set(apiKeyHeader, tt.key)
+			}
+			w := httptest.NewRecorder()
+			s.router.ServeHTTP(w, req)
+
+			if w.Code != tt.want {
+				t.Errorf("status = %d, want %d", w.Code, tt.want)
+			}
+		})
+	}
+}
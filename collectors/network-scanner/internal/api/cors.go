@@ -0,0 +1,85 @@
+package api
+
+import (
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// corsMiddleware answers CORS preflights and tags responses to allowed
+// cross-origin requests. Requests without an Origin, or from the API's own
+// origin, pass untouched; other origins are rejected with 403 unless listed
+// in CORSAllowedOrigins ("*" allows any). With no origins configured the
+// API is same-origin only.
+func (s *Server) corsMiddleware() gin.HandlerFunc {
+	origins := make(map[string]bool, len(s.config.CORSAllowedOrigins))
+	anyOrigin := false
+	for _, origin := range s.config.CORSAllowedOrigins {
+		if origin == "*" {
+			anyOrigin = true
+		}
+		origins[strings.ToLower(strings.TrimSuffix(origin, "/"))] = true
+	}
+	methods := make(map[string]bool, len(s.config.CORSAllowedMethods))
+	for _, method := range s.config.CORSAllowedMethods {
+		methods[strings.ToUpper(method)] = true
+	}
+	allowMethods := strings.Join(s.config.CORSAllowedMethods, ", ")
+	allowHeaders := strings.Join(s.config.CORSAllowedHeaders, ", ")
+	maxAge := strconv.Itoa(s.config.CORSMaxAgeSeconds)
+
+	return func(c *gin.Context) {
+		origin := c.GetHeader("Origin")
+		if origin == "" || sameOrigin(origin, c.Request) {
+			c.Next()
+			return
+		}
+
+		c.Header("Vary", "Origin")
+		if !anyOrigin && !origins[strings.ToLower(origin)] {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{
+				"error": "origin not allowed",
+			})
+			return
+		}
+
+		allowOrigin := origin
+		if anyOrigin {
+			allowOrigin = "*"
+		}
+		c.Header("Access-Control-Allow-Origin", allowOrigin)
+
+		requestMethod := c.GetHeader("Access-Control-Request-Method")
+		if c.Request.Method != http.MethodOptions || requestMethod == "" {
+			c.Next()
+			return
+		}
+
+		// Preflight
+		if !methods[strings.ToUpper(requestMethod)] {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{
+				"error": "method not allowed by CORS policy",
+			})
+			return
+		}
+		c.Header("Access-Control-Allow-Methods", allowMethods)
+		c.Header("Access-Control-Allow-Headers", allowHeaders)
+		if s.config.CORSMaxAgeSeconds > 0 {
+			c.Header("Access-Control-Max-Age", maxAge)
+		}
+		c.AbortWithStatus(http.StatusNoContent)
+	}
+}
+
+// sameOrigin reports whether origin names the host the request was sent
+// to, as browsers also send Origin on same-origin POSTs.
+func sameOrigin(origin string, r *http.Request) bool {
+	u, err := url.Parse(origin)
+	if err != nil || u.Host == "" {
+		return false
+	}
+	return strings.EqualFold(u.Host, r.Host)
+}
@@ -0,0 +1,166 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"go.uber.org/zap"
+
+	"github.com/aiforce-discovery-agent/collectors/network-scanner/internal/config"
+)
+
+func TestCORS(t *testing.T) {
+	allowed := config.ServerConfig{
+		CORSAllowedOrigins: []string{"https://console.example.com/"},
+		CORSAllowedMethods: []string{"GET", "POST"},
+		CORSAllowedHeaders: []string{"Content-Type", apiKeyHeader},
+		CORSMaxAgeSeconds:  600,
+	}
+	anyOrigin := allowed
+	anyOrigin.CORSAllowedOrigins = []string{"*"}
+
+	tests := []struct {
+		name        string
+		cfg         config.ServerConfig
+		method      string
+		path        string
+		header      map[string]string
+		wantStatus  int
+		wantOrigin  string
+		wantMethods string
+		wantHeaders string
+		wantMaxAge  string
+		wantVary    bool
+	}{
+		{
+			name:       "no origin",
+			cfg:        allowed,
+			method:     http.MethodGet,
+			path:       "/health",
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "same origin",
+			cfg:        config.ServerConfig{},
+			method:     http.MethodGet,
+			path:       "/health",
+			header:     map[string]string{"Origin": "http://example.com"},
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "allowed origin",
+			cfg:        allowed,
+			method:     http.MethodGet,
+			path:       "/health",
+			header:     map[string]string{"Origin": "https://Console.example.com"},
+			wantStatus: http.StatusOK,
+			wantOrigin: "https://Console.example.com",
+			wantVary:   true,
+		},
+		{
+			name:       "disallowed origin",
+			cfg:        allowed,
+			method:     http.MethodGet,
+			path:       "/health",
+			header:     map[string]string{"Origin": "https://evil.example.net"},
+			wantStatus: http.StatusForbidden,
+			wantVary:   true,
+		},
+		{
+			name:       "no origins configured",
+			cfg:        config.ServerConfig{},
+			method:     http.MethodGet,
+			path:       "/health",
+			header:     map[string]string{"Origin": "https://console.example.com"},
+			wantStatus: http.StatusForbidden,
+			wantVary:   true,
+		},
+		{
+			name:   "preflight",
+			cfg:    allowed,
+			method: http.MethodOptions,
+			path:   "/api/v1/scan/start",
+			header: map[string]string{
+				"Origin":                         "https://console.example.com",
+				"Access-Control-Request-Method":  "POST",
+				"Access-Control-Request-Headers": apiKeyHeader,
+			},
+			wantStatus:  http.StatusNoContent,
+			wantOrigin:  "https://console.example.com",
+			wantMethods: "GET, POST",
+			wantHeaders: "Content-Type, " + apiKeyHeader,
+			wantMaxAge:  "600",
+			wantVary:    true,
+		},
+		{
+			name:   "preflight for a method not allowed",
+			cfg:    allowed,
+			method: http.MethodOptions,
+			path:   "/api/v1/scan/start",
+			header: map[string]string{
+				"Origin":                        "https://console.example.com",
+				"Access-Control-Request-Method": "DELETE",
+			},
+			wantStatus: http.StatusForbidden,
+			wantOrigin: "https://console.example.com",
+			wantVary:   true,
+		},
+		{
+			name:   "preflight from a disallowed origin",
+			cfg:    allowed,
+			method: http.MethodOptions,
+			path:   "/api/v1/scan/start",
+			header: map[string]string{
+				"Origin":                        "https://evil.example.net",
+				"Access-Control-Request-Method": "POST",
+			},
+			wantStatus: http.StatusForbidden,
+			wantVary:   true,
+		},
+		{
+			name:   "any origin",
+			cfg:    anyOrigin,
+			method: http.MethodOptions,
+			path:   "/api/v1/scan/start",
+			header: map[string]string{
+				"Origin":                        "https://anywhere.example.org",
+				"Access-Control-Request-Method": "GET",
+			},
+			wantStatus:  http.StatusNoContent,
+			wantOrigin:  "*",
+			wantMethods: "GET, POST",
+			wantHeaders: "Content-Type, " + apiKeyHeader,
+			wantMaxAge:  "600",
+			wantVary:    true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := New(tt.cfg, nil, zap.NewNop().Sugar())
+			req := httptest.NewRequest(tt.method, tt.path, nil)
+			for k, v := range tt.header {
+				req.Header.Set(k, v)
+			}
+			w := httptest.NewRecorder()
+			s.router.ServeHTTP(w, req)
+
+			if w.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", w.Code, tt.wantStatus)
+			}
+			for header, want := range map[string]string{
+				"Access-Control-Allow-Origin":  tt.wantOrigin,
+				"Access-Control-Allow-Methods": tt.wantMethods,
+				"Access-Control-Allow-Headers": tt.wantHeaders,
+				"Access-Control-Max-Age":       tt.wantMaxAge,
+			} {
+				if got := w.Header().Get(header); got != want {
+					t.Errorf("%s = %q, want %q", header, got, want)
+				}
+			}
+			if got := w.Header().Get("Vary") == "Origin"; got != tt.wantVary {
+				t.Errorf("Vary: Origin set = %v, want %v", got, tt.wantVary)
+			}
+		})
+	}
+}
@@ -4,15 +4,19 @@ package api
 // StartScanRequest represents the request body for starting an autonomous scan.
 // Reference: ADR-007 Discovery Acquisition Model
 type StartScanRequest struct {
-	ScanID             string   `json:"scan_id" binding:"required,uuid"`
-	Subnets            []string `json:"subnets" binding:"required,min=1"`
+	ScanID             string   `json:"scan_id" binding:"omitempty,uuid"` // generated when omitted and auto_scan_id is set
+	Subnets            []string `json:"subnets"`
+	Targets            []string `json:"targets"`
 	PortRanges         []string `json:"port_ranges"`
 	RateLimitPPS       int      `json:"rate_limit_pps"`
 	TimeoutMS          int      `json:"timeout_ms"`
 	MaxConcurrentHosts int      `json:"max_concurrent_hosts" binding:"omitempty,gte=1"`
 	DeadHostThreshold  int      `json:"dead_host_threshold" binding:"omitempty,gte=1"`
+	Tuning             string   `json:"tuning" binding:"omitempty,oneof=auto"` // auto derives unset concurrency/rate from scan size
 	ProgressURL        string   `json:"progress_url" binding:"required,url"`
 	CompleteURL        string   `json:"complete_url" binding:"required,url"`
+	HeartbeatURL       string   `json:"heartbeat_url" binding:"omitempty,url"`          // liveness beats every heartbeat_interval_seconds
+	ScanTimeoutSeconds int      `json:"scan_timeout_seconds" binding:"omitempty,gte=0"` // wall-clock cap; 0 = none
 }
 
 // StopScanRequest represents the request body for stopping a scan.
@@ -20,6 +24,23 @@ type StopScanRequest struct {
 	ScanID string `json:"scan_id" binding:"required,uuid"`
 }
 
+// ResumeScanRequest represents the request body for resuming a checkpointed scan.
+type ResumeScanRequest struct {
+	ScanID string `json:"scan_id" binding:"required"`
+}
+
+// ResultsPageQuery represents the pagination query of the scan results endpoint.
+type ResultsPageQuery struct {
+	Offset int `form:"offset" binding:"omitempty,gte=0"`
+	Limit  int `form:"limit" binding:"omitempty,gte=1,lte=1000"` // defaults to 100
+}
+
+// CancelScanRequest represents the request body for cancelling a scan.
+type CancelScanRequest struct {
+	ScanID string `json:"scan_id" binding:"omitempty,uuid"`
+	Mode   string `json:"mode" binding:"omitempty,oneof=immediate graceful"`
+}
+
 // ScanProgress represents progress data sent to the callback URL.
 type ScanProgress struct {
 	ScanID         string `json:"scan_id"`
@@ -41,3 +62,12 @@ type ScanComplete struct {
 	ErrorMessage   string `json:"error_message,omitempty"`
 	Timestamp      string `json:"timestamp"`
 }
+
+// BuildInfo identifies the running scanner build and the optional features
+// its configuration enables.
+type BuildInfo struct {
+	Version   string          `json:"version"`
+	Commit    string          `json:"commit"`
+	GoVersion string          `json:"go_version"`
+	Features  map[string]bool `json:"features,omitempty"` // /api/v1/version only
+}
@@ -0,0 +1,109 @@
+package api
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"golang.org/x/time/rate"
+)
+
+// clientLimiterIdle is how long a client's limiter may go unused before it
+// is dropped; maxClientLimiters caps how many clients are tracked at once.
+const (
+	clientLimiterIdle = 10 * time.Minute
+	maxClientLimiters = 10000
+)
+
+// clientLimiters hands out a token bucket per client, created on demand and
+// dropped once idle. Once maxClientLimiters clients are tracked, further
+// clients share one overflow bucket until idle ones are swept.
+type clientLimiters struct {
+	mu        sync.Mutex
+	rps       rate.Limit
+	burst     int
+	limiters  map[string]*clientLimiter
+	overflow  *rate.Limiter
+	lastSweep time.Time
+}
+
+func newClientLimiters(rps rate.Limit, burst int) *clientLimiters {
+	return &clientLimiters{
+		rps:       rps,
+		burst:     burst,
+		limiters:  make(map[string]*clientLimiter),
+		overflow:  rate.NewLimiter(rps, burst),
+		lastSweep: time.Now(),
+	}
+}
+
+type clientLimiter struct {
+	limiter  *rate.Limiter
+	lastUsed time.Time
+}
+
+// get returns the limiter for key, sweeping idle ones at most once per
+// clientLimiterIdle.
+func (l *clientLimiters) get(key string) *rate.Limiter {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	if now.Sub(l.lastSweep) >= clientLimiterIdle {
+		for k, limiter := range l.limiters {
+			if now.Sub(limiter.lastUsed) >= clientLimiterIdle {
+				delete(l.limiters, k)
+			}
+		}
+		l.lastSweep = now
+	}
+
+	limiter, ok := l.limiters[key]
+	if !ok {
+		if len(l.limiters) >= maxClientLimiters {
+			return l.overflow
+		}
+		limiter = &clientLimiter{limiter: rate.NewLimiter(l.rps, l.burst)}
+		l.limiters[key] = limiter
+	}
+	limiter.lastUsed = now
+	return limiter.limiter
+}
+
+// rateLimitMiddleware throttles /api/v1 requests per client to
+// RateLimitRPS with bursts of RateLimitBurst, answering 429 with
+// Retry-After once a client's bucket is empty. It runs before
+// apiKeyMiddleware so invalid-key storms and key guessing are throttled
+// too. Requests with a valid API key are told apart by key, as callers
+// behind one proxy share an address; all others by client IP, which only
+// honours X-Forwarded-For from TrustedProxies. A non-positive RateLimitRPS
+// disables it.
+func (s *Server) rateLimitMiddleware() gin.HandlerFunc {
+	if s.config.RateLimitRPS <= 0 {
+		return func(c *gin.Context) { c.Next() }
+	}
+
+	limiters := newClientLimiters(rate.Limit(s.config.RateLimitRPS), max(s.config.RateLimitBurst, 1))
+	keys := s.apiKeys()
+
+	return func(c *gin.Context) {
+		key := "ip:" + c.ClientIP()
+		if apiKey := c.GetHeader(apiKeyHeader); len(keys) > 0 && validAPIKey(keys, []byte(apiKey)) {
+			key = "key:" + apiKey
+		}
+
+		reservation := limiters.get(key).Reserve()
+		if delay := reservation.Delay(); delay > 0 {
+			reservation.Cancel()
+			c.Header("Retry-After", strconv.Itoa(int(math.Ceil(delay.Seconds()))))
+			c.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{
+				"error": "rate limit exceeded",
+			})
+			return
+		}
+		c.Next()
+	}
+}
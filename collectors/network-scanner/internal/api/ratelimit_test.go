@@ -0,0 +1,143 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+
+	"github.com/aiforce-discovery-agent/collectors/network-scanner/internal/config"
+)
+
+// newRateLimitedServer returns a Server whose router has New's trusted
+// proxy setup and /api/v1 middleware, with a stub handler behind them.
+func newRateLimitedServer(cfg config.ServerConfig) *Server {
+	s := New(cfg, nil, zap.NewNop().Sugar())
+	s.router.GET("/api/v1/test", append(s.v1Middleware(), func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})...)
+	return s
+}
+
+func doRequest(s *Server, remoteAddr string, header map[string]string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/test", nil)
+	req.RemoteAddr = remoteAddr
+	for k, v := range header {
+		req.Header.Set(k, v)
+	}
+	w := httptest.NewRecorder()
+	s.router.ServeHTTP(w, req)
+	return w
+}
+
+func TestRateLimitBurstThenRecovery(t *testing.T) {
+	s := newRateLimitedServer(config.ServerConfig{RateLimitRPS: 20, RateLimitBurst: 3})
+
+	for i := 0; i < 3; i++ {
+		if w := doRequest(s, "192.0.2.1:1000", nil); w.Code != http.StatusOK {
+			t.Fatalf("request %d: status = %d, want 200", i, w.Code)
+		}
+	}
+
+	w := doRequest(s, "192.0.2.1:1000", nil)
+	if w.Code != http.StatusTooManyRequests {
+		t.Fatalf("status after burst = %d, want 429", w.Code)
+	}
+	if got := w.Header().Get("Retry-After"); got != "1" {
+		t.Errorf("Retry-After = %q, want 1", got)
+	}
+
+	// Another client has its own bucket
+	if w := doRequest(s, "192.0.2.2:1000", nil); w.Code != http.StatusOK {
+		t.Errorf("other client status = %d, want 200", w.Code)
+	}
+
+	time.Sleep(100 * time.Millisecond)
+	if w := doRequest(s, "192.0.2.1:1000", nil); w.Code != http.StatusOK {
+		t.Errorf("status after refill = %d, want 200", w.Code)
+	}
+}
+
+func TestRateLimitClientKey(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     config.ServerConfig
+		header  func(i int) map[string]string
+		limited bool
+	}{
+		{
+			name: "rotating X-Forwarded-For from an untrusted peer",
+			cfg:  config.ServerConfig{RateLimitRPS: 1, RateLimitBurst: 2},
+			header: func(i int) map[string]string {
+				return map[string]string{"X-Forwarded-For": fmt.Sprintf("198.51.100.%d", i)}
+			},
+			limited: true,
+		},
+		{
+			name: "X-Forwarded-For from a trusted proxy",
+			cfg:  config.ServerConfig{RateLimitRPS: 1, RateLimitBurst: 2, TrustedProxies: []string{"192.0.2.0/24"}},
+			header: func(i int) map[string]string {
+				return map[string]string{"X-Forwarded-For": fmt.Sprintf("198.51.100.%d", i)}
+			},
+			limited: false,
+		},
+		{
+			name: "rotating invalid API keys",
+			cfg:  config.ServerConfig{RateLimitRPS: 1, RateLimitBurst: 2, APIKeys: []string{"good"}},
+			header: func(i int) map[string]string {
+				return map[string]string{apiKeyHeader: fmt.Sprintf("guess-%d", i)}
+			},
+			limited: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newRateLimitedServer(tt.cfg)
+
+			limited := false
+			for i := 0; i < 5; i++ {
+				if doRequest(s, "192.0.2.1:1000", tt.header(i)).Code == http.StatusTooManyRequests {
+					limited = true
+				}
+			}
+			if limited != tt.limited {
+				t.Errorf("limited = %v, want %v", limited, tt.limited)
+			}
+		})
+	}
+}
+
+func TestRateLimitRunsBeforeAuth(t *testing.T) {
+	s := newRateLimitedServer(config.ServerConfig{RateLimitRPS: 1, RateLimitBurst: 1, APIKeys: []string{"good"}})
+
+	header := map[string]string{apiKeyHeader: "bad"}
+	if w := doRequest(s, "192.0.2.1:1000", header); w.Code != http.StatusUnauthorized {
+		t.Fatalf("first bad key status = %d, want 401", w.Code)
+	}
+	if w := doRequest(s, "192.0.2.1:1000", header); w.Code != http.StatusTooManyRequests {
+		t.Errorf("second bad key status = %d, want 429", w.Code)
+	}
+
+	// A valid key has its own bucket, apart from the address's
+	if w := doRequest(s, "192.0.2.1:1000", map[string]string{apiKeyHeader: "good"}); w.Code != http.StatusOK {
+		t.Errorf("valid key status = %d, want 200", w.Code)
+	}
+}
+
+func TestClientLimitersCapped(t *testing.T) {
+	l := newClientLimiters(1, 1)
+	for i := 0; i < maxClientLimiters+10; i++ {
+		l.get(fmt.Sprintf("ip:%d", i))
+	}
+	if got := len(l.limiters); got != maxClientLimiters {
+		t.Errorf("tracked clients = %d, want %d", got, maxClientLimiters)
+	}
+	if l.get("ip:new") != l.overflow {
+		t.Error("client past the cap didn't get the overflow limiter")
+	}
+}
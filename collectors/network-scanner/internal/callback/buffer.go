@@ -0,0 +1,193 @@
+package callback
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+)
+
+// Replay buffer limits. Progress updates beyond the limit are dropped,
+// oldest first; completions are never dropped for space. Any callback is
+// dropped once it has failed callbackMaxReplays replays or been buffered
+// for callbackMaxAge, so a dead receiver can't hold the buffer forever.
+const (
+	callbackBufferSize    = 100
+	callbackFlushInterval = 5 * time.Second
+	callbackMaxReplays    = 10
+	callbackMaxAge        = 10 * time.Minute
+)
+
+// bufferedCallback is an encoded callback that failed delivery.
+type bufferedCallback struct {
+	url      string
+	body     []byte
+	sequence int
+	phase    string
+	complete bool
+
+	queuedAt time.Time
+	replays  int
+}
+
+func (b bufferedCallback) sameAs(other bufferedCallback) bool {
+	return b.complete == other.complete && b.sequence == other.sequence
+}
+
+// Dropped returns how many callbacks were discarded: progress updates
+// evicted from a full replay buffer, and callbacks the receiver rejected
+// with a 4xx or that ran out of replays.
+func (r *Reporter) Dropped() int64 {
+	return atomic.LoadInt64(&r.dropped)
+}
+
+// enqueueIfBuffering queues cb behind earlier undelivered callbacks and
+// reports whether it did; with an empty buffer the caller sends directly.
+func (r *Reporter) enqueueIfBuffering(cb bufferedCallback) bool {
+	r.bufferMu.Lock()
+	buffering := len(r.buffered) > 0
+	r.bufferMu.Unlock()
+
+	if buffering {
+		r.enqueue(cb)
+	}
+	return buffering
+}
+
+// enqueue buffers cb for replay and starts the background flusher. A newer
+// progress update replaces a buffered one for the same phase, since only
+// the latest matters to the UI.
+func (r *Reporter) enqueue(cb bufferedCallback) {
+	r.bufferMu.Lock()
+	defer r.bufferMu.Unlock()
+
+	if r.closed {
+		atomic.AddInt64(&r.dropped, 1)
+		return
+	}
+	if cb.queuedAt.IsZero() {
+		cb.queuedAt = time.Now()
+	}
+
+	if !cb.complete {
+		kept := r.buffered[:0]
+		for _, b := range r.buffered {
+			if b.complete || b.phase != cb.phase {
+				kept = append(kept, b)
+			}
+		}
+		r.buffered = kept
+	}
+
+	for len(r.buffered) >= r.maxBuffered {
+		if !r.dropOldestProgress() {
+			break
+		}
+	}
+	if len(r.buffered) >= r.maxBuffered && !cb.complete {
+		atomic.AddInt64(&r.dropped, 1)
+		return
+	}
+	r.buffered = append(r.buffered, cb)
+
+	if !r.flushing {
+		r.flushing = true
+		go r.flushLoop()
+	}
+}
+
+// dropOldestProgress removes the oldest buffered progress update. The
+// caller must hold bufferMu.
+func (r *Reporter) dropOldestProgress() bool {
+	for i, b := range r.buffered {
+		if !b.complete {
+			r.buffered = append(r.buffered[:i], r.buffered[i+1:]...)
+			atomic.AddInt64(&r.dropped, 1)
+			return true
+		}
+	}
+	return false
+}
+
+// Close ends the reporter's scan: nothing more is buffered, and replay stops
+// once the buffer has drained. Callbacks already buffered, such as a
+// completion whose delivery failed, keep being replayed until delivered or
+// dropped by the replay and age limits, so a receiver outage at the end of
+// a scan doesn't lose its completion.
+func (r *Reporter) Close() {
+	r.bufferMu.Lock()
+	defer r.bufferMu.Unlock()
+
+	if r.closed {
+		return
+	}
+	r.closed = true
+	if n := len(r.buffered); n > 0 {
+		r.logger.Infow("Replaying undelivered callbacks after scan end", "count", n)
+	}
+}
+
+// flushLoop periodically replays the buffer until it is empty. The replay
+// and age limits drop what a dead receiver never accepts, so the loop ends.
+func (r *Reporter) flushLoop() {
+	ticker := time.NewTicker(r.flushInterval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		if r.flush() == nil {
+			r.bufferMu.Lock()
+			done := len(r.buffered) == 0
+			if done {
+				r.flushing = false
+			}
+			r.bufferMu.Unlock()
+			if done {
+				return
+			}
+		}
+	}
+}
+
+// flush sends buffered callbacks in order, stopping at the first retryable
+// failure so later callbacks are never delivered ahead of earlier ones. A
+// callback the receiver rejects outright, or that has exhausted its
+// replays or aged out, is dropped so it can't block the ones behind it.
+func (r *Reporter) flush() error {
+	r.flushMu.Lock()
+	defer r.flushMu.Unlock()
+
+	for {
+		r.bufferMu.Lock()
+		if len(r.buffered) == 0 {
+			r.bufferMu.Unlock()
+			return nil
+		}
+		next := r.buffered[0]
+		r.bufferMu.Unlock()
+
+		retryable, err := r.postCallback(context.Background(), next.url, next.body)
+		if err != nil && retryable {
+			next.replays++
+			if next.replays < callbackMaxReplays && time.Since(next.queuedAt) < callbackMaxAge {
+				r.bufferMu.Lock()
+				if len(r.buffered) > 0 && r.buffered[0].sameAs(next) {
+					r.buffered[0].replays = next.replays
+				}
+				r.bufferMu.Unlock()
+				return err
+			}
+		}
+		if err != nil {
+			atomic.AddInt64(&r.dropped, 1)
+			r.logger.Warnw("Dropping undeliverable callback",
+				"url", next.url, "sequence", next.sequence, "complete", next.complete,
+				"replays", next.replays, "error", err)
+		}
+
+		r.bufferMu.Lock()
+		// Coalescing may have replaced the head while it was in flight
+		if len(r.buffered) > 0 && r.buffered[0].sameAs(next) {
+			r.buffered = r.buffered[1:]
+		}
+		r.bufferMu.Unlock()
+	}
+}
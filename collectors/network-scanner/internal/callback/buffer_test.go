@@ -0,0 +1,158 @@
+package callback
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+func newTestReporter(url string) *Reporter {
+	r := NewReporter("scan-1", url+"/progress", url+"/complete", "", zap.NewNop().Sugar())
+	r.maxAttempts = 1
+	r.retryBackoff = time.Millisecond
+	r.flushInterval = time.Hour // flushed explicitly by the tests
+	return r
+}
+
+func TestFlushHandlesFailedHead(t *testing.T) {
+	tests := []struct {
+		name        string
+		status      int
+		replays     int
+		queuedAgo   time.Duration
+		wantErr     bool
+		wantLeft    int
+		wantDropped int64
+	}{
+		{name: "delivered", status: http.StatusOK, wantLeft: 0},
+		{name: "rejected 4xx is dropped", status: http.StatusNotFound, wantLeft: 0, wantDropped: 2},
+		{name: "5xx is kept for replay", status: http.StatusBadGateway, wantErr: true, wantLeft: 2},
+		{name: "5xx out of replays is dropped", status: http.StatusBadGateway, replays: callbackMaxReplays - 1, wantErr: true, wantLeft: 1, wantDropped: 1},
+		{name: "5xx aged out is dropped", status: http.StatusBadGateway, queuedAgo: 2 * callbackMaxAge, wantErr: true, wantLeft: 1, wantDropped: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+				w.WriteHeader(tt.status)
+			}))
+			defer srv.Close()
+
+			r := newTestReporter(srv.URL)
+			r.buffered = []bufferedCallback{
+				{url: r.progressURL, body: []byte(`{}`), sequence: 1, phase: "a",
+					queuedAt: time.Now().Add(-tt.queuedAgo), replays: tt.replays},
+				{url: r.completeURL, body: []byte(`{}`), complete: true, queuedAt: time.Now()},
+			}
+
+			err := r.flush()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("flush() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got := len(r.buffered); got != tt.wantLeft {
+				t.Errorf("buffered = %d, want %d", got, tt.wantLeft)
+			}
+			if got := r.Dropped(); got != tt.wantDropped {
+				t.Errorf("Dropped() = %d, want %d", got, tt.wantDropped)
+			}
+		})
+	}
+}
+
+func TestRejectedCallbackDoesNotBlockCompletion(t *testing.T) {
+	var completions int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.URL.Path == "/progress" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		atomic.AddInt32(&completions, 1)
+	}))
+	defer srv.Close()
+
+	r := newTestReporter(srv.URL)
+	if err := r.ReportProgress("port_scanning", 10, ""); err == nil {
+		t.Fatal("ReportProgress() succeeded against a 401 receiver")
+	}
+	if err := r.ReportComplete("completed", "", nil); err != nil {
+		t.Fatalf("ReportComplete() error = %v", err)
+	}
+	if got := atomic.LoadInt32(&completions); got != 1 {
+		t.Errorf("completion delivered %d times, want 1", got)
+	}
+	r.Close()
+}
+
+func TestCompletionSurvivesClose(t *testing.T) {
+	tests := []struct {
+		name          string
+		failures      int32 // completion POSTs answered 503 before the receiver recovers
+		wantDelivered int32
+		wantDropped   int64
+	}{
+		{name: "first completion attempt fails", failures: 1, wantDelivered: 1},
+		{name: "receiver down for several replays", failures: 4, wantDelivered: 1},
+		{name: "receiver never recovers", failures: callbackMaxReplays + 5, wantDropped: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var attempts, delivered int32
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+				if atomic.AddInt32(&attempts, 1) <= tt.failures {
+					w.WriteHeader(http.StatusServiceUnavailable)
+					return
+				}
+				atomic.AddInt32(&delivered, 1)
+			}))
+			defer srv.Close()
+
+			r := newTestReporter(srv.URL)
+			r.flushInterval = 5 * time.Millisecond
+			if err := r.ReportComplete("completed", "", nil); err == nil {
+				t.Fatal("ReportComplete() succeeded against a failing receiver")
+			}
+			r.Close()
+
+			deadline := time.Now().Add(5 * time.Second)
+			for {
+				r.bufferMu.Lock()
+				flushing := r.flushing
+				r.bufferMu.Unlock()
+				if !flushing {
+					break
+				}
+				if time.Now().After(deadline) {
+					t.Fatal("replay still running")
+				}
+				time.Sleep(5 * time.Millisecond)
+			}
+
+			if got := atomic.LoadInt32(&delivered); got != tt.wantDelivered {
+				t.Errorf("completion delivered %d times, want %d", got, tt.wantDelivered)
+			}
+			if got := r.Dropped(); got != tt.wantDropped {
+				t.Errorf("Dropped() = %d, want %d", got, tt.wantDropped)
+			}
+		})
+	}
+}
+
+func TestClosedReporterBuffersNothing(t *testing.T) {
+	r := newTestReporter("http://127.0.0.1")
+	r.Close()
+
+	r.enqueue(bufferedCallback{url: r.progressURL, body: []byte(`{}`), sequence: 1})
+
+	r.bufferMu.Lock()
+	defer r.bufferMu.Unlock()
+	if r.flushing || len(r.buffered) != 0 {
+		t.Errorf("enqueue after Close: flushing %v, buffered %d; want neither", r.flushing, len(r.buffered))
+	}
+	if got := r.Dropped(); got != 1 {
+		t.Errorf("Dropped() = %d, want 1", got)
+	}
+}
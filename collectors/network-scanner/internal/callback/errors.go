@@ -0,0 +1,68 @@
+package callback
+
+import (
+	"sort"
+	"sync"
+)
+
+// Error codes reported in a completion's errors.
+const (
+	ErrorPublishFailed  = "publish_failed"
+	ErrorInvalidSubnet  = "invalid_subnet"
+	ErrorInvalidTarget  = "invalid_target"
+	ErrorResolveFailed  = "resolve_failed"
+	ErrorHostScanFailed = "host_scan_failed"
+)
+
+// ScanError aggregates the errors of one kind seen during a scan, so the
+// receiver can tell e.g. a broker outage from a bad subnet without parsing
+// messages.
+type ScanError struct {
+	Code    string `json:"code"`
+	Count   int64  `json:"count"`
+	Message string `json:"message"` // first occurrence
+}
+
+// errorLog accumulates ScanErrors by code.
+type errorLog struct {
+	mu     sync.Mutex
+	byCode map[string]*ScanError
+}
+
+// RecordError counts an error under code, keeping the first message as a
+// sample.
+func (r *Reporter) RecordError(code, message string) {
+	r.errors.mu.Lock()
+	defer r.errors.mu.Unlock()
+
+	if r.errors.byCode == nil {
+		r.errors.byCode = make(map[string]*ScanError)
+	}
+	entry, ok := r.errors.byCode[code]
+	if !ok {
+		entry = &ScanError{Code: code, Message: message}
+		r.errors.byCode[code] = entry
+	}
+	entry.Count++
+}
+
+// Errors returns the recorded errors ordered by count descending, then code.
+func (r *Reporter) Errors() []ScanError {
+	r.errors.mu.Lock()
+	defer r.errors.mu.Unlock()
+
+	if len(r.errors.byCode) == 0 {
+		return nil
+	}
+	out := make([]ScanError, 0, len(r.errors.byCode))
+	for _, entry := range r.errors.byCode {
+		out = append(out, *entry)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Count != out[j].Count {
+			return out[i].Count > out[j].Count
+		}
+		return out[i].Code < out[j].Code
+	})
+	return out
+}
@@ -0,0 +1,74 @@
+package callback
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strconv"
+	"sync"
+	"testing"
+)
+
+func TestCompletionReportsAggregatedErrors(t *testing.T) {
+	var completion Completion
+	srv := httptest.NewServer(http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+		if err := json.NewDecoder(r.Body).Decode(&completion); err != nil {
+			t.Errorf("decoding completion: %v", err)
+		}
+	}))
+	defer srv.Close()
+
+	r := newTestReporter(srv.URL)
+	r.RecordError(ErrorInvalidSubnet, "invalid CIDR address: 10.0.0.0/33")
+
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			r.RecordError(ErrorPublishFailed, "broker unavailable #"+strconv.Itoa(i))
+		}(i)
+	}
+	wg.Wait()
+	r.RecordError(ErrorHostScanFailed, "too many open files")
+
+	if err := r.ReportComplete("failed", "aborted", nil); err != nil {
+		t.Fatalf("ReportComplete() error = %v", err)
+	}
+
+	// Most frequent first, then by code; one entry per code
+	var codes []string
+	var counts []int64
+	for _, e := range completion.Errors {
+		codes = append(codes, e.Code)
+		counts = append(counts, e.Count)
+	}
+	if want := []string{ErrorPublishFailed, ErrorHostScanFailed, ErrorInvalidSubnet}; !reflect.DeepEqual(codes, want) {
+		t.Errorf("error codes = %v, want %v", codes, want)
+	}
+	if want := []int64{50, 1, 1}; !reflect.DeepEqual(counts, want) {
+		t.Errorf("error counts = %v, want %v", counts, want)
+	}
+	if got := completion.Errors[2].Message; got != "invalid CIDR address: 10.0.0.0/33" {
+		t.Errorf("message = %q, want the first occurrence", got)
+	}
+	if completion.ErrorMessage != "aborted" {
+		t.Errorf("error_message = %q, want the summary kept", completion.ErrorMessage)
+	}
+}
+
+func TestCompletionWithoutErrors(t *testing.T) {
+	var body map[string]interface{}
+	srv := httptest.NewServer(http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+		_ = json.NewDecoder(r.Body).Decode(&body)
+	}))
+	defer srv.Close()
+
+	if err := newTestReporter(srv.URL).ReportComplete("completed", "", nil); err != nil {
+		t.Fatalf("ReportComplete() error = %v", err)
+	}
+	if _, ok := body["errors"]; ok {
+		t.Errorf("completion = %v, want no errors field", body)
+	}
+}
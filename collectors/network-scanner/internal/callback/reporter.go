@@ -5,9 +5,15 @@ package callback
 import (
 	"bytes"
 	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
+	"math/rand"
 	"net/http"
+	"strconv"
+	"sync"
 	"sync/atomic"
 	"time"
 
@@ -24,6 +30,38 @@ type Reporter struct {
 	client         *http.Client
 	sequence       int64 // Monotonic counter for idempotency
 	discoveryCount int64
+	hostsAlive     int64 // hosts that passed the liveness pre-check
+	hostsSkipped   int64 // hosts skipped by the liveness pre-check
+
+	// signingKey, when set, signs each request with HMAC-SHA256
+	signingKey []byte
+
+	// heartbeatURL receives liveness heartbeats; empty disables them
+	heartbeatURL string
+
+	// breakdown counts discoveries by service category. It is updated
+	// together with discoveryCount under breakdownMu, so a progress
+	// update's breakdown always sums to its count.
+	breakdownMu sync.Mutex
+	breakdown   map[string]int
+
+	// errors seen during the scan, reported on completion
+	errors errorLog
+
+	// Retry policy for callback delivery
+	maxAttempts   int
+	retryBackoff  time.Duration
+	retryDeadline time.Duration
+
+	// Undelivered callbacks awaiting replay, in send order
+	bufferMu      sync.Mutex
+	buffered      []bufferedCallback
+	maxBuffered   int
+	dropped       int64
+	flushing      bool
+	closed        bool
+	flushMu       sync.Mutex // serializes replays
+	flushInterval time.Duration
 }
 
 // Progress represents a progress update.
@@ -34,20 +72,109 @@ type Progress struct {
 	Phase          string `json:"phase,omitempty"`
 	Progress       int    `json:"progress"`
 	DiscoveryCount int    `json:"discovery_count"`
-	Message        string `json:"message,omitempty"`
-	Timestamp      string `json:"timestamp"`
+	// DiscoveryBreakdown splits DiscoveryCount by service category
+	// (database, web, ..., other)
+	DiscoveryBreakdown map[string]int `json:"discovery_breakdown,omitempty"`
+	HostsAlive         int            `json:"alive,omitempty"`
+	SkippedDead        int            `json:"skipped_dead,omitempty"`
+	Message            string         `json:"message,omitempty"`
+	Timestamp          string         `json:"timestamp"`
+}
+
+// Heartbeat is a liveness signal sent while a scan runs, independent of
+// progress, so the receiver can declare a scan dead after missed beats.
+type Heartbeat struct {
+	ScanID    string `json:"scan_id"`
+	Collector string `json:"collector"`
+	Phase     string `json:"phase,omitempty"`
+	Timestamp string `json:"timestamp"`
 }
 
 // Completion represents a scan completion.
 type Completion struct {
-	ScanID         string `json:"scan_id"`
-	Collector      string `json:"collector"`
-	Status         string `json:"status"` // completed, failed, timeout
-	DiscoveryCount int    `json:"discovery_count"`
-	ErrorMessage   string `json:"error_message,omitempty"`
-	Timestamp      string `json:"timestamp"`
+	ScanID         string       `json:"scan_id"`
+	Collector      string       `json:"collector"`
+	Status         string       `json:"status"` // completed, failed, cancelled, interrupted, timeout
+	DiscoveryCount int          `json:"discovery_count"`
+	ErrorMessage   string       `json:"error_message,omitempty"` // summary of why the scan failed
+	Summary        *ScanSummary `json:"summary,omitempty"`
+	Timestamp      string       `json:"timestamp"`
+
+	// Errors recorded during the scan, by code
+	Errors []ScanError `json:"errors,omitempty"`
+}
+
+// ScanSummary carries post-scan analytics so dashboards can chart a finished
+// scan without having scraped metrics while it ran.
+type ScanSummary struct {
+	VantagePoint  string        `json:"vantage_point,omitempty"`
+	DialLatencyMS Histogram     `json:"dial_latency_ms"`
+	PortStates    PortStates    `json:"port_states"`
+	KnownAssets   int64         `json:"known_assets"`
+	Cancellation  *Cancellation `json:"cancellation,omitempty"`
+
+	// Ordered by count descending, then name ascending
+	TopServices []NamedCount `json:"top_services,omitempty"`
+	Subnets     []NamedCount `json:"subnets,omitempty"`
+
+	// Requested subnets that were never scanned, as opposed to subnets
+	// scanned with nothing found
+	SkippedSubnets []SkippedSubnet `json:"skipped_subnets,omitempty"`
+}
+
+// SkippedSubnet records a requested subnet that was not scanned and why.
+type SkippedSubnet struct {
+	Subnet string `json:"subnet"`
+	Reason string `json:"reason"`
+}
+
+// NamedCount is a count keyed by name, used for ordered summary sections.
+type NamedCount struct {
+	Name  string `json:"name"`
+	Count int64  `json:"count"`
+}
+
+// Cancellation describes how a cancelled scan handled in-flight hosts.
+type Cancellation struct {
+	Mode      string `json:"mode"` // immediate or graceful
+	Preserved int64  `json:"preserved"`
+	Dropped   int64  `json:"dropped"`
+}
+
+// Histogram is a Prometheus-style histogram with cumulative buckets.
+type Histogram struct {
+	Buckets []HistogramBucket `json:"buckets"`
+	Count   int64             `json:"count"`
+	Sum     int64             `json:"sum"`
 }
 
+// HistogramBucket is a cumulative bucket; LE is the inclusive upper bound
+// or "+Inf".
+type HistogramBucket struct {
+	LE    string `json:"le"`
+	Count int64  `json:"count"`
+}
+
+// PortStates counts probed ports by outcome.
+type PortStates struct {
+	Open     int64 `json:"open"`
+	Closed   int64 `json:"closed"`
+	Filtered int64 `json:"filtered"`
+}
+
+// Callback delivery limits. Each attempt has its own timeout; the deadline
+// bounds all attempts and backoff waits together.
+const (
+	callbackAttemptTimeout = 10 * time.Second
+	callbackMaxAttempts    = 5
+	callbackInitialBackoff = 500 * time.Millisecond
+	callbackDeadline       = 60 * time.Second
+
+	// heartbeatTimeout bounds a heartbeat, so a slow receiver delays the
+	// completion callback by at most this much
+	heartbeatTimeout = 2 * time.Second
+)
+
 // NewReporter creates a new callback reporter.
 func NewReporter(scanID, progressURL, completeURL, apiKey string, logger *zap.SugaredLogger) *Reporter {
 	return &Reporter{
@@ -57,32 +184,88 @@ func NewReporter(scanID, progressURL, completeURL, apiKey string, logger *zap.Su
 		apiKey:      apiKey,
 		logger:      logger,
 		client: &http.Client{
-			Timeout: 10 * time.Second,
+			Timeout: callbackAttemptTimeout,
 		},
+		maxAttempts:   callbackMaxAttempts,
+		retryBackoff:  callbackInitialBackoff,
+		retryDeadline: callbackDeadline,
+		maxBuffered:   callbackBufferSize,
+		flushInterval: callbackFlushInterval,
 	}
 }
 
 // ReportProgress sends a progress update.
 func (r *Reporter) ReportProgress(phase string, progress int, message string) error {
 	seq := atomic.AddInt64(&r.sequence, 1)
-	count := atomic.LoadInt64(&r.discoveryCount)
+	count, breakdown := r.GetDiscoveries()
 
 	payload := Progress{
-		ScanID:         r.scanID,
-		Collector:      "network-scanner",
-		Sequence:       int(seq),
-		Phase:          phase,
-		Progress:       progress,
-		DiscoveryCount: int(count),
-		Message:        message,
-		Timestamp:      time.Now().UTC().Format(time.RFC3339),
+		ScanID:             r.scanID,
+		Collector:          "network-scanner",
+		Sequence:           int(seq),
+		Phase:              phase,
+		Progress:           progress,
+		DiscoveryCount:     count,
+		DiscoveryBreakdown: breakdown,
+		HostsAlive:         int(atomic.LoadInt64(&r.hostsAlive)),
+		SkippedDead:        int(atomic.LoadInt64(&r.hostsSkipped)),
+		Message:            message,
+		Timestamp:          time.Now().UTC().Format(time.RFC3339),
 	}
 
-	return r.sendCallback(r.progressURL, payload)
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("failed to marshal payload: %w", err)
+	}
+	cb := bufferedCallback{url: r.progressURL, body: body, sequence: payload.Sequence, phase: phase}
+
+	// While earlier updates are still buffered, queue behind them so the
+	// receiver sees sequence order
+	if r.enqueueIfBuffering(cb) {
+		return nil
+	}
+	if err := r.deliver(cb.url, cb.body); err != nil {
+		r.enqueue(cb)
+		return err
+	}
+	return nil
 }
 
-// ReportComplete sends a completion callback.
-func (r *Reporter) ReportComplete(status string, errorMsg string) error {
+// SetHeartbeatURL sets where ReportHeartbeat posts heartbeats.
+func (r *Reporter) SetHeartbeatURL(url string) {
+	r.heartbeatURL = url
+}
+
+// HasHeartbeat reports whether a heartbeat URL is set.
+func (r *Reporter) HasHeartbeat() bool {
+	return r.heartbeatURL != ""
+}
+
+// ReportHeartbeat sends a heartbeat in a single attempt. A missed heartbeat
+// is superseded by the next, so failures are neither retried nor buffered.
+func (r *Reporter) ReportHeartbeat(phase string) error {
+	if r.heartbeatURL == "" {
+		return nil
+	}
+
+	body, err := json.Marshal(Heartbeat{
+		ScanID:    r.scanID,
+		Collector: "network-scanner",
+		Phase:     phase,
+		Timestamp: time.Now().UTC().Format(time.RFC3339Nano),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to marshal payload: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), heartbeatTimeout)
+	defer cancel()
+	_, err = r.postCallback(ctx, r.heartbeatURL, body)
+	return err
+}
+
+// ReportComplete sends a completion callback. summary may be nil.
+func (r *Reporter) ReportComplete(status string, errorMsg string, summary *ScanSummary) error {
 	count := atomic.LoadInt64(&r.discoveryCount)
 
 	payload := Completion{
@@ -91,17 +274,104 @@ func (r *Reporter) ReportComplete(status string, errorMsg string) error {
 		Status:         status,
 		DiscoveryCount: int(count),
 		ErrorMessage:   errorMsg,
+		Summary:        summary,
 		Timestamp:      time.Now().UTC().Format(time.RFC3339),
+		Errors:         r.Errors(),
 	}
 
-	return r.sendCallback(r.completeURL, payload)
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("failed to marshal payload: %w", err)
+	}
+	cb := bufferedCallback{url: r.completeURL, body: body, complete: true}
+
+	// Replay buffered progress first so completion is the last event the
+	// receiver sees
+	if err := r.flush(); err != nil {
+		r.enqueue(cb)
+		return fmt.Errorf("completion buffered, callbacks undeliverable: %w", err)
+	}
+	if err := r.deliver(cb.url, cb.body); err != nil {
+		r.enqueue(cb)
+		return err
+	}
+	return nil
 }
 
-// IncrementDiscoveryCount increments the discovery counter.
-func (r *Reporter) IncrementDiscoveryCount() {
+// SetSigningKey enables HMAC request signing. Each request carries
+// X-Timestamp (Unix seconds) and X-Signature, the hex HMAC-SHA256 of
+// "<timestamp>.<body>" under key, so the receiver can verify integrity and
+// reject replays outside its freshness window.
+func (r *Reporter) SetSigningKey(key []byte) {
+	r.signingKey = key
+}
+
+// sign returns the X-Signature value for body at timestamp.
+func (r *Reporter) sign(timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, r.signingKey)
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// IncrementDiscoveryCount counts a discovery of the given service category.
+func (r *Reporter) IncrementDiscoveryCount(category string) {
+	r.breakdownMu.Lock()
+	defer r.breakdownMu.Unlock()
+
+	if r.breakdown == nil {
+		r.breakdown = make(map[string]int)
+	}
+	r.breakdown[category]++
 	atomic.AddInt64(&r.discoveryCount, 1)
 }
 
+// RestoreDiscoveryCount sets the discovery counter and breakdown, so a
+// resumed scan continues counting from its checkpoint. Discoveries the
+// breakdown doesn't account for are counted under otherCategory.
+func (r *Reporter) RestoreDiscoveryCount(count int, breakdown map[string]int, otherCategory string) {
+	r.breakdownMu.Lock()
+	defer r.breakdownMu.Unlock()
+
+	r.breakdown = make(map[string]int, len(breakdown)+1)
+	sum := 0
+	for category, n := range breakdown {
+		r.breakdown[category] = n
+		sum += n
+	}
+	if sum < count {
+		r.breakdown[otherCategory] += count - sum
+	}
+	atomic.StoreInt64(&r.discoveryCount, int64(count))
+}
+
+// GetDiscoveries returns the discovery count and a copy of its breakdown
+// by category, taken together.
+func (r *Reporter) GetDiscoveries() (int, map[string]int) {
+	r.breakdownMu.Lock()
+	defer r.breakdownMu.Unlock()
+
+	var breakdown map[string]int
+	if len(r.breakdown) > 0 {
+		breakdown = make(map[string]int, len(r.breakdown))
+		for category, n := range r.breakdown {
+			breakdown[category] = n
+		}
+	}
+	return int(atomic.LoadInt64(&r.discoveryCount)), breakdown
+}
+
+// IncrementAlive counts a host that passed the liveness pre-check.
+func (r *Reporter) IncrementAlive() {
+	atomic.AddInt64(&r.hostsAlive, 1)
+}
+
+// IncrementSkippedDead counts a host skipped by the liveness pre-check.
+func (r *Reporter) IncrementSkippedDead() {
+	atomic.AddInt64(&r.hostsSkipped, 1)
+}
+
 // GetDiscoveryCount returns the current discovery count.
 func (r *Reporter) GetDiscoveryCount() int {
 	return int(atomic.LoadInt64(&r.discoveryCount))
@@ -112,37 +382,70 @@ func (r *Reporter) GetScanID() string {
 	return r.scanID
 }
 
-func (r *Reporter) sendCallback(url string, payload interface{}) error {
-	body, err := json.Marshal(payload)
-	if err != nil {
-		return fmt.Errorf("failed to marshal payload: %w", err)
+// deliver posts an encoded callback body with retries.
+func (r *Reporter) deliver(url string, body []byte) error {
+	// Overall deadline so an unresponsive receiver can't block the caller
+	// (e.g. scan completion) indefinitely
+	ctx, cancel := context.WithTimeout(context.Background(), r.retryDeadline)
+	defer cancel()
+
+	backoff := r.retryBackoff
+	for attempt := 1; ; attempt++ {
+		retryable, err := r.postCallback(ctx, url, body)
+		if err == nil {
+			return nil
+		}
+		if !retryable || attempt >= r.maxAttempts {
+			return err
+		}
+
+		// Full jitter in [backoff/2, backoff) spreads retries from many
+		// scanners hitting the same receiver
+		wait := backoff/2 + time.Duration(rand.Int63n(int64(backoff/2)+1))
+		r.logger.Debugw("Retrying callback", "url", url, "attempt", attempt, "wait", wait)
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(wait):
+		}
+		backoff *= 2
 	}
+}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+// postCallback makes a single callback attempt with the per-attempt timeout
+// and reports whether a failure is worth retrying.
+func (r *Reporter) postCallback(ctx context.Context, url string, body []byte) (bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, callbackAttemptTimeout)
 	defer cancel()
 
 	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewReader(body))
 	if err != nil {
-		return fmt.Errorf("failed to create request: %w", err)
+		return false, fmt.Errorf("failed to create request: %w", err)
 	}
 
 	req.Header.Set("Content-Type", "application/json")
 	if r.apiKey != "" {
 		req.Header.Set("X-Internal-API-Key", r.apiKey)
 	}
+	if len(r.signingKey) > 0 {
+		// Fresh timestamp per attempt so retries aren't rejected as stale
+		timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+		req.Header.Set("X-Timestamp", timestamp)
+		req.Header.Set("X-Signature", r.sign(timestamp, body))
+	}
 
 	resp, err := r.client.Do(req)
 	if err != nil {
 		r.logger.Warnw("Callback failed", "url", url, "error", err)
-		return fmt.Errorf("callback request failed: %w", err)
+		return true, fmt.Errorf("callback request failed: %w", err)
 	}
 	defer func() { _ = resp.Body.Close() }()
 
 	if resp.StatusCode >= 400 {
 		r.logger.Warnw("Callback returned error", "url", url, "status", resp.StatusCode)
-		return fmt.Errorf("callback returned status %d", resp.StatusCode)
+		return resp.StatusCode >= 500, fmt.Errorf("callback returned status %d", resp.StatusCode)
 	}
 
 	r.logger.Debugw("Callback sent", "url", url, "status", resp.StatusCode)
-	return nil
+	return false, nil
 }
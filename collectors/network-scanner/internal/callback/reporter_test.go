@@ -0,0 +1,138 @@
+package callback
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+)
+
+func TestDiscoveryBreakdownSumsToCount(t *testing.T) {
+	tests := []struct {
+		name       string
+		restore    int
+		restoreBy  map[string]int
+		increments []string
+		wantCount  int
+		wantBy     map[string]int
+	}{
+		{name: "none", wantCount: 0},
+		{
+			name:       "by category",
+			increments: []string{"database", "web", "database", "other"},
+			wantCount:  4,
+			wantBy:     map[string]int{"database": 2, "web": 1, "other": 1},
+		},
+		{
+			name:       "restored checkpoint",
+			restore:    5,
+			restoreBy:  map[string]int{"database": 3, "web": 2},
+			increments: []string{"web"},
+			wantCount:  6,
+			wantBy:     map[string]int{"database": 3, "web": 3},
+		},
+		{
+			name:       "checkpoint without a breakdown counts as other",
+			restore:    4,
+			restoreBy:  map[string]int{"database": 1},
+			increments: []string{"database"},
+			wantCount:  5,
+			wantBy:     map[string]int{"database": 2, "other": 3},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := newTestReporter("http://127.0.0.1")
+			if tt.restore > 0 {
+				r.RestoreDiscoveryCount(tt.restore, tt.restoreBy, "other")
+			}
+			for _, category := range tt.increments {
+				r.IncrementDiscoveryCount(category)
+			}
+
+			count, breakdown := r.GetDiscoveries()
+			if count != tt.wantCount {
+				t.Errorf("count = %d, want %d", count, tt.wantCount)
+			}
+			if len(breakdown) != len(tt.wantBy) {
+				t.Errorf("breakdown = %v, want %v", breakdown, tt.wantBy)
+			}
+			sum := 0
+			for category, n := range breakdown {
+				sum += n
+				if n != tt.wantBy[category] {
+					t.Errorf("breakdown[%q] = %d, want %d", category, n, tt.wantBy[category])
+				}
+			}
+			if sum != count {
+				t.Errorf("breakdown sums to %d, count is %d", sum, count)
+			}
+		})
+	}
+}
+
+func TestDiscoveryBreakdownConsistentUnderConcurrency(t *testing.T) {
+	r := newTestReporter("http://127.0.0.1")
+	categories := []string{"database", "web", "remote_access", "other"}
+
+	var wg sync.WaitGroup
+	done := make(chan struct{})
+	for _, category := range categories {
+		wg.Add(1)
+		go func(category string) {
+			defer wg.Done()
+			for i := 0; i < 500; i++ {
+				r.IncrementDiscoveryCount(category)
+			}
+		}(category)
+	}
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+
+	for {
+		count, breakdown := r.GetDiscoveries()
+		sum := 0
+		for _, n := range breakdown {
+			sum += n
+		}
+		if sum != count {
+			t.Fatalf("breakdown sums to %d, count is %d", sum, count)
+		}
+		select {
+		case <-done:
+			if count, _ := r.GetDiscoveries(); count != 500*len(categories) {
+				t.Errorf("count = %d, want %d", count, 500*len(categories))
+			}
+			return
+		default:
+		}
+	}
+}
+
+func TestProgressCarriesLivenessCounts(t *testing.T) {
+	progress := make(chan map[string]interface{}, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(_ http.ResponseWriter, req *http.Request) {
+		var body map[string]interface{}
+		if err := json.NewDecoder(req.Body).Decode(&body); err != nil {
+			t.Errorf("decoding progress: %v", err)
+		}
+		progress <- body
+	}))
+	defer srv.Close()
+
+	r := newTestReporter(srv.URL)
+	r.IncrementAlive()
+	r.IncrementAlive()
+	r.IncrementSkippedDead()
+	if err := r.ReportProgress("port_scanning", 50, ""); err != nil {
+		t.Fatalf("ReportProgress() error = %v", err)
+	}
+
+	body := <-progress
+	if body["alive"] != 2.0 || body["skipped_dead"] != 1.0 {
+		t.Errorf("alive = %v, skipped_dead = %v; want 2 and 1", body["alive"], body["skipped_dead"])
+	}
+}
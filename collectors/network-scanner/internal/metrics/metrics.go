@@ -0,0 +1,68 @@
+// Package metrics defines the Prometheus metrics exported by the scanner.
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var (
+	// PortsScanned counts every TCP and UDP port probe.
+	PortsScanned = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "scanner_ports_scanned_total",
+		Help: "Total number of ports probed.",
+	})
+
+	// OpenPorts counts probes that found an open port.
+	OpenPorts = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "scanner_open_ports_total",
+		Help: "Total number of open ports found.",
+	})
+
+	// PublishFailures counts discovery events that failed to publish.
+	PublishFailures = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "scanner_publish_failures_total",
+		Help: "Total number of discovery events that failed to publish.",
+	})
+
+	// SecondaryPublishFailures counts events a secondary sink (HTTP or
+	// file) failed to take while the primary publisher accepted them.
+	SecondaryPublishFailures = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "scanner_secondary_publish_failures_total",
+		Help: "Total number of events secondary sinks failed to take.",
+	})
+
+	// PublishDropped counts events dropped because the reconnect buffer
+	// was full.
+	PublishDropped = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "scanner_publish_dropped_total",
+		Help: "Total number of discovery events dropped while the broker was unreachable.",
+	})
+
+	// ActiveScans is the number of autonomous scans in progress.
+	ActiveScans = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "scanner_active_scans",
+		Help: "Number of autonomous scans currently running.",
+	})
+
+	// ScanDuration observes the wall-clock duration of finished scans.
+	ScanDuration = promauto.NewHistogram(prometheus.HistogramOpts{
+		Name:    "scanner_scan_duration_seconds",
+		Help:    "Duration of finished autonomous scans.",
+		Buckets: prometheus.ExponentialBuckets(1, 4, 10), // 1s to ~3 days
+	})
+
+	// DeadHosts counts hosts skipped as unreachable, by the liveness
+	// pre-check or by dead host detection.
+	DeadHosts = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "scanner_dead_hosts_total",
+		Help: "Total number of hosts skipped as unreachable.",
+	})
+
+	// FDExhausted counts dials that failed because the process ran out of
+	// file descriptors and were retried after a backoff.
+	FDExhausted = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "scanner_fd_exhausted_total",
+		Help: "Total number of dials retried after running out of file descriptors.",
+	})
+)
@@ -0,0 +1,114 @@
+package publisher
+
+import (
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+// batcher groups service discoveries and hands full batches to publish.
+type batcher struct {
+	mu     sync.Mutex
+	items  []ServiceDiscoveredData
+	size   int
+	window time.Duration
+	timer  *time.Timer
+
+	publish func(items []ServiceDiscoveredData) error
+	logger  *zap.SugaredLogger
+}
+
+// SetBatching groups service discoveries into discovery.service.batch
+// events of up to size entries, published on routing key
+// discovered.service.batch. Each entry carries its own event_id. A partial
+// batch is published once window has passed since its first entry, or on
+// Flush. A size below 2 disables batching.
+func (p *Publisher) SetBatching(size int, window time.Duration) {
+	p.batch.configure(size, window)
+}
+
+// Flush publishes any partially filled batch. It is a no-op when batching
+// is disabled or nothing is queued.
+func (p *Publisher) Flush() error {
+	return p.batch.flush()
+}
+
+// publishBatch publishes a batch, then the candidate events of its
+// database services, so no candidate precedes its service.
+func (p *Publisher) publishBatch(items []ServiceDiscoveredData) error {
+	event := p.createEvent("discovery.service.batch", items)
+	if err := p.publish(event, "discovered.service.batch"); err != nil {
+		return err
+	}
+	p.publishCandidates(p.publish, p.logger, items...)
+	return nil
+}
+
+func (b *batcher) configure(size int, window time.Duration) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.size = size
+	b.window = window
+}
+
+// settings returns the batch size and window.
+func (b *batcher) settings() (int, time.Duration) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.size, b.window
+}
+
+// add queues a discovery and publishes the batch once it is full.
+// A publish error is reported to the caller that completed the batch.
+func (b *batcher) add(data ServiceDiscoveredData) error {
+	data.EventID = uuid.New().String()
+
+	b.mu.Lock()
+	b.items = append(b.items, data)
+	if len(b.items) == 1 && b.window > 0 {
+		b.timer = time.AfterFunc(b.window, func() {
+			if err := b.flush(); err != nil {
+				b.logger.Errorw("Failed to publish batch", "error", err)
+			}
+		})
+	}
+	if len(b.items) < b.size {
+		b.mu.Unlock()
+		return nil
+	}
+	items := b.take()
+	b.mu.Unlock()
+
+	return b.publish(items)
+}
+
+func (b *batcher) flush() error {
+	b.mu.Lock()
+	items := b.take()
+	b.mu.Unlock()
+
+	if len(items) == 0 {
+		return nil
+	}
+	return b.publish(items)
+}
+
+// take detaches the queued batch. Callers must hold mu.
+func (b *batcher) take() []ServiceDiscoveredData {
+	if b.timer != nil {
+		b.timer.Stop()
+		b.timer = nil
+	}
+	items := b.items
+	b.items = nil
+	return items
+}
+
+// enabled reports whether service discoveries are being batched.
+func (b *batcher) enabled() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.size > 1
+}
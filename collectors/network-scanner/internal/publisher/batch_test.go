@@ -0,0 +1,109 @@
+package publisher
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+func TestBatchCounts(t *testing.T) {
+	tests := []struct {
+		name      string
+		results   int
+		batchSize int
+		window    time.Duration
+		wait      time.Duration
+		wantFull  int // batches published as they filled
+		wantTotal int // after the final flush or window
+	}{
+		{name: "exact batches", results: 6, batchSize: 3, window: time.Hour, wantFull: 2, wantTotal: 2},
+		{name: "partial batch flushed at the end", results: 7, batchSize: 3, window: time.Hour, wantFull: 2, wantTotal: 3},
+		{name: "partial batch published after the window", results: 4, batchSize: 3, window: 10 * time.Millisecond, wait: 100 * time.Millisecond, wantFull: 1, wantTotal: 2},
+		{name: "batching off", results: 4, batchSize: 1, wantFull: 4, wantTotal: 4},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var mu sync.Mutex
+			var events []CloudEvent
+			send := func(event CloudEvent, _ string) error {
+				mu.Lock()
+				defer mu.Unlock()
+				events = append(events, event)
+				return nil
+			}
+			published := func() []CloudEvent {
+				mu.Lock()
+				defer mu.Unlock()
+				return append([]CloudEvent(nil), events...)
+			}
+			p := newScanPublisher(eventBuilder{}, "scan", send, func() bool { return true }, zap.NewNop().Sugar())
+			p.batch.configure(tt.batchSize, tt.window)
+
+			for i := 0; i < tt.results; i++ {
+				if err := p.PublishServiceDiscovered(testResult{ip: "10.0.0.5", port: 8000 + i}); err != nil {
+					t.Fatalf("PublishServiceDiscovered() error = %v", err)
+				}
+			}
+			if got := len(published()); got != tt.wantFull {
+				t.Errorf("published %d events before the flush, want %d", got, tt.wantFull)
+			}
+			time.Sleep(tt.wait)
+			if err := p.Flush(); err != nil {
+				t.Fatalf("Flush() error = %v", err)
+			}
+
+			total, entries := 0, 0
+			ids := make(map[string]bool)
+			for _, event := range published() {
+				total++
+				if items, ok := event.Data.([]ServiceDiscoveredData); ok {
+					for _, item := range items {
+						entries++
+						ids[item.EventID] = true
+					}
+				} else {
+					entries++
+				}
+			}
+			if total != tt.wantTotal {
+				t.Errorf("published %d events in all, want %d", total, tt.wantTotal)
+			}
+			if entries != tt.results {
+				t.Errorf("events carry %d services, want %d", entries, tt.results)
+			}
+			if tt.batchSize > 1 && len(ids) != tt.results {
+				t.Errorf("%d distinct event IDs in the batches, want one per service", len(ids))
+			}
+		})
+	}
+}
+
+func TestBatchEvent(t *testing.T) {
+	var sent []sentEvent
+	var data interface{}
+	send := func(event CloudEvent, routingKey string) error {
+		sent = append(sent, sentEvent{event.Type, event.Subject, routingKey})
+		data = event.Data
+		return nil
+	}
+	p := newScanPublisher(eventBuilder{}, "scan-a", send, func() bool { return true }, zap.NewNop().Sugar())
+	p.batch.configure(2, time.Hour)
+
+	for _, port := range []int{22, 80} {
+		if err := p.PublishServiceDiscovered(testResult{ip: "10.0.0.5", port: port}); err != nil {
+			t.Fatalf("PublishServiceDiscovered() error = %v", err)
+		}
+	}
+
+	want := []sentEvent{{"discovery.service.batch", "scan-a", "discovered.service.batch"}}
+	if fmt.Sprint(sent) != fmt.Sprint(want) {
+		t.Fatalf("sent %v, want %v", sent, want)
+	}
+	items, ok := data.([]ServiceDiscoveredData)
+	if !ok || len(items) != 2 || items[0].Port != 22 || items[1].Port != 80 {
+		t.Errorf("batch data = %+v, want the two services in order", data)
+	}
+}
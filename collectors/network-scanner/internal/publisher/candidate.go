@@ -0,0 +1,97 @@
+package publisher
+
+import (
+	"fmt"
+
+	"go.uber.org/zap"
+)
+
+// candidateRoutingKey routes database candidate events, so consumers can
+// bind to candidates without parsing every service event.
+const candidateRoutingKey = "candidate.database"
+
+// portOnlyConfidence is the confidence of a candidate identified by its
+// port alone; HandshakeConfidence that of one whose protocol handshake the
+// scanner confirmed.
+const (
+	portOnlyConfidence  = 0.5
+	HandshakeConfidence = 0.95
+)
+
+// DatabaseCandidateData flags a discovered service that may be a database
+// (ADR-007). It is published alongside the service event, which keeps the
+// same flags in its metadata.
+type DatabaseCandidateData struct {
+	ServiceID     string  `json:"service_id"`
+	ServerID      string  `json:"server_id,omitempty"`
+	IP            string  `json:"ip"`
+	Port          int     `json:"port"`
+	Protocol      string  `json:"protocol"`
+	Service       string  `json:"service,omitempty"`
+	CandidateType string  `json:"candidate_type"`
+	Confidence    float64 `json:"confidence"`
+	Reason        string  `json:"reason"`
+}
+
+// databaseCandidate reports whether port is a known database port, and
+// the candidate type, confidence and reason if so.
+func databaseCandidate(port int) (string, float64, string, bool) {
+	dbType, ok := databasePorts[port]
+	if !ok {
+		return "", 0, "", false
+	}
+	return dbType, portOnlyConfidence, fmt.Sprintf("Port %d (known %s port)", port, dbType), true
+}
+
+// candidateEvent builds a database candidate event for a service on a
+// known database port, unless a probe ruled it out. Confidence and reason
+// come from the service metadata, where a probe may have raised them.
+func (p *eventBuilder) candidateEvent(service ServiceDiscoveredData) (CloudEvent, bool) {
+	dbType, confidence, reason, ok := databaseCandidate(service.Port)
+	if !ok || service.Metadata["database_candidate"] == false {
+		return CloudEvent{}, false
+	}
+	if probed, ok := service.Metadata["candidate_confidence"].(float64); ok {
+		confidence = probed
+	}
+	if probed, ok := service.Metadata["candidate_reason"].(string); ok {
+		reason = probed
+	}
+	return p.createEvent("discovery.database.candidate", DatabaseCandidateData{
+		ServiceID:     service.ServiceID,
+		ServerID:      service.ServerID,
+		IP:            service.IP,
+		Port:          service.Port,
+		Protocol:      service.Protocol,
+		Service:       service.Service,
+		CandidateType: dbType,
+		Confidence:    confidence,
+		Reason:        reason,
+	}), true
+}
+
+// clearRejectedCandidate drops the candidate details from metadata whose
+// candidate flag a probe cleared.
+func clearRejectedCandidate(metadata map[string]interface{}) {
+	if metadata["database_candidate"] == false {
+		delete(metadata, "candidate_type")
+		delete(metadata, "candidate_confidence")
+		delete(metadata, "candidate_reason")
+	}
+}
+
+// publishCandidates sends a candidate event for each database service.
+// The service itself has already been published, so a failure here is
+// logged rather than reported as a failed discovery.
+func (p *eventBuilder) publishCandidates(send func(CloudEvent, string) error, logger *zap.SugaredLogger, services ...ServiceDiscoveredData) {
+	for _, service := range services {
+		event, ok := p.candidateEvent(service)
+		if !ok {
+			continue
+		}
+		if err := send(event, candidateRoutingKey); err != nil {
+			logger.Warnw("Failed to publish database candidate",
+				"ip", service.IP, "port", service.Port, "error", err)
+		}
+	}
+}
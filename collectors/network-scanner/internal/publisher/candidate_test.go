@@ -0,0 +1,70 @@
+package publisher
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// testResult is a scan result as the scanner passes it in.
+type testResult struct {
+	ip   string
+	port int
+}
+
+func (r testResult) GetIP() string       { return r.ip }
+func (r testResult) GetPort() int        { return r.port }
+func (r testResult) GetProtocol() string { return "tcp" }
+func (r testResult) GetService() string  { return "" }
+func (r testResult) GetBanner() string   { return "" }
+
+func TestCandidateFollowsService(t *testing.T) {
+	const (
+		service   = "discovery.service.discovered"
+		batch     = "discovery.service.batch"
+		candidate = "discovery.database.candidate"
+	)
+
+	tests := []struct {
+		name      string
+		batchSize int
+		ports     []int
+		flush     bool
+		want      []string
+	}{
+		{name: "mysql", ports: []int{3306}, want: []string{service, candidate}},
+		{name: "not a database", ports: []int{80}, want: []string{service}},
+		{name: "batched", batchSize: 2, ports: []int{3306, 5432}, want: []string{batch, candidate, candidate}},
+		{name: "batch not yet full", batchSize: 2, ports: []int{3306}, want: []string{}},
+		{name: "partial batch flushed", batchSize: 2, ports: []int{3306}, flush: true, want: []string{batch, candidate}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var sent []string
+			send := func(event CloudEvent, _ string) error {
+				sent = append(sent, event.Type)
+				return nil
+			}
+			p := newScanPublisher(eventBuilder{}, "scan", send, func() bool { return true }, zap.NewNop().Sugar())
+			p.batch.configure(tt.batchSize, time.Hour)
+
+			for _, port := range tt.ports {
+				if err := p.PublishServiceDiscovered(testResult{ip: "10.0.0.5", port: port}); err != nil {
+					t.Fatalf("PublishServiceDiscovered() error = %v", err)
+				}
+			}
+			if tt.flush {
+				if err := p.Flush(); err != nil {
+					t.Fatalf("Flush() error = %v", err)
+				}
+			}
+
+			if fmt.Sprint(sent) != fmt.Sprint(tt.want) {
+				t.Errorf("sent %v, want %v", sent, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,91 @@
+package publisher
+
+import "strings"
+
+// Service categories used to classify discovered services. The category is
+// appended to the service routing key when category routing is enabled so
+// consumers can bind to e.g. discovered.service.database without inspecting
+// event bodies.
+const (
+	CategoryDatabase     = "database"
+	CategoryWeb          = "web"
+	CategoryMessaging    = "messaging"
+	CategoryRemoteAccess = "remote_access"
+	CategoryMail         = "mail"
+	CategoryFileTransfer = "file_transfer"
+	CategoryInfra        = "infrastructure"
+	CategoryOther        = "other"
+)
+
+// serviceNameCategories maps lower-cased fingerprint names to categories.
+var serviceNameCategories = map[string]string{
+	"mysql":                   CategoryDatabase,
+	"postgresql":              CategoryDatabase,
+	"mongodb":                 CategoryDatabase,
+	"redis":                   CategoryDatabase,
+	"mssql":                   CategoryDatabase,
+	"oracle":                  CategoryDatabase,
+	"elasticsearch":           CategoryDatabase,
+	"elasticsearch-transport": CategoryDatabase,
+	"couchdb":                 CategoryDatabase,
+	"http":                    CategoryWeb,
+	"https":                   CategoryWeb,
+	"http-alt":                CategoryWeb,
+	"https-alt":               CategoryWeb,
+	"amqp":                    CategoryMessaging,
+	"rabbitmq-management":     CategoryMessaging,
+	"ssh":                     CategoryRemoteAccess,
+	"telnet":                  CategoryRemoteAccess,
+	"rdp":                     CategoryRemoteAccess,
+	"smtp":                    CategoryMail,
+	"smtps":                   CategoryMail,
+	"smtp submission":         CategoryMail,
+	"pop3":                    CategoryMail,
+	"pop3s":                   CategoryMail,
+	"imap":                    CategoryMail,
+	"imaps":                   CategoryMail,
+	"ftp":                     CategoryFileTransfer,
+	"smb":                     CategoryFileTransfer,
+	"dns":                     CategoryInfra,
+}
+
+// webPorts are classified as web when the service name is not conclusive.
+var webPorts = map[int]bool{
+	80:   true,
+	443:  true,
+	8000: true,
+	8080: true,
+	8443: true,
+}
+
+// Add folds a service found on port into the summary, classifying it as
+// ServiceCategory does.
+func (h *HostSummary) Add(port int, service string) {
+	switch name := strings.ToLower(service); {
+	case name == "ssh" || (name == "" && port == 22):
+		h.HasSSH = true
+	case name == "rdp" || (name == "" && port == 3389):
+		h.HasRDP = true
+	}
+	switch ServiceCategory(port, service) {
+	case CategoryDatabase:
+		h.HasDatabase = true
+	case CategoryWeb:
+		h.HasWeb = true
+	}
+}
+
+// ServiceCategory classifies a service by its fingerprint name, falling back
+// to the port number when the name is unknown.
+func ServiceCategory(port int, service string) string {
+	if category, ok := serviceNameCategories[strings.ToLower(service)]; ok {
+		return category
+	}
+	if _, ok := databasePorts[port]; ok {
+		return CategoryDatabase
+	}
+	if webPorts[port] {
+		return CategoryWeb
+	}
+	return CategoryOther
+}
@@ -0,0 +1,179 @@
+package publisher
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestHostSummary(t *testing.T) {
+	type service struct {
+		port int
+		name string
+	}
+
+	tests := []struct {
+		name     string
+		services []service
+		want     HostSummary
+	}{
+		{name: "nothing open"},
+		{
+			name:     "ssh and rdp",
+			services: []service{{22, "SSH"}, {3389, "RDP"}},
+			want:     HostSummary{HasSSH: true, HasRDP: true},
+		},
+		{
+			name:     "unidentified ports fall back to the port",
+			services: []service{{22, ""}, {3389, ""}, {5432, ""}, {8080, ""}},
+			want:     HostSummary{HasSSH: true, HasRDP: true, HasDatabase: true, HasWeb: true},
+		},
+		{
+			name:     "ssh on another port",
+			services: []service{{2222, "SSH"}},
+			want:     HostSummary{HasSSH: true},
+		},
+		{
+			name:     "name beats the port",
+			services: []service{{22, "HTTP"}},
+			want:     HostSummary{HasWeb: true},
+		},
+		{
+			name:     "unrecognised name falls back to the port",
+			services: []service{{5432, "unknown"}},
+			want:     HostSummary{HasDatabase: true},
+		},
+		{
+			name:     "database and web",
+			services: []service{{3306, "MySQL"}, {9200, "Elasticsearch"}, {443, "HTTPS"}},
+			want:     HostSummary{HasDatabase: true, HasWeb: true},
+		},
+		{
+			name:     "other services",
+			services: []service{{25, "SMTP"}, {53, "DNS"}, {161, "SNMP"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got HostSummary
+			for _, svc := range tt.services {
+				got.Add(svc.port, svc.name)
+			}
+			if got != tt.want {
+				t.Errorf("summary = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHostSummaryJSON(t *testing.T) {
+	data, err := json.Marshal(ServerDiscoveredData{Summary: HostSummary{HasSSH: true, HasDatabase: true}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var event struct {
+		Summary map[string]bool `json:"summary"`
+	}
+	if err := json.Unmarshal(data, &event); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]bool{"has_ssh": true, "has_rdp": false, "has_db": true, "has_web": false}
+	for key, value := range want {
+		if got, ok := event.Summary[key]; !ok || got != value {
+			t.Errorf("summary.%s = %v (present %v), want %v", key, got, ok, value)
+		}
+	}
+}
+
+func TestServiceCategory(t *testing.T) {
+	tests := []struct {
+		port    int
+		service string
+		want    string
+	}{
+		{port: 5432, service: "PostgreSQL", want: CategoryDatabase},
+		{port: 5432, want: CategoryDatabase},
+		{port: 80, service: "HTTP", want: CategoryWeb},
+		{port: 8080, want: CategoryWeb},
+		{port: 22, service: "HTTP", want: CategoryWeb},
+		{port: 5672, service: "AMQP", want: CategoryMessaging},
+		{port: 22, service: "SSH", want: CategoryRemoteAccess},
+		{port: 25, service: "SMTP", want: CategoryMail},
+		{port: 21, service: "FTP", want: CategoryFileTransfer},
+		{port: 53, service: "DNS", want: CategoryInfra},
+		{port: 9999, service: "unknown", want: CategoryOther},
+	}
+	for _, tt := range tests {
+		if got := ServiceCategory(tt.port, tt.service); got != tt.want {
+			t.Errorf("ServiceCategory(%d, %q) = %q, want %q", tt.port, tt.service, got, tt.want)
+		}
+	}
+}
+
+func TestServiceRoutingKey(t *testing.T) {
+	tests := []struct {
+		name            string
+		categoryRouting bool
+		data            ServiceDiscoveredData
+		want            string
+	}{
+		{
+			name: "routing off",
+			data: ServiceDiscoveredData{Port: 5432, Service: "PostgreSQL"},
+			want: "discovered.service",
+		},
+		{
+			name:            "postgres",
+			categoryRouting: true,
+			data:            ServiceDiscoveredData{Port: 5432, Service: "PostgreSQL"},
+			want:            "discovered.service.database",
+		},
+		{
+			name:            "http",
+			categoryRouting: true,
+			data:            ServiceDiscoveredData{Port: 80, Service: "HTTP"},
+			want:            "discovered.service.web",
+		},
+		{
+			name:            "unidentified port",
+			categoryRouting: true,
+			data:            ServiceDiscoveredData{Port: 9999},
+			want:            "discovered.service.other",
+		},
+		{
+			name:            "category from metadata",
+			categoryRouting: true,
+			data:            ServiceDiscoveredData{Port: 9999, Metadata: map[string]interface{}{"service_category": CategoryDatabase}},
+			want:            "discovered.service.database",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := &eventBuilder{categoryRouting: tt.categoryRouting}
+			if _, got := b.serviceEvent(tt.data); got != tt.want {
+				t.Errorf("routing key = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// The routing key of a published scan result follows its port when the
+// scanner couldn't name the service.
+func TestScanResultRoutingKey(t *testing.T) {
+	tests := []struct {
+		port int
+		want string
+	}{
+		{port: 5432, want: "discovered.service.database"},
+		{port: 80, want: "discovered.service.web"},
+	}
+	for _, tt := range tests {
+		b := &eventBuilder{categoryRouting: true}
+		data, err := b.serviceData(testResult{ip: "10.0.0.5", port: tt.port})
+		if err != nil {
+			t.Fatalf("serviceData() error = %v", err)
+		}
+		if _, got := b.serviceEvent(data); got != tt.want {
+			t.Errorf("port %d: routing key = %q, want %q", tt.port, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,64 @@
+package publisher
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+// confirmChannel wraps a channel in publisher-confirm mode so each publish
+// returns only once the broker has acked it. Publishes are serialized so
+// each confirmation can be matched to its delivery tag.
+type confirmChannel struct {
+	amqpChannel
+
+	mu       sync.Mutex
+	confirms chan amqp.Confirmation
+	nextTag  uint64
+}
+
+func newConfirmChannel(channel amqpChannel) (*confirmChannel, error) {
+	if err := channel.Confirm(false); err != nil {
+		_ = channel.Close()
+		return nil, fmt.Errorf("failed to enable publisher confirms: %w", err)
+	}
+	return &confirmChannel{
+		amqpChannel: channel,
+		confirms:    channel.NotifyPublish(make(chan amqp.Confirmation, 1)),
+		nextTag:     1,
+	}, nil
+}
+
+// PublishWithContext publishes msg and waits for its confirmation until ctx
+// expires. A nack or timeout is returned as an error.
+func (c *confirmChannel) PublishWithContext(ctx context.Context, exchange, key string, mandatory, immediate bool, msg amqp.Publishing) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if err := c.amqpChannel.PublishWithContext(ctx, exchange, key, mandatory, immediate, msg); err != nil {
+		return err
+	}
+	tag := c.nextTag
+	c.nextTag++
+
+	for {
+		select {
+		case confirm, ok := <-c.confirms:
+			if !ok {
+				return amqp.ErrClosed
+			}
+			// Late confirmations for publishes that already timed out
+			if confirm.DeliveryTag < tag {
+				continue
+			}
+			if !confirm.Ack {
+				return fmt.Errorf("broker nacked event %s", msg.MessageId)
+			}
+			return nil
+		case <-ctx.Done():
+			return fmt.Errorf("timed out waiting for confirmation of event %s: %w", msg.MessageId, ctx.Err())
+		}
+	}
+}
@@ -0,0 +1,48 @@
+package publisher
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+func TestConfirmMode(t *testing.T) {
+	tests := []struct {
+		name    string
+		channel *fakeChannel
+		wantErr bool
+	}{
+		{name: "acked", channel: &fakeChannel{}},
+		{name: "nacked", channel: &fakeChannel{nack: true}, wantErr: true},
+		{name: "unconfirmed", channel: &fakeChannel{silent: true}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			channel, err := newConfirmChannel(tt.channel)
+			if err != nil {
+				t.Fatalf("newConfirmChannel() error = %v", err)
+			}
+
+			ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+			defer cancel()
+			err = channel.PublishWithContext(ctx, "discovery.events", "key", false, false, amqp.Publishing{MessageId: "event"})
+			if (err != nil) != tt.wantErr {
+				t.Errorf("PublishWithContext() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestConfirmModeNackFailsPublish(t *testing.T) {
+	conn := &fakeConn{channel: &fakeChannel{nack: true}}
+	p := newFakePublisher(t, conn)
+	if err := p.SetConfirmMode(true); err != nil {
+		t.Fatalf("SetConfirmMode() error = %v", err)
+	}
+
+	if err := p.PublishServerDiscovered(ServerDiscoveredData{}); err == nil {
+		t.Error("publish nacked by the broker returned no error")
+	}
+}
@@ -0,0 +1,78 @@
+package publisher
+
+import (
+	"fmt"
+	"strings"
+)
+
+// exchangeKind is the type of exchange declared by SetExchange. Routing
+// keys are dotted so consumers can bind with wildcards.
+const exchangeKind = "topic"
+
+// SetExchange sets the exchange events are published to. With declare the
+// exchange is declared (durable, topic) now and again on every reconnect;
+// otherwise it must already exist, which is checked now. Call it before
+// publishing.
+func (p *Publisher) SetExchange(name string, declare bool) error {
+	p.connMu.Lock()
+	conn := p.conn
+	p.connMu.Unlock()
+
+	if conn != nil {
+		if err := ensureExchange(conn, name, declare); err != nil {
+			return err
+		}
+	}
+
+	p.connMu.Lock()
+	defer p.connMu.Unlock()
+	p.exchange = name
+	p.declareExchange = declare
+	return nil
+}
+
+// ensureExchange declares the exchange, or checks that it exists, on a
+// channel of its own: a failed check closes the channel it ran on.
+func ensureExchange(conn amqpConnection, name string, declare bool) error {
+	if name == "" {
+		return nil // the default exchange always exists
+	}
+
+	channel, err := conn.Channel()
+	if err != nil {
+		return fmt.Errorf("failed to open channel: %w", err)
+	}
+	defer func() { _ = channel.Close() }()
+
+	if declare {
+		err = channel.ExchangeDeclare(name, exchangeKind, true, false, false, false, nil)
+	} else {
+		err = channel.ExchangeDeclarePassive(name, exchangeKind, true, false, false, false, nil)
+	}
+	if err != nil {
+		return fmt.Errorf("exchange %q: %w", name, err)
+	}
+	return nil
+}
+
+// SetRoutingKeyTemplate maps each routing key through template, in which
+// {key} is replaced by the routing key and {env} by env, e.g.
+// "{env}.{key}" publishes discovered.service as prod.discovered.service
+// when env is "prod". An empty template leaves routing keys unchanged.
+// Call it before publishing.
+func (p *Publisher) SetRoutingKeyTemplate(template, env string) {
+	if template == "" || template == "{key}" {
+		p.routingKeys = nil
+		return
+	}
+	p.routingKeys = &routingKeyTemplate{template: template, env: env}
+}
+
+type routingKeyTemplate struct {
+	template string
+	env      string
+}
+
+func (t *routingKeyTemplate) format(key string) string {
+	return strings.NewReplacer("{key}", key, "{env}", t.env).Replace(t.template)
+}
@@ -0,0 +1,97 @@
+package publisher
+
+import (
+	"reflect"
+	"testing"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+func TestSetExchange(t *testing.T) {
+	tests := []struct {
+		name         string
+		exchange     string
+		declare      bool
+		declareErr   error
+		wantErr      bool
+		wantDeclared []string
+		wantRoute    string
+	}{
+		{name: "declared", exchange: "inventory", declare: true, wantDeclared: []string{"declare inventory topic durable=true"}, wantRoute: "inventory discovered.service"},
+		{name: "must exist", exchange: "inventory", wantDeclared: []string{"passive inventory"}, wantRoute: "inventory discovered.service"},
+		{name: "missing", exchange: "inventory", declareErr: &amqp.Error{Code: amqp.NotFound, Reason: "no exchange 'inventory'"}, wantErr: true, wantRoute: "discovery.events discovered.service"},
+		{name: "default exchange", wantRoute: " discovered.service"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conn := &fakeConn{channel: &fakeChannel{declareErr: tt.declareErr}}
+			p := newFakePublisher(t, conn)
+			p.exchange = "discovery.events"
+
+			err := p.SetExchange(tt.exchange, tt.declare)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("SetExchange() error = %v, want error %v", err, tt.wantErr)
+			}
+			if err := p.publish(CloudEvent{ID: "1"}, "discovered.service"); err != nil {
+				t.Fatalf("publish() error = %v", err)
+			}
+
+			if got := conn.channel.declared; !reflect.DeepEqual(got, tt.wantDeclared) {
+				t.Errorf("declarations = %q, want %q", got, tt.wantDeclared)
+			}
+			// A missing exchange leaves the previous one in place
+			if got := conn.channel.routes; len(got) != 1 || got[0] != tt.wantRoute {
+				t.Errorf("routes = %q, want %q", got, tt.wantRoute)
+			}
+		})
+	}
+}
+
+func TestExchangeDeclaredOnReconnect(t *testing.T) {
+	first := &fakeConn{channel: &fakeChannel{}}
+	second := &fakeConn{channel: &fakeChannel{}}
+	p := newFakePublisher(t, first, second)
+	if err := p.SetExchange("inventory", true); err != nil {
+		t.Fatalf("SetExchange() error = %v", err)
+	}
+
+	// A restarted broker may have lost the exchange
+	first.drop()
+	waitConnected(t, p)
+	if err := p.publish(CloudEvent{ID: "after"}, "discovered.service"); err != nil {
+		t.Fatalf("publish() error = %v", err)
+	}
+
+	want := []string{"declare inventory topic durable=true"}
+	if got := second.channel.declared; !reflect.DeepEqual(got, want) {
+		t.Errorf("declarations after reconnect = %q, want %q", got, want)
+	}
+	if got := second.channel.routes; !reflect.DeepEqual(got, []string{"inventory discovered.service"}) {
+		t.Errorf("routes = %q, want the event on inventory", got)
+	}
+}
+
+func TestRoutingKeyTemplate(t *testing.T) {
+	tests := []struct {
+		template string
+		env      string
+		want     string
+	}{
+		{template: "{env}.{key}", env: "prod", want: "prod.discovered.service"},
+		{template: "scanner.{key}.{env}", env: "eu", want: "scanner.discovered.service.eu"},
+		{template: "{key}", env: "prod", want: "discovered.service"},
+		{template: "", want: "discovered.service"},
+	}
+	for _, tt := range tests {
+		conn := &fakeConn{channel: &fakeChannel{}}
+		p := newFakePublisher(t, conn)
+		p.SetRoutingKeyTemplate(tt.template, tt.env)
+
+		if err := p.publish(CloudEvent{ID: "1"}, "discovered.service"); err != nil {
+			t.Fatalf("publish() error = %v", err)
+		}
+		if got := conn.channel.routes; len(got) != 1 || got[0] != p.exchange+" "+tt.want {
+			t.Errorf("template %q: routes = %q, want routing key %q", tt.template, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,107 @@
+package publisher
+
+import (
+	"errors"
+
+	"github.com/aiforce-discovery-agent/collectors/network-scanner/internal/metrics"
+	"go.uber.org/zap"
+)
+
+// fanout publishes every event through a primary publisher and secondary
+// sinks, e.g. RabbitMQ and an HTTP sink. Only the primary's outcome counts:
+// a secondary failure is logged and counted but doesn't fail the publish,
+// so a flaky sink can't abort a scan whose events reached the broker.
+type fanout struct {
+	primary     EventPublisher
+	secondaries []EventPublisher
+	logger      *zap.SugaredLogger
+}
+
+// Fanout returns a publisher sending every event to primary and to each of
+// secondaries. The primary answers GetScanID and IsConnected.
+func Fanout(logger *zap.SugaredLogger, primary EventPublisher, secondaries ...EventPublisher) EventPublisher {
+	return &fanout{primary: primary, secondaries: secondaries, logger: logger}
+}
+
+// each runs fn on every publisher and returns the primary's error.
+func (f *fanout) each(op string, fn func(EventPublisher) error) error {
+	err := fn(f.primary)
+	for _, pub := range f.secondaries {
+		if serr := fn(pub); serr != nil {
+			metrics.SecondaryPublishFailures.Inc()
+			f.logger.Warnw("Secondary sink failed", "op", op, "error", serr)
+		}
+	}
+	return err
+}
+
+func (f *fanout) PublishServerDiscovered(data ServerDiscoveredData) error {
+	return f.each("publish", func(pub EventPublisher) error { return pub.PublishServerDiscovered(data) })
+}
+
+func (f *fanout) PublishServiceDiscovered(result interface{}) error {
+	return f.each("publish", func(pub EventPublisher) error { return pub.PublishServiceDiscovered(result) })
+}
+
+func (f *fanout) PublishHostDiscovered(data ServerDiscoveredData, results []interface{}) error {
+	return f.each("publish", func(pub EventPublisher) error { return pub.PublishHostDiscovered(data, results) })
+}
+
+func (f *fanout) all() []EventPublisher {
+	return append([]EventPublisher{f.primary}, f.secondaries...)
+}
+
+func (f *fanout) SetScanID(scanID string) {
+	for _, pub := range f.all() {
+		pub.SetScanID(scanID)
+	}
+}
+
+func (f *fanout) GetScanID() string {
+	return f.primary.GetScanID()
+}
+
+func (f *fanout) SetVantagePoint(vantagePoint string) {
+	for _, pub := range f.all() {
+		pub.SetVantagePoint(vantagePoint)
+	}
+}
+
+func (f *fanout) SetCategoryRouting(enabled bool) {
+	for _, pub := range f.all() {
+		pub.SetCategoryRouting(enabled)
+	}
+}
+
+func (f *fanout) Flush() error {
+	return f.each("flush", EventPublisher.Flush)
+}
+
+// Close closes every publisher and reports all of their errors.
+func (f *fanout) Close() error {
+	var errs []error
+	for _, pub := range f.all() {
+		if err := pub.Close(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// IsConnected reports whether the primary is connected.
+func (f *fanout) IsConnected() bool {
+	return f.primary.IsConnected()
+}
+
+// ForScan returns a fanout of each publisher's view for scanID.
+func (f *fanout) ForScan(scanID string) EventPublisher {
+	scoped := &fanout{
+		primary:     f.primary.ForScan(scanID),
+		secondaries: make([]EventPublisher, len(f.secondaries)),
+		logger:      f.logger,
+	}
+	for i, pub := range f.secondaries {
+		scoped.secondaries[i] = pub.ForScan(scanID)
+	}
+	return scoped
+}
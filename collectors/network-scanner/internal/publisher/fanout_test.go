@@ -0,0 +1,83 @@
+package publisher
+
+import (
+	"errors"
+	"testing"
+
+	"go.uber.org/zap"
+)
+
+// stubPublisher fails every call with err and counts publishes.
+type stubPublisher struct {
+	err       error
+	published int
+	connected bool
+}
+
+func (p *stubPublisher) PublishServerDiscovered(ServerDiscoveredData) error {
+	p.published++
+	return p.err
+}
+
+func (p *stubPublisher) PublishServiceDiscovered(interface{}) error {
+	p.published++
+	return p.err
+}
+
+func (p *stubPublisher) PublishHostDiscovered(ServerDiscoveredData, []interface{}) error {
+	p.published++
+	return p.err
+}
+
+func (p *stubPublisher) SetScanID(string)              {}
+func (p *stubPublisher) GetScanID() string             { return "" }
+func (p *stubPublisher) SetVantagePoint(string)        {}
+func (p *stubPublisher) SetCategoryRouting(bool)       {}
+func (p *stubPublisher) Flush() error                  { return p.err }
+func (p *stubPublisher) Close() error                  { return p.err }
+func (p *stubPublisher) IsConnected() bool             { return p.connected }
+func (p *stubPublisher) ForScan(string) EventPublisher { return p }
+
+func TestFanoutReportsPrimaryOnly(t *testing.T) {
+	errPrimary := errors.New("broker down")
+	errSink := errors.New("sink down")
+
+	tests := []struct {
+		name      string
+		primary   error
+		secondary error
+		wantErr   error
+	}{
+		{name: "all succeed"},
+		{name: "secondary fails", secondary: errSink},
+		{name: "primary fails", primary: errPrimary, wantErr: errPrimary},
+		{name: "both fail", primary: errPrimary, secondary: errSink, wantErr: errPrimary},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			primary := &stubPublisher{err: tt.primary, connected: true}
+			secondary := &stubPublisher{err: tt.secondary}
+			f := Fanout(zap.NewNop().Sugar(), primary, secondary).ForScan("scan-1")
+
+			if err := f.PublishServiceDiscovered(struct{}{}); !errors.Is(err, tt.wantErr) || (err == nil) != (tt.wantErr == nil) {
+				t.Errorf("PublishServiceDiscovered() error = %v, want %v", err, tt.wantErr)
+			}
+			if err := f.PublishServerDiscovered(ServerDiscoveredData{}); (err == nil) != (tt.wantErr == nil) {
+				t.Errorf("PublishServerDiscovered() error = %v, want %v", err, tt.wantErr)
+			}
+			if err := f.Flush(); (err == nil) != (tt.wantErr == nil) {
+				t.Errorf("Flush() error = %v, want %v", err, tt.wantErr)
+			}
+			if primary.published != 2 || secondary.published != 2 {
+				t.Errorf("published primary %d, secondary %d; want 2 each", primary.published, secondary.published)
+			}
+			if !f.IsConnected() {
+				t.Error("IsConnected() = false with the primary connected")
+			}
+			if err := f.Close(); errors.Is(err, errSink) != (tt.secondary != nil) {
+				t.Errorf("Close() error = %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,237 @@
+package publisher
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/aiforce-discovery-agent/collectors/network-scanner/internal/metrics"
+	"go.uber.org/zap"
+)
+
+// FileSinkOptions configures the file sink publisher.
+type FileSinkOptions struct {
+	Path       string
+	MaxBytes   int64 // Rotate once the file would grow past this; 0 never rotates
+	MaxBackups int   // Rotated files kept as Path.1 (newest) to Path.N; 0 keeps 1
+}
+
+// fileSinkRotateRetry is how long a file sink whose rotation failed keeps
+// writing to the current file before trying to rotate again.
+const fileSinkRotateRetry = time.Minute
+
+// FileSink appends CloudEvents to a local file as NDJSON, one
+// structured-mode event per line, for runs without a broker. Writes from
+// concurrent workers are serialized so lines never interleave.
+type FileSink struct {
+	eventBuilder
+
+	path       string
+	maxBytes   int64
+	maxBackups int
+	logger     *zap.SugaredLogger
+
+	mu   sync.Mutex
+	file *os.File
+	size int64
+
+	// rotateAfter holds rotation back after a failed attempt
+	rotateAfter time.Time
+}
+
+// NewFileSink creates a new FileSink appending to opts.Path, which is
+// created if it doesn't exist.
+func NewFileSink(opts FileSinkOptions, logger *zap.SugaredLogger) (*FileSink, error) {
+	if opts.Path == "" {
+		return nil, fmt.Errorf("file sink path is required")
+	}
+
+	maxBackups := opts.MaxBackups
+	if maxBackups <= 0 {
+		maxBackups = 1
+	}
+
+	p := &FileSink{
+		path:       opts.Path,
+		maxBytes:   opts.MaxBytes,
+		maxBackups: maxBackups,
+		logger:     logger,
+	}
+	if err := p.open(); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+func (p *FileSink) open() error {
+	file, size, err := openAppend(p.path)
+	if err != nil {
+		return err
+	}
+	p.file = file
+	p.size = size
+	return nil
+}
+
+// openAppend opens path for appending, creating it if needed, and returns
+// its current size.
+func openAppend(path string) (*os.File, int64, error) {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to open file sink: %w", err)
+	}
+	info, err := file.Stat()
+	if err != nil {
+		_ = file.Close()
+		return nil, 0, fmt.Errorf("failed to open file sink: %w", err)
+	}
+	return file, info.Size(), nil
+}
+
+// Close closes the file.
+func (p *FileSink) Close() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.file == nil {
+		return nil
+	}
+	err := p.file.Close()
+	p.file = nil
+	return err
+}
+
+// Flush syncs the file to disk.
+func (p *FileSink) Flush() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.file == nil {
+		return nil
+	}
+	return p.file.Sync()
+}
+
+// IsConnected reports whether the file is open.
+func (p *FileSink) IsConnected() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.file != nil
+}
+
+// PublishServerDiscovered publishes a server discovered event.
+func (p *FileSink) PublishServerDiscovered(data ServerDiscoveredData) error {
+	event, routingKey := p.serverEvent(data)
+	return p.publish(event, routingKey)
+}
+
+// PublishServiceDiscovered publishes a service discovered event, followed
+// by a database candidate event for database ports.
+func (p *FileSink) PublishServiceDiscovered(result interface{}) error {
+	data, err := p.serviceData(result)
+	if err != nil {
+		return err
+	}
+
+	event, routingKey := p.serviceEvent(data)
+	if err := p.publish(event, routingKey); err != nil {
+		return err
+	}
+	p.publishCandidates(p.publish, p.logger, data)
+	return nil
+}
+
+// PublishHostDiscovered publishes one consolidated event for a host and
+// all of its services, then a candidate event per database service.
+func (p *FileSink) PublishHostDiscovered(data ServerDiscoveredData, results []interface{}) error {
+	event, routingKey, services, err := p.hostEvent(data, results)
+	if err != nil {
+		return err
+	}
+	if err := p.publish(event, routingKey); err != nil {
+		return err
+	}
+	p.publishCandidates(p.publish, p.logger, services...)
+	return nil
+}
+
+// ForScan returns a publisher for one scan's events.
+func (p *FileSink) ForScan(scanID string) EventPublisher {
+	return newScanPublisher(p.eventBuilder, scanID, p.publish, p.IsConnected, p.logger)
+}
+
+// publish appends event as one line, rotating first if the line would take
+// the file past maxBytes. If rotation fails the line goes to the current
+// file and rotation is retried after fileSinkRotateRetry, so a full or
+// read-only backup directory never stops publishing. The routing key is
+// not recorded; consumers select on type.
+func (p *FileSink) publish(event CloudEvent, routingKey string) error {
+	line, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("failed to marshal event: %w", err)
+	}
+	line = append(line, '\n')
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.file == nil {
+		metrics.PublishFailures.Inc()
+		return fmt.Errorf("failed to publish event: file sink closed")
+	}
+	if p.maxBytes > 0 && p.size > 0 && p.size+int64(len(line)) > p.maxBytes && !time.Now().Before(p.rotateAfter) {
+		if err := p.rotate(); err != nil {
+			p.rotateAfter = time.Now().Add(fileSinkRotateRetry)
+			p.logger.Errorw("Failed to rotate file sink, writing to the current file",
+				"path", p.path, "retry_in", fileSinkRotateRetry, "error", err)
+		}
+	}
+
+	n, err := p.file.Write(line)
+	p.size += int64(n)
+	if err != nil {
+		metrics.PublishFailures.Inc()
+		return fmt.Errorf("failed to publish event: %w", err)
+	}
+
+	p.logger.Debugw("Event published",
+		"type", event.Type,
+		"id", event.ID,
+		"routing_key", routingKey,
+	)
+
+	return nil
+}
+
+// rotate shifts Path.1..Path.N-1 up by one, dropping the oldest, moves the
+// current file to Path.1 and reopens Path. The current file stays open
+// until the new one is, so on error the sink keeps writing where it was.
+// Called with mu held.
+func (p *FileSink) rotate() error {
+	for i := p.maxBackups - 1; i >= 1; i-- {
+		from := fmt.Sprintf("%s.%d", p.path, i)
+		if err := os.Rename(from, fmt.Sprintf("%s.%d", p.path, i+1)); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	if err := os.Rename(p.path, p.path+".1"); err != nil {
+		return err
+	}
+	file, size, err := openAppend(p.path)
+	if err != nil {
+		// Put the current file back so it is still the one at Path
+		if rerr := os.Rename(p.path+".1", p.path); rerr != nil {
+			p.logger.Errorw("Failed to restore file sink after rotation", "path", p.path, "error", rerr)
+		}
+		return err
+	}
+
+	if err := p.file.Close(); err != nil {
+		p.logger.Warnw("Failed to close rotated file sink", "path", p.path+".1", "error", err)
+	}
+	p.file = file
+	p.size = size
+	return nil
+}
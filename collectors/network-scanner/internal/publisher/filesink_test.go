@@ -0,0 +1,116 @@
+package publisher
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"go.uber.org/zap"
+)
+
+// readEvents decodes every NDJSON line of path.
+func readEvents(t *testing.T, path string) []CloudEvent {
+	t.Helper()
+
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = f.Close() }()
+
+	var events []CloudEvent
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var event CloudEvent
+		if err := json.Unmarshal(scanner.Bytes(), &event); err != nil {
+			t.Fatalf("line %q is not a CloudEvent: %v", scanner.Text(), err)
+		}
+		events = append(events, event)
+	}
+	if err := scanner.Err(); err != nil {
+		t.Fatal(err)
+	}
+	return events
+}
+
+func TestFileSinkWritesNDJSON(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "events.ndjson")
+	sink, err := NewFileSink(FileSinkOptions{Path: path}, zap.NewNop().Sugar())
+	if err != nil {
+		t.Fatal(err)
+	}
+	pub := sink.ForScan("scan-1")
+
+	for _, id := range []string{"a", "b", "c"} {
+		if err := pub.PublishServerDiscovered(ServerDiscoveredData{ServerID: id, IPAddresses: []string{"192.0.2.1"}}); err != nil {
+			t.Fatalf("PublishServerDiscovered() error = %v", err)
+		}
+	}
+	if err := sink.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	events := readEvents(t, path)
+	if len(events) != 3 {
+		t.Fatalf("read back %d events, want 3", len(events))
+	}
+	for i, event := range events {
+		if event.Type != "discovery.server.discovered" || event.Subject != "scan-1" || event.ID == "" {
+			t.Errorf("event %d = type %q subject %q id %q", i, event.Type, event.Subject, event.ID)
+		}
+		data, _ := event.Data.(map[string]interface{})
+		if want := []string{"a", "b", "c"}[i]; data["server_id"] != want {
+			t.Errorf("event %d server_id = %v, want %s", i, data["server_id"], want)
+		}
+	}
+}
+
+func TestFileSinkRotation(t *testing.T) {
+	tests := []struct {
+		name        string
+		blockBackup bool // make Path.1 a directory so rotation fails
+		wantCurrent int
+		wantBackup  int
+	}{
+		{name: "rotates past max size", wantCurrent: 1, wantBackup: 1},
+		{name: "keeps writing when rotation fails", blockBackup: true, wantCurrent: 3},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "events.ndjson")
+			if tt.blockBackup {
+				if err := os.MkdirAll(filepath.Join(path+".1", "keep"), 0o755); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			sink, err := NewFileSink(FileSinkOptions{Path: path, MaxBytes: 1, MaxBackups: 1}, zap.NewNop().Sugar())
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer func() { _ = sink.Close() }()
+
+			// Each event after the first would take the file past MaxBytes
+			for i := 0; i < 3; i++ {
+				if err := sink.PublishServerDiscovered(ServerDiscoveredData{ServerID: "s"}); err != nil {
+					t.Fatalf("publish %d error = %v", i, err)
+				}
+			}
+			if !sink.IsConnected() {
+				t.Fatal("sink closed itself")
+			}
+
+			if got := len(readEvents(t, path)); got != tt.wantCurrent {
+				t.Errorf("events in current file = %d, want %d", got, tt.wantCurrent)
+			}
+			if !tt.blockBackup {
+				if got := len(readEvents(t, path+".1")); got != tt.wantBackup {
+					t.Errorf("events in backup = %d, want %d", got, tt.wantBackup)
+				}
+			}
+		})
+	}
+}
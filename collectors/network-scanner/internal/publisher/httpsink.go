@@ -0,0 +1,152 @@
+package publisher
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/aiforce-discovery-agent/collectors/network-scanner/internal/metrics"
+	"go.uber.org/zap"
+)
+
+// HTTPSinkOptions configures the HTTP sink publisher.
+type HTTPSinkOptions struct {
+	URL     string
+	Timeout time.Duration // Per request; 0 uses 10s
+}
+
+// HTTPSink POSTs CloudEvents to an HTTP ingestion endpoint in binary
+// content mode: event attributes travel as ce-* headers and the event data
+// is the JSON request body. Any 2xx response counts as delivered.
+type HTTPSink struct {
+	eventBuilder
+
+	url    string
+	client *http.Client
+	logger *zap.SugaredLogger
+}
+
+// NewHTTPSink creates a new HTTPSink posting to opts.URL.
+func NewHTTPSink(opts HTTPSinkOptions, logger *zap.SugaredLogger) (*HTTPSink, error) {
+	parsed, err := url.Parse(opts.URL)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		return nil, fmt.Errorf("invalid HTTP sink URL %q", opts.URL)
+	}
+
+	timeout := opts.Timeout
+	if timeout <= 0 {
+		timeout = 10 * time.Second
+	}
+
+	return &HTTPSink{
+		url:    opts.URL,
+		client: &http.Client{Timeout: timeout},
+		logger: logger,
+	}, nil
+}
+
+// Close releases idle connections.
+func (p *HTTPSink) Close() error {
+	p.client.CloseIdleConnections()
+	return nil
+}
+
+// Flush is a no-op; every event is sent synchronously.
+func (p *HTTPSink) Flush() error {
+	return nil
+}
+
+// IsConnected is always true: the sink holds no connection, and a
+// failing endpoint shows up as publish errors instead.
+func (p *HTTPSink) IsConnected() bool {
+	return true
+}
+
+// PublishServerDiscovered publishes a server discovered event.
+func (p *HTTPSink) PublishServerDiscovered(data ServerDiscoveredData) error {
+	event, routingKey := p.serverEvent(data)
+	return p.publish(event, routingKey)
+}
+
+// PublishServiceDiscovered publishes a service discovered event, followed
+// by a database candidate event for database ports.
+func (p *HTTPSink) PublishServiceDiscovered(result interface{}) error {
+	data, err := p.serviceData(result)
+	if err != nil {
+		return err
+	}
+
+	event, routingKey := p.serviceEvent(data)
+	if err := p.publish(event, routingKey); err != nil {
+		return err
+	}
+	p.publishCandidates(p.publish, p.logger, data)
+	return nil
+}
+
+// PublishHostDiscovered publishes one consolidated event for a host and
+// all of its services, then a candidate event per database service.
+func (p *HTTPSink) PublishHostDiscovered(data ServerDiscoveredData, results []interface{}) error {
+	event, routingKey, services, err := p.hostEvent(data, results)
+	if err != nil {
+		return err
+	}
+	if err := p.publish(event, routingKey); err != nil {
+		return err
+	}
+	p.publishCandidates(p.publish, p.logger, services...)
+	return nil
+}
+
+// ForScan returns a publisher for one scan's events.
+func (p *HTTPSink) ForScan(scanID string) EventPublisher {
+	return newScanPublisher(p.eventBuilder, scanID, p.publish, p.IsConnected, p.logger)
+}
+
+// publish sends event in binary content mode. The routing key has no
+// equivalent over HTTP; consumers select on ce-type.
+func (p *HTTPSink) publish(event CloudEvent, routingKey string) error {
+	body, err := json.Marshal(event.Data)
+	if err != nil {
+		return fmt.Errorf("failed to marshal event data: %w", err)
+	}
+
+	req, err := http.NewRequest(http.MethodPost, p.url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to build request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("ce-specversion", event.SpecVersion)
+	req.Header.Set("ce-type", event.Type)
+	req.Header.Set("ce-source", event.Source)
+	req.Header.Set("ce-id", event.ID)
+	req.Header.Set("ce-time", event.Time)
+	if event.Subject != "" {
+		req.Header.Set("ce-subject", event.Subject)
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		metrics.PublishFailures.Inc()
+		return fmt.Errorf("failed to publish event: %w", err)
+	}
+	_, _ = io.Copy(io.Discard, resp.Body)
+	_ = resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		metrics.PublishFailures.Inc()
+		return fmt.Errorf("failed to publish event: HTTP sink returned %d", resp.StatusCode)
+	}
+
+	p.logger.Debugw("Event published",
+		"type", event.Type,
+		"id", event.ID,
+		"routing_key", routingKey,
+	)
+
+	return nil
+}
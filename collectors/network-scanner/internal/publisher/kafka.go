@@ -0,0 +1,139 @@
+package publisher
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/aiforce-discovery-agent/collectors/network-scanner/internal/metrics"
+	"github.com/segmentio/kafka-go"
+	"go.uber.org/zap"
+)
+
+// KafkaOptions configures the Kafka publisher.
+type KafkaOptions struct {
+	Brokers []string
+	Topic   string
+}
+
+// KafkaPublisher sends CloudEvents to a Kafka topic. Messages are keyed by
+// scan ID, so one scan's events stay in order on a single partition, and
+// carry the routing key as a header for consumers that filter on it.
+type KafkaPublisher struct {
+	eventBuilder
+
+	writer *kafka.Writer
+	logger *zap.SugaredLogger
+}
+
+// NewKafka creates a new KafkaPublisher. Brokers are connected to lazily
+// on the first publish.
+func NewKafka(opts KafkaOptions, logger *zap.SugaredLogger) (*KafkaPublisher, error) {
+	if len(opts.Brokers) == 0 {
+		return nil, fmt.Errorf("at least one Kafka broker is required")
+	}
+	if opts.Topic == "" {
+		return nil, fmt.Errorf("kafka topic is required")
+	}
+
+	return &KafkaPublisher{
+		writer: &kafka.Writer{
+			Addr:         kafka.TCP(opts.Brokers...),
+			Topic:        opts.Topic,
+			Balancer:     &kafka.Hash{},
+			RequiredAcks: kafka.RequireOne,
+			// Each publish is written synchronously; don't wait to fill a batch
+			BatchTimeout: 10 * time.Millisecond,
+		},
+		logger: logger,
+	}, nil
+}
+
+// Close flushes pending writes and closes the broker connections.
+func (p *KafkaPublisher) Close() error {
+	return p.writer.Close()
+}
+
+// Flush is a no-op; every event is written synchronously.
+func (p *KafkaPublisher) Flush() error {
+	return nil
+}
+
+// IsConnected is always true: connections are opened per write, and an
+// unreachable cluster shows up as publish errors instead.
+func (p *KafkaPublisher) IsConnected() bool {
+	return true
+}
+
+// PublishServerDiscovered publishes a server discovered event.
+func (p *KafkaPublisher) PublishServerDiscovered(data ServerDiscoveredData) error {
+	event, routingKey := p.serverEvent(data)
+	return p.publish(event, routingKey)
+}
+
+// PublishServiceDiscovered publishes a service discovered event, followed
+// by a database candidate event for database ports.
+func (p *KafkaPublisher) PublishServiceDiscovered(result interface{}) error {
+	data, err := p.serviceData(result)
+	if err != nil {
+		return err
+	}
+
+	event, routingKey := p.serviceEvent(data)
+	if err := p.publish(event, routingKey); err != nil {
+		return err
+	}
+	p.publishCandidates(p.publish, p.logger, data)
+	return nil
+}
+
+// PublishHostDiscovered publishes one consolidated event for a host and
+// all of its services, then a candidate event per database service.
+func (p *KafkaPublisher) PublishHostDiscovered(data ServerDiscoveredData, results []interface{}) error {
+	event, routingKey, services, err := p.hostEvent(data, results)
+	if err != nil {
+		return err
+	}
+	if err := p.publish(event, routingKey); err != nil {
+		return err
+	}
+	p.publishCandidates(p.publish, p.logger, services...)
+	return nil
+}
+
+// ForScan returns a publisher for one scan's events.
+func (p *KafkaPublisher) ForScan(scanID string) EventPublisher {
+	return newScanPublisher(p.eventBuilder, scanID, p.publish, p.IsConnected, p.logger)
+}
+
+func (p *KafkaPublisher) publish(event CloudEvent, routingKey string) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("failed to marshal event: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	err = p.writer.WriteMessages(ctx, kafka.Message{
+		Key:   []byte(event.Subject),
+		Value: body,
+		Headers: []kafka.Header{
+			{Key: "content-type", Value: []byte("application/cloudevents+json")},
+			{Key: "routing_key", Value: []byte(routingKey)},
+		},
+	})
+	if err != nil {
+		metrics.PublishFailures.Inc()
+		return fmt.Errorf("failed to publish event: %w", err)
+	}
+
+	p.logger.Debugw("Event published",
+		"type", event.Type,
+		"id", event.ID,
+		"routing_key", routingKey,
+	)
+
+	return nil
+}
@@ -0,0 +1,179 @@
+package publisher
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/aiforce-discovery-agent/collectors/network-scanner/internal/metrics"
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
+	"go.uber.org/zap"
+)
+
+// NATSOptions configures the NATS JetStream publisher.
+type NATSOptions struct {
+	URL           string
+	SubjectPrefix string // Prepended to routing keys to form subjects
+	Stream        string // Created or updated on connect when set
+	CredsFile     string // NATS .creds file (JWT + NKey seed)
+	TokenFile     string // File containing an auth token
+}
+
+// NATSPublisher sends CloudEvents to NATS JetStream. Routing keys map to
+// subjects as <subject_prefix>.<routing_key>, e.g.
+// discovery.events.discovered.service.
+type NATSPublisher struct {
+	eventBuilder
+
+	conn          *nats.Conn
+	js            jetstream.JetStream
+	subjectPrefix string
+	logger        *zap.SugaredLogger
+}
+
+// NewNATS creates a new NATSPublisher connected to a NATS server.
+func NewNATS(opts NATSOptions, logger *zap.SugaredLogger) (*NATSPublisher, error) {
+	natsOpts := []nats.Option{nats.Name("network-scanner")}
+	if opts.CredsFile != "" {
+		natsOpts = append(natsOpts, nats.UserCredentials(opts.CredsFile))
+	}
+	if opts.TokenFile != "" {
+		token, err := os.ReadFile(opts.TokenFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read NATS token file: %w", err)
+		}
+		natsOpts = append(natsOpts, nats.Token(strings.TrimSpace(string(token))))
+	}
+
+	conn, err := nats.Connect(opts.URL, natsOpts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to NATS: %w", err)
+	}
+
+	js, err := jetstream.New(conn)
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("failed to create JetStream context: %w", err)
+	}
+
+	prefix := strings.TrimSuffix(opts.SubjectPrefix, ".")
+	if opts.Stream != "" {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		_, err := js.CreateOrUpdateStream(ctx, jetstream.StreamConfig{
+			Name:     opts.Stream,
+			Subjects: []string{prefix + ".>"},
+		})
+		if err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("failed to ensure stream %s: %w", opts.Stream, err)
+		}
+	}
+
+	return &NATSPublisher{
+		conn:          conn,
+		js:            js,
+		subjectPrefix: prefix,
+		logger:        logger,
+	}, nil
+}
+
+// Close drains and closes the NATS connection.
+func (p *NATSPublisher) Close() error {
+	if p.conn != nil {
+		return p.conn.Drain()
+	}
+	return nil
+}
+
+// Flush is a no-op; NATS events are not batched.
+func (p *NATSPublisher) Flush() error {
+	return nil
+}
+
+// PublishServerDiscovered publishes a server discovered event.
+func (p *NATSPublisher) PublishServerDiscovered(data ServerDiscoveredData) error {
+	event, routingKey := p.serverEvent(data)
+	return p.publish(event, routingKey)
+}
+
+// PublishServiceDiscovered publishes a service discovered event, followed
+// by a database candidate event for database ports.
+func (p *NATSPublisher) PublishServiceDiscovered(result interface{}) error {
+	data, err := p.serviceData(result)
+	if err != nil {
+		return err
+	}
+
+	event, routingKey := p.serviceEvent(data)
+	if err := p.publish(event, routingKey); err != nil {
+		return err
+	}
+	p.publishCandidates(p.publish, p.logger, data)
+	return nil
+}
+
+// PublishHostDiscovered publishes one consolidated event for a host and
+// all of its services, then a candidate event per database service.
+func (p *NATSPublisher) PublishHostDiscovered(data ServerDiscoveredData, results []interface{}) error {
+	event, routingKey, services, err := p.hostEvent(data, results)
+	if err != nil {
+		return err
+	}
+	if err := p.publish(event, routingKey); err != nil {
+		return err
+	}
+	p.publishCandidates(p.publish, p.logger, services...)
+	return nil
+}
+
+// IsConnected reports whether the NATS connection is up.
+func (p *NATSPublisher) IsConnected() bool {
+	return p.conn != nil && p.conn.IsConnected()
+}
+
+// ForScan returns a publisher for one scan's events.
+func (p *NATSPublisher) ForScan(scanID string) EventPublisher {
+	return newScanPublisher(p.eventBuilder, scanID, p.publish, p.IsConnected, p.logger)
+}
+
+// subject maps a routing key onto the configured subject prefix.
+func (p *NATSPublisher) subject(routingKey string) string {
+	if p.subjectPrefix == "" {
+		return routingKey
+	}
+	return p.subjectPrefix + "." + routingKey
+}
+
+func (p *NATSPublisher) publish(event CloudEvent, routingKey string) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("failed to marshal event: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	msg := nats.NewMsg(p.subject(routingKey))
+	msg.Data = body
+	msg.Header.Set("Content-Type", "application/cloudevents+json")
+
+	// Msg ID lets JetStream de-duplicate retried publishes
+	if _, err := p.js.PublishMsg(ctx, msg, jetstream.WithMsgID(event.ID)); err != nil {
+		metrics.PublishFailures.Inc()
+		return fmt.Errorf("failed to publish event: %w", err)
+	}
+
+	p.logger.Debugw("Event published",
+		"type", event.Type,
+		"id", event.ID,
+		"subject", msg.Subject,
+	)
+
+	return nil
+}
@@ -0,0 +1,263 @@
+package publisher
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"go.uber.org/zap"
+)
+
+// natsMsg is a message published to the stub server.
+type natsMsg struct {
+	subject string
+	header  string
+	data    []byte
+}
+
+// natsStub speaks enough of the NATS client protocol for the publisher:
+// connect, subscribe to a reply inbox, and publish. It answers JetStream
+// stream create/update requests and acks publishes to subjects a stream
+// covers; publishes to other subjects get no responders.
+type natsStub struct {
+	ln net.Listener
+
+	mu       sync.Mutex
+	connects []map[string]interface{}
+	streams  map[string][]string // name -> subjects
+	msgs     []natsMsg
+}
+
+func newNATSStub(t *testing.T) *natsStub {
+	t.Helper()
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	stub := &natsStub{ln: ln, streams: make(map[string][]string)}
+	t.Cleanup(func() { _ = ln.Close() })
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			t.Cleanup(func() { _ = conn.Close() })
+			go stub.serve(conn)
+		}
+	}()
+	return stub
+}
+
+func (s *natsStub) url() string {
+	return "nats://" + s.ln.Addr().String()
+}
+
+func (s *natsStub) messages() []natsMsg {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]natsMsg(nil), s.msgs...)
+}
+
+func (s *natsStub) serve(conn net.Conn) {
+	var writeMu sync.Mutex
+	write := func(format string, args ...interface{}) {
+		writeMu.Lock()
+		defer writeMu.Unlock()
+		_, _ = fmt.Fprintf(conn, format, args...)
+	}
+	write("INFO {\"server_id\":\"stub\",\"version\":\"2.10.0\",\"proto\":1,\"headers\":true,\"max_payload\":1048576,\"jetstream\":true}\r\n")
+
+	subs := make(map[string]string) // subject -> sid
+	reply := func(subject string, payload []byte, header string) {
+		for pattern, sid := range subs {
+			if subject == pattern || (strings.HasSuffix(pattern, ".*") && strings.HasPrefix(subject, strings.TrimSuffix(pattern, "*"))) {
+				if header != "" {
+					write("HMSG %s %s %d %d\r\n%s%s\r\n", subject, sid, len(header), len(header)+len(payload), header, payload)
+				} else {
+					write("MSG %s %s %d\r\n%s\r\n", subject, sid, len(payload), payload)
+				}
+				return
+			}
+		}
+	}
+
+	r := bufio.NewReader(conn)
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		switch strings.ToUpper(fields[0]) {
+		case "CONNECT":
+			var info map[string]interface{}
+			_ = json.Unmarshal([]byte(strings.TrimSpace(strings.TrimPrefix(line, fields[0]))), &info)
+			s.mu.Lock()
+			s.connects = append(s.connects, info)
+			s.mu.Unlock()
+		case "PING":
+			write("PONG\r\n")
+		case "SUB":
+			subs[fields[1]] = fields[len(fields)-1]
+		case "PUB", "HPUB":
+			hdrLen := 0
+			if fields[0] == "HPUB" {
+				hdrLen, _ = strconv.Atoi(fields[len(fields)-2])
+			}
+			total, _ := strconv.Atoi(fields[len(fields)-1])
+			body := make([]byte, total+2)
+			if _, err := io.ReadFull(r, body); err != nil {
+				return
+			}
+			// PUB <subject> [reply] <size>; HPUB adds the header size
+			replyTo := ""
+			if (fields[0] == "PUB" && len(fields) == 4) || (fields[0] == "HPUB" && len(fields) == 5) {
+				replyTo = fields[2]
+			}
+			payload, ack, header := s.handle(natsMsg{subject: fields[1], header: string(body[:hdrLen]), data: body[hdrLen:total]})
+			if replyTo != "" && (ack || header != "") {
+				reply(replyTo, payload, header)
+			}
+		}
+	}
+}
+
+// handle processes a publish and returns the reply to send, if any.
+func (s *natsStub) handle(msg natsMsg) (payload []byte, ack bool, header string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	switch {
+	case strings.HasPrefix(msg.subject, "$JS.API.STREAM.UPDATE."):
+		name := strings.TrimPrefix(msg.subject, "$JS.API.STREAM.UPDATE.")
+		if _, ok := s.streams[name]; !ok {
+			return []byte(`{"type":"io.nats.jetstream.api.v1.stream_update_response","error":{"code":404,"err_code":10059,"description":"stream not found"}}`), true, ""
+		}
+		fallthrough
+	case strings.HasPrefix(msg.subject, "$JS.API.STREAM.CREATE."):
+		var cfg struct {
+			Name     string   `json:"name"`
+			Subjects []string `json:"subjects"`
+		}
+		_ = json.Unmarshal(msg.data, &cfg)
+		s.streams[cfg.Name] = cfg.Subjects
+		return []byte(fmt.Sprintf(`{"type":"io.nats.jetstream.api.v1.stream_create_response","config":%s,`+
+			`"created":"2026-01-01T00:00:00Z","state":{"messages":0,"bytes":0,"first_seq":0,"last_seq":0,"consumer_count":0}}`, msg.data)), true, ""
+	}
+
+	for name, subjects := range s.streams {
+		for _, subject := range subjects {
+			if strings.HasPrefix(msg.subject, strings.TrimSuffix(subject, ">")) {
+				s.msgs = append(s.msgs, msg)
+				return []byte(fmt.Sprintf(`{"stream":%q,"seq":%d}`, name, len(s.msgs))), true, ""
+			}
+		}
+	}
+	return nil, false, "NATS/1.0 503\r\n\r\n"
+}
+
+func TestNATSPublisherPublishesToStream(t *testing.T) {
+	stub := newNATSStub(t)
+	p, err := NewNATS(NATSOptions{URL: stub.url(), SubjectPrefix: "discovery.events.", Stream: "DISCOVERY"}, zap.NewNop().Sugar())
+	if err != nil {
+		t.Fatalf("NewNATS() error = %v", err)
+	}
+	defer func() { _ = p.Close() }()
+
+	if !p.IsConnected() {
+		t.Error("IsConnected() = false after connecting")
+	}
+	stub.mu.Lock()
+	subjects := stub.streams["DISCOVERY"]
+	stub.mu.Unlock()
+	if len(subjects) != 1 || subjects[0] != "discovery.events.>" {
+		t.Fatalf("stream subjects = %v, want [discovery.events.>]", subjects)
+	}
+
+	if err := p.PublishServiceDiscovered(testResult{ip: "10.0.0.5", port: 22}); err != nil {
+		t.Fatalf("PublishServiceDiscovered() error = %v", err)
+	}
+	// A database port is followed by its candidate event
+	if err := p.PublishServiceDiscovered(testResult{ip: "10.0.0.5", port: 5432}); err != nil {
+		t.Fatalf("PublishServiceDiscovered() error = %v", err)
+	}
+
+	msgs := stub.messages()
+	var got []string
+	for _, msg := range msgs {
+		got = append(got, msg.subject)
+	}
+	want := []string{
+		"discovery.events.discovered.service",
+		"discovery.events.discovered.service",
+		"discovery.events.candidate.database",
+	}
+	if strings.Join(got, " ") != strings.Join(want, " ") {
+		t.Fatalf("subjects = %v, want %v", got, want)
+	}
+
+	var event CloudEvent
+	if err := json.Unmarshal(msgs[0].data, &event); err != nil {
+		t.Fatalf("decoding event: %v", err)
+	}
+	if event.Type != "discovery.service.discovered" || event.ID == "" {
+		t.Errorf("event = %+v, want a service event with an ID", event)
+	}
+	// JetStream de-duplicates on the message ID
+	if !strings.Contains(msgs[0].header, "Nats-Msg-Id: "+event.ID) {
+		t.Errorf("headers %q lack the event ID as Nats-Msg-Id", msgs[0].header)
+	}
+	if !strings.Contains(msgs[0].header, "Content-Type: application/cloudevents+json") {
+		t.Errorf("headers %q lack the CloudEvents content type", msgs[0].header)
+	}
+}
+
+func TestNATSPublishWithoutStreamFails(t *testing.T) {
+	stub := newNATSStub(t)
+	p, err := NewNATS(NATSOptions{URL: stub.url(), SubjectPrefix: "discovery.events"}, zap.NewNop().Sugar())
+	if err != nil {
+		t.Fatalf("NewNATS() error = %v", err)
+	}
+	defer func() { _ = p.Close() }()
+
+	if err := p.PublishServiceDiscovered(testResult{ip: "10.0.0.5", port: 22}); err == nil {
+		t.Error("PublishServiceDiscovered() succeeded with no stream for the subject")
+	}
+}
+
+func TestNATSTokenFile(t *testing.T) {
+	stub := newNATSStub(t)
+	tokenFile := filepath.Join(t.TempDir(), "token")
+	if err := os.WriteFile(tokenFile, []byte("s3cret\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	p, err := NewNATS(NATSOptions{URL: stub.url(), TokenFile: tokenFile}, zap.NewNop().Sugar())
+	if err != nil {
+		t.Fatalf("NewNATS() error = %v", err)
+	}
+	defer func() { _ = p.Close() }()
+
+	stub.mu.Lock()
+	defer stub.mu.Unlock()
+	if len(stub.connects) == 0 || stub.connects[0]["auth_token"] != "s3cret" {
+		t.Errorf("CONNECT = %v, want auth_token s3cret", stub.connects)
+	}
+
+	if _, err := NewNATS(NATSOptions{URL: stub.url(), TokenFile: filepath.Join(t.TempDir(), "missing")}, zap.NewNop().Sugar()); err == nil {
+		t.Error("NewNATS() succeeded with a missing token file")
+	}
+}
@@ -0,0 +1,32 @@
+package publisher
+
+// nopPublisher discards every event. It backs output mode "none", where
+// results are only available through the results API.
+type nopPublisher struct {
+	eventBuilder
+}
+
+// Nop returns a publisher that discards events.
+func Nop() EventPublisher {
+	return &nopPublisher{}
+}
+
+func (p *nopPublisher) PublishServerDiscovered(ServerDiscoveredData) error { return nil }
+
+func (p *nopPublisher) PublishServiceDiscovered(interface{}) error { return nil }
+
+func (p *nopPublisher) PublishHostDiscovered(ServerDiscoveredData, []interface{}) error {
+	return nil
+}
+
+func (p *nopPublisher) Flush() error { return nil }
+
+func (p *nopPublisher) Close() error { return nil }
+
+func (p *nopPublisher) IsConnected() bool { return true }
+
+func (p *nopPublisher) ForScan(scanID string) EventPublisher {
+	scoped := &nopPublisher{eventBuilder: p.eventBuilder}
+	scoped.scanID = scanID
+	return scoped
+}
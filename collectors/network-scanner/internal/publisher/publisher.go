@@ -1,24 +1,82 @@
-// Package publisher handles publishing discovery events to RabbitMQ.
+// Package publisher handles publishing discovery events to RabbitMQ or NATS.
 package publisher
 
 import (
-	"context"
 	"encoding/json"
 	"fmt"
+	"sync"
 	"time"
 
+	"github.com/aiforce-discovery-agent/collectors/network-scanner/internal/metrics"
 	"github.com/google/uuid"
 	amqp "github.com/rabbitmq/amqp091-go"
 	"go.uber.org/zap"
 )
 
-// Publisher sends CloudEvents to RabbitMQ.
+// EventPublisher is implemented by every discovery event sink.
+type EventPublisher interface {
+	PublishServerDiscovered(data ServerDiscoveredData) error
+	PublishServiceDiscovered(result interface{}) error
+	PublishHostDiscovered(data ServerDiscoveredData, results []interface{}) error
+	SetScanID(scanID string)
+	GetScanID() string
+	SetVantagePoint(vantagePoint string)
+	SetCategoryRouting(enabled bool)
+	Flush() error
+	Close() error
+
+	// IsConnected reports whether events can currently be delivered to
+	// the broker.
+	IsConnected() bool
+
+	// ForScan returns a view publishing through the same connection whose
+	// events carry scanID as subject, so concurrent scans don't share the
+	// mutable scan ID. Closing the view only flushes its pending batch.
+	ForScan(scanID string) EventPublisher
+}
+
+// Publisher sends CloudEvents to RabbitMQ. When the connection drops it
+// reconnects in the background, buffering events up to a bounded limit.
 type Publisher struct {
-	conn     *amqp.Connection
-	channel  *amqp.Channel
+	eventBuilder
+
+	url      string
+	dial     func(url string) (amqpConnection, error)
 	exchange string
 	logger   *zap.SugaredLogger
-	scanID   string // ADR-007: Current scan ID for CloudEvent subject
+
+	// declareExchange declares the exchange on every connect (SetExchange)
+	declareExchange bool
+
+	// routingKeys rewrites routing keys; nil leaves them unchanged
+	routingKeys *routingKeyTemplate
+
+	connMu       sync.Mutex
+	conn         amqpConnection
+	channel      amqpChannel // nil while disconnected
+	pending      []pendingMessage
+	maxPending   int
+	confirmMode  bool
+	reconnecting bool
+	closing      bool
+	done         chan struct{}
+
+	// Optional batching of service discoveries (see SetBatching)
+	batch batcher
+}
+
+// eventBuilder builds CloudEvents and routing keys shared by all publishers.
+type eventBuilder struct {
+	scanID string // ADR-007: Current scan ID for CloudEvent subject
+
+	// categoryRouting appends the service category to service routing keys
+	// (discovered.service.<category>). Off by default so existing bindings
+	// on discovered.service keep receiving events.
+	categoryRouting bool
+
+	// vantagePoint identifies the site/zone the scanner runs from and is
+	// attached to every discovery event's metadata.
+	vantagePoint string
 }
 
 // CloudEvent represents the CloudEvents 1.0 specification structure.
@@ -40,9 +98,19 @@ type ServerDiscoveredData struct {
 	IPAddresses []string               `json:"ip_addresses"`
 	OpenPorts   []int                  `json:"open_ports"`
 	OS          *OSInfo                `json:"os,omitempty"`
+	Summary     HostSummary            `json:"summary"`
 	Metadata    map[string]interface{} `json:"metadata,omitempty"` // Phase 1: cloud_provider, hosting_model
 }
 
+// HostSummary rolls a host's services up into flags, so consumers can
+// select hosts (e.g. with both SSH and RDP open) from server events alone.
+type HostSummary struct {
+	HasSSH      bool `json:"has_ssh"`
+	HasRDP      bool `json:"has_rdp"`
+	HasDatabase bool `json:"has_db"`
+	HasWeb      bool `json:"has_web"`
+}
+
 // ServiceDiscoveredData represents data for a discovered service event.
 type ServiceDiscoveredData struct {
 	ServiceID string                 `json:"service_id"`
@@ -54,6 +122,17 @@ type ServiceDiscoveredData struct {
 	Version   string                 `json:"version,omitempty"`
 	Banner    string                 `json:"banner,omitempty"`
 	Metadata  map[string]interface{} `json:"metadata,omitempty"` // ADR-007: candidate flags
+
+	// EventID identifies a discovery within a batch event, standing in for
+	// the CloudEvent ID it would have had if published on its own
+	EventID string `json:"event_id,omitempty"`
+}
+
+// HostDiscoveredData is a consolidated host event: the server fields plus
+// every discovered service nested under it.
+type HostDiscoveredData struct {
+	ServerDiscoveredData
+	Services []ServiceDiscoveredData `json:"services"`
 }
 
 // Database ports for candidate identification (ADR-007)
@@ -77,54 +156,173 @@ type OSInfo struct {
 
 // New creates a new Publisher connected to RabbitMQ.
 func New(url string, logger *zap.SugaredLogger) (*Publisher, error) {
-	conn, err := amqp.Dial(url)
-	if err != nil {
-		return nil, fmt.Errorf("failed to connect to RabbitMQ: %w", err)
+	return newPublisher(url, dialAMQP, logger)
+}
+
+func newPublisher(url string, dial func(string) (amqpConnection, error), logger *zap.SugaredLogger) (*Publisher, error) {
+	p := &Publisher{
+		url:        url,
+		dial:       dial,
+		exchange:   "discovery.events",
+		logger:     logger,
+		maxPending: defaultReconnectBuffer,
+		done:       make(chan struct{}),
+	}
+	p.batch.publish = p.publishBatch
+	p.batch.logger = logger
+	if err := p.connect(); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// Close publishes any pending batch, then stops reconnecting and closes
+// the RabbitMQ connection. Events still buffered are discarded.
+func (p *Publisher) Close() error {
+	if err := p.Flush(); err != nil {
+		p.logger.Warnw("Failed to publish pending batch on close", "error", err)
+	}
+
+	p.connMu.Lock()
+	if p.closing {
+		p.connMu.Unlock()
+		return nil
+	}
+	p.closing = true
+	close(p.done)
+	channel, conn := p.channel, p.conn
+	p.channel = nil
+	p.connMu.Unlock()
+
+	if channel != nil {
+		_ = channel.Close()
 	}
+	if conn != nil {
+		return conn.Close()
+	}
+	return nil
+}
+
+// IsConnected reports whether the RabbitMQ connection and channel are up.
+// It is false while reconnecting, when events are only buffered.
+func (p *Publisher) IsConnected() bool {
+	p.connMu.Lock()
+	defer p.connMu.Unlock()
+	return !p.closing && p.conn != nil && p.channel != nil && !p.conn.IsClosed()
+}
+
+// PublishServerDiscovered publishes a server discovered event.
+func (p *Publisher) PublishServerDiscovered(data ServerDiscoveredData) error {
+	event, routingKey := p.serverEvent(data)
+	return p.publish(event, routingKey)
+}
 
-	channel, err := conn.Channel()
+// PublishServiceDiscovered publishes a service discovered event, or queues
+// it when batching, followed by a database candidate event for database
+// ports once the service (or its batch) is out.
+func (p *Publisher) PublishServiceDiscovered(result interface{}) error {
+	data, err := p.serviceData(result)
 	if err != nil {
-		_ = conn.Close()
-		return nil, fmt.Errorf("failed to open channel: %w", err)
+		return err
 	}
 
-	return &Publisher{
-		conn:     conn,
-		channel:  channel,
-		exchange: "discovery.events",
-		logger:   logger,
-	}, nil
+	// A queued service's candidate follows its batch
+	if p.batch.enabled() {
+		return p.batch.add(data)
+	}
+	event, routingKey := p.serviceEvent(data)
+	if err := p.publish(event, routingKey); err != nil {
+		return err
+	}
+	p.publishCandidates(p.publish, p.logger, data)
+	return nil
 }
 
-// Close closes the RabbitMQ connection.
-func (p *Publisher) Close() error {
-	if p.channel != nil {
-		_ = p.channel.Close()
+// PublishHostDiscovered publishes one consolidated event for a host and
+// all of its services, then a candidate event per database service.
+func (p *Publisher) PublishHostDiscovered(data ServerDiscoveredData, results []interface{}) error {
+	event, routingKey, services, err := p.hostEvent(data, results)
+	if err != nil {
+		return err
 	}
-	if p.conn != nil {
-		return p.conn.Close()
+	if err := p.publish(event, routingKey); err != nil {
+		return err
 	}
+	p.publishCandidates(p.publish, p.logger, services...)
 	return nil
 }
 
+// ForScan returns a publisher for one scan's events, batched separately
+// with the same settings.
+func (p *Publisher) ForScan(scanID string) EventPublisher {
+	scoped := newScanPublisher(p.eventBuilder, scanID, p.publish, p.IsConnected, p.logger)
+	scoped.batch.configure(p.batch.settings())
+	return scoped
+}
+
 // SetScanID sets the current scan ID for CloudEvent subject (ADR-007).
-func (p *Publisher) SetScanID(scanID string) {
+func (p *eventBuilder) SetScanID(scanID string) {
 	p.scanID = scanID
 }
 
 // GetScanID returns the current scan ID.
-func (p *Publisher) GetScanID() string {
+func (p *eventBuilder) GetScanID() string {
 	return p.scanID
 }
 
-// PublishServerDiscovered publishes a server discovered event.
-func (p *Publisher) PublishServerDiscovered(data ServerDiscoveredData) error {
-	event := p.createEvent("discovery.server.discovered", data)
-	return p.publish(event, "discovered.server")
+// SetVantagePoint sets the scanner vantage point attached to discovery events.
+func (p *eventBuilder) SetVantagePoint(vantagePoint string) {
+	p.vantagePoint = vantagePoint
 }
 
-// PublishServiceDiscovered publishes a service discovered event.
-func (p *Publisher) PublishServiceDiscovered(result interface{}) error {
+// SetCategoryRouting enables or disables per-category service routing keys.
+func (p *eventBuilder) SetCategoryRouting(enabled bool) {
+	p.categoryRouting = enabled
+}
+
+// serverEvent builds a server discovered event and its routing key.
+func (p *eventBuilder) serverEvent(data ServerDiscoveredData) (CloudEvent, string) {
+	if p.vantagePoint != "" {
+		if data.Metadata == nil {
+			data.Metadata = make(map[string]interface{})
+		}
+		data.Metadata["vantage_point"] = p.vantagePoint
+	}
+	return p.createEvent("discovery.server.discovered", data), "discovered.server"
+}
+
+// serviceEvent builds a service discovered event and its routing key.
+func (p *eventBuilder) serviceEvent(data ServiceDiscoveredData) (CloudEvent, string) {
+	return p.createEvent("discovery.service.discovered", data), p.serviceRoutingKey(data)
+}
+
+// hostEvent builds a consolidated host event with services nested, and its
+// routing key. It also returns the nested services.
+func (p *eventBuilder) hostEvent(server ServerDiscoveredData, results []interface{}) (CloudEvent, string, []ServiceDiscoveredData, error) {
+	if p.vantagePoint != "" {
+		if server.Metadata == nil {
+			server.Metadata = make(map[string]interface{})
+		}
+		server.Metadata["vantage_point"] = p.vantagePoint
+	}
+
+	data := HostDiscoveredData{
+		ServerDiscoveredData: server,
+		Services:             make([]ServiceDiscoveredData, 0, len(results)),
+	}
+	for _, result := range results {
+		service, err := p.serviceData(result)
+		if err != nil {
+			return CloudEvent{}, "", nil, err
+		}
+		service.ServerID = server.ServerID
+		data.Services = append(data.Services, service)
+	}
+	return p.createEvent("discovery.host.discovered", data), "discovered.host", data.Services, nil
+}
+
+// serviceData converts a scan result into service event data.
+func (p *eventBuilder) serviceData(result interface{}) (ServiceDiscoveredData, error) {
 	// Convert ScanResult to ServiceDiscoveredData
 	var data ServiceDiscoveredData
 
@@ -148,14 +346,70 @@ func (p *Publisher) PublishServiceDiscovered(result interface{}) error {
 			Banner:    banner,
 			Metadata:  buildMetadata(port, banner), // ADR-007: Add candidate flags
 		}
+		data.Metadata["service_category"] = ServiceCategory(port, data.Service)
+
+		// Links the service to its host's server event
+		if serverResult, ok := result.(interface{ GetServerID() string }); ok {
+			data.ServerID = serverResult.GetServerID()
+		}
+
+		// Version and product let enrichment tell e.g. nginx 1.18 from 1.25
+		if versioned, ok := result.(interface {
+			GetVersion() string
+			GetProduct() string
+		}); ok {
+			data.Version = versioned.GetVersion()
+			if product := versioned.GetProduct(); product != "" {
+				data.Metadata["product"] = product
+			}
+		}
+
+		// Which signature identified the service, for debugging detections
+		if fpResult, ok := result.(interface{ GetFingerprintSource() string }); ok && fpResult.GetFingerprintSource() != "" {
+			data.Metadata["fingerprint_source"] = fpResult.GetFingerprintSource()
+		}
+
+		// CDN edge addresses front services hosted elsewhere
+		if cdnResult, ok := result.(interface{ GetCDN() string }); ok && cdnResult.GetCDN() != "" {
+			data.Metadata["cdn_fronted"] = true
+			data.Metadata["cdn"] = cdnResult.GetCDN()
+		}
+
+		// Probe-specific metadata (e.g. ALPN) from the scanner
+		if metaResult, ok := result.(interface {
+			GetMetadata() map[string]interface{}
+		}); ok {
+			for k, v := range metaResult.GetMetadata() {
+				data.Metadata[k] = v
+			}
+			clearRejectedCandidate(data.Metadata)
+		}
+
+		// Certificate details from TLS ports
+		if tlsResult, ok := result.(interface {
+			GetTLSSubject() string
+			GetTLSIssuer() string
+			GetTLSSANs() []string
+			GetTLSNotAfter() time.Time
+		}); ok && tlsResult.GetTLSSubject() != "" {
+			data.Metadata["tls_subject"] = tlsResult.GetTLSSubject()
+			data.Metadata["tls_issuer"] = tlsResult.GetTLSIssuer()
+			data.Metadata["tls_sans"] = tlsResult.GetTLSSANs()
+			data.Metadata["tls_not_after"] = tlsResult.GetTLSNotAfter().UTC().Format(time.RFC3339)
+		}
+
+		// Already in the inventory; consumers can skip or refresh
+		if knownResult, ok := result.(interface{ GetKnown() bool }); ok && knownResult.GetKnown() {
+			data.Metadata["known"] = true
+		}
 	} else {
 		// Direct struct conversion for simple cases
 		jsonBytes, err := json.Marshal(result)
 		if err != nil {
-			return fmt.Errorf("failed to marshal result: %w", err)
+			return data, fmt.Errorf("failed to marshal result: %w", err)
 		}
 		if err := json.Unmarshal(jsonBytes, &data); err != nil {
-			return fmt.Errorf("failed to unmarshal to ServiceDiscoveredData: %w", err)
+			return data, fmt.Errorf("failed to unmarshal to ServiceDiscoveredData: %w", err)
 		}
 		if data.ServiceID == "" {
 			data.ServiceID = uuid.New().String()
@@ -164,13 +418,32 @@ func (p *Publisher) PublishServiceDiscovered(result interface{}) error {
 		if data.Metadata == nil {
 			data.Metadata = buildMetadata(data.Port, data.Banner)
 		}
+		if _, ok := data.Metadata["service_category"]; !ok {
+			data.Metadata["service_category"] = ServiceCategory(data.Port, data.Service)
+		}
+	}
+
+	if p.vantagePoint != "" {
+		data.Metadata["vantage_point"] = p.vantagePoint
 	}
 
-	event := p.createEvent("discovery.service.discovered", data)
-	return p.publish(event, "discovered.service")
+	return data, nil
+}
+
+// serviceRoutingKey returns the routing key for a service event, including
+// the service category when category routing is enabled.
+func (p *eventBuilder) serviceRoutingKey(data ServiceDiscoveredData) string {
+	if !p.categoryRouting {
+		return "discovered.service"
+	}
+	category, _ := data.Metadata["service_category"].(string)
+	if category == "" {
+		category = ServiceCategory(data.Port, data.Service)
+	}
+	return "discovered.service." + category
 }
 
-func (p *Publisher) createEvent(eventType string, data interface{}) CloudEvent {
+func (p *eventBuilder) createEvent(eventType string, data interface{}) CloudEvent {
 	event := CloudEvent{
 		SpecVersion:     "1.0",
 		Type:            eventType,
@@ -194,40 +467,49 @@ func buildMetadata(port int, banner string) map[string]interface{} {
 	metadata := make(map[string]interface{})
 
 	// Check if port is a known database port
-	if dbType, ok := databasePorts[port]; ok {
+	if dbType, confidence, reason, ok := databaseCandidate(port); ok {
 		metadata["database_candidate"] = true
 		metadata["candidate_type"] = dbType
-		metadata["candidate_confidence"] = 0.5 // port_only confidence
-		metadata["candidate_reason"] = fmt.Sprintf("Port %d (known %s port)", port, dbType)
+		metadata["candidate_confidence"] = confidence
+		metadata["candidate_reason"] = reason
 	}
 
 	return metadata
 }
 
 func (p *Publisher) publish(event CloudEvent, routingKey string) error {
+	if p.routingKeys != nil {
+		routingKey = p.routingKeys.format(routingKey)
+	}
+
 	body, err := json.Marshal(event)
 	if err != nil {
 		return fmt.Errorf("failed to marshal event: %w", err)
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-	defer cancel()
-
-	err = p.channel.PublishWithContext(
-		ctx,
-		p.exchange,
-		routingKey,
-		false, // mandatory
-		false, // immediate
-		amqp.Publishing{
-			ContentType: "application/cloudevents+json",
-			Body:        body,
-			MessageId:   event.ID,
-			Timestamp:   time.Now(),
-		},
-	)
+	msg := amqp.Publishing{
+		ContentType: "application/cloudevents+json",
+		Body:        body,
+		MessageId:   event.ID,
+		Timestamp:   time.Now(),
+	}
 
+	// Buffer while a reconnect is in progress
+	p.connMu.Lock()
+	channel := p.channel
+	p.connMu.Unlock()
+	if channel == nil {
+		return p.buffer(routingKey, msg)
+	}
+
+	err = p.send(channel, pendingMessage{routingKey: routingKey, msg: msg})
+	if err != nil && isConnectionClosed(err) {
+		p.logger.Warnw("RabbitMQ channel closed, reconnecting", "error", err)
+		p.triggerReconnect()
+		return p.buffer(routingKey, msg)
+	}
 	if err != nil {
+		metrics.PublishFailures.Inc()
 		return fmt.Errorf("failed to publish event: %w", err)
 	}
 
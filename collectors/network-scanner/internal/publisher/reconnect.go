@@ -0,0 +1,261 @@
+package publisher
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/aiforce-discovery-agent/collectors/network-scanner/internal/metrics"
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+// Reconnect backoff bounds and the default number of events buffered while
+// RabbitMQ is unreachable.
+const (
+	reconnectInitialBackoff = 100 * time.Millisecond
+	reconnectMaxBackoff     = 30 * time.Second
+	defaultReconnectBuffer  = 1000
+)
+
+// amqpChannel is the subset of *amqp.Channel the publisher uses.
+type amqpChannel interface {
+	PublishWithContext(ctx context.Context, exchange, key string, mandatory, immediate bool, msg amqp.Publishing) error
+	Confirm(noWait bool) error
+	NotifyPublish(confirm chan amqp.Confirmation) chan amqp.Confirmation
+	ExchangeDeclare(name, kind string, durable, autoDelete, internal, noWait bool, args amqp.Table) error
+	ExchangeDeclarePassive(name, kind string, durable, autoDelete, internal, noWait bool, args amqp.Table) error
+	Close() error
+}
+
+// amqpConnection is the subset of *amqp.Connection the publisher uses.
+type amqpConnection interface {
+	Channel() (amqpChannel, error)
+	NotifyClose(receiver chan *amqp.Error) chan *amqp.Error
+	IsClosed() bool
+	Close() error
+}
+
+// amqpConn adapts *amqp.Connection to amqpConnection.
+type amqpConn struct {
+	*amqp.Connection
+}
+
+func (c amqpConn) Channel() (amqpChannel, error) {
+	return c.Connection.Channel()
+}
+
+func dialAMQP(url string) (amqpConnection, error) {
+	conn, err := amqp.Dial(url)
+	if err != nil {
+		return nil, err
+	}
+	return amqpConn{conn}, nil
+}
+
+// pendingMessage is an event buffered while the connection is down.
+type pendingMessage struct {
+	routingKey string
+	msg        amqp.Publishing
+}
+
+// SetReconnectBuffer sets how many events are buffered while reconnecting.
+// Events beyond the limit are dropped and counted.
+func (p *Publisher) SetReconnectBuffer(size int) {
+	p.connMu.Lock()
+	defer p.connMu.Unlock()
+	p.maxPending = size
+}
+
+// SetConfirmMode enables publisher confirms: publish returns only after the
+// broker acks the event, and a nack or timeout is an error. It applies to
+// the current channel and every channel opened on reconnect.
+func (p *Publisher) SetConfirmMode(enabled bool) error {
+	p.connMu.Lock()
+	defer p.connMu.Unlock()
+
+	if enabled == p.confirmMode {
+		return nil
+	}
+	if !enabled {
+		// amqp channels can't leave confirm mode; reconnect to drop it
+		p.confirmMode = false
+		return nil
+	}
+	if p.channel != nil {
+		channel, err := newConfirmChannel(p.channel)
+		if err != nil {
+			return err
+		}
+		p.channel = channel
+	}
+	p.confirmMode = true
+	return nil
+}
+
+// connect dials RabbitMQ, flushes events buffered while disconnected, and
+// only then makes the channel available, so buffered events keep their
+// order ahead of new ones.
+func (p *Publisher) connect() error {
+	conn, err := p.dial(p.url)
+	if err != nil {
+		return fmt.Errorf("failed to connect to RabbitMQ: %w", err)
+	}
+
+	// A restarted broker may have lost a non-durable setup; declare again
+	p.connMu.Lock()
+	exchange, declare := p.exchange, p.declareExchange
+	p.connMu.Unlock()
+	if declare {
+		if err := ensureExchange(conn, exchange, true); err != nil {
+			_ = conn.Close()
+			return err
+		}
+	}
+
+	channel, err := conn.Channel()
+	if err != nil {
+		_ = conn.Close()
+		return fmt.Errorf("failed to open channel: %w", err)
+	}
+
+	p.connMu.Lock()
+	confirm := p.confirmMode
+	p.connMu.Unlock()
+	if confirm {
+		if channel, err = newConfirmChannel(channel); err != nil {
+			_ = conn.Close()
+			return err
+		}
+	}
+
+	p.connMu.Lock()
+	p.conn = conn
+	p.connMu.Unlock()
+
+	go p.watch(conn, conn.NotifyClose(make(chan *amqp.Error, 1)))
+
+	for {
+		p.connMu.Lock()
+		if len(p.pending) == 0 {
+			p.channel = channel
+			p.reconnecting = false
+			p.connMu.Unlock()
+			return nil
+		}
+		batch := p.pending
+		p.pending = nil
+		p.connMu.Unlock()
+
+		for i, m := range batch {
+			if err := p.send(channel, m); err != nil {
+				p.connMu.Lock()
+				p.pending = append(batch[i:], p.pending...)
+				p.connMu.Unlock()
+				_ = conn.Close()
+				return fmt.Errorf("failed to flush buffered events: %w", err)
+			}
+		}
+		p.logger.Infow("Flushed buffered events after reconnect", "count", len(batch))
+	}
+}
+
+// watch waits for conn to close and starts reconnecting, unless the close
+// was requested through Close.
+func (p *Publisher) watch(conn amqpConnection, closed chan *amqp.Error) {
+	amqpErr, ok := <-closed
+	if !ok {
+		return
+	}
+
+	p.connMu.Lock()
+	current := p.conn == conn
+	p.connMu.Unlock()
+	if !current {
+		return
+	}
+
+	p.logger.Warnw("RabbitMQ connection lost", "error", amqpErr)
+	p.triggerReconnect()
+}
+
+// triggerReconnect marks the channel unusable and starts the reconnect loop
+// if one isn't already running.
+func (p *Publisher) triggerReconnect() {
+	p.connMu.Lock()
+	defer p.connMu.Unlock()
+
+	if p.reconnecting || p.closing {
+		return
+	}
+	p.reconnecting = true
+	p.channel = nil
+	go p.reconnectLoop()
+}
+
+// reconnectLoop redials with exponential backoff until it succeeds or the
+// publisher is closed.
+func (p *Publisher) reconnectLoop() {
+	p.connMu.Lock()
+	old := p.conn
+	p.connMu.Unlock()
+	if old != nil {
+		_ = old.Close()
+	}
+
+	backoff := reconnectInitialBackoff
+	for attempt := 1; ; attempt++ {
+		select {
+		case <-p.done:
+			return
+		case <-time.After(backoff):
+		}
+
+		err := p.connect()
+		if err == nil {
+			p.logger.Infow("Reconnected to RabbitMQ", "attempts", attempt)
+			return
+		}
+		p.logger.Warnw("RabbitMQ reconnect failed", "attempt", attempt, "error", err)
+
+		backoff *= 2
+		if backoff > reconnectMaxBackoff {
+			backoff = reconnectMaxBackoff
+		}
+	}
+}
+
+// buffer queues an event for delivery after reconnecting. It returns an
+// error, and counts the drop, when the buffer is full.
+func (p *Publisher) buffer(routingKey string, msg amqp.Publishing) error {
+	p.connMu.Lock()
+	defer p.connMu.Unlock()
+
+	if len(p.pending) >= p.maxPending {
+		metrics.PublishDropped.Inc()
+		return fmt.Errorf("reconnect buffer full, event %s dropped", msg.MessageId)
+	}
+	p.pending = append(p.pending, pendingMessage{routingKey: routingKey, msg: msg})
+	return nil
+}
+
+func (p *Publisher) send(channel amqpChannel, m pendingMessage) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	return channel.PublishWithContext(
+		ctx,
+		p.exchange,
+		m.routingKey,
+		false, // mandatory
+		false, // immediate
+		m.msg,
+	)
+}
+
+// isConnectionClosed reports whether a publish failed because the channel
+// or connection has gone away.
+func isConnectionClosed(err error) bool {
+	var amqpErr *amqp.Error
+	return errors.Is(err, amqp.ErrClosed) || (errors.As(err, &amqpErr) && !amqpErr.Recover)
+}
@@ -0,0 +1,233 @@
+package publisher
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+	"go.uber.org/zap"
+)
+
+// fakeChannel records the IDs of published events, where they were routed
+// and the exchanges declared on it. With err set every publish fails, and
+// with declareErr every declaration; in confirm mode each publish is
+// acked, or nacked with nack set, or left unconfirmed with silent set.
+type fakeChannel struct {
+	mu         sync.Mutex
+	published  []string
+	routes     []string // "exchange routing-key"
+	declared   []string // "declare name" or "passive name"
+	err        error
+	declareErr error
+	nack       bool
+	silent     bool
+	confirms   chan amqp.Confirmation
+	tag        uint64
+}
+
+func (c *fakeChannel) PublishWithContext(_ context.Context, exchange, key string, _, _ bool, msg amqp.Publishing) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.err != nil {
+		return c.err
+	}
+	c.published = append(c.published, msg.MessageId)
+	c.routes = append(c.routes, exchange+" "+key)
+	if c.confirms != nil && !c.silent {
+		c.tag++
+		confirm := amqp.Confirmation{DeliveryTag: c.tag, Ack: !c.nack}
+		go func() { c.confirms <- confirm }()
+	}
+	return nil
+}
+
+func (c *fakeChannel) Confirm(bool) error { return nil }
+
+func (c *fakeChannel) NotifyPublish(confirm chan amqp.Confirmation) chan amqp.Confirmation {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.confirms = confirm
+	return confirm
+}
+
+func (c *fakeChannel) ExchangeDeclare(name, kind string, durable, _, _, _ bool, _ amqp.Table) error {
+	return c.declare(fmt.Sprintf("declare %s %s durable=%v", name, kind, durable))
+}
+
+func (c *fakeChannel) ExchangeDeclarePassive(name, _ string, _, _, _, _ bool, _ amqp.Table) error {
+	return c.declare("passive " + name)
+}
+
+func (c *fakeChannel) declare(call string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.declareErr != nil {
+		return c.declareErr
+	}
+	c.declared = append(c.declared, call)
+	return nil
+}
+
+func (c *fakeChannel) Close() error { return nil }
+
+// fail makes every later publish return err.
+func (c *fakeChannel) fail(err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.err = err
+}
+
+func (c *fakeChannel) ids() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]string(nil), c.published...)
+}
+
+// fakeConn hands out its channel and reports closing through NotifyClose
+// once drop is called.
+type fakeConn struct {
+	channel *fakeChannel
+
+	mu     sync.Mutex
+	notify chan *amqp.Error
+	closed bool
+}
+
+func (c *fakeConn) Channel() (amqpChannel, error) { return c.channel, nil }
+
+func (c *fakeConn) NotifyClose(receiver chan *amqp.Error) chan *amqp.Error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.notify = receiver
+	return receiver
+}
+
+func (c *fakeConn) IsClosed() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.closed
+}
+
+func (c *fakeConn) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.closed = true
+	return nil
+}
+
+// drop closes the connection, and so its channel, as a broker restart
+// would.
+func (c *fakeConn) drop() {
+	c.channel.fail(amqp.ErrClosed)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.closed = true
+	c.notify <- &amqp.Error{Code: amqp.ConnectionForced, Reason: "broker restarting"}
+}
+
+// newFakePublisher returns a Publisher dialing conns in turn.
+func newFakePublisher(t *testing.T, conns ...*fakeConn) *Publisher {
+	t.Helper()
+
+	var mu sync.Mutex
+	dial := func(string) (amqpConnection, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		if len(conns) == 0 {
+			return nil, fmt.Errorf("connection refused")
+		}
+		conn := conns[0]
+		conns = conns[1:]
+		return conn, nil
+	}
+	p, err := newPublisher("amqp://broker", dial, zap.NewNop().Sugar())
+	if err != nil {
+		t.Fatalf("newPublisher() error = %v", err)
+	}
+	t.Cleanup(func() { _ = p.Close() })
+	return p
+}
+
+// waitConnected waits for p to finish reconnecting.
+func waitConnected(t *testing.T, p *Publisher) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for !p.IsConnected() {
+		if time.Now().After(deadline) {
+			t.Fatal("publisher did not reconnect")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestPublisherReconnects(t *testing.T) {
+	tests := []struct {
+		name string
+		lose func(first *fakeConn)
+	}{
+		{name: "closed channel on publish", lose: func(first *fakeConn) { first.channel.fail(amqp.ErrClosed) }},
+		{name: "connection closed by the broker", lose: func(first *fakeConn) { first.drop() }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			first := &fakeConn{channel: &fakeChannel{}}
+			second := &fakeConn{channel: &fakeChannel{}}
+			p := newFakePublisher(t, first, second)
+
+			if err := p.publish(CloudEvent{ID: "before"}, "key"); err != nil {
+				t.Fatalf("publish() error = %v", err)
+			}
+			tt.lose(first)
+			// Buffered, either after the failed publish or while reconnecting
+			if err := p.publish(CloudEvent{ID: "during"}, "key"); err != nil {
+				t.Fatalf("publish() while reconnecting error = %v", err)
+			}
+			waitConnected(t, p)
+			if err := p.publish(CloudEvent{ID: "after"}, "key"); err != nil {
+				t.Fatalf("publish() after reconnect error = %v", err)
+			}
+
+			if got := fmt.Sprint(first.channel.ids()); got != "[before]" {
+				t.Errorf("first connection published %s, want [before]", got)
+			}
+			if got := fmt.Sprint(second.channel.ids()); got != "[during after]" {
+				t.Errorf("second connection published %s, want [during after]", got)
+			}
+		})
+	}
+}
+
+func TestReconnectBufferFull(t *testing.T) {
+	first := &fakeConn{channel: &fakeChannel{}}
+	p := newFakePublisher(t, first)
+	p.SetReconnectBuffer(1)
+	first.channel.fail(amqp.ErrClosed)
+
+	if err := p.publish(CloudEvent{ID: "buffered"}, "key"); err != nil {
+		t.Fatalf("first publish error = %v, want it buffered", err)
+	}
+	if err := p.publish(CloudEvent{ID: "dropped"}, "key"); err == nil {
+		t.Error("publish with a full buffer succeeded")
+	}
+}
+
+func TestIsConnected(t *testing.T) {
+	conn := &fakeConn{channel: &fakeChannel{}}
+	p := newFakePublisher(t, conn)
+	if !p.IsConnected() {
+		t.Fatal("IsConnected() = false after connecting")
+	}
+
+	// No second connection to redial, so it stays down
+	conn.drop()
+	deadline := time.Now().Add(time.Second)
+	for p.IsConnected() {
+		if time.Now().After(deadline) {
+			t.Fatal("IsConnected() still true after the connection closed")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
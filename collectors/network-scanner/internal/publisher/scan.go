@@ -0,0 +1,100 @@
+package publisher
+
+import (
+	"go.uber.org/zap"
+)
+
+// scanPublisher publishes one scan's events through its parent's
+// connection. It has its own copy of the event builder, so its scan ID is
+// independent of the parent's, and its own batch.
+type scanPublisher struct {
+	eventBuilder
+
+	send      func(event CloudEvent, routingKey string) error
+	connected func() bool
+	batch     batcher
+}
+
+func newScanPublisher(builder eventBuilder, scanID string, send func(CloudEvent, string) error, connected func() bool, logger *zap.SugaredLogger) *scanPublisher {
+	p := &scanPublisher{
+		eventBuilder: builder,
+		send:         send,
+		connected:    connected,
+	}
+	p.scanID = scanID
+	p.batch.publish = p.publishBatch
+	p.batch.logger = logger
+	return p
+}
+
+// PublishServerDiscovered publishes a server discovered event.
+func (p *scanPublisher) PublishServerDiscovered(data ServerDiscoveredData) error {
+	event, routingKey := p.serverEvent(data)
+	return p.send(event, routingKey)
+}
+
+// PublishServiceDiscovered publishes a service discovered event, or queues
+// it when batching, followed by a database candidate event for database
+// ports once the service (or its batch) is out.
+func (p *scanPublisher) PublishServiceDiscovered(result interface{}) error {
+	data, err := p.serviceData(result)
+	if err != nil {
+		return err
+	}
+
+	// A queued service's candidate follows its batch
+	if p.batch.enabled() {
+		return p.batch.add(data)
+	}
+	event, routingKey := p.serviceEvent(data)
+	if err := p.send(event, routingKey); err != nil {
+		return err
+	}
+	p.publishCandidates(p.send, p.batch.logger, data)
+	return nil
+}
+
+// PublishHostDiscovered publishes one consolidated event for a host and
+// all of its services, then a candidate event per database service.
+func (p *scanPublisher) PublishHostDiscovered(data ServerDiscoveredData, results []interface{}) error {
+	event, routingKey, services, err := p.hostEvent(data, results)
+	if err != nil {
+		return err
+	}
+	if err := p.send(event, routingKey); err != nil {
+		return err
+	}
+	p.publishCandidates(p.send, p.batch.logger, services...)
+	return nil
+}
+
+// ForScan returns another view on the same connection.
+func (p *scanPublisher) ForScan(scanID string) EventPublisher {
+	scoped := newScanPublisher(p.eventBuilder, scanID, p.send, p.connected, p.batch.logger)
+	scoped.batch.configure(p.batch.settings())
+	return scoped
+}
+
+// IsConnected reports whether the parent's connection is up.
+func (p *scanPublisher) IsConnected() bool {
+	return p.connected()
+}
+
+// Flush publishes this scan's partially filled batch.
+func (p *scanPublisher) Flush() error {
+	return p.batch.flush()
+}
+
+// Close flushes the pending batch; the connection belongs to the parent.
+func (p *scanPublisher) Close() error {
+	return p.batch.flush()
+}
+
+func (p *scanPublisher) publishBatch(items []ServiceDiscoveredData) error {
+	event := p.createEvent("discovery.service.batch", items)
+	if err := p.send(event, "discovered.service.batch"); err != nil {
+		return err
+	}
+	p.publishCandidates(p.send, p.batch.logger, items...)
+	return nil
+}
@@ -0,0 +1,311 @@
+package publisher
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"go.uber.org/zap"
+)
+
+// sentEvent is an event as a backend was asked to send it.
+type sentEvent struct {
+	eventType, subject, routingKey string
+}
+
+func TestScanPublisherEvents(t *testing.T) {
+	tests := []struct {
+		name    string
+		publish func(EventPublisher) error
+		want    []sentEvent
+	}{
+		{
+			name:    "server",
+			publish: func(p EventPublisher) error { return p.PublishServerDiscovered(ServerDiscoveredData{}) },
+			want:    []sentEvent{{"discovery.server.discovered", "scan-a", "discovered.server"}},
+		},
+		{
+			name:    "web service",
+			publish: func(p EventPublisher) error { return p.PublishServiceDiscovered(testResult{ip: "10.0.0.5", port: 80}) },
+			want:    []sentEvent{{"discovery.service.discovered", "scan-a", "discovered.service"}},
+		},
+		{
+			name: "host with a database",
+			publish: func(p EventPublisher) error {
+				return p.PublishHostDiscovered(ServerDiscoveredData{}, []interface{}{testResult{ip: "10.0.0.5", port: 5432}})
+			},
+			want: []sentEvent{
+				{"discovery.host.discovered", "scan-a", "discovered.host"},
+				{"discovery.database.candidate", "scan-a", "candidate.database"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var sent []sentEvent
+			send := func(event CloudEvent, routingKey string) error {
+				sent = append(sent, sentEvent{event.Type, event.Subject, routingKey})
+				return nil
+			}
+			parent := newScanPublisher(eventBuilder{}, "", send, func() bool { return true }, zap.NewNop().Sugar())
+
+			// A second scan's view doesn't change the first one's subject
+			scanA := parent.ForScan("scan-a")
+			parent.ForScan("scan-b")
+			if err := tt.publish(scanA); err != nil {
+				t.Fatalf("publish error = %v", err)
+			}
+
+			if fmt.Sprint(sent) != fmt.Sprint(tt.want) {
+				t.Errorf("sent %v, want %v", sent, tt.want)
+			}
+		})
+	}
+}
+
+func TestHostEventNestsServices(t *testing.T) {
+	b := eventBuilder{vantagePoint: "dc1"}
+	server := ServerDiscoveredData{ServerID: "srv-1", IPAddresses: []string{"10.0.0.5"}, OpenPorts: []int{22, 5432}}
+
+	event, routingKey, services, err := b.hostEvent(server, []interface{}{
+		testResult{ip: "10.0.0.5", port: 22},
+		testResult{ip: "10.0.0.5", port: 5432},
+	})
+	if err != nil {
+		t.Fatalf("hostEvent() error = %v", err)
+	}
+	if event.Type != "discovery.host.discovered" || routingKey != "discovered.host" {
+		t.Errorf("event %s routed to %s, want discovery.host.discovered on discovered.host", event.Type, routingKey)
+	}
+	if len(services) != 2 {
+		t.Errorf("hostEvent() returned %d services, want 2", len(services))
+	}
+
+	// Server fields stay at the top level with services nested under them
+	raw, err := json.Marshal(event.Data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got struct {
+		ServerID string                 `json:"server_id"`
+		Metadata map[string]interface{} `json:"metadata"`
+		Services []struct {
+			ServerID string `json:"server_id"`
+			Port     int    `json:"port"`
+		} `json:"services"`
+	}
+	if err := json.Unmarshal(raw, &got); err != nil {
+		t.Fatalf("decoding %s: %v", raw, err)
+	}
+	if got.ServerID != "srv-1" || got.Metadata["vantage_point"] != "dc1" {
+		t.Errorf("host = %s, want server srv-1 from vantage point dc1", raw)
+	}
+	if len(got.Services) != 2 || got.Services[0].Port != 22 || got.Services[1].Port != 5432 {
+		t.Fatalf("services = %+v, want ports 22 and 5432", got.Services)
+	}
+	for _, service := range got.Services {
+		if service.ServerID != "srv-1" {
+			t.Errorf("service on port %d has server_id %q, want srv-1", service.Port, service.ServerID)
+		}
+	}
+}
+
+func TestNewKafkaRequiresBrokersAndTopic(t *testing.T) {
+	tests := []struct {
+		name    string
+		opts    KafkaOptions
+		wantErr bool
+	}{
+		{name: "valid", opts: KafkaOptions{Brokers: []string{"kafka:9092"}, Topic: "discovery"}},
+		{name: "no brokers", opts: KafkaOptions{Topic: "discovery"}, wantErr: true},
+		{name: "no topic", opts: KafkaOptions{Brokers: []string{"kafka:9092"}}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := NewKafka(tt.opts, zap.NewNop().Sugar())
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("NewKafka() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if p != nil {
+				_ = p.Close()
+			}
+		})
+	}
+}
+
+func TestVantagePointInEvents(t *testing.T) {
+	tests := []struct {
+		name    string
+		publish func(EventPublisher) error
+	}{
+		{name: "server", publish: func(p EventPublisher) error { return p.PublishServerDiscovered(ServerDiscoveredData{}) }},
+		{name: "service", publish: func(p EventPublisher) error { return p.PublishServiceDiscovered(testResult{ip: "10.0.0.5", port: 22}) }},
+		{
+			name: "host",
+			publish: func(p EventPublisher) error {
+				return p.PublishHostDiscovered(ServerDiscoveredData{}, []interface{}{testResult{ip: "10.0.0.5", port: 22}})
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var events []CloudEvent
+			send := func(event CloudEvent, _ string) error {
+				events = append(events, event)
+				return nil
+			}
+			p := newScanPublisher(eventBuilder{}, "", send, func() bool { return true }, zap.NewNop().Sugar())
+			p.SetVantagePoint("dmz-eu1")
+			if err := tt.publish(p); err != nil {
+				t.Fatalf("publish error = %v", err)
+			}
+			if len(events) != 1 {
+				t.Fatalf("sent %d events, want 1", len(events))
+			}
+
+			body, err := json.Marshal(events[0].Data)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var data struct {
+				Metadata map[string]interface{} `json:"metadata"`
+				Services []struct {
+					Metadata map[string]interface{} `json:"metadata"`
+				} `json:"services"`
+			}
+			if err := json.Unmarshal(body, &data); err != nil {
+				t.Fatal(err)
+			}
+			if data.Metadata["vantage_point"] != "dmz-eu1" {
+				t.Errorf("metadata = %v, want vantage_point dmz-eu1", data.Metadata)
+			}
+			for _, service := range data.Services {
+				if service.Metadata["vantage_point"] != "dmz-eu1" {
+					t.Errorf("nested service metadata = %v, want vantage_point dmz-eu1", service.Metadata)
+				}
+			}
+		})
+	}
+}
+
+// cdnResult is a scan result on a CDN edge address.
+type cdnResult struct {
+	testResult
+	cdn string
+}
+
+func (r cdnResult) GetCDN() string { return r.cdn }
+
+func TestCDNFrontedServiceMetadata(t *testing.T) {
+	var b eventBuilder
+	tests := []struct {
+		name   string
+		result interface{}
+		want   map[string]interface{}
+	}{
+		{name: "cdn edge", result: cdnResult{testResult{ip: "104.16.1.1", port: 443}, "cloudflare"}, want: map[string]interface{}{"cdn_fronted": true, "cdn": "cloudflare"}},
+		{name: "origin", result: testResult{ip: "10.0.0.5", port: 443}, want: map[string]interface{}{"cdn_fronted": nil, "cdn": nil}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := b.serviceData(tt.result)
+			if err != nil {
+				t.Fatalf("serviceData() error = %v", err)
+			}
+			for key, want := range tt.want {
+				if got := data.Metadata[key]; got != want {
+					t.Errorf("metadata[%s] = %v, want %v", key, got, want)
+				}
+			}
+		})
+	}
+}
+
+// knownResult is a scan result that may be in the known assets inventory.
+type knownResult struct {
+	testResult
+	known bool
+}
+
+func (r knownResult) GetKnown() bool { return r.known }
+
+func TestKnownServiceMetadata(t *testing.T) {
+	var b eventBuilder
+	tests := []struct {
+		name   string
+		result interface{}
+		want   interface{}
+	}{
+		{name: "known", result: knownResult{testResult{ip: "10.0.0.5", port: 22}, true}, want: true},
+		{name: "new", result: knownResult{testResult{ip: "10.0.0.5", port: 22}, false}, want: nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := b.serviceData(tt.result)
+			if err != nil {
+				t.Fatalf("serviceData() error = %v", err)
+			}
+			if got := data.Metadata["known"]; got != tt.want {
+				t.Errorf("metadata[known] = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// sourceResult is a scan result identified by a fingerprint signature.
+type sourceResult struct {
+	testResult
+	source string
+}
+
+func (r sourceResult) GetFingerprintSource() string { return r.source }
+
+func TestFingerprintSourceMetadata(t *testing.T) {
+	var b eventBuilder
+	tests := []struct {
+		name   string
+		result interface{}
+		want   interface{}
+	}{
+		{name: "signature", result: sourceResult{testResult{ip: "10.0.0.5", port: 22}, "banner:ssh"}, want: "banner:ssh"},
+		{name: "port fallback", result: sourceResult{testResult{ip: "10.0.0.5", port: 22}, "port"}, want: "port"},
+		{name: "unset", result: sourceResult{testResult{ip: "10.0.0.5", port: 22}, ""}, want: nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := b.serviceData(tt.result)
+			if err != nil {
+				t.Fatalf("serviceData() error = %v", err)
+			}
+			if got := data.Metadata["fingerprint_source"]; got != tt.want {
+				t.Errorf("metadata[fingerprint_source] = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// metadataResult is a scan result carrying probe metadata.
+type metadataResult struct {
+	testResult
+	metadata map[string]interface{}
+}
+
+func (r metadataResult) GetMetadata() map[string]interface{} { return r.metadata }
+
+func TestProbeMetadataInServiceEvent(t *testing.T) {
+	var b eventBuilder
+	result := metadataResult{testResult{ip: "10.0.0.5", port: 443}, map[string]interface{}{
+		"alpn": []string{"h2", "http/1.1"}, "http3_advertised": true,
+	}}
+
+	data, err := b.serviceData(result)
+	if err != nil {
+		t.Fatalf("serviceData() error = %v", err)
+	}
+	if got := fmt.Sprint(data.Metadata["alpn"]); got != "[h2 http/1.1]" {
+		t.Errorf("metadata[alpn] = %s, want [h2 http/1.1]", got)
+	}
+	if got := data.Metadata["http3_advertised"]; got != true {
+		t.Errorf("metadata[http3_advertised] = %v, want true", got)
+	}
+}
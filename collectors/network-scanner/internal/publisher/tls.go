@@ -0,0 +1,92 @@
+package publisher
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+	"strings"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+	"go.uber.org/zap"
+)
+
+// TLSOptions configures TLS for the RabbitMQ connection: a CA bundle to
+// verify the broker with instead of the system roots, and a client
+// certificate and key for mutual TLS.
+type TLSOptions struct {
+	CACert     string // PEM file
+	ClientCert string // PEM file; requires ClientKey
+	ClientKey  string // PEM file
+	SkipVerify bool   // don't verify the broker certificate (testing only)
+}
+
+func (o TLSOptions) enabled() bool {
+	return o.CACert != "" || o.ClientCert != "" || o.ClientKey != "" || o.SkipVerify
+}
+
+// TLSConfig builds the tls.Config for the options, or returns nil when none
+// is set. Unreadable or invalid files are reported by option.
+func (o TLSOptions) TLSConfig() (*tls.Config, error) {
+	if !o.enabled() {
+		return nil, nil
+	}
+
+	config := &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		InsecureSkipVerify: o.SkipVerify,
+	}
+
+	if o.CACert != "" {
+		pem, err := os.ReadFile(o.CACert)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read TLS CA certificate: %w", err)
+		}
+		config.RootCAs = x509.NewCertPool()
+		if !config.RootCAs.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("TLS CA certificate %s contains no PEM certificates", o.CACert)
+		}
+	}
+
+	if o.ClientCert != "" || o.ClientKey != "" {
+		if o.ClientCert == "" || o.ClientKey == "" {
+			return nil, fmt.Errorf("TLS client certificate and key must be set together")
+		}
+		cert, err := tls.LoadX509KeyPair(o.ClientCert, o.ClientKey)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load TLS client certificate: %w", err)
+		}
+		config.Certificates = []tls.Certificate{cert}
+	}
+
+	return config, nil
+}
+
+// NewTLS creates a RabbitMQ publisher like New, connecting over TLS built
+// from opts. With any option set an amqp:// URL is dialed as amqps://; with
+// none it is the same as New, where amqps:// URLs use the system roots.
+func NewTLS(url string, opts TLSOptions, logger *zap.SugaredLogger) (*Publisher, error) {
+	config, err := opts.TLSConfig()
+	if err != nil {
+		return nil, err
+	}
+	if config == nil {
+		return New(url, logger)
+	}
+	return newPublisher(url, dialAMQPTLS(config), logger)
+}
+
+// dialAMQPTLS returns a dial function connecting with config. Each dial
+// gets its own copy, since the client fills in the server name.
+func dialAMQPTLS(config *tls.Config) func(string) (amqpConnection, error) {
+	return func(url string) (amqpConnection, error) {
+		if rest, ok := strings.CutPrefix(url, "amqp://"); ok {
+			url = "amqps://" + rest
+		}
+		conn, err := amqp.DialTLS(url, config.Clone())
+		if err != nil {
+			return nil, err
+		}
+		return amqpConn{conn}, nil
+	}
+}
@@ -0,0 +1,105 @@
+package publisher
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// writeCert writes a self-signed certificate and its key as PEM files in
+// dir and returns their paths.
+func writeCert(t *testing.T, dir string) (string, string) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "scanner"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	certFile, keyFile := filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+	if err := os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return certFile, keyFile
+}
+
+func TestTLSConfig(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := writeCert(t, dir)
+	notPEM := filepath.Join(dir, "not.pem")
+	if err := os.WriteFile(notPEM, []byte("not a certificate"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name         string
+		opts         TLSOptions
+		wantNil      bool
+		wantRoots    bool
+		wantCerts    int
+		wantInsecure bool
+		wantErr      bool
+	}{
+		{name: "no options", wantNil: true},
+		{name: "CA", opts: TLSOptions{CACert: certFile}, wantRoots: true},
+		{name: "mutual TLS", opts: TLSOptions{CACert: certFile, ClientCert: certFile, ClientKey: keyFile}, wantRoots: true, wantCerts: 1},
+		{name: "skip verify", opts: TLSOptions{SkipVerify: true}, wantInsecure: true},
+		{name: "missing CA file", opts: TLSOptions{CACert: filepath.Join(dir, "missing.pem")}, wantErr: true},
+		{name: "CA without certificates", opts: TLSOptions{CACert: notPEM}, wantErr: true},
+		{name: "client cert without key", opts: TLSOptions{ClientCert: certFile}, wantErr: true},
+		{name: "key that doesn't parse", opts: TLSOptions{ClientCert: certFile, ClientKey: notPEM}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config, err := tt.opts.TLSConfig()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("TLSConfig() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if (config == nil) != tt.wantNil {
+				t.Fatalf("TLSConfig() = %v, want nil %v", config, tt.wantNil)
+			}
+			if config == nil {
+				return
+			}
+			if (config.RootCAs != nil) != tt.wantRoots {
+				t.Errorf("RootCAs set %v, want %v", config.RootCAs != nil, tt.wantRoots)
+			}
+			if len(config.Certificates) != tt.wantCerts {
+				t.Errorf("%d client certificates, want %d", len(config.Certificates), tt.wantCerts)
+			}
+			if config.InsecureSkipVerify != tt.wantInsecure {
+				t.Errorf("InsecureSkipVerify = %v, want %v", config.InsecureSkipVerify, tt.wantInsecure)
+			}
+		})
+	}
+}
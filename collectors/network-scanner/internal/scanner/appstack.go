@@ -0,0 +1,90 @@
+package scanner
+
+import (
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// appStackIndicators give away the application behind a web server or
+// reverse proxy, whose own Server header (e.g. nginx) hides it. An
+// X-Powered-By naming the framework is more telling than a session cookie
+// name that other stacks may reuse.
+var appStackIndicators = []struct {
+	header     string // canonical header name, or Set-Cookie for cookie names
+	pattern    string // lowercase substring of the value or cookie name
+	stack      string
+	confidence float64
+}{
+	{"X-Powered-By", "express", "Express", 0.9},
+	{"X-Powered-By", "php", "PHP", 0.9},
+	{"X-Powered-By", "asp.net", "ASP.NET", 0.9},
+	{"X-Powered-By", "servlet", "Java", 0.8},
+	{"X-Application-Context", "", "Spring", 0.8},
+	{"X-Aspnet-Version", "", "ASP.NET", 0.8},
+	{"Server", "wsgiserver", "Django", 0.6},
+	{"Server", "gunicorn", "Python", 0.6},
+	{"Set-Cookie", "csrftoken", "Django", 0.6},
+	{"Set-Cookie", "django_language", "Django", 0.7},
+	{"Set-Cookie", "phpsessid", "PHP", 0.7},
+	{"Set-Cookie", "laravel_session", "PHP", 0.7},
+	{"Set-Cookie", "connect.sid", "Express", 0.7},
+	{"Set-Cookie", "asp.net_sessionid", "ASP.NET", 0.7},
+	// Any servlet container issues JSESSIONID; Spring is the usual one
+	{"Set-Cookie", "jsessionid", "Spring", 0.5},
+}
+
+// inferAppStack guesses the application stack from response headers. The
+// most confident indicator wins; ties go to the earlier indicator. It
+// returns "" when no header gives the stack away.
+func inferAppStack(header http.Header) (string, float64) {
+	cookies := cookieNames(header)
+
+	var stack string
+	var confidence float64
+	for _, ind := range appStackIndicators {
+		if ind.confidence <= confidence {
+			continue
+		}
+		var values []string
+		if ind.header == "Set-Cookie" {
+			values = cookies
+		} else {
+			values = header.Values(ind.header)
+		}
+		for _, v := range values {
+			if strings.Contains(strings.ToLower(v), ind.pattern) {
+				stack, confidence = ind.stack, ind.confidence
+				break
+			}
+		}
+	}
+	return stack, confidence
+}
+
+// responseHeaders flattens response headers for metadata, joining repeated
+// headers with ", ". Set-Cookie is reduced to the cookie names, since the
+// values can be live session tokens.
+func responseHeaders(header http.Header) map[string]string {
+	headers := make(map[string]string, len(header))
+	for name, values := range header {
+		if name == "Set-Cookie" {
+			continue
+		}
+		headers[name] = strings.Join(values, ", ")
+	}
+	if names := cookieNames(header); len(names) > 0 {
+		headers["Set-Cookie"] = strings.Join(names, ", ")
+	}
+	return headers
+}
+
+// cookieNames returns the sorted names of the cookies a response sets.
+func cookieNames(header http.Header) []string {
+	var names []string
+	for _, c := range (&http.Response{Header: header}).Cookies() {
+		names = append(names, c.Name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,79 @@
+package scanner
+
+import (
+	"net/http"
+	"reflect"
+	"testing"
+)
+
+func TestInferAppStack(t *testing.T) {
+	tests := []struct {
+		name           string
+		header         http.Header
+		wantStack      string
+		wantConfidence float64
+	}{
+		{
+			name:           "express behind nginx",
+			header:         http.Header{"Server": {"nginx/1.24.0"}, "X-Powered-By": {"Express"}},
+			wantStack:      "Express",
+			wantConfidence: 0.9,
+		},
+		{
+			name:           "express session cookie",
+			header:         http.Header{"Set-Cookie": {"connect.sid=s%3Aabc.def; Path=/; HttpOnly"}},
+			wantStack:      "Express",
+			wantConfidence: 0.7,
+		},
+		{
+			name:           "jsessionid",
+			header:         http.Header{"Set-Cookie": {"JSESSIONID=8F2C1A; Path=/app; Secure; HttpOnly"}},
+			wantStack:      "Spring",
+			wantConfidence: 0.5,
+		},
+		{
+			name: "x-powered-by outranks jsessionid",
+			header: http.Header{
+				"X-Powered-By": {"Servlet/4.0 JSP/2.3"},
+				"Set-Cookie":   {"JSESSIONID=8F2C1A; Path=/"},
+			},
+			wantStack:      "Java",
+			wantConfidence: 0.8,
+		},
+		{
+			name:           "tie goes to the earlier indicator",
+			header:         http.Header{"Set-Cookie": {"PHPSESSID=1", "laravel_session=2"}},
+			wantStack:      "PHP",
+			wantConfidence: 0.7,
+		},
+		{
+			name:   "cookie value isn't a cookie name",
+			header: http.Header{"Set-Cookie": {"session=jsessionid"}},
+		},
+		{name: "plain nginx", header: http.Header{"Server": {"nginx"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stack, confidence := inferAppStack(tt.header)
+			if stack != tt.wantStack || confidence != tt.wantConfidence {
+				t.Errorf("inferAppStack() = %q, %g; want %q, %g", stack, confidence, tt.wantStack, tt.wantConfidence)
+			}
+		})
+	}
+}
+
+func TestResponseHeadersHideCookieValues(t *testing.T) {
+	header := http.Header{
+		"Server":     {"nginx"},
+		"Vary":       {"Accept", "Origin"},
+		"Set-Cookie": {"JSESSIONID=8F2C1A; Path=/", "XSRF-TOKEN=secret; Path=/"},
+	}
+	want := map[string]string{
+		"Server":     "nginx",
+		"Vary":       "Accept, Origin",
+		"Set-Cookie": "JSESSIONID, XSRF-TOKEN",
+	}
+	if got := responseHeaders(header); !reflect.DeepEqual(got, want) {
+		t.Errorf("responseHeaders() = %v, want %v", got, want)
+	}
+}
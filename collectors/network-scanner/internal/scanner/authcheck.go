@@ -0,0 +1,93 @@
+package scanner
+
+import (
+	"context"
+	"net"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// accessCheck asks a fingerprinted service for its details with a command
+// that needs no arguments. parse reports whether the service demanded
+// authentication and its version, or false when the response says neither.
+type accessCheck struct {
+	probe   string // active_probes name gating the check
+	command string
+	parse   func(response string) (requiresAuth bool, version string, ok bool)
+}
+
+// accessChecks are run on services fingerprinted as these names.
+var accessChecks = map[string]accessCheck{
+	"Redis":     {probe: "redis", command: "INFO server\r\n", parse: parseRedisInfo},
+	"Memcached": {probe: "memcached", command: "stats\r\n", parse: parseMemcachedStats},
+}
+
+// checkAccess records in result's metadata whether a Redis or memcached
+// service answers without authentication (requires_auth), flagging open
+// access as a high-severity finding, and fills in the version it reports.
+// The check dials afresh under the rate limit and stops with ctx.
+func (s *Scanner) checkAccess(ctx context.Context, result *ScanResult, timeout time.Duration) {
+	check, ok := accessChecks[result.Service]
+	if !ok || !s.activeProbeEnabled(check.probe) {
+		return
+	}
+
+	address := net.JoinHostPort(result.IP, strconv.Itoa(result.Port))
+	conn, _, err := s.dialProbe(ctx, "tcp", address, timeout)
+	if err != nil {
+		return
+	}
+	defer func() { _ = conn.Close() }()
+
+	response := sendProbe(conn, check.command, time.Duration(s.config.ActiveProbeTimeoutMS)*time.Millisecond)
+	requiresAuth, version, ok := check.parse(response)
+	if !ok {
+		return
+	}
+
+	if result.Metadata == nil {
+		result.Metadata = make(map[string]interface{})
+	}
+	result.Metadata["requires_auth"] = requiresAuth
+	if !requiresAuth {
+		result.Metadata["unauthenticated_access"] = true
+		result.Metadata["finding_severity"] = "high"
+	}
+	if version != "" && result.Version == "" {
+		result.Version = version
+	}
+}
+
+var redisVersionPattern = regexp.MustCompile(`(?m)^redis_version:(\S+)`)
+
+// parseRedisInfo reads an INFO reply. NOAUTH means a password is required;
+// DENIED (protected mode) refuses remote commands until one is set.
+func parseRedisInfo(response string) (bool, string, bool) {
+	switch {
+	case strings.HasPrefix(response, "-NOAUTH"), strings.HasPrefix(response, "-DENIED"):
+		return true, "", true
+	case strings.HasPrefix(response, "$"):
+		if m := redisVersionPattern.FindStringSubmatch(response); m != nil {
+			return false, m[1], true
+		}
+		return false, "", true
+	}
+	return false, "", false
+}
+
+var memcachedVersionPattern = regexp.MustCompile(`(?m)^STAT version (\S+)`)
+
+// parseMemcachedStats reads a stats reply. Servers with ASCII
+// authentication enabled answer unauthenticated commands with
+// CLIENT_ERROR unauthenticated.
+func parseMemcachedStats(response string) (bool, string, bool) {
+	if strings.HasPrefix(response, "CLIENT_ERROR unauthenticated") {
+		return true, "", true
+	}
+	if m := memcachedVersionPattern.FindStringSubmatch(response); m != nil {
+		return false, m[1], true
+	}
+	return false, "", false
+}
@@ -0,0 +1,82 @@
+package scanner
+
+import (
+	"bufio"
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/aiforce-discovery-agent/collectors/network-scanner/internal/config"
+)
+
+const redisInfoServer = "$180\r\n# Server\r\nredis_version:7.2.4\r\nredis_git_sha1:00000000\r\nredis_mode:standalone\r\nos:Linux 6.1.0 x86_64\r\narch_bits:64\r\ntcp_port:6379\r\n\r\n"
+
+func TestCheckAccess(t *testing.T) {
+	tests := []struct {
+		name         string
+		service      string
+		probes       []string
+		reply        string
+		wantAuth     interface{}
+		wantOpen     interface{}
+		wantSeverity interface{}
+		wantVersion  string
+		wantDials    int32
+	}{
+		{
+			name: "open redis", service: "Redis", probes: []string{"redis"}, reply: redisInfoServer,
+			wantAuth: false, wantOpen: true, wantSeverity: "high", wantVersion: "7.2.4", wantDials: 1,
+		},
+		{
+			name: "redis with a password", service: "Redis", probes: []string{"redis"},
+			reply: "-NOAUTH Authentication required.\r\n", wantAuth: true, wantDials: 1,
+		},
+		{
+			name: "redis in protected mode", service: "Redis", probes: []string{"redis"},
+			reply: "-DENIED Redis is running in protected mode because protected mode is enabled\r\n", wantAuth: true, wantDials: 1,
+		},
+		{name: "not redis", service: "Redis", probes: []string{"redis"}, reply: "HTTP/1.1 400 Bad Request\r\n\r\n", wantDials: 1},
+		{
+			name: "open memcached", service: "Memcached", probes: []string{"memcached"},
+			reply:    "STAT pid 1\r\nSTAT uptime 42\r\nSTAT version 1.6.21\r\nEND\r\n",
+			wantAuth: false, wantOpen: true, wantSeverity: "high", wantVersion: "1.6.21", wantDials: 1,
+		},
+		{
+			name: "memcached with sasl", service: "Memcached", probes: []string{"memcached"},
+			reply: "CLIENT_ERROR unauthenticated\r\n", wantAuth: true, wantDials: 1,
+		},
+		{name: "probe disabled", service: "Redis", reply: redisInfoServer},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestScanner(t, config.ScannerConfig{ActiveProbes: tt.probes, ActiveProbeTimeoutMS: 1000})
+			var dials int32
+			s.dial = pipeDial(&dials, func(conn net.Conn) {
+				if _, err := bufio.NewReader(conn).ReadString('\n'); err != nil {
+					return
+				}
+				_, _ = conn.Write([]byte(tt.reply))
+			})
+
+			result := ScanResult{IP: "192.0.2.1", Port: 6379, Service: tt.service}
+			s.checkAccess(context.Background(), &result, time.Second)
+
+			if dials != tt.wantDials {
+				t.Errorf("dials = %d, want %d", dials, tt.wantDials)
+			}
+			if got := result.Metadata["requires_auth"]; got != tt.wantAuth {
+				t.Errorf("requires_auth = %v, want %v", got, tt.wantAuth)
+			}
+			if got := result.Metadata["unauthenticated_access"]; got != tt.wantOpen {
+				t.Errorf("unauthenticated_access = %v, want %v", got, tt.wantOpen)
+			}
+			if got := result.Metadata["finding_severity"]; got != tt.wantSeverity {
+				t.Errorf("finding_severity = %v, want %v", got, tt.wantSeverity)
+			}
+			if result.Version != tt.wantVersion {
+				t.Errorf("version = %q, want %q", result.Version, tt.wantVersion)
+			}
+		})
+	}
+}
@@ -0,0 +1,142 @@
+package scanner
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aiforce-discovery-agent/collectors/network-scanner/internal/secrets"
+)
+
+// authProbes are read-only authenticated probes keyed by identified
+// service. Each logs in over conn and returns metadata confirmed after
+// login.
+var authProbes = map[string]func(conn net.Conn, creds secrets.Credentials, timeout time.Duration) (map[string]interface{}, error){
+	"Redis": probeRedisAuthenticated,
+}
+
+// SetSecretProvider installs the credential source for authenticated
+// probes, replacing the one built from config (e.g. with a Vault client).
+func (s *Scanner) SetSecretProvider(provider secrets.Provider) {
+	s.secrets = provider
+}
+
+// authenticatedProbe runs the authenticated probe for result's service, if
+// one exists and credentials are available. Secret values are never logged;
+// failures are logged with the target only.
+func (s *Scanner) authenticatedProbe(ctx context.Context, result *ScanResult, timeout time.Duration) {
+	if s.secrets == nil {
+		return
+	}
+	probe, ok := authProbes[result.Service]
+	if !ok {
+		return
+	}
+
+	target := secrets.Target{
+		Service: strings.ToLower(result.Service),
+		IP:      result.IP,
+		Port:    result.Port,
+	}
+	lookupCtx, cancel := context.WithTimeout(ctx, timeout)
+	creds, err := s.secrets.Credentials(lookupCtx, target)
+	cancel()
+	if err != nil {
+		if !errors.Is(err, secrets.ErrNotFound) {
+			s.log(ctx).Warnw("Failed to fetch probe credentials",
+				"service", target.Service, "ip", target.IP, "port", target.Port, "error", err)
+		}
+		return
+	}
+
+	address := net.JoinHostPort(result.IP, strconv.Itoa(result.Port))
+	conn, _, err := s.dialProbe(ctx, "tcp", address, timeout)
+	if err != nil {
+		return
+	}
+	defer func() { _ = conn.Close() }()
+
+	metadata, err := probe(conn, creds, timeout)
+	if err != nil {
+		s.log(ctx).Debugw("Authenticated probe failed",
+			"service", target.Service, "ip", target.IP, "port", target.Port, "error", err)
+		return
+	}
+
+	if result.Metadata == nil {
+		result.Metadata = make(map[string]interface{})
+	}
+	for k, v := range metadata {
+		result.Metadata[k] = v
+	}
+	result.Metadata["authenticated_probe"] = true
+}
+
+// probeRedisAuthenticated logs in with AUTH and reads the server version
+// from INFO server. Both commands are read-only.
+func probeRedisAuthenticated(conn net.Conn, creds secrets.Credentials, timeout time.Duration) (map[string]interface{}, error) {
+	if err := conn.SetDeadline(time.Now().Add(timeout)); err != nil {
+		return nil, err
+	}
+
+	reader := bufio.NewReader(conn)
+
+	auth := []string{"AUTH", creds.Password.Reveal()}
+	if creds.Username != "" {
+		auth = []string{"AUTH", creds.Username, creds.Password.Reveal()}
+	}
+	if _, err := conn.Write(respCommand(auth...)); err != nil {
+		return nil, err
+	}
+	line, err := reader.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	if !strings.HasPrefix(line, "+OK") {
+		// Don't echo the reply; some servers include the command in errors
+		return nil, fmt.Errorf("redis authentication rejected")
+	}
+
+	if _, err := conn.Write(respCommand("INFO", "server")); err != nil {
+		return nil, err
+	}
+	header, err := reader.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	if !strings.HasPrefix(header, "$") {
+		return nil, fmt.Errorf("unexpected INFO reply")
+	}
+	size, err := strconv.Atoi(strings.TrimSpace(header[1:]))
+	if err != nil || size < 0 || size > maxBannerSize*16 {
+		return nil, fmt.Errorf("unexpected INFO reply size")
+	}
+	body := make([]byte, size)
+	if _, err := io.ReadFull(reader, body); err != nil {
+		return nil, err
+	}
+
+	for _, field := range strings.Split(string(body), "\r\n") {
+		if version, ok := strings.CutPrefix(field, "redis_version:"); ok {
+			return map[string]interface{}{"version": version}, nil
+		}
+	}
+	return nil, fmt.Errorf("redis_version missing from INFO reply")
+}
+
+// respCommand encodes a Redis command as a RESP array so arguments may
+// contain spaces.
+func respCommand(args ...string) []byte {
+	var b strings.Builder
+	fmt.Fprintf(&b, "*%d\r\n", len(args))
+	for _, arg := range args {
+		fmt.Fprintf(&b, "$%d\r\n%s\r\n", len(arg), arg)
+	}
+	return []byte(b.String())
+}
@@ -0,0 +1,153 @@
+package scanner
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aiforce-discovery-agent/collectors/network-scanner/internal/config"
+	"github.com/aiforce-discovery-agent/collectors/network-scanner/internal/secrets"
+)
+
+const testRedisPassword = "hunter2-do-not-log"
+
+// fakeProvider returns fixed credentials for one target and records every
+// lookup.
+type fakeProvider struct {
+	mu      sync.Mutex
+	target  secrets.Target
+	creds   secrets.Credentials
+	lookups []secrets.Target
+}
+
+func (p *fakeProvider) Credentials(_ context.Context, target secrets.Target) (secrets.Credentials, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.lookups = append(p.lookups, target)
+	if target != p.target {
+		return secrets.Credentials{}, secrets.ErrNotFound
+	}
+	return p.creds, nil
+}
+
+// serveRedis answers AUTH with reply and INFO server with a version.
+func serveRedis(reply string) func(net.Conn) {
+	return func(conn net.Conn) {
+		reader := bufio.NewReader(conn)
+		for {
+			header, err := reader.ReadString('\n')
+			if err != nil {
+				return
+			}
+			var n int
+			if _, err := fmt.Sscanf(header, "*%d\r\n", &n); err != nil {
+				return
+			}
+			var args []string
+			for i := 0; i < n; i++ {
+				if _, err := reader.ReadString('\n'); err != nil {
+					return
+				}
+				arg, err := reader.ReadString('\n')
+				if err != nil {
+					return
+				}
+				args = append(args, strings.TrimRight(arg, "\r\n"))
+			}
+			switch args[0] {
+			case "AUTH":
+				if args[len(args)-1] != testRedisPassword {
+					reply = "-WRONGPASS invalid password\r\n"
+				}
+				_, _ = conn.Write([]byte(reply))
+			case "INFO":
+				body := "# Server\r\nredis_version:7.2.4\r\n"
+				_, _ = fmt.Fprintf(conn, "$%d\r\n%s\r\n", len(body), body)
+			}
+		}
+	}
+}
+
+func TestAuthenticatedProbe(t *testing.T) {
+	target := secrets.Target{Service: "redis", IP: "10.0.0.5", Port: 6379}
+
+	tests := []struct {
+		name        string
+		result      ScanResult
+		password    string
+		authReply   string
+		wantVersion string
+		wantDials   int32
+	}{
+		{
+			name:        "credentials for the exact target",
+			result:      ScanResult{IP: "10.0.0.5", Port: 6379, Service: "Redis"},
+			password:    testRedisPassword,
+			authReply:   "+OK\r\n",
+			wantVersion: "7.2.4",
+			wantDials:   1,
+		},
+		{
+			name:      "wrong password",
+			result:    ScanResult{IP: "10.0.0.5", Port: 6379, Service: "Redis"},
+			password:  "wrong",
+			authReply: "+OK\r\n",
+			wantDials: 1,
+		},
+		{
+			name:      "no credentials for another host",
+			result:    ScanResult{IP: "10.0.0.6", Port: 6379, Service: "Redis"},
+			password:  testRedisPassword,
+			wantDials: 0,
+		},
+		{
+			name:      "service without an authenticated probe",
+			result:    ScanResult{IP: "10.0.0.5", Port: 6379, Service: "HTTP"},
+			password:  testRedisPassword,
+			wantDials: 0,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestScanner(t, config.ScannerConfig{})
+			logs := observeLogs(s)
+			provider := &fakeProvider{
+				target: target,
+				creds:  secrets.Credentials{Password: secrets.Secret(tt.password)},
+			}
+			s.SetSecretProvider(provider)
+			var dials int32
+			s.dial = pipeDial(&dials, serveRedis(tt.authReply))
+
+			result := tt.result
+			s.authenticatedProbe(context.Background(), &result, time.Second)
+
+			if dials != tt.wantDials {
+				t.Errorf("dials = %d, want %d", dials, tt.wantDials)
+			}
+			got, _ := result.Metadata["version"].(string)
+			if got != tt.wantVersion {
+				t.Errorf("version = %q, want %q", got, tt.wantVersion)
+			}
+			if authed := result.Metadata["authenticated_probe"] == true; authed != (tt.wantVersion != "") {
+				t.Errorf("authenticated_probe = %v", authed)
+			}
+			if tt.result.Service == "Redis" && len(provider.lookups) != 1 {
+				t.Errorf("credential lookups = %d, want 1", len(provider.lookups))
+			}
+
+			for _, entry := range logs.All() {
+				line := fmt.Sprint(entry.Message, entry.ContextMap())
+				if strings.Contains(line, testRedisPassword) {
+					t.Errorf("password logged: %s", line)
+				}
+			}
+		})
+	}
+}
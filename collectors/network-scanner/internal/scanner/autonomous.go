@@ -3,17 +3,22 @@ package scanner
 import (
 	"context"
 	"fmt"
-	"net"
+	"math/rand"
+	"sync"
 	"sync/atomic"
 	"time"
 
 	"github.com/aiforce-discovery-agent/collectors/network-scanner/internal/callback"
+	"github.com/aiforce-discovery-agent/collectors/network-scanner/internal/metrics"
+	"github.com/aiforce-discovery-agent/collectors/network-scanner/internal/publisher"
+	"golang.org/x/time/rate"
 )
 
 // AutonomousScanConfig holds configuration for an autonomous scan (ADR-007).
 type AutonomousScanConfig struct {
 	ScanID             string
-	Subnets            []string
+	Subnets            []string // CIDRs or start-end ranges
+	Targets            []string // IPs, hostnames, host:port entries or start-end ranges
 	PortRanges         []string
 	RateLimitPPS       int
 	TimeoutMS          int
@@ -21,31 +26,137 @@ type AutonomousScanConfig struct {
 	DeadHostThreshold  int
 	ProgressURL        string
 	CompleteURL        string
+	HeartbeatURL       string
+	ScanTimeoutSeconds int // wall-clock cap; 0 = none
 	APIKey             string
 }
 
-// StartAutonomous begins an autonomous scan with custom config and callbacks (ADR-007).
+// StartAutonomous begins an autonomous scan with custom config and
+// callbacks (ADR-007). Scans with distinct IDs run concurrently, up to
+// MaxConcurrentScans, each in its own session.
 func (s *Scanner) StartAutonomous(cfg AutonomousScanConfig) error {
+	return s.startSession(cfg, nil)
+}
+
+// startSession starts a session for cfg, resuming from checkpoint when it
+// is not nil.
+func (s *Scanner) startSession(cfg AutonomousScanConfig, checkpoint *Checkpoint) error {
 	s.mu.Lock()
+	if s.draining {
+		s.mu.Unlock()
+		return ErrDraining
+	}
 	if s.running {
 		s.mu.Unlock()
 		return fmt.Errorf("scanner already running")
 	}
-	s.running = true
+	if _, ok := s.sessions[cfg.ScanID]; ok {
+		s.mu.Unlock()
+		return fmt.Errorf("%w: %s", ErrScanIDActive, cfg.ScanID)
+	}
+	if s.config.SignCallbacks && s.config.CallbackSigningSecret == "" && cfg.APIKey == "" {
+		s.mu.Unlock()
+		return ErrNoSigningKey
+	}
+	limit := s.config.MaxConcurrentScans
+	if limit < 1 {
+		limit = 1
+	}
+	if running := len(s.sessions); running >= limit {
+		s.mu.Unlock()
+		return fmt.Errorf("%w: %d already running", ErrTooManyScans, running)
+	}
+	if s.ledger != nil {
+		if err := s.ledger.claim(cfg.ScanID); err != nil {
+			s.mu.Unlock()
+			return err
+		}
+	}
+	session := s.newSession(cfg.ScanID)
+	session.request = cfg
+	if checkpoint != nil {
+		session.randSeed = checkpoint.Seed
+		session.resumeFrom = checkpoint.Sources
+	}
+	s.sessions[cfg.ScanID] = session
+	s.lastSession = session
+	s.mu.Unlock()
+
+	session.startAutonomous(cfg, checkpoint)
+	return nil
+}
+
+// newSession returns a Scanner for one autonomous scan. It shares the
+// root's publisher connection, fingerprints, caches and descriptor
+// throttle, and starts from the root's config with its own context, rate
+// limiter and counters.
+func (s *Scanner) newSession(scanID string) *Scanner {
+	ctx, cancel := context.WithCancel(context.Background())
+	feedCtx, stopFeed := context.WithCancel(ctx)
+
+	return &Scanner{
+		config:         s.config,
+		publisher:      s.publisher.ForScan(scanID),
+		logger:         s.logger.With("scan_id", scanID),
+		limiter:        newProbeLimiter(s.config.RateLimit, s.config.RateBurst),
+		publishLimiter: s.publishLimiter,
+		hostLimiters:   s.hostLimiters,
+		dial:           s.dial,
+		fdPausedUntil:  s.fdPausedUntil,
+		fingerprinter:  s.fingerprinter,
+		cloudDetector:  s.cloudDetector,
+		results:        s.results,
+		knownAssets:    s.knownAssets,
+		pingMode:       s.pingMode,
+		ledger:         s.ledger,
+		checkpoints:    s.checkpoints,
+		progressHub:    s.progressHub,
+		progressKick:   make(chan struct{}, 1),
+		secrets:        s.secrets,
+		randSeed:       s.randSeed,
+		excludeNets:    s.excludeNets,
+		excludeIPs:     s.excludeIPs,
+		ctx:            ctx,
+		cancel:         cancel,
+		feedCtx:        feedCtx,
+		stopFeed:       stopFeed,
+		parent:         s,
+		scanID:         scanID,
+		phase:          "initializing",
+		startedAt:      time.Now(),
+		running:        true,
+		done:           make(chan struct{}),
+	}
+}
+
+// endSession forgets a finished session so its scan ID can be reused.
+func (s *Scanner) endSession(session *Scanner) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
 
-	// Reset context for new scan
-	s.ctx, s.cancel = context.WithCancel(context.Background())
+	if s.sessions[session.scanID] == session {
+		delete(s.sessions, session.scanID)
+	}
+}
 
-	// Apply custom config
-	if len(cfg.Subnets) > 0 {
+// startAutonomous applies the request to a new session and starts it.
+func (s *Scanner) startAutonomous(cfg AutonomousScanConfig, checkpoint *Checkpoint) {
+	s.mu.Lock()
+	metrics.ActiveScans.Inc()
+
+	// Apply custom config. Subnets and targets are replaced together so a
+	// targets-only request doesn't also scan previously configured subnets.
+	if len(cfg.Subnets) > 0 || len(cfg.Targets) > 0 {
 		s.config.Subnets = cfg.Subnets
+		s.config.Targets = cfg.Targets
 	}
 	if len(cfg.PortRanges) > 0 {
 		s.config.PortRanges = cfg.PortRanges
 	}
 	if cfg.RateLimitPPS > 0 {
 		s.config.RateLimit = cfg.RateLimitPPS
-		s.limiter = rate.NewLimiter(rate.Limit(cfg.RateLimitPPS), cfg.RateLimitPPS)
+		// In place: Reload adjusts the limiters of running sessions
+		s.limiter.SetLimit(rate.Limit(cfg.RateLimitPPS))
 	}
 	if cfg.TimeoutMS > 0 {
 		s.config.Timeout = cfg.TimeoutMS
@@ -70,65 +181,132 @@ func (s *Scanner) StartAutonomous(cfg AutonomousScanConfig) error {
 		}
 		s.config.DeadHostThreshold = cfg.DeadHostThreshold
 	}
+	if cfg.ScanTimeoutSeconds > 0 {
+		// Past the cap no new hosts are fed; in-flight hosts drain and the
+		// scan completes with status "timeout"
+		feedCtx, stopTimer := context.WithTimeout(s.feedCtx, time.Duration(cfg.ScanTimeoutSeconds)*time.Second)
+		stopFeed := s.stopFeed
+		s.feedCtx = feedCtx
+		s.stopFeed = func() {
+			stopTimer()
+			stopFeed()
+		}
+	}
 
-	// Set up callback reporter
-	s.reporter = callback.NewReporter(cfg.ScanID, cfg.ProgressURL, cfg.CompleteURL, cfg.APIKey, s.logger)
-
-	// Set scan ID on publisher for CloudEvent subject
-	s.publisher.SetScanID(cfg.ScanID)
+	// Set up callback reporter. The scan goroutines capture this pointer at
+	// start and never re-read s.reporter, so finishAutonomousScan clearing the
+	// field cannot race with late IncrementDiscoveryCount calls.
+	reporter := callback.NewReporter(cfg.ScanID, cfg.ProgressURL, cfg.CompleteURL, cfg.APIKey, s.logger)
+	if s.config.SignCallbacks {
+		// A dedicated secret keeps signing independent of the API key
+		key := s.config.CallbackSigningSecret
+		if key == "" {
+			key = cfg.APIKey
+		}
+		reporter.SetSigningKey([]byte(key))
+	}
+	reporter.SetHeartbeatURL(cfg.HeartbeatURL)
+	if checkpoint != nil {
+		reporter.RestoreDiscoveryCount(checkpoint.DiscoveryCount, checkpoint.DiscoveryBreakdown, publisher.CategoryOther)
+	}
+	s.reporter = reporter
+	s.errorReporter.Store(reporter)
+	s.stats.Store(newScanStats())
+	if !s.config.StreamingOnly {
+		s.results.Start(cfg.ScanID)
+	}
 
 	s.mu.Unlock()
 
 	s.logger.Infow("Starting autonomous network scan",
-		"scan_id", cfg.ScanID,
 		"subnets", cfg.Subnets,
+		"targets", len(cfg.Targets),
 		"port_ranges", cfg.PortRanges,
+		"resumed", checkpoint != nil,
 	)
 
 	// Report initial progress
-	if err := s.reporter.ReportProgress("initializing", 0, "Starting network scan"); err != nil {
+	if err := reporter.ReportProgress("initializing", 0, "Starting network scan"); err != nil {
 		s.logger.Warnw("Failed to report initial progress", "error", err)
 	}
 
-	// Start scanning in goroutine
-	go s.runAutonomousScan()
+	s.stopHeartbeats = s.startHeartbeats(reporter, time.Duration(s.config.HeartbeatIntervalSeconds)*time.Second)
 
-	return nil
+	// Start scanning in goroutine
+	go s.runAutonomousScan(reporter)
 }
 
-func (s *Scanner) runAutonomousScan() {
+func (s *Scanner) runAutonomousScan(reporter *callback.Reporter) {
 	// Count total IPs across all subnets for finer-grained progress
 	var totalIPs int64
 	for _, subnet := range s.config.Subnets {
-		_, ipNet, err := net.ParseCIDR(subnet)
+		r, err := s.parseScanSubnet(subnet)
 		if err != nil {
 			continue
 		}
-		ones, bits := ipNet.Mask.Size()
-		totalIPs += 1 << uint(bits-ones)
+		totalIPs += r.size
+		if totalIPs > maxAddressCount {
+			totalIPs = maxAddressCount
+		}
 	}
-	var scannedIPs int64
 
-	// Start periodic progress reporter (every 10s) so the UI stays updated
+	// Explicit targets count one per resolved address
+	targetHosts := s.resolveTargets(s.config.Targets)
+	totalIPs += countAddresses(targetHosts)
+
+	// A resumed scan starts with its completed hosts already counted
+	scannedIPs := &s.scannedIPs
+	atomic.StoreInt64(scannedIPs, s.resumedAddresses(targetHosts))
+
+	// Workers ask for progress updates; one goroutine sends them, so
+	// subscribers see them in order. Stopped before the final update.
+	streamStop := make(chan struct{})
+	streamDone := make(chan struct{})
+	go func() {
+		defer close(streamDone)
+		s.runProgressStream(streamStop)
+	}()
+	stopStream := sync.OnceFunc(func() {
+		close(streamStop)
+		<-streamDone
+	})
+	defer stopStream()
+
+	s.mu.Lock()
+	s.totalIPs = totalIPs
+	s.phase = "port_scanning"
+	s.mu.Unlock()
+	s.notifyProgress()
+
+	// Start periodic progress reporter so the UI stays updated. Intervals
+	// are jittered so a fleet of scanners doesn't call back in lockstep.
+	// stopProgress is the only way progressDone gets closed, so the
+	// cancellation and completion paths can both call it safely.
 	progressDone := make(chan struct{})
+	stopProgress := sync.OnceFunc(func() { close(progressDone) })
+	defer stopProgress()
 	go func() {
-		ticker := time.NewTicker(10 * time.Second)
-		defer ticker.Stop()
+		interval := time.Duration(s.config.ProgressIntervalSeconds) * time.Second
+		if interval <= 0 {
+			interval = 10 * time.Second
+		}
+		timer := time.NewTimer(jitterInterval(interval, s.config.ProgressJitter))
+		defer timer.Stop()
 		for {
 			select {
-			case <-ticker.C:
-				if s.reporter != nil {
-					progress := 0
-					if totalIPs > 0 {
-						progress = int((atomic.LoadInt64(&scannedIPs) * 100) / totalIPs)
-					}
-					if progress > 99 {
-						progress = 99 // Reserve 100 for completion
-					}
-					scanned := atomic.LoadInt64(&scannedIPs)
-					msg := fmt.Sprintf("Scanned %d/%d hosts", scanned, totalIPs)
-					_ = s.reporter.ReportProgress("port_scanning", progress, msg)
+			case <-timer.C:
+				timer.Reset(jitterInterval(interval, s.config.ProgressJitter))
+				progress := 0
+				if totalIPs > 0 {
+					progress = int((atomic.LoadInt64(scannedIPs) * 100) / totalIPs)
+				}
+				if progress > 99 {
+					progress = 99 // Reserve 100 for completion
 				}
+				scanned := atomic.LoadInt64(scannedIPs)
+				msg := fmt.Sprintf("Scanned %d/%d hosts", scanned, totalIPs)
+				_ = reporter.ReportProgress("port_scanning", progress, msg)
+				s.notifyProgress()
 			case <-progressDone:
 				return
 			case <-s.ctx.Done():
@@ -137,54 +315,169 @@ func (s *Scanner) runAutonomousScan() {
 		}
 	}()
 
-	for _, subnet := range s.config.Subnets {
+	// Save checkpoints until the workers are done; waited for so a late
+	// save can't recreate a checkpoint deleted on completion
+	var checkpointWg sync.WaitGroup
+	if s.checkpoints != nil && s.config.CheckpointIntervalSeconds > 0 {
+		interval := time.Duration(s.config.CheckpointIntervalSeconds) * time.Second
+		checkpointWg.Add(1)
+		go func() {
+			defer checkpointWg.Done()
+			s.runCheckpoints(reporter, interval, progressDone)
+		}()
+	}
+
+	// Subnets are scanned concurrently up to MaxConcurrentSubnets. All of
+	// them share s.limiter, so the aggregate PPS cap still holds.
+	maxSubnets := s.config.MaxConcurrentSubnets
+	if maxSubnets <= 0 {
+		maxSubnets = 1
+	}
+	subnetSlots := make(chan struct{}, maxSubnets)
+
+	for i, subnet := range s.config.Subnets {
 		select {
-		case <-s.ctx.Done():
-			close(progressDone)
-			s.finishAutonomousScan("cancelled", "Scan was cancelled")
-			return
-		default:
+		case subnetSlots <- struct{}{}:
+		case <-s.feedCtx.Done():
+		}
+
+		if s.feedCtx.Err() != nil {
+			for _, remaining := range s.config.Subnets[i:] {
+				s.recordSkippedSubnet(remaining, "scan cancelled before subnet started")
+			}
+			break
 		}
 
 		// Report subnet start
-		if s.reporter != nil {
-			scanned := atomic.LoadInt64(&scannedIPs)
-			msg := fmt.Sprintf("Scanning %s (%d/%d hosts done)", subnet, scanned, totalIPs)
-			_ = s.reporter.ReportProgress("port_scanning", int((scanned*100)/totalIPs), msg)
+		scanned := atomic.LoadInt64(scannedIPs)
+		progress := 0
+		if totalIPs > 0 {
+			progress = int((scanned * 100) / totalIPs)
 		}
+		msg := fmt.Sprintf("Scanning %s (%d/%d hosts done)", subnet, scanned, totalIPs)
+		_ = reporter.ReportProgress("port_scanning", progress, msg)
 
 		s.wg.Add(1)
-		s.scanSubnetAutonomous(subnet, &scannedIPs)
+		go func(subnet string) {
+			defer func() { <-subnetSlots }()
+			s.scanSubnetAutonomous(subnet, reporter)
+		}(subnet)
 	}
 
-	close(progressDone)
+	// Explicit targets share the rate limiter with any running subnets
+	if len(targetHosts) > 0 && s.feedCtx.Err() == nil {
+		s.wg.Add(1)
+		go s.scanTargetsAutonomous(targetHosts, reporter)
+	}
+
+	// Keep reporting progress until the last in-flight subnets finish
 	s.wg.Wait()
+	stopProgress()
+	stopStream()
+	checkpointWg.Wait()
+
+	if reason := s.abortReason(); reason != nil {
+		s.finishAutonomousScan(reporter, "failed", reason.Error())
+		return
+	}
+	if s.wasInterrupted() {
+		// Hosts drained before shutdown are marked done; resume from here
+		s.saveCheckpoint(reporter)
+		s.finishAutonomousScan(reporter, "interrupted", "Scan was interrupted by service shutdown")
+		return
+	}
+	if s.feedCtx.Err() == context.DeadlineExceeded {
+		msg := fmt.Sprintf("Scan exceeded its %ds time limit", s.request.ScanTimeoutSeconds)
+		s.finishAutonomousScan(reporter, "timeout", msg)
+		return
+	}
+	if s.feedCtx.Err() != nil {
+		s.finishAutonomousScan(reporter, "cancelled", "Scan was cancelled")
+		return
+	}
 
 	// Check if discoveries were published successfully
-	if s.reporter != nil && s.reporter.GetDiscoveryCount() == 0 {
+	if reporter.GetDiscoveryCount() == 0 {
 		s.logger.Warnw("Scan completed with zero published discoveries")
 	}
-	s.finishAutonomousScan("completed", "")
+	s.finishAutonomousScan(reporter, "completed", "")
+}
+
+// wasInterrupted reports whether a shutdown drained the scan.
+func (s *Scanner) wasInterrupted() bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.interrupted
 }
 
-func (s *Scanner) finishAutonomousScan(status string, errorMsg string) {
+// finishAutonomousScan sends the completion callback through the reporter
+// captured at scan start, detaches it and ends the session.
+func (s *Scanner) finishAutonomousScan(reporter *callback.Reporter, status string, errorMsg string) {
+	// Deferred first so it runs once mu is released
+	defer s.streamFinal()
+
+	// Before taking mu, which a heartbeat reads the phase under
+	s.stopHeartbeats()
+
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
 	s.running = false
+	// Free the slot before the callback so the orchestrator can start the
+	// next scan as soon as it hears this one finished
+	s.parent.endSession(s)
+	s.phase = status
+	s.finishedAt = time.Now()
+	metrics.ActiveScans.Dec()
+	metrics.ScanDuration.Observe(s.finishedAt.Sub(s.startedAt).Seconds())
+	s.finalDiscoveries = reporter.GetDiscoveryCount()
 
-	// Clear scan ID from publisher
-	s.publisher.SetScanID("")
-
-	// Send completion callback
-	if s.reporter != nil {
-		if err := s.reporter.ReportComplete(status, errorMsg); err != nil {
-			s.logger.Errorw("Failed to report completion", "error", err)
+	// Publish any partial batch
+	if err := s.publisher.Flush(); err != nil {
+		s.logger.Errorw("Failed to flush pending discoveries", "error", err)
+	}
+	if !s.config.StreamingOnly {
+		s.results.Finish(reporter.GetScanID(), status)
+	}
+	if s.ledger != nil {
+		s.ledger.release(reporter.GetScanID())
+	}
+	// A stopped scan keeps its checkpoint for resuming; any other outcome
+	// is final
+	if s.checkpoints != nil && !s.keepCheckpoint {
+		if err := s.checkpoints.Delete(reporter.GetScanID()); err != nil {
+			s.logger.Warnw("Failed to delete scan checkpoint", "error", err)
 		}
-		s.logger.Infow("Autonomous scan finished",
-			"status", status,
-			"discovery_count", s.reporter.GetDiscoveryCount(),
-		)
-		s.reporter = nil
 	}
+
+	s.errorReporter.Store(nil)
+
+	// Send completion callback with the probe statistics gathered so far
+	var summary *callback.ScanSummary
+	if st := s.stats.Swap(nil); st != nil {
+		summary = st.summary()
+		summary.VantagePoint = s.config.VantagePoint
+	}
+	if err := reporter.ReportComplete(status, errorMsg, summary); err != nil {
+		s.logger.Errorw("Failed to report completion", "error", err)
+	}
+	// Nothing more is sent for this scan; a completion that failed delivery
+	// keeps being replayed until delivered or expired
+	reporter.Close()
+	s.logger.Infow("Autonomous scan finished",
+		"status", status,
+		"discovery_count", reporter.GetDiscoveryCount(),
+	)
+
+	s.reporter = nil
+	close(s.done)
+}
+
+// jitterInterval returns base shifted by a random amount of up to jitter
+// (a fraction of base) either way.
+func jitterInterval(base time.Duration, jitter float64) time.Duration {
+	if jitter <= 0 {
+		return base
+	}
+	return base + time.Duration((rand.Float64()*2-1)*jitter*float64(base))
 }
@@ -0,0 +1,413 @@
+package scanner
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/aiforce-discovery-agent/collectors/network-scanner/internal/callback"
+	"github.com/aiforce-discovery-agent/collectors/network-scanner/internal/config"
+)
+
+func TestScanTimeout(t *testing.T) {
+	const scanID = "7e4a2c91-3b5d-4f60-8a1e-9c0d2b3f4e5a"
+
+	s := newTestScanner(t, config.ScannerConfig{MaxConcurrentScans: 1, Concurrency: 2, RateLimit: 100000})
+	s.dial = func(_, _ string, _ time.Duration) (net.Conn, error) {
+		time.Sleep(50 * time.Millisecond)
+		return nil, &net.OpError{Op: "dial", Net: "tcp", Err: syscall.ECONNREFUSED}
+	}
+
+	callbacks := httptest.NewServer(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}))
+	defer callbacks.Close()
+
+	// 254 hosts at 25 a second would take 10s without the cap
+	if err := s.StartAutonomous(AutonomousScanConfig{
+		ScanID:             scanID,
+		Subnets:            []string{"10.9.0.0/24"},
+		PortRanges:         []string{"80"},
+		ScanTimeoutSeconds: 1,
+		ProgressURL:        callbacks.URL,
+		CompleteURL:        callbacks.URL,
+	}); err != nil {
+		t.Fatalf("StartAutonomous() error = %v", err)
+	}
+	session := s.lastSession
+	select {
+	case <-session.done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("scan ran past its time limit")
+	}
+
+	records := s.results.Scans()
+	if len(records) != 1 || records[0].Status != "timeout" {
+		t.Errorf("Scans() = %+v, want one scan with status timeout", records)
+	}
+}
+
+func TestSignedCallbacksNeedAKey(t *testing.T) {
+	s := newTestScanner(t, config.ScannerConfig{MaxConcurrentScans: 1, SignCallbacks: true})
+
+	err := s.StartAutonomous(AutonomousScanConfig{ScanID: "2f9d8c7b-6a5e-4d3c-b2a1-0f9e8d7c6b5a", Subnets: []string{"10.9.0.0/30"}})
+	if !errors.Is(err, ErrNoSigningKey) {
+		t.Fatalf("StartAutonomous() error = %v, want ErrNoSigningKey", err)
+	}
+	if len(s.sessions) != 0 {
+		t.Errorf("sessions = %d after a refused start, want 0", len(s.sessions))
+	}
+}
+
+// Run with -race: workers finishing their hosts after a graceful cancel
+// count discoveries while the scan finishes and status is read.
+func TestLateDiscoveriesAfterCancel(t *testing.T) {
+	for i := 0; i < 10; i++ {
+		s := newTestScanner(t, config.ScannerConfig{MaxConcurrentScans: 1, Concurrency: 8, RateLimit: 100000})
+		var dials int32
+		s.dial = pipeDial(&dials, func(net.Conn) { time.Sleep(time.Millisecond) })
+		pub := &recordingPublisher{}
+		s.publisher = pub
+
+		callbacks := httptest.NewServer(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}))
+
+		scanID := fmt.Sprintf("9a1b2c3d-4e5f-4a6b-8c7d-%012d", i)
+		if err := s.StartAutonomous(AutonomousScanConfig{
+			ScanID:      scanID,
+			Subnets:     []string{"10.9.0.0/26"},
+			PortRanges:  []string{"80"},
+			ProgressURL: callbacks.URL,
+			CompleteURL: callbacks.URL,
+		}); err != nil {
+			t.Fatalf("StartAutonomous() error = %v", err)
+		}
+		session := s.lastSession
+
+		stopReading := make(chan struct{})
+		go func() {
+			for {
+				select {
+				case <-stopReading:
+					return
+				default:
+					s.ScanStatus()
+				}
+			}
+		}()
+		for atomic.LoadInt32(&dials) < 8 {
+			time.Sleep(time.Millisecond)
+		}
+		if err := s.Cancel(scanID, CancelGraceful); err != nil && !errors.Is(err, ErrNoScanRunning) {
+			t.Fatalf("Cancel() error = %v", err)
+		}
+		select {
+		case <-session.done:
+		case <-time.After(5 * time.Second):
+			t.Fatal("cancelled scan did not finish")
+		}
+		close(stopReading)
+		callbacks.Close()
+
+		pub.mu.Lock()
+		published := len(pub.services)
+		pub.mu.Unlock()
+		if status := session.status(); status.OpenPorts != published {
+			t.Fatalf("run %d: final discovery count %d, want the %d published", i, status.OpenPorts, published)
+		}
+	}
+}
+
+// Cancels land before, during and right after the subnet loop; none may
+// close the progress channel twice.
+func TestCancelAsScanFinishes(t *testing.T) {
+	callbacks := httptest.NewServer(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}))
+	defer callbacks.Close()
+
+	for i := 0; i < 30; i++ {
+		s := newTestScanner(t, config.ScannerConfig{MaxConcurrentScans: 1, Concurrency: 4, RateLimit: 100000})
+		s.dial = func(_, _ string, _ time.Duration) (net.Conn, error) {
+			return nil, &net.OpError{Op: "dial", Net: "tcp", Err: syscall.ECONNREFUSED}
+		}
+
+		scanID := fmt.Sprintf("4c3b2a19-8f7e-4d6c-9b5a-%012d", i)
+		if err := s.StartAutonomous(AutonomousScanConfig{
+			ScanID:      scanID,
+			Subnets:     []string{"10.9.0.0/29"},
+			PortRanges:  []string{"80"},
+			ProgressURL: callbacks.URL,
+			CompleteURL: callbacks.URL,
+		}); err != nil {
+			t.Fatalf("StartAutonomous() error = %v", err)
+		}
+		session := s.lastSession
+
+		time.Sleep(time.Duration(i%6) * 100 * time.Microsecond)
+		mode := CancelImmediate
+		if i%2 == 0 {
+			mode = CancelGraceful
+		}
+		if err := s.Cancel(scanID, mode); err != nil && !errors.Is(err, ErrNoScanRunning) && !errors.Is(err, ErrScanIDMismatch) {
+			t.Fatalf("Cancel() error = %v", err)
+		}
+		select {
+		case <-session.done:
+		case <-time.After(5 * time.Second):
+			t.Fatal("cancelled scan did not finish")
+		}
+	}
+}
+
+// The scan is cancelled while the first host is between its ports: port
+// 80 has answered and port 81 is being dialed.
+func TestCancelModes(t *testing.T) {
+	const scanID = "6d5c4b3a-2f1e-4d0c-8b9a-7f6e5d4c3b2a"
+	tests := []struct {
+		mode          string
+		wantPreserved bool
+		wantDropped   int64
+	}{
+		{mode: CancelGraceful, wantPreserved: true},
+		{mode: CancelImmediate, wantDropped: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.mode, func(t *testing.T) {
+			s := newTestScanner(t, config.ScannerConfig{MaxConcurrentScans: 1, Concurrency: 1, RateLimit: 100000})
+			var cancelled atomic.Bool
+			s.dial = func(_, address string, _ time.Duration) (net.Conn, error) {
+				if _, port, _ := net.SplitHostPort(address); port == "80" {
+					client, server := net.Pipe()
+					_ = server.Close()
+					return client, nil
+				}
+				if !cancelled.Swap(true) {
+					if err := s.Cancel(scanID, tt.mode); err != nil {
+						t.Errorf("Cancel() error = %v", err)
+					}
+				}
+				return nil, &net.OpError{Op: "dial", Net: "tcp", Err: syscall.ECONNREFUSED}
+			}
+			pub := &recordingPublisher{}
+			s.publisher = pub
+
+			completion := runAutonomous(t, s, AutonomousScanConfig{
+				ScanID: scanID, Subnets: []string{"10.9.0.0/28"}, PortRanges: []string{"80-82"},
+			})
+
+			if completion.Status != "cancelled" {
+				t.Errorf("status = %q, want cancelled", completion.Status)
+			}
+			if completion.Summary == nil || completion.Summary.Cancellation == nil {
+				t.Fatalf("summary = %+v, want cancellation details", completion.Summary)
+			}
+			got := completion.Summary.Cancellation
+			if got.Mode != tt.mode || got.Dropped != tt.wantDropped {
+				t.Errorf("cancellation = %+v, want mode %s with %d dropped", got, tt.mode, tt.wantDropped)
+			}
+			// Graceful: the in-flight host and any already queued finish and
+			// are published; immediate: nothing more is published
+			published := int64(len(pub.services))
+			if tt.wantPreserved && (got.Preserved == 0 || got.Preserved != published) {
+				t.Errorf("preserved %d, published %d; want the in-flight results preserved and published", got.Preserved, published)
+			}
+			if !tt.wantPreserved && (got.Preserved != 0 || published != 0) {
+				t.Errorf("preserved %d, published %d; want none", got.Preserved, published)
+			}
+			if published >= 16 {
+				t.Errorf("published %d, want the scan stopped early", published)
+			}
+		})
+	}
+}
+
+func TestConcurrentScansAreIndependent(t *testing.T) {
+	const (
+		scanA = "a1a1a1a1-0000-4000-8000-000000000001"
+		scanB = "b2b2b2b2-0000-4000-8000-000000000002"
+	)
+
+	s := newTestScanner(t, config.ScannerConfig{MaxConcurrentScans: 2, Concurrency: 1, RateLimit: 100000})
+	// Scan B's hosts hang until released
+	release := make(chan struct{})
+	s.dial = func(_, address string, _ time.Duration) (net.Conn, error) {
+		if strings.HasPrefix(address, "10.2.") {
+			<-release
+		}
+		return nil, &net.OpError{Op: "dial", Net: "tcp", Err: syscall.ECONNREFUSED}
+	}
+
+	callbacks := httptest.NewServer(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}))
+	defer callbacks.Close()
+
+	start := func(scanID, subnet string) *Scanner {
+		t.Helper()
+		if err := s.StartAutonomous(AutonomousScanConfig{
+			ScanID:      scanID,
+			Subnets:     []string{subnet},
+			PortRanges:  []string{"80"},
+			ProgressURL: callbacks.URL,
+			CompleteURL: callbacks.URL,
+		}); err != nil {
+			t.Fatalf("StartAutonomous(%s) error = %v", scanID, err)
+		}
+		return s.lastSession
+	}
+	sessionA := start(scanA, "10.1.0.0/29")
+	sessionB := start(scanB, "10.2.0.0/29")
+
+	if err := s.StartAutonomous(AutonomousScanConfig{ScanID: "c3c3c3c3-0000-4000-8000-000000000003"}); !errors.Is(err, ErrTooManyScans) {
+		t.Errorf("third StartAutonomous() error = %v, want ErrTooManyScans", err)
+	}
+
+	select {
+	case <-sessionA.done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("scan A did not finish while B was held")
+	}
+	if status := sessionA.status(); status.Phase != "completed" || status.ScannedHosts != status.TotalHosts {
+		t.Errorf("scan A %s with %d of %d hosts, want completed with all", status.Phase, status.ScannedHosts, status.TotalHosts)
+	}
+	if status := sessionB.status(); !status.Running || status.ScannedHosts == status.TotalHosts {
+		t.Errorf("scan B running %v with %d of %d hosts, want it still running", status.Running, status.ScannedHosts, status.TotalHosts)
+	}
+	if !s.IsRunning(scanB) || s.IsRunning(scanA) {
+		t.Errorf("IsRunning: A %v, B %v; want only B", s.IsRunning(scanA), s.IsRunning(scanB))
+	}
+
+	if err := s.Cancel(scanA, CancelImmediate); err == nil {
+		t.Error("cancelling finished scan A succeeded")
+	}
+	if err := s.Cancel(scanB, CancelImmediate); err != nil {
+		t.Fatalf("Cancel(B) error = %v", err)
+	}
+	close(release)
+	select {
+	case <-sessionB.done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("cancelled scan B did not finish")
+	}
+	if phase := sessionB.status().Phase; phase != "cancelled" {
+		t.Errorf("scan B finished %s, want cancelled", phase)
+	}
+	if phase := sessionA.status().Phase; phase != "completed" {
+		t.Errorf("cancelling B changed scan A to %s", phase)
+	}
+}
+
+func TestJitterInterval(t *testing.T) {
+	const base = 10 * time.Second
+
+	tests := []struct {
+		name     string
+		jitter   float64
+		min, max time.Duration
+	}{
+		{name: "no jitter", jitter: 0, min: base, max: base},
+		{name: "negative jitter", jitter: -0.5, min: base, max: base},
+		{name: "ten percent", jitter: 0.1, min: 9 * time.Second, max: 11 * time.Second},
+		{name: "half", jitter: 0.5, min: 5 * time.Second, max: 15 * time.Second},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			spread := false
+			first := jitterInterval(base, tt.jitter)
+			for i := 0; i < 1000; i++ {
+				got := jitterInterval(base, tt.jitter)
+				if got < tt.min || got > tt.max {
+					t.Fatalf("jitterInterval(%v, %g) = %v, want within [%v, %v]", base, tt.jitter, got, tt.min, tt.max)
+				}
+				spread = spread || got != first
+			}
+			if tt.min != tt.max && !spread {
+				t.Errorf("jitterInterval(%v, %g) always returned %v", base, tt.jitter, first)
+			}
+		})
+	}
+}
+
+func TestCompletionSummaryVantagePoint(t *testing.T) {
+	s := newTestScanner(t, config.ScannerConfig{MaxConcurrentScans: 1, RateLimit: 100000, VantagePoint: "dmz-eu1"})
+	s.dial = func(_, _ string, _ time.Duration) (net.Conn, error) {
+		return nil, &net.OpError{Op: "dial", Net: "tcp", Err: syscall.ECONNREFUSED}
+	}
+
+	completion := runAutonomous(t, s, AutonomousScanConfig{Targets: []string{"192.0.2.10"}, PortRanges: []string{"22"}})
+	if completion.Summary == nil || completion.Summary.VantagePoint != "dmz-eu1" {
+		t.Errorf("summary = %+v, want vantage point dmz-eu1", completion.Summary)
+	}
+}
+
+func TestCompletionErrorCodes(t *testing.T) {
+	s := newTestScanner(t, config.ScannerConfig{MaxConcurrentScans: 1, RateLimit: 100000})
+	var dials int32
+	s.dial = pipeDial(&dials, func(net.Conn) {})
+	s.publisher = &flakyPublisher{recordingPublisher: &recordingPublisher{}, failEvery: 1}
+
+	completion := runAutonomous(t, s, AutonomousScanConfig{
+		Subnets:    []string{"10.9.300.0/30", "10.9.0.0/30"},
+		Targets:    []string{"192.0.2.10:notaport"},
+		PortRanges: []string{"80"},
+	})
+
+	// Each kind of error is reported once under its code, counted
+	got := make(map[string]int64)
+	for _, e := range completion.Errors {
+		if e.Message == "" {
+			t.Errorf("%s error has no message", e.Code)
+		}
+		got[e.Code] = e.Count
+	}
+	want := map[string]int64{
+		callback.ErrorInvalidSubnet: 1,
+		callback.ErrorInvalidTarget: 1,
+		callback.ErrorPublishFailed: int64(dials),
+	}
+	if dials == 0 || !reflect.DeepEqual(got, want) {
+		t.Errorf("errors = %v, want %v", got, want)
+	}
+}
+
+func TestSessionStatusETA(t *testing.T) {
+	tests := []struct {
+		name        string
+		scanned     int64
+		running     bool
+		wantPercent float64
+		wantETA     time.Duration // zero: none reported
+	}{
+		{name: "too early for an ETA", scanned: 4, running: true, wantPercent: 4},
+		{name: "a quarter done", scanned: 25, running: true, wantPercent: 25, wantETA: 30 * time.Second},
+		{name: "finished", scanned: 100, wantPercent: 100},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			root := newTestScanner(t, config.ScannerConfig{})
+			session := root.newSession("1f2e3d4c-5b6a-4798-8a7b-6c5d4e3f2a1b")
+			session.running = tt.running
+			session.totalIPs = 100
+			session.scannedIPs = tt.scanned
+			session.startedAt = time.Now().Add(-10 * time.Second)
+			session.finishedAt = session.startedAt.Add(10 * time.Second)
+
+			status := session.status()
+			if status.PercentDone != tt.wantPercent {
+				t.Errorf("percent done = %v, want %v", status.PercentDone, tt.wantPercent)
+			}
+			if status.Elapsed < 10*time.Second || status.Elapsed > 11*time.Second {
+				t.Errorf("elapsed = %v, want 10s", status.Elapsed)
+			}
+			if status.ETAAvailable != (tt.wantETA > 0) {
+				t.Fatalf("ETA available = %v, want %v", status.ETAAvailable, tt.wantETA > 0)
+			}
+			// Three quarters left at the rate so far: three times the elapsed time
+			if diff := status.ETA - tt.wantETA; diff < 0 || diff > 3*time.Second {
+				t.Errorf("ETA = %v, want %v", status.ETA, tt.wantETA)
+			}
+		})
+	}
+}
@@ -0,0 +1,311 @@
+package scanner
+
+import (
+	"encoding/hex"
+	"io"
+	"net"
+	"strings"
+	"time"
+)
+
+// maxBannerSize caps how many bytes are read from a probe response, and
+// from a service greeting when BannerMaxBytes is unset.
+const maxBannerSize = 1024
+
+// bannerChunkGap is how long a banner read waits for another chunk once
+// data has arrived. It ends the read early for services that have finished
+// their greeting; the banner deadline still bounds the whole read.
+const bannerChunkGap = 250 * time.Millisecond
+
+// bannerFraming describes how a service frames its initial greeting.
+type bannerFraming int
+
+const (
+	// framingRaw reads whatever arrives before the stream goes quiet, up
+	// to the cap.
+	framingRaw bannerFraming = iota
+	// framingMySQL reads a MySQL packet: a 3-byte little-endian payload
+	// length and a 1-byte sequence id, followed by the payload.
+	framingMySQL
+)
+
+// lengthPrefixedPorts maps ports to the framing of their greeting. Ports not
+// listed here use raw reads.
+var lengthPrefixedPorts = map[int]bannerFraming{
+	3306: framingMySQL,
+}
+
+// firstSpeaker says which side sends the first bytes on a connection, which
+// decides whether a banner is read immediately or only after a probe.
+type firstSpeaker int
+
+const (
+	// speakerUnknown reads and waits for a greeting, as for any port.
+	speakerUnknown firstSpeaker = iota
+	// speakerServer services send a greeting on connect.
+	speakerServer
+	// speakerClient services stay silent until the client speaks.
+	speakerClient
+)
+
+// serviceSpeakers maps services from wellKnownPorts to who speaks first.
+// Services not listed are treated as unknown.
+var serviceSpeakers = map[string]firstSpeaker{
+	"FTP":                 speakerServer,
+	"SSH":                 speakerServer,
+	"Telnet":              speakerServer,
+	"SMTP":                speakerServer,
+	"SMTP Submission":     speakerServer,
+	"POP3":                speakerServer,
+	"IMAP":                speakerServer,
+	"MySQL":               speakerServer,
+	"HTTP":                speakerClient,
+	"HTTP-Alt":            speakerClient,
+	"HTTPS":               speakerClient,
+	"HTTPS-Alt":           speakerClient,
+	"Elasticsearch":       speakerClient,
+	"CouchDB":             speakerClient,
+	"RabbitMQ-Management": speakerClient,
+	"MSSQL":               speakerClient,
+	"PostgreSQL":          speakerClient,
+	"MongoDB":             speakerClient,
+	"RDP":                 speakerClient,
+}
+
+// httpProbe elicits a response header from plaintext HTTP services.
+const httpProbe = "HEAD / HTTP/1.0\r\n\r\n"
+
+// clientProbes are sent to client-speaks-first services before reading.
+// Client-first services without a probe skip the read instead of waiting
+// out the timeout for a greeting that never comes.
+var clientProbes = map[string]string{
+	"HTTP":                httpProbe,
+	"HTTP-Alt":            httpProbe,
+	"Elasticsearch":       httpProbe,
+	"CouchDB":             httpProbe,
+	"RabbitMQ-Management": httpProbe,
+}
+
+// speakerForPort returns who speaks first on port, seeded from the
+// well-known service table.
+func speakerForPort(port int) firstSpeaker {
+	return serviceSpeakers[wellKnownPorts[port].Name]
+}
+
+// grabBanner reads up to maxBytes of the service banner from conn, sending a
+// probe first on client-speaks-first ports. The caller is expected to have
+// set deadline on conn.
+func grabBanner(conn net.Conn, port, maxBytes int, deadline time.Time) string {
+	if speakerForPort(port) != speakerClient {
+		return readBanner(conn, port, maxBytes, deadline)
+	}
+
+	probe, ok := clientProbes[wellKnownPorts[port].Name]
+	if !ok {
+		return ""
+	}
+	if _, err := conn.Write([]byte(probe)); err != nil {
+		return ""
+	}
+	return readBanner(conn, port, maxBytes, deadline)
+}
+
+// bannerReadTimeout derives the banner read timeout from the measured dial
+// RTT so slow links get more time to send a greeting and fast links don't
+// wait the full cold-dial timeout on silent services. The result is clamped
+// to the configured bounds; without a multiplier the port's static timeout
+// is used.
+func (s *Scanner) bannerReadTimeout(dialRTT, static time.Duration) time.Duration {
+	if s.config.BannerTimeoutMultiplier <= 0 {
+		return static
+	}
+
+	timeout := time.Duration(float64(dialRTT) * s.config.BannerTimeoutMultiplier)
+
+	if minTimeout := time.Duration(s.config.BannerTimeoutMinMS) * time.Millisecond; timeout < minTimeout {
+		timeout = minTimeout
+	}
+	maxTimeout := time.Duration(s.config.BannerTimeoutMaxMS) * time.Millisecond
+	if maxTimeout <= 0 {
+		maxTimeout = static
+	}
+	if maxTimeout > 0 && timeout > maxTimeout {
+		timeout = maxTimeout
+	}
+
+	return timeout
+}
+
+// bannerMaxBytes returns the configured banner read cap.
+func (s *Scanner) bannerMaxBytes() int {
+	if s.config.BannerMaxBytes <= 0 {
+		return maxBannerSize
+	}
+	return s.config.BannerMaxBytes
+}
+
+// shouldGrabBanner reports whether a banner should be read from port. An
+// empty BannerPorts list allows banner grabbing on every open port.
+func (s *Scanner) shouldGrabBanner(port int) bool {
+	if len(s.config.BannerPorts) == 0 {
+		return true
+	}
+	for _, p := range s.config.BannerPorts {
+		if p == port {
+			return true
+		}
+	}
+	return false
+}
+
+// readBanner reads a service greeting from conn. For known length-prefixed
+// protocols it reads the header, then exactly the advertised payload length
+// (bounded by maxBytes) so binary greetings are neither truncated nor
+// padded with unrelated bytes. The caller is expected to have set deadline
+// on conn.
+func readBanner(conn net.Conn, port, maxBytes int, deadline time.Time) string {
+	switch lengthPrefixedPorts[port] {
+	case framingMySQL:
+		if banner, ok := readMySQLGreeting(conn, maxBytes); ok {
+			return banner
+		}
+		return ""
+	default:
+		return readChunks(conn, maxBytes, deadline)
+	}
+}
+
+// readChunks reads and concatenates chunks until maxBytes, EOF or the
+// deadline. After the first chunk each read waits at most bannerChunkGap,
+// never past deadline, so neither a finished greeting nor a server
+// trickling bytes holds the worker beyond the banner timeout.
+func readChunks(conn net.Conn, maxBytes int, deadline time.Time) string {
+	buffer := make([]byte, maxBytes)
+	n := 0
+	for n < maxBytes {
+		read, err := conn.Read(buffer[n:])
+		n += read
+		if err != nil {
+			break
+		}
+
+		next := time.Now().Add(bannerChunkGap)
+		if next.After(deadline) {
+			next = deadline
+		}
+		if err := conn.SetReadDeadline(next); err != nil {
+			break
+		}
+	}
+	return string(buffer[:n])
+}
+
+// sanitizeBanner makes result's banner safe to log and publish: terminal
+// escape sequences and other non-printable bytes are removed and the rest
+// is cut to BannerMaxLen bytes (banner_truncated). A banner that contained
+// binary data keeps the original hex-encoded in banner_raw.
+func (s *Scanner) sanitizeBanner(result *ScanResult) {
+	banner, raw := printableBanner(result.Banner)
+	if maxLen := s.config.BannerMaxLen; maxLen > 0 && len(banner) > maxLen {
+		banner = banner[:maxLen]
+		if result.Metadata == nil {
+			result.Metadata = make(map[string]interface{})
+		}
+		result.Metadata["banner_truncated"] = true
+	}
+	result.Banner = banner
+	if raw != "" {
+		if result.Metadata == nil {
+			result.Metadata = make(map[string]interface{})
+		}
+		result.Metadata["banner_raw"] = raw
+	}
+}
+
+// printableBanner strips terminal escape sequences and bytes other than
+// printable ASCII and whitespace from banner. When any were stripped it
+// also returns the original bytes hex-encoded.
+func printableBanner(banner string) (string, string) {
+	var b strings.Builder
+	stripped := false
+	for i := 0; i < len(banner); i++ {
+		c := banner[i]
+		if (c >= 0x20 && c < 0x7f) || c == '\t' || c == '\r' || c == '\n' {
+			b.WriteByte(c)
+			continue
+		}
+		stripped = true
+		if c == 0x1b {
+			// Skip the whole sequence, not just ESC, so no "[31m" is left
+			i += escapeSequenceLen(banner[i+1:])
+		}
+	}
+	if !stripped {
+		return banner, ""
+	}
+	return b.String(), hex.EncodeToString([]byte(banner))
+}
+
+// escapeSequenceLen returns how many bytes after an ESC belong to its
+// sequence: CSI (ESC [ params final), OSC (ESC ] text BEL or ESC \) or a
+// two-byte escape.
+func escapeSequenceLen(rest string) int {
+	if rest == "" {
+		return 0
+	}
+	switch rest[0] {
+	case '[':
+		for i := 1; i < len(rest); i++ {
+			if rest[i] >= 0x40 && rest[i] <= 0x7e {
+				return i + 1
+			}
+			if rest[i] < 0x20 || rest[i] > 0x3f {
+				// Not a parameter or intermediate byte; malformed
+				return i
+			}
+		}
+		return len(rest)
+	case ']':
+		for i := 1; i < len(rest); i++ {
+			if rest[i] == 0x07 {
+				return i + 1
+			}
+			if rest[i] == 0x1b && i+1 < len(rest) && rest[i+1] == '\\' {
+				return i + 2
+			}
+		}
+		return len(rest)
+	}
+	if rest[0] >= 0x20 && rest[0] < 0x7f {
+		return 1
+	}
+	return 0
+}
+
+// readMySQLGreeting reads a single MySQL protocol packet and returns the
+// header and payload as the banner.
+func readMySQLGreeting(conn net.Conn, maxBytes int) (string, bool) {
+	header := make([]byte, 4)
+	if _, err := io.ReadFull(conn, header); err != nil {
+		return "", false
+	}
+
+	length := int(header[0]) | int(header[1])<<8 | int(header[2])<<16
+	if length == 0 {
+		return string(header), true
+	}
+	if length > maxBytes-len(header) {
+		length = maxBytes - len(header)
+	}
+	if length <= 0 {
+		return string(header), true
+	}
+
+	payload := make([]byte, length)
+	n, err := io.ReadFull(conn, payload)
+	if err != nil && n == 0 {
+		return string(header), true
+	}
+
+	return string(header) + string(payload[:n]), true
+}
@@ -0,0 +1,385 @@
+package scanner
+
+import (
+	"context"
+	"encoding/hex"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/aiforce-discovery-agent/collectors/network-scanner/internal/config"
+)
+
+func TestReadChunks(t *testing.T) {
+	tests := []struct {
+		name     string
+		serve    func(net.Conn)
+		maxBytes int
+		deadline time.Duration
+		want     string
+		maxWait  time.Duration
+	}{
+		{
+			name: "banner split across two writes",
+			serve: func(conn net.Conn) {
+				_, _ = conn.Write([]byte("220 mail.example.com "))
+				time.Sleep(20 * time.Millisecond)
+				_, _ = conn.Write([]byte("ESMTP Postfix\r\n"))
+			},
+			maxBytes: 1024,
+			deadline: time.Second,
+			want:     "220 mail.example.com ESMTP Postfix\r\n",
+			maxWait:  time.Second,
+		},
+		{
+			name:     "capped at maxBytes",
+			serve:    func(conn net.Conn) { _, _ = conn.Write([]byte("SSH-2.0-OpenSSH_8.9p1\r\n")) },
+			maxBytes: 7,
+			deadline: time.Second,
+			want:     "SSH-2.0",
+			maxWait:  time.Second,
+		},
+		{
+			name: "trickling server stopped at the deadline",
+			serve: func(conn net.Conn) {
+				for {
+					if _, err := conn.Write([]byte("x")); err != nil {
+						return
+					}
+					time.Sleep(10 * time.Millisecond)
+				}
+			},
+			maxBytes: 1024,
+			deadline: 100 * time.Millisecond,
+			maxWait:  300 * time.Millisecond,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, server := net.Pipe()
+			go func() {
+				defer func() { _ = server.Close() }()
+				tt.serve(server)
+			}()
+			defer func() { _ = client.Close() }()
+
+			start := time.Now()
+			deadline := start.Add(tt.deadline)
+			if err := client.SetDeadline(deadline); err != nil {
+				t.Fatal(err)
+			}
+			got := readChunks(client, tt.maxBytes, deadline)
+
+			if elapsed := time.Since(start); elapsed > tt.maxWait {
+				t.Errorf("read took %v, want at most %v", elapsed, tt.maxWait)
+			}
+			if tt.want != "" && got != tt.want {
+				t.Errorf("readChunks() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGrabBannerFirstSpeaker(t *testing.T) {
+	const deadline = 2 * time.Second
+
+	tests := []struct {
+		name        string
+		port        int
+		greeting    string // written on connect by server-first services
+		response    string // written after a probe arrives
+		wantRequest string
+		wantBanner  string
+	}{
+		{name: "server first", port: 22, greeting: "SSH-2.0-OpenSSH_9.6\r\n", wantBanner: "SSH-2.0-OpenSSH_9.6\r\n"},
+		{name: "client first with a probe", port: 80, response: "HTTP/1.0 200 OK\r\nServer: nginx\r\n\r\n", wantRequest: httpProbe, wantBanner: "HTTP/1.0 200 OK\r\nServer: nginx\r\n\r\n"},
+		{name: "client first without a probe", port: 5432},
+		{name: "unknown service", port: 9999, greeting: "hello\n", wantBanner: "hello\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, server := net.Pipe()
+			requests := make(chan string, 1)
+			go func() {
+				defer func() { _ = server.Close() }()
+				if tt.greeting != "" {
+					_, _ = server.Write([]byte(tt.greeting))
+				}
+				_ = server.SetReadDeadline(time.Now().Add(deadline))
+				buf := make([]byte, 256)
+				n, _ := server.Read(buf)
+				requests <- string(buf[:n])
+				if n > 0 && tt.response != "" {
+					_, _ = server.Write([]byte(tt.response))
+				}
+			}()
+			defer func() { _ = client.Close() }()
+
+			start := time.Now()
+			end := start.Add(deadline)
+			if err := client.SetDeadline(end); err != nil {
+				t.Fatal(err)
+			}
+			got := grabBanner(client, tt.port, 1024, end)
+			if elapsed := time.Since(start); elapsed > deadline/2 {
+				t.Errorf("grabBanner() took %v, want no wait for a greeting", elapsed)
+			}
+			_ = client.Close()
+
+			if got != tt.wantBanner {
+				t.Errorf("grabBanner() = %q, want %q", got, tt.wantBanner)
+			}
+			if request := <-requests; request != tt.wantRequest {
+				t.Errorf("sent %q, want %q", request, tt.wantRequest)
+			}
+		})
+	}
+}
+
+func TestPrintableBanner(t *testing.T) {
+	tests := []struct {
+		name    string
+		banner  string
+		want    string
+		wantRaw bool
+	}{
+		{name: "plain", banner: "SSH-2.0-OpenSSH_8.9p1\r\n", want: "SSH-2.0-OpenSSH_8.9p1\r\n"},
+		{name: "tabs and newlines kept", banner: "a\tb\r\nc", want: "a\tb\r\nc"},
+		{name: "color codes", banner: "\x1b[1;31mWelcome\x1b[0m to router", want: "Welcome to router", wantRaw: true},
+		{name: "clear screen and cursor moves", banner: "\x1b[2J\x1b[H\x1b[10;20HLogin:", want: "Login:", wantRaw: true},
+		{name: "window title", banner: "\x1b]0;pwned\x07$ ", want: "$ ", wantRaw: true},
+		{name: "title ended by ST", banner: "\x1b]2;pwned\x1b\\ok", want: "ok", wantRaw: true},
+		{name: "two-byte escape", banner: "\x1bcreset", want: "reset", wantRaw: true},
+		{name: "unterminated CSI", banner: "ok\x1b[12;", want: "ok", wantRaw: true},
+		{name: "malformed CSI keeps the text", banner: "\x1b[1\nline", want: "\nline", wantRaw: true},
+		{name: "trailing ESC", banner: "ok\x1b", want: "ok", wantRaw: true},
+		{name: "NULs", banner: "J\x00\x00\x005.7.44\x00", want: "J5.7.44", wantRaw: true},
+		{name: "high bytes", banner: "caf\xc3\xa9\xff", want: "caf", wantRaw: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, raw := printableBanner(tt.banner)
+			if got != tt.want {
+				t.Errorf("printableBanner() = %q, want %q", got, tt.want)
+			}
+			wantRaw := ""
+			if tt.wantRaw {
+				wantRaw = hex.EncodeToString([]byte(tt.banner))
+			}
+			if raw != wantRaw {
+				t.Errorf("raw = %q, want %q", raw, wantRaw)
+			}
+		})
+	}
+}
+
+func TestSanitizeBanner(t *testing.T) {
+	tests := []struct {
+		name          string
+		maxLen        int
+		banner        string
+		want          string
+		wantTruncated interface{}
+		wantRaw       interface{}
+	}{
+		{name: "clean", banner: "220 ready\r\n", want: "220 ready\r\n"},
+		{name: "escapes", banner: "\x1b[31m220\x1b[0m", want: "220", wantRaw: "1b5b33316d3232301b5b306d"},
+		{name: "truncated", maxLen: 4, banner: "220 ready", want: "220 ", wantTruncated: true},
+		{name: "cut after stripping", maxLen: 3, banner: "\x00\x00220 ready", want: "220", wantTruncated: true, wantRaw: "0000323230207265616479"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestScanner(t, config.ScannerConfig{BannerMaxLen: tt.maxLen})
+			result := ScanResult{Banner: tt.banner}
+			s.sanitizeBanner(&result)
+
+			if result.Banner != tt.want {
+				t.Errorf("banner = %q, want %q", result.Banner, tt.want)
+			}
+			if got := result.Metadata["banner_truncated"]; got != tt.wantTruncated {
+				t.Errorf("banner_truncated = %v, want %v", got, tt.wantTruncated)
+			}
+			if got := result.Metadata["banner_raw"]; got != tt.wantRaw {
+				t.Errorf("banner_raw = %v, want %v", got, tt.wantRaw)
+			}
+		})
+	}
+}
+
+func TestReadMySQLGreeting(t *testing.T) {
+	// Header (3-byte length 10, sequence 0) and a 10-byte payload
+	greeting := "\x0a\x00\x00\x00" + "\x0a8.0.36\x00\x01\x00"
+
+	tests := []struct {
+		name     string
+		greeting string
+		maxBytes int
+		want     string
+		wantOK   bool
+	}{
+		{name: "whole greeting", greeting: greeting, maxBytes: 1024, want: greeting, wantOK: true},
+		{name: "payload capped", greeting: greeting, maxBytes: 8, want: greeting[:8], wantOK: true},
+		{name: "room for the header only", greeting: greeting, maxBytes: 4, want: greeting[:4], wantOK: true},
+		{name: "cap below the header", greeting: greeting, maxBytes: 3, want: greeting[:4], wantOK: true},
+		{name: "zero cap", greeting: greeting, maxBytes: 0, want: greeting[:4], wantOK: true},
+		{name: "empty packet", greeting: "\x00\x00\x00\x00", maxBytes: 1024, want: "\x00\x00\x00\x00", wantOK: true},
+		{name: "short header", greeting: "\x0a\x00", maxBytes: 1024},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, server := net.Pipe()
+			go func() {
+				defer func() { _ = server.Close() }()
+				_, _ = server.Write([]byte(tt.greeting))
+			}()
+			defer func() { _ = client.Close() }()
+			if err := client.SetDeadline(time.Now().Add(time.Second)); err != nil {
+				t.Fatal(err)
+			}
+
+			got, ok := readMySQLGreeting(client, tt.maxBytes)
+			if got != tt.want || ok != tt.wantOK {
+				t.Errorf("readMySQLGreeting() = %q, %v; want %q, %v", got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestScanPortReadsMySQLGreeting(t *testing.T) {
+	tests := []struct {
+		name        string
+		payload     string
+		wantProduct string
+		wantVersion string
+	}{
+		{name: "mysql", payload: "\x0a8.0.36\x00\x2a\x00\x00\x00abcdefgh\x00", wantProduct: "MySQL", wantVersion: "8.0.36"},
+		{name: "mariadb", payload: "\x0a5.5.5-10.11.6-MariaDB\x00\x2a\x00\x00\x00abcdefgh\x00", wantProduct: "MariaDB", wantVersion: "10.11.6"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			packet := string([]byte{byte(len(tt.payload)), 0, 0, 0}) + tt.payload
+
+			s := newTestScanner(t, config.ScannerConfig{Timeout: 2000})
+			var dials int32
+			done := make(chan struct{})
+			defer close(done)
+			s.dial = pipeDial(&dials, func(conn net.Conn) {
+				// Bytes past the packet and a connection left open: a
+				// fixed-size read would wait out the timeout for more
+				_, _ = conn.Write([]byte(packet + "trailing"))
+				<-done
+			})
+
+			start := time.Now()
+			result := s.scanPort(context.Background(), "192.0.2.10", 3306, "tcp")
+			if elapsed := time.Since(start); elapsed > time.Second {
+				t.Errorf("scanPort() took %v, want the greeting read without waiting for the timeout", elapsed)
+			}
+
+			if result.Product != tt.wantProduct || result.Version != tt.wantVersion {
+				t.Errorf("product %q version %q, want %q %q", result.Product, result.Version, tt.wantProduct, tt.wantVersion)
+			}
+			if raw := result.Metadata["banner_raw"]; raw != hex.EncodeToString([]byte(packet)) {
+				t.Errorf("banner_raw = %v, want exactly the greeting packet", raw)
+			}
+		})
+	}
+}
+
+func TestBannerReadTimeout(t *testing.T) {
+	const static = 2 * time.Second
+
+	tests := []struct {
+		name       string
+		multiplier float64
+		minMS      int
+		maxMS      int
+		rtt        time.Duration
+		want       time.Duration
+	}{
+		{name: "off", rtt: 100 * time.Millisecond, want: static},
+		{name: "scaled", multiplier: 10, rtt: 40 * time.Millisecond, want: 400 * time.Millisecond},
+		{name: "raised to the minimum", multiplier: 10, minMS: 200, rtt: time.Millisecond, want: 200 * time.Millisecond},
+		{name: "capped at the maximum", multiplier: 10, maxMS: 500, rtt: 300 * time.Millisecond, want: 500 * time.Millisecond},
+		{name: "capped at the port timeout", multiplier: 10, rtt: time.Second, want: static},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestScanner(t, config.ScannerConfig{
+				BannerTimeoutMultiplier: tt.multiplier, BannerTimeoutMinMS: tt.minMS, BannerTimeoutMaxMS: tt.maxMS,
+			})
+			if got := s.bannerReadTimeout(tt.rtt, static); got != tt.want {
+				t.Errorf("bannerReadTimeout(%v) = %v, want %v", tt.rtt, got, tt.want)
+			}
+		})
+	}
+}
+
+// A slow link's greeting arrives long after the minimum read timeout, but
+// within a multiple of its dial RTT.
+func TestBannerDeadlineScalesWithRTT(t *testing.T) {
+	const greetingDelay = 300 * time.Millisecond
+
+	tests := []struct {
+		name       string
+		rtt        time.Duration
+		wantBanner bool
+	}{
+		{name: "fast link", wantBanner: false},
+		{name: "slow link", rtt: 150 * time.Millisecond, wantBanner: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestScanner(t, config.ScannerConfig{
+				Timeout: 2000, BannerTimeoutMultiplier: 4, BannerTimeoutMinMS: 50,
+			})
+			var dials int32
+			serve := pipeDial(&dials, func(conn net.Conn) {
+				time.Sleep(greetingDelay)
+				_, _ = conn.Write([]byte("SSH-2.0-OpenSSH_9.6\r\n"))
+			})
+			s.dial = func(network, address string, timeout time.Duration) (net.Conn, error) {
+				time.Sleep(tt.rtt)
+				return serve(network, address, timeout)
+			}
+
+			result := s.scanPort(context.Background(), "192.0.2.10", 22, "tcp")
+			if !result.Open {
+				t.Fatal("port not open")
+			}
+			if got := result.Banner != ""; got != tt.wantBanner {
+				t.Errorf("banner %q read = %v, want %v", result.Banner, got, tt.wantBanner)
+			}
+		})
+	}
+}
+
+func TestBannerPortsAllowlist(t *testing.T) {
+	tests := []struct {
+		name        string
+		bannerPorts []int
+		wantBanners map[int]bool
+	}{
+		{name: "every port by default", wantBanners: map[int]bool{22: true, 2222: true}},
+		{name: "listed ports only", bannerPorts: []int{22}, wantBanners: map[int]bool{22: true, 2222: false}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestScanner(t, config.ScannerConfig{Timeout: 500, BannerPorts: tt.bannerPorts})
+			var dials int32
+			s.dial = pipeDial(&dials, func(conn net.Conn) {
+				_, _ = conn.Write([]byte("SSH-2.0-OpenSSH_9.6\r\n"))
+			})
+
+			for port, want := range tt.wantBanners {
+				result := s.scanPort(context.Background(), "192.0.2.10", port, "tcp")
+				if !result.Open {
+					t.Fatalf("port %d not open", port)
+				}
+				if got := result.Banner != ""; got != want {
+					t.Errorf("port %d: banner %q grabbed = %v, want %v", port, result.Banner, got, want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,70 @@
+// Package scanner provides network scanning functionality.
+// cdn.go implements detection of CDN-fronted (anycast edge) addresses.
+package scanner
+
+import (
+	_ "embed"
+	"encoding/json"
+	"net"
+)
+
+// cdnOriginConfidence is the confidence that a CDN edge address represents
+// the origin asset behind it.
+const cdnOriginConfidence = 0.2
+
+// cdnIPRanges is the embedded CDN edge range file format.
+type cdnIPRanges struct {
+	CDNs []struct {
+		Name  string   `json:"name"`
+		CIDRs []string `json:"cidrs"`
+	} `json:"cdns"`
+}
+
+type cdnNet struct {
+	name string
+	net  *net.IPNet
+}
+
+//go:embed data/cdn_ip_ranges.json
+var cdnIPRangesData []byte
+
+// loadCDNRanges parses the embedded CDN edge ranges.
+func loadCDNRanges() []cdnNet {
+	var ranges cdnIPRanges
+	if err := json.Unmarshal(cdnIPRangesData, &ranges); err != nil {
+		return nil
+	}
+
+	var nets []cdnNet
+	for _, cdn := range ranges.CDNs {
+		for _, cidr := range cdn.CIDRs {
+			if _, ipnet, err := net.ParseCIDR(cidr); err == nil {
+				nets = append(nets, cdnNet{name: cdn.Name, net: ipnet})
+			}
+		}
+	}
+	return nets
+}
+
+// matchCDN returns the CDN whose edge ranges contain ip, if any.
+func (cd *CloudDetector) matchCDN(ip net.IP) (string, bool) {
+	for _, n := range cd.cdnNets {
+		if n.net.Contains(ip) {
+			return n.name, true
+		}
+	}
+	return "", false
+}
+
+// DetectCDN reports whether ipStr is a CDN edge address and which CDN.
+func (cd *CloudDetector) DetectCDN(ipStr string) (string, bool) {
+	ip := net.ParseIP(ipStr)
+	if ip == nil {
+		return "", false
+	}
+
+	cd.mu.RLock()
+	defer cd.mu.RUnlock()
+
+	return cd.matchCDN(ip)
+}
@@ -0,0 +1,268 @@
+package scanner
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/aiforce-discovery-agent/collectors/network-scanner/internal/callback"
+)
+
+// ErrNoCheckpoint is returned when no checkpoint exists for a scan ID.
+var ErrNoCheckpoint = errors.New("no checkpoint for scan")
+
+// Checkpoint is the persisted progress of an autonomous scan, enough to
+// resume it after a restart without rescanning completed addresses.
+type Checkpoint struct {
+	ScanID string               `json:"scan_id"`
+	Config AutonomousScanConfig `json:"config"`
+	// Seed reproduces a randomized scan order on resume
+	Seed           int64                     `json:"seed"`
+	Sources        map[string]SourceProgress `json:"sources"` // by subnet, or "targets"
+	DiscoveryCount int                       `json:"discovery_count"`
+	// DiscoveryBreakdown splits DiscoveryCount by service category
+	DiscoveryBreakdown map[string]int `json:"discovery_breakdown,omitempty"`
+	UpdatedAt          time.Time      `json:"updated_at"`
+}
+
+// SourceProgress is how far a subnet or the target list has been scanned:
+// the first Completed addresses in scan order are all done, the last of
+// them being LastIP. Hosts that finished beyond that prefix are scanned
+// again on resume.
+type SourceProgress struct {
+	Completed int64  `json:"completed"`
+	LastIP    string `json:"last_ip,omitempty"`
+}
+
+// CheckpointStore persists scan checkpoints. The file store is built in;
+// SetCheckpointStore installs another (e.g. Redis-backed).
+type CheckpointStore interface {
+	Save(checkpoint Checkpoint) error
+	// Load returns ErrNoCheckpoint when scanID has none.
+	Load(scanID string) (Checkpoint, error)
+	Delete(scanID string) error
+}
+
+// fileCheckpointStore keeps one JSON file per scan ID. Checkpoints hold the
+// callback API key, so files are private to the scanner's user.
+type fileCheckpointStore struct {
+	dir string
+}
+
+// NewFileCheckpointStore returns a CheckpointStore writing to dir.
+func NewFileCheckpointStore(dir string) (CheckpointStore, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("failed to create checkpoint directory: %w", err)
+	}
+	return &fileCheckpointStore{dir: dir}, nil
+}
+
+func (f *fileCheckpointStore) path(scanID string) string {
+	return filepath.Join(f.dir, filepath.Base(scanID)+".json")
+}
+
+// Save writes the checkpoint atomically, so a crash mid-write leaves the
+// previous one intact.
+func (f *fileCheckpointStore) Save(checkpoint Checkpoint) error {
+	data, err := json.Marshal(checkpoint)
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(f.dir, ".checkpoint-*")
+	if err != nil {
+		return err
+	}
+	defer func() { _ = os.Remove(tmp.Name()) }()
+
+	if _, err := tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), f.path(checkpoint.ScanID))
+}
+
+func (f *fileCheckpointStore) Load(scanID string) (Checkpoint, error) {
+	var checkpoint Checkpoint
+	data, err := os.ReadFile(f.path(scanID))
+	if errors.Is(err, os.ErrNotExist) {
+		return checkpoint, fmt.Errorf("%w: %s", ErrNoCheckpoint, scanID)
+	}
+	if err != nil {
+		return checkpoint, err
+	}
+	if err := json.Unmarshal(data, &checkpoint); err != nil {
+		return checkpoint, fmt.Errorf("invalid checkpoint for %s: %w", scanID, err)
+	}
+	return checkpoint, nil
+}
+
+func (f *fileCheckpointStore) Delete(scanID string) error {
+	if err := os.Remove(f.path(scanID)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+// SetCheckpointStore installs the checkpoint store, replacing the file
+// store built from config. Call it before starting scans.
+func (s *Scanner) SetCheckpointStore(store CheckpointStore) {
+	s.checkpoints = store
+}
+
+// resumedAddresses counts the addresses in the completed prefixes of the
+// checkpoint being resumed: one per subnet position, and those of the
+// first Completed non-excluded targets.
+func (s *Scanner) resumedAddresses(targets []hostTarget) int64 {
+	var n int64
+	for label, from := range s.resumeFrom {
+		if label != targetsLabel {
+			n += from.Completed
+			continue
+		}
+		remaining := from.Completed
+		for _, host := range targets {
+			if remaining == 0 {
+				break
+			}
+			if s.hostExcluded(host) {
+				continue
+			}
+			n += int64(len(host.addrs()))
+			remaining--
+		}
+	}
+	return n
+}
+
+// sourceProgress tracks one source's completed prefix. Workers finish out
+// of order, so completions beyond the prefix wait in pending until the gap
+// before them closes.
+type sourceProgress struct {
+	mu        sync.Mutex
+	resumed   int64 // prefix completed before a resume; skipped when feeding
+	completed int64
+	lastIP    string
+	pending   map[int64]string
+}
+
+func newSourceProgress(from SourceProgress) *sourceProgress {
+	return &sourceProgress{
+		resumed:   from.Completed,
+		completed: from.Completed,
+		lastIP:    from.LastIP,
+		pending:   make(map[int64]string),
+	}
+}
+
+// done marks the host at position pos as finished.
+func (p *sourceProgress) done(host hostTarget) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if host.pos != p.completed {
+		p.pending[host.pos] = host.ip
+		return
+	}
+	p.completed++
+	p.lastIP = host.ip
+	for {
+		ip, ok := p.pending[p.completed]
+		if !ok {
+			return
+		}
+		delete(p.pending, p.completed)
+		p.completed++
+		p.lastIP = ip
+	}
+}
+
+func (p *sourceProgress) snapshot() SourceProgress {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return SourceProgress{Completed: p.completed, LastIP: p.lastIP}
+}
+
+// sourceProgress returns the progress tracker for a host source, seeded
+// from the checkpoint being resumed, if any.
+func (s *Scanner) sourceProgress(label string) *sourceProgress {
+	s.progressMu.Lock()
+	defer s.progressMu.Unlock()
+
+	if s.progress == nil {
+		s.progress = make(map[string]*sourceProgress)
+	}
+	progress, ok := s.progress[label]
+	if !ok {
+		progress = newSourceProgress(s.resumeFrom[label])
+		s.progress[label] = progress
+	}
+	return progress
+}
+
+// saveCheckpoint records the session's progress, if checkpointing is
+// enabled. Failures are logged; the scan carries on.
+func (s *Scanner) saveCheckpoint(reporter *callback.Reporter) {
+	if s.checkpoints == nil || reporter == nil {
+		return
+	}
+
+	count, breakdown := reporter.GetDiscoveries()
+	checkpoint := Checkpoint{
+		ScanID:             reporter.GetScanID(),
+		Config:             s.request,
+		Seed:               s.randSeed,
+		Sources:            make(map[string]SourceProgress),
+		DiscoveryCount:     count,
+		DiscoveryBreakdown: breakdown,
+		UpdatedAt:          time.Now().UTC(),
+	}
+	// Sources not started yet keep the progress they were resumed with
+	for label, progress := range s.resumeFrom {
+		checkpoint.Sources[label] = progress
+	}
+	s.progressMu.Lock()
+	for label, progress := range s.progress {
+		checkpoint.Sources[label] = progress.snapshot()
+	}
+	s.progressMu.Unlock()
+
+	if err := s.checkpoints.Save(checkpoint); err != nil {
+		s.logger.Warnw("Failed to save scan checkpoint", "error", err)
+	}
+}
+
+// runCheckpoints saves a checkpoint every interval until done is closed.
+func (s *Scanner) runCheckpoints(reporter *callback.Reporter, interval time.Duration, done <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			s.saveCheckpoint(reporter)
+		case <-done:
+			return
+		}
+	}
+}
+
+// ResumeScan restarts an autonomous scan from its last checkpoint, under
+// the same scan ID and callbacks. Addresses completed before the
+// checkpoint are skipped and the discovery count continues from it.
+func (s *Scanner) ResumeScan(scanID string) error {
+	if s.checkpoints == nil {
+		return fmt.Errorf("scan checkpointing is disabled")
+	}
+	checkpoint, err := s.checkpoints.Load(scanID)
+	if err != nil {
+		return err
+	}
+	return s.startSession(checkpoint.Config, &checkpoint)
+}
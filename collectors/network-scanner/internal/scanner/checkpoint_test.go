@@ -0,0 +1,134 @@
+package scanner
+
+import (
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/aiforce-discovery-agent/collectors/network-scanner/internal/config"
+)
+
+func TestResumedAddresses(t *testing.T) {
+	targets := []hostTarget{
+		{ip: "db.example", addresses: []string{"10.0.0.1", "10.0.0.2"}},
+		{ip: "10.0.0.9"},
+		{ip: "10.0.0.3"},
+		{ip: "10.0.0.4"},
+	}
+
+	tests := []struct {
+		name       string
+		resumeFrom map[string]SourceProgress
+		want       int64
+	}{
+		{name: "fresh scan", want: 0},
+		{name: "subnet", resumeFrom: map[string]SourceProgress{"10.1.0.0/24": {Completed: 40}}, want: 40},
+		{
+			name:       "targets count addresses and skip excluded hosts",
+			resumeFrom: map[string]SourceProgress{targetsLabel: {Completed: 2}},
+			want:       3,
+		},
+		{
+			name: "subnet and targets",
+			resumeFrom: map[string]SourceProgress{
+				"10.1.0.0/24": {Completed: 40},
+				targetsLabel:  {Completed: 3},
+			},
+			want: 44,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestScanner(t, config.ScannerConfig{})
+			s.excludeIPs = map[string]struct{}{"10.0.0.9": {}}
+			s.resumeFrom = tt.resumeFrom
+
+			if got := s.resumedAddresses(targets); got != tt.want {
+				t.Errorf("resumedAddresses() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestResumeScanSkipsCompletedHosts(t *testing.T) {
+	const scanID = "5b0f3f5e-2d7c-4b8a-9b1e-3f4c2a1d6e70"
+
+	s := newTestScanner(t, config.ScannerConfig{MaxConcurrentScans: 1, Concurrency: 2, RateLimit: 100000})
+	store, err := NewFileCheckpointStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewFileCheckpointStore() error = %v", err)
+	}
+	s.checkpoints = store
+
+	var mu sync.Mutex
+	var dialed []string
+	s.dial = func(_, address string, _ time.Duration) (net.Conn, error) {
+		host, _, _ := net.SplitHostPort(address)
+		mu.Lock()
+		dialed = append(dialed, host)
+		mu.Unlock()
+		return nil, &net.OpError{Op: "dial", Net: "tcp", Err: syscall.ECONNREFUSED}
+	}
+
+	var bodies []string
+	callbacks := httptest.NewServer(http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		mu.Lock()
+		bodies = append(bodies, string(body))
+		mu.Unlock()
+	}))
+	defer callbacks.Close()
+
+	checkpoint := Checkpoint{
+		ScanID: scanID,
+		Config: AutonomousScanConfig{
+			ScanID:      scanID,
+			Subnets:     []string{"10.9.0.0/29"},
+			PortRanges:  []string{"80"},
+			ProgressURL: callbacks.URL,
+			CompleteURL: callbacks.URL,
+		},
+		Sources:        map[string]SourceProgress{"10.9.0.0/29": {Completed: 3, LastIP: "10.9.0.2"}},
+		DiscoveryCount: 7,
+	}
+	if err := store.Save(checkpoint); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+
+	if err := s.ResumeScan(scanID); err != nil {
+		t.Fatalf("ResumeScan() error = %v", err)
+	}
+	session := s.lastSession
+	select {
+	case <-session.done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("resumed scan did not finish")
+	}
+
+	status := s.ScanStatus()
+	if status.ScannedHosts != status.TotalHosts {
+		t.Errorf("finished with %d of %d hosts scanned", status.ScannedHosts, status.TotalHosts)
+	}
+	if status.OpenPorts != 7 {
+		t.Errorf("discovery count = %d, want the checkpoint's 7", status.OpenPorts)
+	}
+
+	// Progress picks up where the checkpoint left off
+	if !strings.Contains(strings.Join(bodies, "\n"), "(3/8 hosts done)") {
+		t.Errorf("subnet start progress doesn't count the 3 completed hosts: %v", bodies)
+	}
+
+	// 10.9.0.0-2 were completed before the checkpoint
+	sort.Strings(dialed)
+	if want := []string{"10.9.0.3", "10.9.0.4", "10.9.0.5", "10.9.0.6", "10.9.0.7"}; !reflect.DeepEqual(dialed, want) {
+		t.Errorf("resumed scan dialed %v, want %v", dialed, want)
+	}
+}
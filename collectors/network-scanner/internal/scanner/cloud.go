@@ -19,8 +19,27 @@ const (
 	CloudProviderOther   CloudProvider = "other"
 	CloudProviderNone    CloudProvider = "none"
 	CloudProviderUnknown CloudProvider = "unknown"
+
+	CloudProviderDigitalOcean CloudProvider = "digitalocean"
+	CloudProviderOracle       CloudProvider = "oracle"
+	CloudProviderAlibaba      CloudProvider = "alibaba"
 )
 
+// cloudProviderOrder is the order provider ranges are loaded in, so a CIDR
+// listed for more than one provider always resolves to the same one. It
+// also breaks ties between equally confident providers in DetectMultiple.
+var cloudProviderOrder = []CloudProvider{
+	CloudProviderAWS,
+	CloudProviderAzure,
+	CloudProviderGCP,
+	CloudProviderDigitalOcean,
+	CloudProviderOracle,
+	CloudProviderAlibaba,
+	CloudProviderOther,
+	CloudProviderNone,
+	CloudProviderUnknown,
+}
+
 // HostingModel represents the inferred hosting model.
 type HostingModel string
 
@@ -37,13 +56,21 @@ type CloudDetectionResult struct {
 	HostingModel HostingModel  `json:"hosting_model"`
 	Region       string        `json:"region,omitempty"`
 	Confidence   float64       `json:"confidence"`
+
+	// CDNFronted is set when the IP is a CDN edge address; the service
+	// behind it is fronted, so the IP is unlikely to be the origin asset.
+	CDNFronted bool   `json:"cdn_fronted,omitempty"`
+	CDN        string `json:"cdn,omitempty"`
 }
 
 // cloudIPRanges stores parsed cloud provider IP ranges.
 type cloudIPRanges struct {
-	AWS   []ipRange `json:"aws"`
-	Azure []ipRange `json:"azure"`
-	GCP   []ipRange `json:"gcp"`
+	AWS          []ipRange `json:"aws"`
+	Azure        []ipRange `json:"azure"`
+	GCP          []ipRange `json:"gcp"`
+	DigitalOcean []ipRange `json:"digitalocean"`
+	Oracle       []ipRange `json:"oracle"`
+	Alibaba      []ipRange `json:"alibaba"`
 }
 
 type ipRange struct {
@@ -60,19 +87,20 @@ var cloudIPRangesData []byte
 
 // CloudDetector detects cloud providers from IP addresses.
 type CloudDetector struct {
-	awsNets   []*net.IPNet
-	azureNets []*net.IPNet
-	gcpNets   []*net.IPNet
-	regions   map[string]string // CIDR -> region mapping
-	mu        sync.RWMutex
-	loaded    bool
+	prefixes *prefixTrie // provider ranges, longest-prefix match
+	cdnNets  []cdnNet
+	mu       sync.RWMutex
+	loaded   bool
+
+	// ranges is the source data behind the parsed nets: embedded or
+	// fallback data at start, replaced per provider by refreshes
+	ranges  cloudIPRanges
+	sources CloudRangeSources
 }
 
 // NewCloudDetector creates a new cloud detector.
 func NewCloudDetector() *CloudDetector {
-	cd := &CloudDetector{
-		regions: make(map[string]string),
-	}
+	cd := &CloudDetector{}
 	cd.loadRanges()
 	return cd
 }
@@ -86,24 +114,37 @@ func (cd *CloudDetector) loadRanges() {
 		return
 	}
 
+	cd.cdnNets = loadCDNRanges()
+
+	cd.ranges = embeddedRanges()
+	cd.applyRanges(cd.ranges)
+	cd.loaded = true
+}
+
+// embeddedRanges returns the embedded range data, or the hardcoded fallback
+// if it is missing or unparseable.
+func embeddedRanges() cloudIPRanges {
 	var ranges cloudIPRanges
 
 	// Try to parse embedded data
 	if len(cloudIPRangesData) > 0 {
 		if err := json.Unmarshal(cloudIPRangesData, &ranges); err == nil {
-			cd.parseRanges(ranges)
-			cd.loaded = true
-			return
+			return ranges
 		}
 	}
 
 	// Fallback to hardcoded common ranges
-	cd.loadFallbackRanges()
-	cd.loaded = true
+	return fallbackRanges()
 }
 
-// loadFallbackRanges loads minimal hardcoded ranges as fallback.
-func (cd *CloudDetector) loadFallbackRanges() {
+// applyRanges replaces the parsed nets with ranges. Callers must hold mu.
+func (cd *CloudDetector) applyRanges(ranges cloudIPRanges) {
+	cd.prefixes = newPrefixTrie()
+	cd.parseRanges(ranges)
+}
+
+// fallbackRanges returns minimal hardcoded ranges without regions.
+func fallbackRanges() cloudIPRanges {
 	// AWS common ranges (subset)
 	awsCIDRs := []string{
 		"3.0.0.0/8",
@@ -151,50 +192,83 @@ func (cd *CloudDetector) loadFallbackRanges() {
 		"146.148.0.0/17",
 	}
 
-	for _, cidr := range awsCIDRs {
-		if _, ipnet, err := net.ParseCIDR(cidr); err == nil {
-			cd.awsNets = append(cd.awsNets, ipnet)
-		}
+	// DigitalOcean common ranges (subset)
+	digitalOceanCIDRs := []string{
+		"104.131.0.0/16",
+		"138.68.0.0/16",
+		"142.93.0.0/16",
+		"159.65.0.0/16",
+		"159.89.0.0/16",
+		"165.227.0.0/16",
+		"167.99.0.0/16",
+		"178.62.0.0/16",
+		"188.166.0.0/16",
+		"206.189.0.0/16",
 	}
 
-	for _, cidr := range azureCIDRs {
-		if _, ipnet, err := net.ParseCIDR(cidr); err == nil {
-			cd.azureNets = append(cd.azureNets, ipnet)
-		}
+	// Oracle Cloud Infrastructure common ranges (subset)
+	oracleCIDRs := []string{
+		"129.146.0.0/16",
+		"129.213.0.0/16",
+		"130.61.0.0/16",
+		"132.145.0.0/16",
+		"140.238.0.0/16",
+		"150.136.0.0/16",
+		"152.67.0.0/16",
+		"158.101.0.0/16",
+		"193.122.0.0/16",
 	}
 
-	for _, cidr := range gcpCIDRs {
-		if _, ipnet, err := net.ParseCIDR(cidr); err == nil {
-			cd.gcpNets = append(cd.gcpNets, ipnet)
-		}
+	// Alibaba Cloud common ranges (subset)
+	alibabaCIDRs := []string{
+		"47.52.0.0/16",
+		"47.74.0.0/15",
+		"47.88.0.0/14",
+		"47.235.0.0/16",
+		"47.236.0.0/14",
+		"149.129.0.0/16",
+		"161.117.0.0/16",
+	}
+
+	return cloudIPRanges{
+		AWS:          cidrRanges(awsCIDRs),
+		Azure:        cidrRanges(azureCIDRs),
+		GCP:          cidrRanges(gcpCIDRs),
+		DigitalOcean: cidrRanges(digitalOceanCIDRs),
+		Oracle:       cidrRanges(oracleCIDRs),
+		Alibaba:      cidrRanges(alibabaCIDRs),
 	}
 }
 
-// parseRanges parses IP ranges from the data structure.
-func (cd *CloudDetector) parseRanges(ranges cloudIPRanges) {
-	for _, r := range ranges.AWS {
-		if _, ipnet, err := net.ParseCIDR(r.CIDR); err == nil {
-			cd.awsNets = append(cd.awsNets, ipnet)
-			if r.Region != "" {
-				cd.regions[r.CIDR] = r.Region
-			}
-		}
+// cidrRanges wraps CIDRs as ranges without a region.
+func cidrRanges(cidrs []string) []ipRange {
+	ranges := make([]ipRange, len(cidrs))
+	for i, cidr := range cidrs {
+		ranges[i] = ipRange{CIDR: cidr}
 	}
+	return ranges
+}
 
-	for _, r := range ranges.Azure {
-		if _, ipnet, err := net.ParseCIDR(r.CIDR); err == nil {
-			cd.azureNets = append(cd.azureNets, ipnet)
-			if r.Region != "" {
-				cd.regions[r.CIDR] = r.Region
-			}
-		}
+// parseRanges inserts the IP ranges into the prefix trie. Providers are
+// inserted in cloudProviderOrder and the first provider to list a CIDR
+// keeps it, so a shared CIDR resolves predictably.
+func (cd *CloudDetector) parseRanges(ranges cloudIPRanges) {
+	byProvider := []struct {
+		provider CloudProvider
+		ranges   []ipRange
+	}{
+		{CloudProviderAWS, ranges.AWS},
+		{CloudProviderAzure, ranges.Azure},
+		{CloudProviderGCP, ranges.GCP},
+		{CloudProviderDigitalOcean, ranges.DigitalOcean},
+		{CloudProviderOracle, ranges.Oracle},
+		{CloudProviderAlibaba, ranges.Alibaba},
 	}
 
-	for _, r := range ranges.GCP {
-		if _, ipnet, err := net.ParseCIDR(r.CIDR); err == nil {
-			cd.gcpNets = append(cd.gcpNets, ipnet)
-			if r.Region != "" {
-				cd.regions[r.CIDR] = r.Region
+	for _, p := range byProvider {
+		for _, r := range p.ranges {
+			if _, ipnet, err := net.ParseCIDR(r.CIDR); err == nil {
+				cd.prefixes.insert(ipnet, prefixEntry{provider: p.provider, region: r.Region})
 			}
 		}
 	}
@@ -223,6 +297,22 @@ func (cd *CloudDetector) Detect(ipStr string) CloudDetectionResult {
 		}
 	}
 
+	// CDN edge addresses front origin services hosted elsewhere
+	if cdn, ok := cd.matchCDN(ip); ok {
+		provider, region := cd.matchProvider(ip)
+		if provider == CloudProviderNone {
+			provider = CloudProviderOther
+		}
+		return CloudDetectionResult{
+			Provider:     provider,
+			HostingModel: HostingModelUnknown,
+			Region:       region,
+			Confidence:   cdnOriginConfidence,
+			CDNFronted:   true,
+			CDN:          cdn,
+		}
+	}
+
 	// Check cloud provider ranges
 	if provider, region := cd.matchProvider(ip); provider != CloudProviderNone {
 		return CloudDetectionResult{
@@ -241,41 +331,28 @@ func (cd *CloudDetector) Detect(ipStr string) CloudDetectionResult {
 	}
 }
 
-// matchProvider checks if an IP matches any cloud provider range.
+// matchProvider returns the provider and region of the most specific
+// range containing ip, or CloudProviderNone.
 func (cd *CloudDetector) matchProvider(ip net.IP) (CloudProvider, string) {
-	// Check AWS
-	for _, ipnet := range cd.awsNets {
-		if ipnet.Contains(ip) {
-			return CloudProviderAWS, cd.regions[ipnet.String()]
-		}
-	}
-
-	// Check Azure
-	for _, ipnet := range cd.azureNets {
-		if ipnet.Contains(ip) {
-			return CloudProviderAzure, cd.regions[ipnet.String()]
-		}
-	}
-
-	// Check GCP
-	for _, ipnet := range cd.gcpNets {
-		if ipnet.Contains(ip) {
-			return CloudProviderGCP, cd.regions[ipnet.String()]
-		}
+	if entry, ok := cd.prefixes.lookup(ip); ok {
+		return entry.provider, entry.region
 	}
-
 	return CloudProviderNone, ""
 }
 
 // isPrivateIP checks if an IP is in a private/reserved range.
 func isPrivateIP(ip net.IP) bool {
-	// Private IPv4 ranges
+	// Private and reserved ranges
 	privateRanges := []string{
 		"10.0.0.0/8",
 		"172.16.0.0/12",
 		"192.168.0.0/16",
 		"127.0.0.0/8",
 		"169.254.0.0/16",
+		// IPv6 unique local, link-local and loopback
+		"fc00::/7",
+		"fe80::/10",
+		"::1/128",
 	}
 
 	for _, cidr := range privateRanges {
@@ -291,7 +368,12 @@ func isPrivateIP(ip net.IP) bool {
 	return false
 }
 
-// DetectMultiple detects cloud providers for multiple IPs and aggregates results.
+// DetectMultiple detects the cloud provider for each of a host's addresses,
+// e.g. the A and AAAA records of a dual-stack host, and aggregates them.
+// The provider comes from the highest-confidence detection that names one,
+// so a private address doesn't mask a public cloud address; equal
+// confidences go to the earlier provider in cloudProviderOrder. Region and
+// CDN come from the chosen detection.
 func (cd *CloudDetector) DetectMultiple(ips []string) CloudDetectionResult {
 	if len(ips) == 0 {
 		return CloudDetectionResult{
@@ -301,26 +383,13 @@ func (cd *CloudDetector) DetectMultiple(ips []string) CloudDetectionResult {
 		}
 	}
 
-	providers := make(map[CloudProvider]int)
 	hostingModels := make(map[HostingModel]int)
-	var region string
-
-	for _, ip := range ips {
+	var best CloudDetectionResult
+	for i, ip := range ips {
 		result := cd.Detect(ip)
-		providers[result.Provider]++
 		hostingModels[result.HostingModel]++
-		if result.Region != "" && region == "" {
-			region = result.Region
-		}
-	}
-
-	// Find dominant provider
-	var maxProvider CloudProvider
-	var maxCount int
-	for p, count := range providers {
-		if count > maxCount {
-			maxProvider = p
-			maxCount = count
+		if i == 0 || preferDetection(result, best) {
+			best = result
 		}
 	}
 
@@ -339,12 +408,34 @@ func (cd *CloudDetector) DetectMultiple(ips []string) CloudDetectionResult {
 		hostingModel = HostingModelUnknown
 	}
 
-	confidence := float64(maxCount) / float64(len(ips))
+	best.HostingModel = hostingModel
+	return best
+}
 
-	return CloudDetectionResult{
-		Provider:     maxProvider,
-		HostingModel: hostingModel,
-		Region:       region,
-		Confidence:   confidence,
+// preferDetection reports whether a should replace b as DetectMultiple's
+// aggregate result.
+func preferDetection(a, b CloudDetectionResult) bool {
+	if namesProvider(a.Provider) != namesProvider(b.Provider) {
+		return namesProvider(a.Provider)
+	}
+	if a.Confidence != b.Confidence {
+		return a.Confidence > b.Confidence
+	}
+	return providerRank(a.Provider) < providerRank(b.Provider)
+}
+
+// namesProvider reports whether p identifies a hosting provider rather
+// than the absence of one.
+func namesProvider(p CloudProvider) bool {
+	return p != CloudProviderNone && p != CloudProviderUnknown
+}
+
+// providerRank is p's position in cloudProviderOrder.
+func providerRank(p CloudProvider) int {
+	for i, provider := range cloudProviderOrder {
+		if provider == p {
+			return i
+		}
 	}
+	return len(cloudProviderOrder)
 }
@@ -0,0 +1,242 @@
+package scanner
+
+import (
+	"math/rand"
+	"net"
+	"reflect"
+	"testing"
+
+	"github.com/aiforce-discovery-agent/collectors/network-scanner/internal/config"
+)
+
+// linearRange is one provider range for linearMatch.
+type linearRange struct {
+	ipnet    *net.IPNet
+	provider CloudProvider
+	region   string
+}
+
+// linearRanges flattens ranges in the order parseRanges inserts them.
+func linearRanges(ranges cloudIPRanges) []linearRange {
+	byProvider := [][]ipRange{ranges.AWS, ranges.Azure, ranges.GCP, ranges.DigitalOcean, ranges.Oracle, ranges.Alibaba}
+	var flat []linearRange
+	for i, provider := range cloudProviderOrder[:len(byProvider)] {
+		for _, r := range byProvider[i] {
+			if _, ipnet, err := net.ParseCIDR(r.CIDR); err == nil {
+				flat = append(flat, linearRange{ipnet: ipnet, provider: provider, region: r.Region})
+			}
+		}
+	}
+	return flat
+}
+
+// linearMatch is the lookup the trie replaced: a scan of every range for
+// the longest prefix containing ip, the first listed winning a tie.
+func linearMatch(ranges []linearRange, ip net.IP) (CloudProvider, string) {
+	provider, region, best := CloudProviderNone, "", -1
+	for _, r := range ranges {
+		if ones, _ := r.ipnet.Mask.Size(); ones > best && r.ipnet.Contains(ip) {
+			provider, region, best = r.provider, r.region, ones
+		}
+	}
+	return provider, region
+}
+
+// sampleIPs returns addresses inside, at the edges of and around ranges,
+// plus random ones.
+func sampleIPs(ranges []linearRange, n int) []net.IP {
+	rng := rand.New(rand.NewSource(1))
+	var ips []net.IP
+	for _, r := range ranges {
+		first := r.ipnet.IP
+		last := make(net.IP, len(first))
+		for i := range first {
+			last[i] = first[i] | ^r.ipnet.Mask[i]
+		}
+		ips = append(ips, first, last, nextIP(last))
+		if len(ips) >= n {
+			break
+		}
+	}
+	for len(ips) < 2*n {
+		v4 := make(net.IP, 4)
+		rng.Read(v4)
+		ips = append(ips, v4)
+	}
+	return ips
+}
+
+// nextIP returns the address after ip.
+func nextIP(ip net.IP) net.IP {
+	next := append(net.IP(nil), ip...)
+	for i := len(next) - 1; i >= 0; i-- {
+		next[i]++
+		if next[i] != 0 {
+			break
+		}
+	}
+	return next
+}
+
+func TestMatchProviderAgreesWithLinearScan(t *testing.T) {
+	ranges := cloudIPRanges{
+		AWS:   []ipRange{{CIDR: "3.0.0.0/9", Region: "us-east-1"}, {CIDR: "3.5.140.0/22", Region: "ap-northeast-2"}},
+		Azure: []ipRange{{CIDR: "3.5.140.0/22", Region: "koreacentral"}, {CIDR: "20.33.0.0/16"}},
+		GCP:   []ipRange{{CIDR: "34.64.0.0/10"}, {CIDR: "2600:1900::/28", Region: "us-central1"}},
+	}
+	for name, r := range map[string]cloudIPRanges{"overlapping": ranges, "embedded": embeddedRanges()} {
+		t.Run(name, func(t *testing.T) {
+			cd := &CloudDetector{}
+			cd.applyRanges(r)
+			flat := linearRanges(r)
+
+			for _, ip := range sampleIPs(flat, 2000) {
+				wantProvider, wantRegion := linearMatch(flat, ip)
+				if provider, region := cd.matchProvider(ip); provider != wantProvider || region != wantRegion {
+					t.Errorf("matchProvider(%s) = %s %q, linear scan %s %q", ip, provider, region, wantProvider, wantRegion)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkMatchProvider(b *testing.B) {
+	ranges := embeddedRanges()
+	cd := &CloudDetector{}
+	cd.applyRanges(ranges)
+	flat := linearRanges(ranges)
+	ips := sampleIPs(flat, 1000)
+
+	b.Run("trie", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			cd.matchProvider(ips[i%len(ips)])
+		}
+	})
+	b.Run("linear", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			linearMatch(flat, ips[i%len(ips)])
+		}
+	})
+}
+
+func TestDetectAdditionalProviders(t *testing.T) {
+	fallback := &CloudDetector{}
+	fallback.ranges = fallbackRanges()
+	fallback.applyRanges(fallback.ranges)
+
+	detectors := []struct {
+		name string
+		cd   *CloudDetector
+	}{
+		{name: "embedded", cd: NewCloudDetector()},
+		{name: "fallback", cd: fallback},
+	}
+	tests := []struct {
+		ip   string
+		want CloudProvider
+	}{
+		{ip: "104.131.20.30", want: CloudProviderDigitalOcean},
+		{ip: "167.99.1.2", want: CloudProviderDigitalOcean},
+		{ip: "129.146.20.30", want: CloudProviderOracle},
+		{ip: "193.122.1.2", want: CloudProviderOracle},
+		{ip: "47.52.20.30", want: CloudProviderAlibaba},
+		{ip: "47.75.1.2", want: CloudProviderAlibaba},
+	}
+	for _, d := range detectors {
+		for _, tt := range tests {
+			got := d.cd.Detect(tt.ip)
+			if got.Provider != tt.want || got.HostingModel != HostingModelCloud {
+				t.Errorf("%s: Detect(%s) = %s %s, want %s cloud", d.name, tt.ip, got.Provider, got.HostingModel, tt.want)
+			}
+		}
+	}
+}
+
+func TestDetectCDNFronted(t *testing.T) {
+	tests := []struct {
+		ip      string
+		wantCDN string
+	}{
+		{ip: "104.16.1.1", wantCDN: "cloudflare"},
+		{ip: "172.67.10.20", wantCDN: "cloudflare"},
+		{ip: "23.235.33.7", wantCDN: "fastly"},
+		{ip: "8.8.8.8"},
+		{ip: "10.0.0.1"},
+	}
+	cd := NewCloudDetector()
+	for _, tt := range tests {
+		t.Run(tt.ip, func(t *testing.T) {
+			got := cd.Detect(tt.ip)
+			if got.CDNFronted != (tt.wantCDN != "") || got.CDN != tt.wantCDN {
+				t.Fatalf("Detect() = %+v, want CDN %q", got, tt.wantCDN)
+			}
+			if tt.wantCDN != "" && (got.Confidence != cdnOriginConfidence || got.HostingModel != HostingModelUnknown) {
+				t.Errorf("Detect() = %+v, want origin confidence %v and an unknown hosting model", got, cdnOriginConfidence)
+			}
+		})
+	}
+}
+
+func TestScanTagsCDNFrontedServices(t *testing.T) {
+	s := newTestScanner(t, config.ScannerConfig{Timeout: 500, PortRanges: []string{"22"}})
+	var dials int32
+	s.dial = pipeDial(&dials, func(conn net.Conn) {
+		_, _ = conn.Write([]byte("SSH-2.0-OpenSSH_9.6\r\n"))
+	})
+
+	results, err := s.ScanTarget("104.16.1.1")
+	if err != nil || len(results) != 1 {
+		t.Fatalf("ScanTarget() = %v, %v; want one result", results, err)
+	}
+	if results[0].CDN != "cloudflare" {
+		t.Errorf("CDN = %q, want cloudflare", results[0].CDN)
+	}
+	server := s.serverData(results)
+	if server.Metadata["cdn_fronted"] != true || server.Metadata["cdn"] != "cloudflare" {
+		t.Errorf("server metadata = %v, want cdn_fronted by cloudflare", server.Metadata)
+	}
+}
+
+func TestScanTagsCloudMetadata(t *testing.T) {
+	tests := []struct {
+		name    string
+		enabled bool
+		ip      string
+		want    map[string]interface{}
+	}{
+		{
+			name: "cloud", enabled: true, ip: "129.146.20.30",
+			want: map[string]interface{}{
+				"cloud_provider": "oracle", "hosting_model": "cloud", "cloud_region": "us-phoenix-1", "cloud_confidence": 0.85,
+			},
+		},
+		{
+			name: "on premises", enabled: true, ip: "10.0.0.5",
+			want: map[string]interface{}{"cloud_provider": "none", "hosting_model": "on_premises", "cloud_confidence": 0.9},
+		},
+		{name: "disabled", ip: "129.146.20.30", want: map[string]interface{}{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestScanner(t, config.ScannerConfig{Timeout: 500, PortRanges: []string{"22", "80"}, EnableCloudDetection: tt.enabled})
+			var dials int32
+			s.dial = pipeDial(&dials, func(net.Conn) {})
+
+			results, err := s.ScanTarget(tt.ip)
+			if err != nil || len(results) != 2 {
+				t.Fatalf("ScanTarget() = %v, %v; want two results", results, err)
+			}
+			for _, result := range results {
+				got := make(map[string]interface{})
+				for _, key := range []string{"cloud_provider", "hosting_model", "cloud_region", "cloud_confidence"} {
+					if v, ok := result.Metadata[key]; ok {
+						got[key] = v
+					}
+				}
+				if !reflect.DeepEqual(got, tt.want) {
+					t.Errorf("port %d cloud metadata = %v, want %v", result.Port, got, tt.want)
+				}
+			}
+		})
+	}
+}
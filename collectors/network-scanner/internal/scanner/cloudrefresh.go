@@ -0,0 +1,205 @@
+package scanner
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// maxRangeDocumentBytes bounds a downloaded range document; the Azure
+// service tags file is the largest at a few megabytes.
+const maxRangeDocumentBytes = 64 << 20
+
+// CloudRangeSources are the URLs refreshed ranges are fetched from. An
+// empty URL leaves that provider's ranges as they are.
+type CloudRangeSources struct {
+	AWS   string // ip-ranges.json format
+	Azure string // ServiceTags_Public JSON format
+	GCP   string // cloud.json format
+}
+
+// awsRangeDocument is the subset of AWS ip-ranges.json used.
+type awsRangeDocument struct {
+	Prefixes []struct {
+		IPPrefix string `json:"ip_prefix"`
+		Region   string `json:"region"`
+	} `json:"prefixes"`
+	IPv6Prefixes []struct {
+		IPv6Prefix string `json:"ipv6_prefix"`
+		Region     string `json:"region"`
+	} `json:"ipv6_prefixes"`
+}
+
+// azureRangeDocument is the subset of Azure service tags used. Regional
+// AzureCloud.<region> tags carry the address prefixes per region.
+type azureRangeDocument struct {
+	Values []struct {
+		Name       string `json:"name"`
+		Properties struct {
+			Region          string   `json:"region"`
+			AddressPrefixes []string `json:"addressPrefixes"`
+		} `json:"properties"`
+	} `json:"values"`
+}
+
+// gcpRangeDocument is the subset of GCP cloud.json used.
+type gcpRangeDocument struct {
+	Prefixes []struct {
+		IPv4Prefix string `json:"ipv4Prefix"`
+		IPv6Prefix string `json:"ipv6Prefix"`
+		Scope      string `json:"scope"`
+	} `json:"prefixes"`
+}
+
+// SetSources sets the URLs RefreshNow fetches ranges from.
+func (cd *CloudDetector) SetSources(sources CloudRangeSources) {
+	cd.mu.Lock()
+	defer cd.mu.Unlock()
+	cd.sources = sources
+}
+
+// RefreshNow fetches each configured provider's ranges and rebuilds the
+// lookup. A provider whose fetch fails, or yields no ranges, keeps its
+// current ranges (initially the embedded data), so detection never runs on
+// an empty set. The returned error joins the per-provider failures.
+func (cd *CloudDetector) RefreshNow(ctx context.Context) error {
+	cd.mu.RLock()
+	sources := cd.sources
+	ranges := cd.ranges
+	cd.mu.RUnlock()
+
+	var errs []error
+	refresh := func(name, url string, parse func([]byte) ([]ipRange, error), dst *[]ipRange) {
+		if url == "" {
+			return
+		}
+		fetched, err := fetchRanges(ctx, url, parse)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s ranges: %w", name, err))
+			return
+		}
+		*dst = fetched
+	}
+	refresh("aws", sources.AWS, parseAWSRanges, &ranges.AWS)
+	refresh("azure", sources.Azure, parseAzureRanges, &ranges.Azure)
+	refresh("gcp", sources.GCP, parseGCPRanges, &ranges.GCP)
+
+	cd.mu.Lock()
+	cd.ranges = ranges
+	cd.applyRanges(ranges)
+	cd.mu.Unlock()
+
+	return errors.Join(errs...)
+}
+
+// StartRefresh refreshes ranges immediately and then every interval for
+// the life of the process. Failures are logged and the previous ranges
+// kept.
+func (cd *CloudDetector) StartRefresh(interval time.Duration, logger *zap.SugaredLogger) {
+	refresh := func() {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+		defer cancel()
+		if err := cd.RefreshNow(ctx); err != nil {
+			logger.Warnw("Cloud range refresh failed, keeping previous ranges", "error", err)
+			return
+		}
+		logger.Infow("Cloud ranges refreshed")
+	}
+
+	go func() {
+		refresh()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for range ticker.C {
+			refresh()
+		}
+	}()
+}
+
+// fetchRanges downloads a range document and parses it, rejecting empty
+// results.
+func fetchRanges(ctx context.Context, url string, parse func([]byte) ([]ipRange, error)) ([]ipRange, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxRangeDocumentBytes))
+	if err != nil {
+		return nil, err
+	}
+
+	ranges, err := parse(body)
+	if err != nil {
+		return nil, err
+	}
+	if len(ranges) == 0 {
+		return nil, errors.New("document contains no ranges")
+	}
+	return ranges, nil
+}
+
+func parseAWSRanges(body []byte) ([]ipRange, error) {
+	var doc awsRangeDocument
+	if err := json.Unmarshal(body, &doc); err != nil {
+		return nil, err
+	}
+	var ranges []ipRange
+	for _, p := range doc.Prefixes {
+		ranges = append(ranges, ipRange{CIDR: p.IPPrefix, Region: p.Region})
+	}
+	for _, p := range doc.IPv6Prefixes {
+		ranges = append(ranges, ipRange{CIDR: p.IPv6Prefix, Region: p.Region})
+	}
+	return ranges, nil
+}
+
+func parseAzureRanges(body []byte) ([]ipRange, error) {
+	var doc azureRangeDocument
+	if err := json.Unmarshal(body, &doc); err != nil {
+		return nil, err
+	}
+	var ranges []ipRange
+	for _, v := range doc.Values {
+		if !strings.HasPrefix(v.Name, "AzureCloud.") {
+			continue
+		}
+		for _, prefix := range v.Properties.AddressPrefixes {
+			ranges = append(ranges, ipRange{CIDR: prefix, Region: v.Properties.Region})
+		}
+	}
+	return ranges, nil
+}
+
+func parseGCPRanges(body []byte) ([]ipRange, error) {
+	var doc gcpRangeDocument
+	if err := json.Unmarshal(body, &doc); err != nil {
+		return nil, err
+	}
+	var ranges []ipRange
+	for _, p := range doc.Prefixes {
+		cidr := p.IPv4Prefix
+		if cidr == "" {
+			cidr = p.IPv6Prefix
+		}
+		if cidr != "" {
+			ranges = append(ranges, ipRange{CIDR: cidr, Region: p.Scope})
+		}
+	}
+	return ranges, nil
+}
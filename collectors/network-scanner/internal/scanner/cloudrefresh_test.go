@@ -0,0 +1,86 @@
+package scanner
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// rangeDocuments serves provider range documents by path; other paths fail.
+func rangeDocuments(t *testing.T, docs map[string]string) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		doc, ok := docs[r.URL.Path]
+		if !ok {
+			http.Error(w, "unavailable", http.StatusInternalServerError)
+			return
+		}
+		_, _ = w.Write([]byte(doc))
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestRefreshCloudRanges(t *testing.T) {
+	srv := rangeDocuments(t, map[string]string{
+		"/aws.json": `{"prefixes":[{"ip_prefix":"192.0.2.0/24","region":"us-east-1"}],` +
+			`"ipv6_prefixes":[{"ipv6_prefix":"2001:db8:a::/48","region":"us-east-1"}]}`,
+		"/azure.json": `{"values":[` +
+			`{"name":"AzureCloud.westeurope","properties":{"region":"westeurope","addressPrefixes":["198.51.100.0/24"]}},` +
+			`{"name":"Storage","properties":{"region":"","addressPrefixes":["198.51.101.0/24"]}}]}`,
+		"/gcp.json": `{"prefixes":[{"ipv4Prefix":"203.0.113.0/24","scope":"europe-west1"}]}`,
+	})
+
+	cd := NewCloudDetector()
+	cd.SetSources(CloudRangeSources{AWS: srv.URL + "/aws.json", Azure: srv.URL + "/azure.json", GCP: srv.URL + "/gcp.json"})
+	if err := cd.RefreshNow(context.Background()); err != nil {
+		t.Fatalf("RefreshNow() error = %v", err)
+	}
+
+	tests := []struct {
+		ip           string
+		wantProvider CloudProvider
+		wantRegion   string
+	}{
+		{ip: "192.0.2.10", wantProvider: CloudProviderAWS, wantRegion: "us-east-1"},
+		{ip: "2001:db8:a::10", wantProvider: CloudProviderAWS, wantRegion: "us-east-1"},
+		{ip: "198.51.100.10", wantProvider: CloudProviderAzure, wantRegion: "westeurope"},
+		{ip: "203.0.113.10", wantProvider: CloudProviderGCP, wantRegion: "europe-west1"},
+		// Only the regional AzureCloud tags are Azure compute
+		{ip: "198.51.101.10", wantProvider: CloudProviderOther},
+	}
+	for _, tt := range tests {
+		got := cd.Detect(tt.ip)
+		if got.Provider != tt.wantProvider || got.Region != tt.wantRegion {
+			t.Errorf("Detect(%s) = %s %q, want %s %q", tt.ip, got.Provider, got.Region, tt.wantProvider, tt.wantRegion)
+		}
+	}
+}
+
+func TestRefreshKeepsRangesOnFailure(t *testing.T) {
+	srv := rangeDocuments(t, map[string]string{
+		"/aws.json": `{"prefixes":[{"ip_prefix":"192.0.2.0/24","region":"us-east-1"}]}`,
+		"/gcp.json": `{"prefixes":[]}`,
+	})
+
+	cd := NewCloudDetector()
+	embeddedAzure, embeddedGCP := len(cd.ranges.Azure), len(cd.ranges.GCP)
+	cd.SetSources(CloudRangeSources{AWS: srv.URL + "/aws.json", Azure: srv.URL + "/azure.json", GCP: srv.URL + "/gcp.json"})
+
+	err := cd.RefreshNow(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "azure ranges: unexpected status 500") ||
+		!strings.Contains(err.Error(), "gcp ranges: document contains no ranges") {
+		t.Fatalf("RefreshNow() error = %v, want the Azure and GCP failures", err)
+	}
+
+	// The providers that failed keep their ranges; the rest are refreshed
+	if len(cd.ranges.Azure) != embeddedAzure || len(cd.ranges.GCP) != embeddedGCP {
+		t.Errorf("Azure and GCP ranges = %d and %d, want the embedded %d and %d",
+			len(cd.ranges.Azure), len(cd.ranges.GCP), embeddedAzure, embeddedGCP)
+	}
+	if got := cd.Detect("192.0.2.10"); got.Provider != CloudProviderAWS {
+		t.Errorf("Detect(192.0.2.10) = %s, want AWS from the refreshed ranges", got.Provider)
+	}
+}
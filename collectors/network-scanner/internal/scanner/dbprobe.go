@@ -0,0 +1,221 @@
+package scanner
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net"
+	"regexp"
+	"strconv"
+	"time"
+
+	"github.com/aiforce-discovery-agent/collectors/network-scanner/internal/publisher"
+)
+
+// handshakeProbe confirms a database that only answers its own protocol's
+// handshake. parse reports whether a response is that protocol and, if the
+// response carries it, the server version.
+type handshakeProbe struct {
+	name    string // active_probes name
+	service string // wellKnownPorts name
+	packet  []byte
+	parse   func(response []byte) (version string, ok bool)
+}
+
+// handshakeProbes are sent to their port, when enabled in ActiveProbes, in
+// place of the generic probes. Without them a listener on 1433 or 1521 is
+// a database candidate by port alone.
+var handshakeProbes = map[int]handshakeProbe{
+	1433: {name: "mssql", service: "MSSQL", packet: tdsPrelogin, parse: parseTDSPrelogin},
+	1521: {name: "oracle", service: "Oracle", packet: tnsVersionConnect, parse: parseTNSResponse},
+}
+
+// handshakeResult is the outcome of a probe confirming a database.
+type handshakeResult struct {
+	name      string // active_probes name of the probe
+	service   string // service the probe confirms
+	response  []byte
+	confirmed bool
+	product   string
+	version   string
+}
+
+// handshake sends the port's handshake probe on conn. It reports false when
+// the port has no enabled probe or the service sent nothing back, leaving
+// the port-only identification as it was.
+func (s *Scanner) handshake(conn net.Conn, port int) (handshakeResult, bool) {
+	probe, ok := handshakeProbes[port]
+	if !ok || !s.activeProbeEnabled(probe.name) {
+		return handshakeResult{}, false
+	}
+
+	timeout := time.Duration(s.config.ActiveProbeTimeoutMS) * time.Millisecond
+	response := sendProbe(conn, string(probe.packet), timeout)
+	if response == "" {
+		return handshakeResult{}, false
+	}
+	version, confirmed := probe.parse([]byte(response))
+	return handshakeResult{
+		name:      probe.name,
+		service:   probe.service,
+		response:  []byte(response),
+		confirmed: confirmed,
+		version:   version,
+	}, true
+}
+
+// applyHandshake records a handshake outcome on result, after banner
+// fingerprinting. A confirmed service raises the candidate confidence; any
+// other response clears the database candidate flag, and the response is
+// left as the banner for the signatures to identify.
+func applyHandshake(result *ScanResult, hs handshakeResult) {
+	if result.Metadata == nil {
+		result.Metadata = make(map[string]interface{})
+	}
+	if !hs.confirmed {
+		result.Metadata["database_candidate"] = false
+		return
+	}
+
+	result.Service = hs.service
+	result.Version = hs.version
+	if hs.product != "" {
+		result.Product = hs.product
+	}
+	result.FingerprintSource = "handshake:" + hs.name
+	reason := fmt.Sprintf("%s confirmed by probe on port %d", hs.service, result.Port)
+	if hs.version != "" {
+		reason += ", version " + hs.version
+	}
+	result.Metadata["candidate_confidence"] = publisher.HandshakeConfidence
+	result.Metadata["candidate_reason"] = reason
+}
+
+func (s *Scanner) activeProbeEnabled(name string) bool {
+	for _, enabled := range s.config.ActiveProbes {
+		if enabled == name {
+			return true
+		}
+	}
+	return false
+}
+
+// TDS (MS-TDS) packet types and PRELOGIN option tokens.
+const (
+	tdsPacketPrelogin  = 0x12
+	tdsPacketResponse  = 0x04
+	tdsOptionVersion   = 0x00
+	tdsOptionTerminate = 0xff
+	tdsHeaderSize      = 8
+)
+
+// tdsPrelogin is a PRELOGIN packet offering VERSION and ENCRYPTION (not
+// supported), which servers answer without a login.
+var tdsPrelogin = []byte{
+	// Header: type, status (end of message), length, SPID, packet ID, window
+	tdsPacketPrelogin, 0x01, 0x00, 0x1a, 0x00, 0x00, 0x01, 0x00,
+	// Options: token, offset, length
+	0x00, 0x00, 0x0b, 0x00, 0x06, // VERSION
+	0x01, 0x00, 0x11, 0x00, 0x01, // ENCRYPTION
+	tdsOptionTerminate,
+	// VERSION data (client version, unused) and ENCRYPT_NOT_SUP
+	0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+	0x02,
+}
+
+// parseTDSPrelogin parses a PRELOGIN response, returning the server version
+// as major.minor.build (e.g. 15.0.2000 for SQL Server 2019).
+func parseTDSPrelogin(response []byte) (string, bool) {
+	if len(response) < tdsHeaderSize+1 || response[0] != tdsPacketResponse {
+		return "", false
+	}
+	length := int(binary.BigEndian.Uint16(response[2:4]))
+	if length < tdsHeaderSize+1 || length > len(response) {
+		return "", false
+	}
+	payload := response[tdsHeaderSize:length]
+
+	for i := 0; i < len(payload); i += 5 {
+		token := payload[i]
+		if token == tdsOptionTerminate {
+			return "", true
+		}
+		if i+5 > len(payload) {
+			return "", false
+		}
+		offset := int(binary.BigEndian.Uint16(payload[i+1 : i+3]))
+		size := int(binary.BigEndian.Uint16(payload[i+3 : i+5]))
+		if offset+size > len(payload) {
+			return "", false
+		}
+		if token == tdsOptionVersion && size >= 4 {
+			v := payload[offset : offset+size]
+			return fmt.Sprintf("%d.%d.%d", v[0], v[1], binary.BigEndian.Uint16(v[2:4])), true
+		}
+	}
+	return "", false
+}
+
+// TNS packet types.
+const (
+	tnsPacketConnect  = 1
+	tnsPacketAccept   = 2
+	tnsPacketRefuse   = 4
+	tnsPacketRedirect = 5
+	tnsPacketResend   = 11
+	tnsHeaderSize     = 8
+)
+
+// tnsVersionConnect is a TNS CONNECT asking the listener for its version.
+// Listeners that refuse remote commands still report VSNNUM in the refusal.
+var tnsVersionConnect = buildTNSConnect("(CONNECT_DATA=(COMMAND=version))")
+
+func buildTNSConnect(connectData string) []byte {
+	const dataOffset = 58
+
+	packet := make([]byte, dataOffset, dataOffset+len(connectData))
+	binary.BigEndian.PutUint16(packet[0:], uint16(dataOffset+len(connectData)))
+	packet[4] = tnsPacketConnect
+
+	body := packet[tnsHeaderSize:]
+	binary.BigEndian.PutUint16(body[0:], 0x013a)  // version
+	binary.BigEndian.PutUint16(body[2:], 0x012c)  // lowest compatible version
+	binary.BigEndian.PutUint16(body[6:], 0x0800)  // SDU
+	binary.BigEndian.PutUint16(body[8:], 0x7fff)  // TDU
+	binary.BigEndian.PutUint16(body[10:], 0x4f98) // protocol characteristics
+	binary.BigEndian.PutUint16(body[14:], 0x0001) // byte order check
+	binary.BigEndian.PutUint16(body[16:], uint16(len(connectData)))
+	binary.BigEndian.PutUint16(body[18:], dataOffset)
+	body[24], body[25] = 0x41, 0x41 // connect flags
+
+	return append(packet, connectData...)
+}
+
+// tnsVersionPattern finds the listener version number in a TNS response.
+var tnsVersionPattern = regexp.MustCompile(`VSNNUM=(\d+)`)
+
+// parseTNSResponse recognises a listener's answer to a CONNECT, returning
+// the version from VSNNUM (e.g. 19.0.0.0.0) when the response has one.
+func parseTNSResponse(response []byte) (string, bool) {
+	if len(response) < tnsHeaderSize {
+		return "", false
+	}
+	length := int(binary.BigEndian.Uint16(response[0:2]))
+	switch response[4] {
+	case tnsPacketAccept, tnsPacketRefuse, tnsPacketRedirect, tnsPacketResend:
+	default:
+		return "", false
+	}
+	if length < tnsHeaderSize || response[5] != 0 {
+		return "", false
+	}
+
+	match := tnsVersionPattern.FindSubmatch(response)
+	if match == nil {
+		return "", true
+	}
+	vsn, err := strconv.ParseUint(string(match[1]), 10, 32)
+	if err != nil {
+		return "", true
+	}
+	return fmt.Sprintf("%d.%d.%d.%d.%d", vsn>>24, vsn>>20&0xf, vsn>>12&0xff, vsn>>8&0xf, vsn&0xff), true
+}
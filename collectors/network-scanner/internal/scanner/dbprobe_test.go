@@ -0,0 +1,157 @@
+package scanner
+
+import (
+	"encoding/binary"
+	"io"
+	"net"
+	"testing"
+
+	"github.com/aiforce-discovery-agent/collectors/network-scanner/internal/config"
+)
+
+// sqlServer2019Prelogin is a SQL Server 2019 (15.0.2000) PRELOGIN response
+// with VERSION, ENCRYPTION, INSTOPT, THREADID and MARS options.
+var sqlServer2019Prelogin = []byte{
+	0x04, 0x01, 0x00, 0x2b, 0x00, 0x00, 0x01, 0x00,
+	0x00, 0x00, 0x1a, 0x00, 0x06,
+	0x01, 0x00, 0x20, 0x00, 0x01,
+	0x02, 0x00, 0x21, 0x00, 0x01,
+	0x03, 0x00, 0x22, 0x00, 0x00,
+	0x04, 0x00, 0x22, 0x00, 0x01,
+	0xff,
+	0x0f, 0x00, 0x07, 0xd0, 0x00, 0x00,
+	0x02,
+	0x00,
+	0x00,
+}
+
+func TestParseTDSPrelogin(t *testing.T) {
+	truncated := append([]byte(nil), sqlServer2019Prelogin...)
+	binary.BigEndian.PutUint16(truncated[2:], 0x40)
+
+	badOffset := append([]byte(nil), sqlServer2019Prelogin...)
+	badOffset[10] = 0x30
+
+	tests := []struct {
+		name        string
+		response    []byte
+		wantVersion string
+		wantOK      bool
+	}{
+		{name: "sql server 2019", response: sqlServer2019Prelogin, wantVersion: "15.0.2000", wantOK: true},
+		{name: "no version option", response: []byte{0x04, 0x01, 0x00, 0x09, 0x00, 0x00, 0x01, 0x00, 0xff}, wantOK: true},
+		{name: "not a tabular result", response: append([]byte{0x12}, sqlServer2019Prelogin[1:]...)},
+		{name: "length past the response", response: truncated},
+		{name: "option data past the payload", response: badOffset},
+		{name: "http reply", response: []byte("HTTP/1.1 400 Bad Request\r\n\r\n")},
+		{name: "short", response: []byte{0x04, 0x01}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			version, ok := parseTDSPrelogin(tt.response)
+			if version != tt.wantVersion || ok != tt.wantOK {
+				t.Errorf("parseTDSPrelogin() = %q, %v; want %q, %v", version, ok, tt.wantVersion, tt.wantOK)
+			}
+		})
+	}
+}
+
+// tnsPacket frames data as a TNS packet of the given type, with a refuse
+// packet's reason bytes and data length before it; the parser only looks at
+// the header and the data.
+func tnsPacket(packetType byte, data string) []byte {
+	body := []byte{0x22, 0x00}
+	body = binary.BigEndian.AppendUint16(body, uint16(len(data)))
+	body = append(body, data...)
+
+	packet := make([]byte, tnsHeaderSize, tnsHeaderSize+len(body))
+	binary.BigEndian.PutUint16(packet[0:], uint16(tnsHeaderSize+len(body)))
+	packet[4] = packetType
+	return append(packet, body...)
+}
+
+func TestParseTNSResponse(t *testing.T) {
+	reservedSet := tnsPacket(tnsPacketRefuse, "(DESCRIPTION=(ERR=1189))")
+	reservedSet[5] = 1
+
+	tests := []struct {
+		name        string
+		response    []byte
+		wantVersion string
+		wantOK      bool
+	}{
+		{
+			name:        "19c refusing remote commands",
+			response:    tnsPacket(tnsPacketRefuse, "(DESCRIPTION=(TMP=)(VSNNUM=318767104)(ERR=1189)(ERROR_STACK=(ERROR=(CODE=1189)(EMFI=4))))"),
+			wantVersion: "19.0.0.0.0",
+			wantOK:      true,
+		},
+		{
+			name:        "11g accepting the version command",
+			response:    tnsPacket(tnsPacketAccept, "(DESCRIPTION=(TMP=)(VSNNUM=186647552)(ERR=0))"),
+			wantVersion: "11.2.0.4.0",
+			wantOK:      true,
+		},
+		{name: "refusal without a version", response: tnsPacket(tnsPacketRefuse, "(DESCRIPTION=(ERR=12514))"), wantOK: true},
+		{name: "redirect", response: tnsPacket(tnsPacketRedirect, "(ADDRESS=(PROTOCOL=TCP)(HOST=db2)(PORT=1521))"), wantOK: true},
+		{name: "connect echoed back", response: tnsVersionConnect},
+		{name: "reserved byte set", response: reservedSet},
+		{name: "ssh banner", response: []byte("SSH-2.0-OpenSSH_8.9p1\r\n")},
+		{name: "short", response: []byte{0x00, 0x08, 0x00}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			version, ok := parseTNSResponse(tt.response)
+			if version != tt.wantVersion || ok != tt.wantOK {
+				t.Errorf("parseTNSResponse() = %q, %v; want %q, %v", version, ok, tt.wantVersion, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestHandshake(t *testing.T) {
+	tests := []struct {
+		name          string
+		probes        []string
+		reply         []byte
+		wantSent      bool
+		wantService   string
+		wantVersion   string
+		wantCandidate interface{}
+	}{
+		{name: "confirmed", probes: []string{"mssql"}, reply: sqlServer2019Prelogin, wantSent: true, wantService: "MSSQL", wantVersion: "15.0.2000"},
+		{name: "another protocol", probes: []string{"mssql"}, reply: []byte("HTTP/1.1 400 Bad Request\r\n\r\n"), wantSent: true, wantService: "unknown", wantCandidate: false},
+		{name: "probe disabled", probes: []string{"oracle"}, reply: sqlServer2019Prelogin, wantService: "unknown"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestScanner(t, config.ScannerConfig{ActiveProbes: tt.probes, ActiveProbeTimeoutMS: 1000})
+			client, server := net.Pipe()
+			defer func() { _ = client.Close() }()
+			go func() {
+				defer func() { _ = server.Close() }()
+				buffer := make([]byte, len(tdsPrelogin))
+				if _, err := io.ReadFull(server, buffer); err != nil {
+					return
+				}
+				_, _ = server.Write(tt.reply)
+			}()
+
+			result := ScanResult{Port: 1433, Service: "unknown"}
+			hs, sent := s.handshake(client, 1433)
+			if sent != tt.wantSent {
+				t.Fatalf("handshake() sent = %v, want %v", sent, tt.wantSent)
+			}
+			if sent {
+				applyHandshake(&result, hs)
+			}
+
+			if result.Service != tt.wantService || result.Version != tt.wantVersion {
+				t.Errorf("service %q version %q, want %q %q", result.Service, result.Version, tt.wantService, tt.wantVersion)
+			}
+			if got := result.Metadata["database_candidate"]; got != tt.wantCandidate {
+				t.Errorf("database_candidate = %v, want %v", got, tt.wantCandidate)
+			}
+		})
+	}
+}
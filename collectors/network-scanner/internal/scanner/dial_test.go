@@ -0,0 +1,359 @@
+package scanner
+
+import (
+	"context"
+	"errors"
+	"net"
+	"os"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+	"golang.org/x/time/rate"
+
+	"github.com/aiforce-discovery-agent/collectors/network-scanner/internal/config"
+)
+
+// timeoutErr is a dial timeout.
+type timeoutErr struct{}
+
+func (timeoutErr) Error() string   { return "i/o timeout" }
+func (timeoutErr) Timeout() bool   { return true }
+func (timeoutErr) Temporary() bool { return true }
+
+// spentLimiter returns a slow limiter whose only token the caller took for
+// the first attempt.
+func spentLimiter() *rate.Limiter {
+	l := rate.NewLimiter(rate.Every(time.Hour), 1)
+	l.Allow()
+	return l
+}
+
+func TestDialWithRetryWaitsOnLimiter(t *testing.T) {
+	tests := []struct {
+		name       string
+		retryCount int
+		limiter    *rate.Limiter
+		wantDials  int32
+	}{
+		{name: "no retries", retryCount: 0, limiter: rate.NewLimiter(rate.Inf, 1), wantDials: 1},
+		{name: "retries under an open limiter", retryCount: 2, limiter: rate.NewLimiter(rate.Inf, 1), wantDials: 3},
+		{name: "retries held by the limiter", retryCount: 2, limiter: spentLimiter(), wantDials: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestScanner(t, config.ScannerConfig{RetryCount: tt.retryCount})
+			s.limiter = tt.limiter
+			var dials int32
+			s.dial = func(string, string, time.Duration) (net.Conn, error) {
+				atomic.AddInt32(&dials, 1)
+				return nil, timeoutErr{}
+			}
+
+			ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+			defer cancel()
+			_, _, err := s.dialWithRetry(ctx, "tcp", "192.0.2.1:80", time.Millisecond)
+			if err == nil {
+				t.Fatal("dialWithRetry() succeeded")
+			}
+			if got := atomic.LoadInt32(&dials); got != tt.wantDials {
+				t.Errorf("dials = %d, want %d", got, tt.wantDials)
+			}
+			var netErr net.Error
+			if tt.wantDials > 1 && (!errors.As(err, &netErr) || !netErr.Timeout()) {
+				t.Errorf("error = %v, want the dial timeout", err)
+			}
+		})
+	}
+}
+
+func TestDialWithRetry(t *testing.T) {
+	refused := &net.OpError{Op: "dial", Net: "tcp", Err: syscall.ECONNREFUSED}
+	tests := []struct {
+		name      string
+		errs      []error // per dial; dials past the end connect
+		wantDials int
+		wantConn  bool
+	}{
+		{name: "connects", wantDials: 1, wantConn: true},
+		{name: "refused is final", errs: []error{refused}, wantDials: 1},
+		{name: "timeout then connects", errs: []error{timeoutErr{}}, wantDials: 2, wantConn: true},
+		{name: "timeout then refused", errs: []error{timeoutErr{}, refused}, wantDials: 2},
+		{name: "retries exhausted", errs: []error{timeoutErr{}, timeoutErr{}, timeoutErr{}, timeoutErr{}}, wantDials: 3},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestScanner(t, config.ScannerConfig{RetryCount: 2, RetryBackoffMS: 20})
+			var dialTimes []time.Time
+			s.dial = func(string, string, time.Duration) (net.Conn, error) {
+				dialTimes = append(dialTimes, time.Now())
+				if n := len(dialTimes); n <= len(tt.errs) {
+					return nil, tt.errs[n-1]
+				}
+				client, server := net.Pipe()
+				_ = server.Close()
+				return client, nil
+			}
+
+			conn, _, err := s.dialWithRetry(context.Background(), "tcp", "192.0.2.1:80", time.Millisecond)
+			if conn != nil {
+				_ = conn.Close()
+			}
+			if (conn != nil) != tt.wantConn || (err == nil) != tt.wantConn {
+				t.Errorf("dialWithRetry() = %v, %v; want a connection %v", conn, err, tt.wantConn)
+			}
+			if len(dialTimes) != tt.wantDials {
+				t.Fatalf("dials = %d, want %d", len(dialTimes), tt.wantDials)
+			}
+			// Backoff doubles after each timed-out attempt: 20ms, then 40ms
+			for i := 1; i < len(dialTimes); i++ {
+				want := 20 * time.Millisecond << uint(i-1)
+				if gap := dialTimes[i].Sub(dialTimes[i-1]); gap < want {
+					t.Errorf("retry %d after %v, want at least %v", i, gap, want)
+				}
+			}
+		})
+	}
+}
+
+func TestDialWithRetryBackoffCancelled(t *testing.T) {
+	s := newTestScanner(t, config.ScannerConfig{RetryCount: 3, RetryBackoffMS: 10000})
+	var dials int32
+	s.dial = func(string, string, time.Duration) (net.Conn, error) {
+		atomic.AddInt32(&dials, 1)
+		return nil, timeoutErr{}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if _, _, err := s.dialWithRetry(ctx, "tcp", "192.0.2.1:80", time.Millisecond); err == nil {
+		t.Fatal("dialWithRetry() succeeded")
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("dialWithRetry() returned after %v, want the backoff cut short", elapsed)
+	}
+	if got := atomic.LoadInt32(&dials); got != 1 {
+		t.Errorf("dials = %d, want 1", got)
+	}
+}
+
+func TestScanPortCountsTimeoutAfterRetries(t *testing.T) {
+	tests := []struct {
+		name         string
+		retryCount   int
+		wantTimedOut bool
+	}{
+		{name: "no retries", retryCount: 0, wantTimedOut: true},
+		{name: "answered on retry", retryCount: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestScanner(t, config.ScannerConfig{Timeout: 100, RetryCount: tt.retryCount})
+			var dials int32
+			s.dial = func(string, string, time.Duration) (net.Conn, error) {
+				if atomic.AddInt32(&dials, 1) == 1 {
+					return nil, timeoutErr{}
+				}
+				return nil, &net.OpError{Op: "dial", Net: "tcp", Err: syscall.ECONNREFUSED}
+			}
+
+			result := s.scanPort(context.Background(), "192.0.2.1", 80, "tcp")
+			if result.Open || result.TimedOut != tt.wantTimedOut {
+				t.Errorf("open %v, timed out %v; want closed, timed out %v", result.Open, result.TimedOut, tt.wantTimedOut)
+			}
+		})
+	}
+}
+
+func TestProbeDialsWaitOnLimiter(t *testing.T) {
+	const burst = 100
+	ctx := context.Background()
+
+	tests := []struct {
+		name      string
+		probe     func(s *Scanner)
+		wantDials int32
+	}{
+		{name: "tcp ping", probe: func(s *Scanner) { s.tcpPing("192.0.2.1", time.Second) }, wantDials: 2},
+		{name: "tls", probe: func(s *Scanner) { _, _ = s.grabTLS(ctx, "192.0.2.1:443", 443, time.Second) }, wantDials: 1},
+		{name: "starttls", probe: func(s *Scanner) { _, _ = s.grabTLS(ctx, "192.0.2.1:5432", 5432, time.Second) }, wantDials: 1},
+		{name: "http versions", probe: func(s *Scanner) { s.probeHTTPVersions(ctx, "192.0.2.1:443", time.Second) }, wantDials: 2},
+		{name: "udp", probe: func(s *Scanner) { s.scanUDPPort(ctx, "192.0.2.1", 161) }, wantDials: 1},
+		{name: "dns", probe: func(s *Scanner) { _, _ = s.dnsExchange(ctx, "udp", "192.0.2.1:53", nil, time.Second) }, wantDials: 1},
+		{name: "access check", probe: func(s *Scanner) {
+			s.checkAccess(ctx, &ScanResult{IP: "192.0.2.1", Port: 6379, Service: "Redis"}, time.Second)
+		}, wantDials: 1},
+		{name: "active probe redial", probe: func(s *Scanner) {
+			client, server := net.Pipe()
+			_ = server.Close()
+			s.activeProbe(ctx, client, "192.0.2.1:8080", 8080)
+		}, wantDials: 1},
+		{name: "http enrichment", probe: func(s *Scanner) {
+			s.enrichHTTP(ctx, &ScanResult{IP: "192.0.2.1", Port: 80, Service: "HTTP"}, time.Second)
+		}, wantDials: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestScanner(t, config.ScannerConfig{
+				Timeout:        1000,
+				ActiveProbes:   []string{"http", "redis"},
+				HTTPEnrichment: true,
+			})
+			s.limiter = rate.NewLimiter(rate.Every(time.Hour), burst)
+			var dials int32
+			s.dial = func(_, _ string, _ time.Duration) (net.Conn, error) {
+				atomic.AddInt32(&dials, 1)
+				return nil, &net.OpError{Op: "dial", Net: "tcp", Err: timeoutErr{}}
+			}
+
+			tt.probe(s)
+
+			if dials != tt.wantDials {
+				t.Errorf("dials = %d, want %d", dials, tt.wantDials)
+			}
+			if waited := burst - int32(s.limiter.Tokens()); waited != dials {
+				t.Errorf("limiter waits = %d, want one per dial (%d)", waited, dials)
+			}
+		})
+	}
+}
+
+func TestFDExhaustionRetried(t *testing.T) {
+	emfile := &net.OpError{Op: "dial", Net: "tcp", Err: os.NewSyscallError("socket", syscall.EMFILE)}
+	enfile := &net.OpError{Op: "dial", Net: "tcp", Err: os.NewSyscallError("socket", syscall.ENFILE)}
+	refused := &net.OpError{Op: "dial", Net: "tcp", Err: syscall.ECONNREFUSED}
+
+	tests := []struct {
+		name         string
+		errs         []error // per dial; nil opens the port
+		wantDials    int32
+		wantOpen     bool
+		wantTimedOut bool
+	}{
+		{name: "open after EMFILE", errs: []error{emfile, emfile, nil}, wantDials: 3, wantOpen: true},
+		{name: "closed after ENFILE", errs: []error{enfile, refused}, wantDials: 2},
+		{name: "descriptor retries don't use up RetryCount", errs: []error{emfile, timeoutErr{}}, wantDials: 2, wantTimedOut: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			const burst = 100
+			s := newTestScanner(t, config.ScannerConfig{Timeout: 100})
+			s.limiter = rate.NewLimiter(rate.Every(time.Hour), burst)
+			var dials int32
+			s.dial = func(string, string, time.Duration) (net.Conn, error) {
+				err := tt.errs[atomic.AddInt32(&dials, 1)-1]
+				if err != nil {
+					return nil, err
+				}
+				client, server := net.Pipe()
+				_ = server.Close()
+				return client, nil
+			}
+
+			result := s.scanPort(s.ctx, "192.0.2.1", 8080, "tcp")
+
+			if got := atomic.LoadInt32(&dials); got != tt.wantDials {
+				t.Errorf("dials = %d, want %d", got, tt.wantDials)
+			}
+			// The caller waits for the first dial; every redial waits here
+			if waited := burst - int32(s.limiter.Tokens()); waited != tt.wantDials-1 {
+				t.Errorf("limiter waits = %d, want one per redial (%d)", waited, tt.wantDials-1)
+			}
+			if result.Open != tt.wantOpen || result.TimedOut != tt.wantTimedOut || result.fdExhausted {
+				t.Errorf("open %v, timed out %v, fd exhausted %v; want %v, %v, false",
+					result.Open, result.TimedOut, result.fdExhausted, tt.wantOpen, tt.wantTimedOut)
+			}
+		})
+	}
+}
+
+func TestProbeLimiterBurst(t *testing.T) {
+	tests := []struct {
+		burst int
+		want  int
+	}{
+		{burst: 1, want: 1},
+		{burst: 5, want: 5},
+		{burst: 0, want: 1},
+	}
+	for _, tt := range tests {
+		// One probe a second: nothing refills while counting
+		l := newProbeLimiter(1, tt.burst)
+		allowed := 0
+		for l.Allow() {
+			allowed++
+		}
+		if allowed != tt.want {
+			t.Errorf("burst %d: %d probes allowed at once, want %d", tt.burst, allowed, tt.want)
+		}
+	}
+}
+
+func TestRequestedRateKeepsBurst(t *testing.T) {
+	s, err := New(config.ScannerConfig{MaxConcurrentScans: 1, RateLimit: 100000, RateBurst: 3}, &recordingPublisher{}, zap.NewNop().Sugar())
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	if got := s.limiter.Burst(); got != 3 {
+		t.Errorf("burst = %d, want rate_burst 3 rather than the rate", got)
+	}
+	s.dial = func(_, _ string, _ time.Duration) (net.Conn, error) {
+		return nil, &net.OpError{Op: "dial", Net: "tcp", Err: syscall.ECONNREFUSED}
+	}
+
+	runAutonomous(t, s, AutonomousScanConfig{Targets: []string{"192.0.2.10"}, PortRanges: []string{"22"}, RateLimitPPS: 50000})
+
+	if got := s.lastSession.limiter; got.Limit() != 50000 || got.Burst() != 3 {
+		t.Errorf("session limiter = %v/s burst %d, want 50000/s burst 3", got.Limit(), got.Burst())
+	}
+}
+
+func TestPortTimeouts(t *testing.T) {
+	tests := []struct {
+		name string
+		port int
+		want time.Duration
+	}{
+		{name: "override", port: 2222, want: 400 * time.Millisecond},
+		{name: "non-positive override ignored", port: 22, want: 40 * time.Millisecond},
+		{name: "global timeout", port: 9999, want: 40 * time.Millisecond},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestScanner(t, config.ScannerConfig{
+				Timeout: 40, PortTimeouts: map[int]int{2222: 400, 22: 0},
+			})
+			var dialTimeout time.Duration
+			var dials int32
+			silent := pipeDial(&dials, func(conn net.Conn) {
+				// Say nothing until the client gives up
+				_, _ = conn.Read(make([]byte, 1))
+			})
+			s.dial = func(network, address string, timeout time.Duration) (net.Conn, error) {
+				dialTimeout = timeout
+				return silent(network, address, timeout)
+			}
+
+			start := time.Now()
+			result := s.scanPort(context.Background(), "192.0.2.10", tt.port, "tcp")
+			elapsed := time.Since(start)
+
+			if !result.Open {
+				t.Fatalf("port %d not open", tt.port)
+			}
+			if dialTimeout != tt.want {
+				t.Errorf("dial timeout = %v, want %v", dialTimeout, tt.want)
+			}
+			// Waiting for a silent service's greeting takes the port's timeout
+			if elapsed < tt.want-5*time.Millisecond || elapsed > tt.want+300*time.Millisecond {
+				t.Errorf("scanPort() took %v, want about %v", elapsed, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,198 @@
+package scanner
+
+import (
+	"context"
+	"encoding/binary"
+	"io"
+	"net"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+// dnsPort is confirmed with a DNS query when the dns probe is enabled.
+const dnsPort = 53
+
+// dnsRecursionProbe is an A query an authoritative-only server has no
+// reason to answer with data. An answer from a server offering recursion
+// makes it an open resolver.
+var dnsRecursionProbe = dnsmessage.MustNewName("example.com.")
+
+// dnsVersionName is the CHAOS TXT name BIND, Unbound, dnsmasq and others
+// answer with their version, unless configured to hide it.
+var dnsVersionName = dnsmessage.MustNewName("version.bind.")
+
+// probeDNS confirms a DNS server on port 53 with an A query, over UDP with
+// a TCP fallback, and records whether it offers recursion; a resolver
+// answering the query is flagged as an open resolver. With DNSVersionQuery
+// it also asks version.bind to fingerprint the server. The server is probed
+// once per host, even with 53 open on both TCP and UDP, and what it answers
+// is recorded on every port 53 result. Queries wait on the probe rate
+// limiter and stop with ctx.
+func (s *Scanner) probeDNS(ctx context.Context, ip string, results []ScanResult) {
+	if !s.activeProbeEnabled("dns") {
+		return
+	}
+	var ports []*ScanResult
+	for i := range results {
+		if results[i].Port == dnsPort && results[i].Open {
+			ports = append(ports, &results[i])
+		}
+	}
+	if len(ports) == 0 {
+		return
+	}
+
+	response, ok := s.dnsQuery(ctx, ip, dnsRecursionProbe, dnsmessage.TypeA, dnsmessage.ClassINET, true)
+	if !ok {
+		return
+	}
+
+	metadata := map[string]interface{}{
+		"dns_rcode":               strings.TrimPrefix(response.RCode.String(), "RCode"),
+		"dns_recursion_available": response.RecursionAvailable,
+	}
+	if response.RecursionAvailable && response.RCode == dnsmessage.RCodeSuccess && len(response.Answers) > 0 {
+		metadata["dns_open_resolver"] = true
+		metadata["finding_severity"] = "medium"
+	}
+
+	var product, version string
+	if s.config.DNSVersionQuery {
+		if response, ok := s.dnsQuery(ctx, ip, dnsVersionName, dnsmessage.TypeTXT, dnsmessage.ClassCHAOS, false); ok {
+			if banner, _ := printableBanner(dnsTXT(response)); banner != "" {
+				metadata["dns_version"] = banner
+				product, version = parseDNSVersion(banner)
+			}
+		}
+	}
+
+	for _, result := range ports {
+		result.Service = "DNS"
+		result.FingerprintSource = "probe:dns"
+		if result.Metadata == nil {
+			result.Metadata = make(map[string]interface{})
+		}
+		for key, value := range metadata {
+			result.Metadata[key] = value
+		}
+		if product != "" {
+			result.Product, result.Version = product, version
+		}
+	}
+}
+
+// dnsQuery sends a query over UDP and, if that gets no answer or a
+// truncated one, over TCP. It reports false when neither transport got a
+// response to the query.
+func (s *Scanner) dnsQuery(ctx context.Context, ip string, name dnsmessage.Name, qtype dnsmessage.Type, class dnsmessage.Class, recursive bool) (dnsmessage.Message, bool) {
+	id := uint16(time.Now().UnixNano())
+	query := dnsmessage.Message{
+		Header:    dnsmessage.Header{ID: id, RecursionDesired: recursive},
+		Questions: []dnsmessage.Question{{Name: name, Type: qtype, Class: class}},
+	}
+	packet, err := query.Pack()
+	if err != nil {
+		return dnsmessage.Message{}, false
+	}
+
+	address := net.JoinHostPort(ip, strconv.Itoa(dnsPort))
+	timeout := time.Duration(s.config.ActiveProbeTimeoutMS) * time.Millisecond
+
+	for _, network := range []string{"udp", "tcp"} {
+		raw, err := s.dnsExchange(ctx, network, address, packet, timeout)
+		if err != nil {
+			continue
+		}
+		var response dnsmessage.Message
+		if err := response.Unpack(raw); err != nil || !response.Response || response.ID != id {
+			continue
+		}
+		if response.Truncated && network == "udp" {
+			continue
+		}
+		return response, true
+	}
+	return dnsmessage.Message{}, false
+}
+
+// dnsExchange sends packet and reads one response, with the 2-byte length
+// framing DNS uses over TCP.
+func (s *Scanner) dnsExchange(ctx context.Context, network, address string, packet []byte, timeout time.Duration) ([]byte, error) {
+	conn, _, err := s.dialProbe(ctx, network, address, timeout)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = conn.Close() }()
+	if err := conn.SetDeadline(time.Now().Add(timeout)); err != nil {
+		return nil, err
+	}
+
+	if network == "udp" {
+		if _, err := conn.Write(packet); err != nil {
+			return nil, err
+		}
+		buffer := make([]byte, 4096)
+		n, err := conn.Read(buffer)
+		if err != nil {
+			return nil, err
+		}
+		return buffer[:n], nil
+	}
+
+	framed := binary.BigEndian.AppendUint16(nil, uint16(len(packet)))
+	if _, err := conn.Write(append(framed, packet...)); err != nil {
+		return nil, err
+	}
+	var length [2]byte
+	if _, err := io.ReadFull(conn, length[:]); err != nil {
+		return nil, err
+	}
+	buffer := make([]byte, binary.BigEndian.Uint16(length[:]))
+	if _, err := io.ReadFull(conn, buffer); err != nil {
+		return nil, err
+	}
+	return buffer, nil
+}
+
+// dnsTXT returns the first TXT answer's strings joined, or "".
+func dnsTXT(response dnsmessage.Message) string {
+	for _, answer := range response.Answers {
+		if txt, ok := answer.Body.(*dnsmessage.TXTResource); ok {
+			return strings.Join(txt.TXT, "")
+		}
+	}
+	return ""
+}
+
+// dnsProducts names the servers matched by dnsProductVersionPattern.
+var dnsProducts = map[string]string{
+	"unbound":                       "Unbound",
+	"dnsmasq":                       "dnsmasq",
+	"powerdns recursor":             "PowerDNS Recursor",
+	"powerdns authoritative server": "PowerDNS Authoritative",
+	"knot dns":                      "Knot DNS",
+	"knot resolver":                 "Knot Resolver",
+	"coredns":                       "CoreDNS",
+}
+
+var (
+	dnsProductVersionPattern = regexp.MustCompile(`(?i)^(unbound|dnsmasq|powerdns recursor|powerdns authoritative server|knot dns|knot resolver|coredns)[ -]v?(\d+(?:\.\d+)+)`)
+	dnsBINDVersionPattern    = regexp.MustCompile(`^(?:BIND )?(9\.\d+\.\d+)`)
+)
+
+// parseDNSVersion maps a version.bind string to product and version, e.g.
+// "unbound 1.13.1" or "9.16.1-Ubuntu" (BIND). Unrecognised strings, such
+// as the decoys operators configure, return no product.
+func parseDNSVersion(version string) (string, string) {
+	if m := dnsProductVersionPattern.FindStringSubmatch(version); m != nil {
+		return dnsProducts[strings.ToLower(m[1])], m[2]
+	}
+	if m := dnsBINDVersionPattern.FindStringSubmatch(version); m != nil {
+		return "BIND", m[1]
+	}
+	return "", ""
+}
@@ -0,0 +1,127 @@
+package scanner
+
+import (
+	"net"
+	"sync/atomic"
+	"testing"
+
+	"golang.org/x/net/dns/dnsmessage"
+
+	"github.com/aiforce-discovery-agent/collectors/network-scanner/internal/config"
+)
+
+// serveDNS answers one UDP query on conn as a recursive server would, and
+// version.bind with version.
+func serveDNS(version string) func(net.Conn) {
+	return func(conn net.Conn) {
+		buffer := make([]byte, 512)
+		n, err := conn.Read(buffer)
+		if err != nil {
+			return
+		}
+		var query dnsmessage.Message
+		if err := query.Unpack(buffer[:n]); err != nil || len(query.Questions) != 1 {
+			return
+		}
+		question := query.Questions[0]
+		response := dnsmessage.Message{
+			Header:    dnsmessage.Header{ID: query.ID, Response: true, RecursionAvailable: true},
+			Questions: query.Questions,
+		}
+		header := dnsmessage.ResourceHeader{Name: question.Name, Type: question.Type, Class: question.Class}
+		switch question.Type {
+		case dnsmessage.TypeA:
+			response.Answers = []dnsmessage.Resource{{Header: header, Body: &dnsmessage.AResource{A: [4]byte{93, 184, 215, 14}}}}
+		case dnsmessage.TypeTXT:
+			response.Answers = []dnsmessage.Resource{{Header: header, Body: &dnsmessage.TXTResource{TXT: []string{version}}}}
+		}
+		packet, err := response.Pack()
+		if err != nil {
+			return
+		}
+		_, _ = conn.Write(packet)
+	}
+}
+
+func TestProbeDNS(t *testing.T) {
+	tests := []struct {
+		name         string
+		versionQuery bool
+		results      []ScanResult
+		wantDials    int32
+		wantProduct  string
+		wantVersion  string
+	}{
+		{
+			name:      "tcp and udp probed once",
+			results:   []ScanResult{{Port: 53, Protocol: "tcp", Open: true}, {Port: 53, Protocol: "udp", Open: true}},
+			wantDials: 1,
+		},
+		{
+			name:         "version.bind",
+			versionQuery: true,
+			results:      []ScanResult{{Port: 53, Protocol: "tcp", Open: true}, {Port: 53, Protocol: "udp", Open: true}},
+			wantDials:    2,
+			wantProduct:  "BIND",
+			wantVersion:  "9.16.1",
+		},
+		{
+			name:    "no dns port",
+			results: []ScanResult{{Port: 80, Protocol: "tcp", Open: true}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestScanner(t, config.ScannerConfig{
+				ActiveProbes:         []string{"dns"},
+				ActiveProbeTimeoutMS: 1000,
+				DNSVersionQuery:      tt.versionQuery,
+			})
+			var dials int32
+			s.dial = pipeDial(&dials, serveDNS("9.16.1-Ubuntu"))
+
+			s.probeDNS(s.ctx, "10.0.0.53", tt.results)
+
+			if dials := atomic.LoadInt32(&dials); dials != tt.wantDials {
+				t.Errorf("probe dialed %d times, want %d", dials, tt.wantDials)
+			}
+			for _, result := range tt.results {
+				if result.Port != dnsPort {
+					if result.Service != "" {
+						t.Errorf("port %d identified as %q", result.Port, result.Service)
+					}
+					continue
+				}
+				if result.Service != "DNS" || result.Metadata["dns_open_resolver"] != true {
+					t.Errorf("%s/53: service %q, metadata %v, want an open resolver", result.Protocol, result.Service, result.Metadata)
+				}
+				if result.Product != tt.wantProduct || result.Version != tt.wantVersion {
+					t.Errorf("%s/53: product %q %q, want %q %q", result.Protocol, result.Product, result.Version, tt.wantProduct, tt.wantVersion)
+				}
+			}
+		})
+	}
+}
+
+func TestParseDNSVersion(t *testing.T) {
+	tests := []struct {
+		version     string
+		wantProduct string
+		wantVersion string
+	}{
+		{version: "9.16.1-Ubuntu", wantProduct: "BIND", wantVersion: "9.16.1"},
+		{version: "BIND 9.18.24", wantProduct: "BIND", wantVersion: "9.18.24"},
+		{version: "unbound 1.13.1", wantProduct: "Unbound", wantVersion: "1.13.1"},
+		{version: "dnsmasq-2.85", wantProduct: "dnsmasq", wantVersion: "2.85"},
+		{version: "PowerDNS Recursor 4.8.4", wantProduct: "PowerDNS Recursor", wantVersion: "4.8.4"},
+		{version: "none of your business"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.version, func(t *testing.T) {
+			product, version := parseDNSVersion(tt.version)
+			if product != tt.wantProduct || version != tt.wantVersion {
+				t.Errorf("parseDNSVersion(%q) = %q, %q, want %q, %q", tt.version, product, version, tt.wantProduct, tt.wantVersion)
+			}
+		})
+	}
+}
@@ -0,0 +1,40 @@
+package scanner
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/aiforce-discovery-agent/collectors/network-scanner/internal/config"
+)
+
+func TestNoScansStartWhileDraining(t *testing.T) {
+	s := newTestScanner(t, config.ScannerConfig{MaxConcurrentScans: 2})
+
+	if err := s.Drain(context.Background()); !errors.Is(err, ErrNoScanRunning) {
+		t.Fatalf("Drain() error = %v, want ErrNoScanRunning", err)
+	}
+
+	tests := []struct {
+		name  string
+		start func() error
+	}{
+		{name: "legacy", start: s.Start},
+		{name: "autonomous", start: func() error {
+			return s.StartAutonomous(AutonomousScanConfig{ScanID: "b3c1b1c6-4a59-4b8e-9a53-0e7a5b4d2f10"})
+		}},
+		{name: "resume", start: func() error {
+			return s.startSession(AutonomousScanConfig{ScanID: "0d3b2f43-6a8e-4c3e-8f0e-6a1f4f1b9c2d"}, &Checkpoint{})
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.start(); !errors.Is(err, ErrDraining) {
+				t.Errorf("start error = %v, want ErrDraining", err)
+			}
+		})
+	}
+	if len(s.sessions) != 0 {
+		t.Errorf("sessions = %d after drain, want 0", len(s.sessions))
+	}
+}
@@ -0,0 +1,72 @@
+package scanner
+
+import (
+	"context"
+	"errors"
+	"net"
+	"syscall"
+	"time"
+
+	"github.com/aiforce-discovery-agent/collectors/network-scanner/internal/metrics"
+)
+
+// Backoff applied when the process runs out of file descriptors. Retries
+// for descriptor exhaustion don't count against RetryCount.
+const (
+	fdBackoffInitial = 50 * time.Millisecond
+	fdBackoffMax     = 2 * time.Second
+	fdMaxRetries     = 8
+)
+
+// dialFunc dials a connection; a field on Scanner so tests can inject
+// failures.
+type dialFunc func(network, address string, timeout time.Duration) (net.Conn, error)
+
+// isFDExhausted reports whether err means the process or system ran out of
+// file descriptors, which says nothing about the target port.
+func isFDExhausted(err error) bool {
+	return errors.Is(err, syscall.EMFILE) || errors.Is(err, syscall.ENFILE)
+}
+
+// fdBackoff returns the backoff before the given descriptor retry.
+func fdBackoff(retry int) time.Duration {
+	backoff := fdBackoffInitial << uint(retry)
+	if backoff > fdBackoffMax || backoff <= 0 {
+		backoff = fdBackoffMax
+	}
+	return backoff
+}
+
+// pauseForFDs extends the process-wide descriptor backoff. Every worker of
+// every scan waits it out before dialing, so recurring exhaustion throttles
+// effective concurrency until descriptors are released.
+func (s *Scanner) pauseForFDs(backoff time.Duration) {
+	until := time.Now().Add(backoff).UnixNano()
+	for {
+		current := s.fdPausedUntil.Load()
+		if current >= until || s.fdPausedUntil.CompareAndSwap(current, until) {
+			return
+		}
+	}
+}
+
+// waitForFDs blocks while a descriptor backoff is in effect.
+func (s *Scanner) waitForFDs(ctx context.Context) error {
+	wait := time.Until(time.Unix(0, s.fdPausedUntil.Load()))
+	if wait <= 0 {
+		return nil
+	}
+	select {
+	case <-time.After(wait):
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// noteFDExhausted records a dial that failed for lack of descriptors and
+// pauses dialing before the given retry.
+func (s *Scanner) noteFDExhausted(retry int) {
+	metrics.FDExhausted.Inc()
+	s.pauseForFDs(fdBackoff(retry))
+}
@@ -3,15 +3,35 @@ package scanner
 
 import (
 	"regexp"
+	"sort"
 	"strings"
 )
 
 // ServiceFingerprint contains fingerprint information for a service.
 type ServiceFingerprint struct {
 	Name    string
-	Version string
+	Version string // the product's version, never the protocol's
 	Product string
 	Info    string
+	Source  string // banner:<signature name> or port
+
+	// ProtocolVersion is the protocol version a banner announces, such
+	// as 2.0 for SSH-2.0; it's published as protocol_version metadata
+	ProtocolVersion string
+}
+
+// applyTo records fp as result's service.
+func (fp ServiceFingerprint) applyTo(result *ScanResult) {
+	result.Service = fp.Name
+	result.Version = fp.Version
+	result.Product = fp.Product
+	result.FingerprintSource = fp.Source
+	if fp.ProtocolVersion != "" {
+		if result.Metadata == nil {
+			result.Metadata = make(map[string]interface{})
+		}
+		result.Metadata["protocol_version"] = fp.ProtocolVersion
+	}
 }
 
 // Fingerprinter identifies services from banners and port numbers.
@@ -20,6 +40,7 @@ type Fingerprinter struct {
 }
 
 type signature struct {
+	name    string // identifies the signature in fingerprint sources
 	pattern *regexp.Regexp
 	service string
 	extract func([]string) ServiceFingerprint
@@ -38,7 +59,9 @@ func (f *Fingerprinter) Identify(port int, banner string) ServiceFingerprint {
 	if banner != "" {
 		for _, sig := range f.signatures {
 			if matches := sig.pattern.FindStringSubmatch(banner); matches != nil {
-				return sig.extract(matches)
+				fp := sig.extract(matches)
+				fp.Source = "banner:" + sig.name
+				return fp
 			}
 		}
 	}
@@ -49,55 +72,86 @@ func (f *Fingerprinter) Identify(port int, banner string) ServiceFingerprint {
 
 func (f *Fingerprinter) loadSignatures() {
 	f.signatures = []signature{
-		// SSH
+		// SSH, with the product's version when its software version is
+		// product_version, as in OpenSSH_8.9p1 or dropbear_2022.83
 		{
-			pattern: regexp.MustCompile(`SSH-(\d+\.\d+)-(\S+)`),
+			name:    "ssh",
+			pattern: regexp.MustCompile(`SSH-(\d+\.\d+)-([^\s_]+)(?:_(\S+))?`),
 			service: "ssh",
 			extract: func(m []string) ServiceFingerprint {
 				return ServiceFingerprint{
-					Name:    "SSH",
-					Version: m[1],
-					Product: m[2],
+					Name:            "SSH",
+					Version:         m[3],
+					Product:         m[2],
+					ProtocolVersion: m[1],
 				}
 			},
 		},
-		// HTTP/HTTPS servers
+		// Apache
 		{
-			pattern: regexp.MustCompile(`(?i)HTTP/(\d+\.\d+)\s+\d+`),
+			name:    "apache",
+			pattern: regexp.MustCompile(`(?i)Apache[/ ](\d+\.\d+(?:\.\d+)?)`),
 			service: "http",
 			extract: func(m []string) ServiceFingerprint {
 				return ServiceFingerprint{
 					Name:    "HTTP",
 					Version: m[1],
+					Product: "Apache",
 				}
 			},
 		},
-		// Apache
+		// nginx
 		{
-			pattern: regexp.MustCompile(`(?i)Apache[/ ](\d+\.\d+(?:\.\d+)?)`),
+			name:    "nginx",
+			pattern: regexp.MustCompile(`(?i)nginx[/ ](\d+\.\d+(?:\.\d+)?)`),
 			service: "http",
 			extract: func(m []string) ServiceFingerprint {
 				return ServiceFingerprint{
 					Name:    "HTTP",
 					Version: m[1],
-					Product: "Apache",
+					Product: "nginx",
 				}
 			},
 		},
-		// nginx
+		// Generic HTTP/HTTPS servers, after the product signatures so a Server
+		// header wins over the protocol version
 		{
-			pattern: regexp.MustCompile(`(?i)nginx[/ ](\d+\.\d+(?:\.\d+)?)`),
+			name:    "http-status",
+			pattern: regexp.MustCompile(`(?i)HTTP/(\d+\.\d+)\s+\d+`),
 			service: "http",
 			extract: func(m []string) ServiceFingerprint {
 				return ServiceFingerprint{
-					Name:    "HTTP",
-					Version: m[1],
-					Product: "nginx",
+					Name:            "HTTP",
+					ProtocolVersion: m[1],
+				}
+			},
+		},
+		// MySQL handshake packet: 4-byte header, protocol version 10, then a
+		// NUL-terminated server version (read via length-prefixed framing)
+		{
+			name:    "mysql-handshake",
+			pattern: regexp.MustCompile(`(?s)^.{4}\x0a(\d+\.\d+\.\d+)([^\x00]*)\x00`),
+			service: "mysql",
+			extract: func(m []string) ServiceFingerprint {
+				product := "MySQL"
+				version := m[1]
+				if strings.Contains(m[2], "MariaDB") {
+					// MariaDB advertises "5.5.5-<real version>-MariaDB"
+					product = "MariaDB"
+					if rest := strings.TrimPrefix(m[2], "-"); rest != m[2] {
+						version = strings.TrimSuffix(strings.SplitN(rest, "-MariaDB", 2)[0], "-")
+					}
+				}
+				return ServiceFingerprint{
+					Name:    "MySQL",
+					Version: version,
+					Product: product,
 				}
 			},
 		},
 		// MySQL
 		{
+			name:    "mysql-version",
 			pattern: regexp.MustCompile(`(\d+\.\d+\.\d+).*MySQL`),
 			service: "mysql",
 			extract: func(m []string) ServiceFingerprint {
@@ -110,6 +164,7 @@ func (f *Fingerprinter) loadSignatures() {
 		},
 		// PostgreSQL
 		{
+			name:    "postgresql",
 			pattern: regexp.MustCompile(`PostgreSQL (\d+\.\d+)`),
 			service: "postgresql",
 			extract: func(m []string) ServiceFingerprint {
@@ -122,6 +177,7 @@ func (f *Fingerprinter) loadSignatures() {
 		},
 		// Redis
 		{
+			name:    "redis",
 			pattern: regexp.MustCompile(`-ERR.*redis|REDIS`),
 			service: "redis",
 			extract: func(m []string) ServiceFingerprint {
@@ -131,8 +187,34 @@ func (f *Fingerprinter) loadSignatures() {
 				}
 			},
 		},
+		// Redis PING reply from an active probe
+		{
+			name:    "redis-pong",
+			pattern: regexp.MustCompile(`^\+PONG\r?\n`),
+			service: "redis",
+			extract: func(m []string) ServiceFingerprint {
+				return ServiceFingerprint{
+					Name:    "Redis",
+					Product: "Redis",
+				}
+			},
+		},
+		// memcached stats reply from an active probe
+		{
+			name:    "memcached",
+			pattern: regexp.MustCompile(`(?m)^STAT version (\S+)`),
+			service: "memcached",
+			extract: func(m []string) ServiceFingerprint {
+				return ServiceFingerprint{
+					Name:    "Memcached",
+					Version: m[1],
+					Product: "memcached",
+				}
+			},
+		},
 		// MongoDB
 		{
+			name:    "mongodb",
 			pattern: regexp.MustCompile(`MongoDB|mongod`),
 			service: "mongodb",
 			extract: func(m []string) ServiceFingerprint {
@@ -144,6 +226,7 @@ func (f *Fingerprinter) loadSignatures() {
 		},
 		// RabbitMQ
 		{
+			name:    "rabbitmq",
 			pattern: regexp.MustCompile(`AMQP|RabbitMQ`),
 			service: "amqp",
 			extract: func(m []string) ServiceFingerprint {
@@ -155,6 +238,7 @@ func (f *Fingerprinter) loadSignatures() {
 		},
 		// FTP
 		{
+			name:    "ftp",
 			pattern: regexp.MustCompile(`(?i)^220[- ].*FTP`),
 			service: "ftp",
 			extract: func(m []string) ServiceFingerprint {
@@ -165,6 +249,7 @@ func (f *Fingerprinter) loadSignatures() {
 		},
 		// SMTP
 		{
+			name:    "smtp",
 			pattern: regexp.MustCompile(`(?i)^220[- ].*SMTP|ESMTP`),
 			service: "smtp",
 			extract: func(m []string) ServiceFingerprint {
@@ -176,78 +261,107 @@ func (f *Fingerprinter) loadSignatures() {
 	}
 }
 
-func (f *Fingerprinter) identifyByPort(port int) ServiceFingerprint {
-	// Well-known port mappings
-	portServices := map[int]ServiceFingerprint{
-		21:    {Name: "FTP"},
-		22:    {Name: "SSH"},
-		23:    {Name: "Telnet"},
-		25:    {Name: "SMTP"},
-		53:    {Name: "DNS"},
-		80:    {Name: "HTTP"},
-		110:   {Name: "POP3"},
-		143:   {Name: "IMAP"},
-		443:   {Name: "HTTPS"},
-		445:   {Name: "SMB"},
-		465:   {Name: "SMTPS"},
-		587:   {Name: "SMTP Submission"},
-		993:   {Name: "IMAPS"},
-		995:   {Name: "POP3S"},
-		1433:  {Name: "MSSQL"},
-		1521:  {Name: "Oracle"},
-		3306:  {Name: "MySQL"},
-		3389:  {Name: "RDP"},
-		5432:  {Name: "PostgreSQL"},
-		5672:  {Name: "AMQP", Product: "RabbitMQ"},
-		6379:  {Name: "Redis"},
-		8080:  {Name: "HTTP-Alt"},
-		8443:  {Name: "HTTPS-Alt"},
-		9200:  {Name: "Elasticsearch"},
-		9300:  {Name: "Elasticsearch-Transport"},
-		15672: {Name: "RabbitMQ-Management"},
-		27017: {Name: "MongoDB"},
-	}
+// wellKnownPorts maps well-known ports to the service usually found there.
+var wellKnownPorts = map[int]ServiceFingerprint{
+	21:    {Name: "FTP"},
+	22:    {Name: "SSH"},
+	23:    {Name: "Telnet"},
+	25:    {Name: "SMTP"},
+	53:    {Name: "DNS"},
+	80:    {Name: "HTTP"},
+	110:   {Name: "POP3"},
+	123:   {Name: "NTP"},
+	143:   {Name: "IMAP"},
+	161:   {Name: "SNMP"},
+	443:   {Name: "HTTPS"},
+	445:   {Name: "SMB"},
+	465:   {Name: "SMTPS"},
+	587:   {Name: "SMTP Submission"},
+	993:   {Name: "IMAPS"},
+	995:   {Name: "POP3S"},
+	1433:  {Name: "MSSQL"},
+	1521:  {Name: "Oracle"},
+	3306:  {Name: "MySQL"},
+	3389:  {Name: "RDP"},
+	5432:  {Name: "PostgreSQL"},
+	5672:  {Name: "AMQP", Product: "RabbitMQ"},
+	5984:  {Name: "CouchDB"},
+	6379:  {Name: "Redis"},
+	8080:  {Name: "HTTP-Alt"},
+	8443:  {Name: "HTTPS-Alt"},
+	9200:  {Name: "Elasticsearch"},
+	9300:  {Name: "Elasticsearch-Transport"},
+	11211: {Name: "Memcached"},
+	15672: {Name: "RabbitMQ-Management"},
+	27017: {Name: "MongoDB"},
+}
 
-	if fp, ok := portServices[port]; ok {
+func (f *Fingerprinter) identifyByPort(port int) ServiceFingerprint {
+	if fp, ok := wellKnownPorts[port]; ok {
+		fp.Source = "port"
 		return fp
 	}
 
-	return ServiceFingerprint{Name: "Unknown"}
+	return ServiceFingerprint{Name: "Unknown", Source: "port"}
 }
 
-// IdentifyOS attempts to identify the OS from various clues.
-func IdentifyOS(banners map[int]string) string {
-	for _, banner := range banners {
-		bannerLower := strings.ToLower(banner)
-
-		// Windows indicators
-		if strings.Contains(bannerLower, "windows") ||
-			strings.Contains(bannerLower, "microsoft") ||
-			strings.Contains(bannerLower, "iis") {
-			return "Windows"
-		}
+// OSGuess is an operating system inferred from service banners.
+type OSGuess struct {
+	Name       string  // e.g. Ubuntu, Windows, FreeBSD; Unknown when no clue
+	Family     string  // Linux, Windows, macOS, BSD or Network (router/switch OS)
+	Confidence float64 // 0 when unknown
+}
 
-		// Linux indicators
-		if strings.Contains(bannerLower, "ubuntu") ||
-			strings.Contains(bannerLower, "debian") ||
-			strings.Contains(bannerLower, "centos") ||
-			strings.Contains(bannerLower, "rhel") ||
-			strings.Contains(bannerLower, "fedora") ||
-			strings.Contains(bannerLower, "linux") {
-			return "Linux"
-		}
+// osIndicators are lowercase substrings that give away the OS in SSH
+// banners (e.g. "OpenSSH_8.2p1 Ubuntu-4ubuntu0.5") and HTTP Server headers
+// (e.g. "Microsoft-IIS/10.0", "Apache/2.4.41 (Debian)"), and in SNMP
+// sysDescr (e.g. "Cisco IOS Software, C2960 ..."). Distribution names
+// are more specific than a bare "linux" and carry more confidence.
+var osIndicators = []struct {
+	pattern    string
+	name       string
+	family     string
+	confidence float64
+}{
+	{"openssh_for_windows", "Windows", "Windows", 0.9},
+	{"cisco ios", "Cisco IOS", "Network", 0.8},
+	{"cisco nx-os", "Cisco NX-OS", "Network", 0.8},
+	{"junos", "Junos", "Network", 0.8},
+	{"routeros", "RouterOS", "Network", 0.8},
+	{"microsoft-iis", "Windows", "Windows", 0.8},
+	{"ubuntu", "Ubuntu", "Linux", 0.8},
+	{"debian", "Debian", "Linux", 0.8},
+	{"centos", "CentOS", "Linux", 0.8},
+	{"red hat", "RHEL", "Linux", 0.8},
+	{"rhel", "RHEL", "Linux", 0.8},
+	{"fedora", "Fedora", "Linux", 0.8},
+	{"freebsd", "FreeBSD", "BSD", 0.8},
+	{"windows", "Windows", "Windows", 0.7},
+	{"macos", "macOS", "macOS", 0.7},
+	{"darwin", "macOS", "macOS", 0.6},
+	{"microsoft", "Windows", "Windows", 0.6},
+	{"iis", "Windows", "Windows", 0.5},
+	{"linux", "Linux", "Linux", 0.5},
+}
 
-		// macOS indicators
-		if strings.Contains(bannerLower, "darwin") ||
-			strings.Contains(bannerLower, "macos") {
-			return "macOS"
-		}
+// IdentifyOS attempts to identify the OS from a host's banners, keyed by
+// port. The most confident indicator across all banners wins; ties go to
+// the lowest port so the result is deterministic.
+func IdentifyOS(banners map[int]string) OSGuess {
+	ports := make([]int, 0, len(banners))
+	for port := range banners {
+		ports = append(ports, port)
+	}
+	sort.Ints(ports)
 
-		// FreeBSD
-		if strings.Contains(bannerLower, "freebsd") {
-			return "FreeBSD"
+	best := OSGuess{Name: "Unknown"}
+	for _, port := range ports {
+		bannerLower := strings.ToLower(banners[port])
+		for _, ind := range osIndicators {
+			if ind.confidence > best.Confidence && strings.Contains(bannerLower, ind.pattern) {
+				best = OSGuess{Name: ind.name, Family: ind.family, Confidence: ind.confidence}
+			}
 		}
 	}
-
-	return "Unknown"
+	return best
 }
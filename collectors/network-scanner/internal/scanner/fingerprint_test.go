@@ -0,0 +1,134 @@
+package scanner
+
+import (
+	"net"
+	"testing"
+
+	"github.com/aiforce-discovery-agent/collectors/network-scanner/internal/config"
+)
+
+func TestIdentifyVersions(t *testing.T) {
+	tests := []struct {
+		name         string
+		port         int
+		banner       string
+		wantProduct  string
+		wantVersion  string
+		wantProtocol string
+	}{
+		{name: "openssh", port: 22, banner: "SSH-2.0-OpenSSH_8.9p1 Ubuntu-3ubuntu0.6", wantProduct: "OpenSSH", wantVersion: "8.9p1", wantProtocol: "2.0"},
+		{name: "ssh without product version", port: 22, banner: "SSH-2.0-Cisco-1.25", wantProduct: "Cisco-1.25", wantProtocol: "2.0"},
+		{name: "http status only", port: 8080, banner: "HTTP/1.1 200 OK\r\n\r\n", wantProtocol: "1.1"},
+		{name: "http server header", port: 80, banner: "HTTP/1.1 200 OK\r\nServer: nginx/1.24.0\r\n\r\n", wantProduct: "nginx", wantVersion: "1.24.0"},
+	}
+	f := NewFingerprinter()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var result ScanResult
+			f.Identify(tt.port, tt.banner).applyTo(&result)
+
+			if result.Product != tt.wantProduct || result.Version != tt.wantVersion {
+				t.Errorf("product %q version %q, want %q %q", result.Product, result.Version, tt.wantProduct, tt.wantVersion)
+			}
+			protocol, _ := result.Metadata["protocol_version"].(string)
+			if protocol != tt.wantProtocol {
+				t.Errorf("protocol_version = %q, want %q", protocol, tt.wantProtocol)
+			}
+		})
+	}
+}
+
+func TestIdentifySource(t *testing.T) {
+	tests := []struct {
+		name        string
+		port        int
+		banner      string
+		wantService string
+		wantSource  string
+	}{
+		{name: "banner signature", port: 2222, banner: "SSH-2.0-OpenSSH_9.6", wantService: "SSH", wantSource: "banner:ssh"},
+		{name: "server header", port: 8080, banner: "HTTP/1.1 200 OK\r\nServer: nginx/1.24.0\r\n\r\n", wantService: "HTTP", wantSource: "banner:nginx"},
+		{name: "unmatched banner", port: 5432, banner: "\x00\x01garbage", wantService: "PostgreSQL", wantSource: "port"},
+		{name: "no banner", port: 6379, wantService: "Redis", wantSource: "port"},
+		{name: "unknown port", port: 9999, wantService: "Unknown", wantSource: "port"},
+	}
+	f := NewFingerprinter()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var result ScanResult
+			f.Identify(tt.port, tt.banner).applyTo(&result)
+
+			if result.Service != tt.wantService || result.FingerprintSource != tt.wantSource {
+				t.Errorf("service %q from %q, want %q from %q", result.Service, result.FingerprintSource, tt.wantService, tt.wantSource)
+			}
+		})
+	}
+}
+
+func TestIdentifyOS(t *testing.T) {
+	tests := []struct {
+		name    string
+		banners map[int]string
+		want    OSGuess
+	}{
+		{
+			name:    "distribution in the SSH banner",
+			banners: map[int]string{22: "SSH-2.0-OpenSSH_8.2p1 Ubuntu-4ubuntu0.5"},
+			want:    OSGuess{Name: "Ubuntu", Family: "Linux", Confidence: 0.8},
+		},
+		{
+			name:    "IIS server header",
+			banners: map[int]string{80: "HTTP/1.1 200 OK\r\nServer: Microsoft-IIS/10.0"},
+			want:    OSGuess{Name: "Windows", Family: "Windows", Confidence: 0.8},
+		},
+		{
+			name:    "the most confident clue wins across ports",
+			banners: map[int]string{80: "Server: Apache/2.4 (Linux)", 22: "SSH-2.0-OpenSSH_9.2p1 Debian-2"},
+			want:    OSGuess{Name: "Debian", Family: "Linux", Confidence: 0.8},
+		},
+		{
+			name:    "ties go to the lowest port",
+			banners: map[int]string{8080: "Server: nginx (CentOS)", 22: "SSH-2.0-OpenSSH_9.6 FreeBSD-20240104"},
+			want:    OSGuess{Name: "FreeBSD", Family: "BSD", Confidence: 0.8},
+		},
+		{
+			name:    "no clue",
+			banners: map[int]string{22: "SSH-2.0-OpenSSH_9.6"},
+			want:    OSGuess{Name: "Unknown"},
+		},
+		{name: "no banners", want: OSGuess{Name: "Unknown"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := IdentifyOS(tt.banners); got != tt.want {
+				t.Errorf("IdentifyOS() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestScanPublishesOSGuess(t *testing.T) {
+	s := newTestScanner(t, config.ScannerConfig{MaxConcurrentScans: 1, RateLimit: 100000, Timeout: 500})
+	var dials int32
+	s.dial = pipeDial(&dials, func(conn net.Conn) {
+		_, _ = conn.Write([]byte("SSH-2.0-OpenSSH_8.2p1 Ubuntu-4ubuntu0.5\r\n"))
+	})
+	pub := &recordingPublisher{}
+	s.publisher = pub
+
+	runAutonomous(t, s, AutonomousScanConfig{Targets: []string{"192.0.2.10"}, PortRanges: []string{"22"}})
+
+	if len(pub.servers) != 1 || len(pub.services) != 1 {
+		t.Fatalf("published %d servers and %d services, want 1 each", len(pub.servers), len(pub.services))
+	}
+	server := pub.servers[0]
+	if server.OS == nil || server.OS.Name != "Ubuntu" || server.OS.Family != "Linux" {
+		t.Errorf("server OS = %+v, want Ubuntu (Linux)", server.OS)
+	}
+	if got := server.Metadata["os_confidence"]; got != 0.8 {
+		t.Errorf("os_confidence = %v, want 0.8", got)
+	}
+	if got := pub.services[0].(ScanResult).Metadata["os_family"]; got != "Linux" {
+		t.Errorf("service os_family = %v, want Linux", got)
+	}
+}
@@ -0,0 +1,44 @@
+package scanner
+
+import (
+	"sync"
+	"time"
+
+	"github.com/aiforce-discovery-agent/collectors/network-scanner/internal/callback"
+)
+
+// startHeartbeats sends a heartbeat callback every interval until the
+// returned function is called; it waits for an in-flight heartbeat, so none
+// arrives after the completion callback. It is a no-op without a URL.
+func (s *Scanner) startHeartbeats(reporter *callback.Reporter, interval time.Duration) func() {
+	if !reporter.HasHeartbeat() || interval <= 0 {
+		return func() {}
+	}
+
+	stop := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				s.mu.RLock()
+				phase := s.phase
+				s.mu.RUnlock()
+				if err := reporter.ReportHeartbeat(phase); err != nil {
+					s.logger.Debugw("Heartbeat failed", "error", err)
+				}
+			case <-stop:
+				return
+			}
+		}
+	}()
+
+	return sync.OnceFunc(func() {
+		close(stop)
+		wg.Wait()
+	})
+}
@@ -0,0 +1,78 @@
+package scanner
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/aiforce-discovery-agent/collectors/network-scanner/internal/callback"
+	"github.com/aiforce-discovery-agent/collectors/network-scanner/internal/config"
+)
+
+func TestHeartbeats(t *testing.T) {
+	const window = 250 * time.Millisecond
+
+	tests := []struct {
+		name     string
+		noURL    bool
+		interval time.Duration
+		min, max int
+	}{
+		{name: "every 50ms", interval: 50 * time.Millisecond, min: 3, max: 5},
+		{name: "interval longer than the scan", interval: 400 * time.Millisecond, min: 0, max: 0},
+		{name: "disabled interval", interval: 0, min: 0, max: 0},
+		{name: "no heartbeat url", noURL: true, interval: 50 * time.Millisecond, min: 0, max: 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var mu sync.Mutex
+			var beats []callback.Heartbeat
+			srv := httptest.NewServer(http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+				var beat callback.Heartbeat
+				if err := json.NewDecoder(r.Body).Decode(&beat); err != nil {
+					t.Errorf("heartbeat body: %v", err)
+				}
+				mu.Lock()
+				beats = append(beats, beat)
+				mu.Unlock()
+			}))
+			defer srv.Close()
+
+			s := newTestScanner(t, config.ScannerConfig{})
+			s.phase = "scanning"
+			reporter := callback.NewReporter("scan-1", srv.URL, srv.URL, "", zap.NewNop().Sugar())
+			if !tt.noURL {
+				reporter.SetHeartbeatURL(srv.URL + "/heartbeat")
+			}
+
+			stop := s.startHeartbeats(reporter, tt.interval)
+			time.Sleep(window)
+			stop()
+			mu.Lock()
+			got := len(beats)
+			mu.Unlock()
+
+			if got < tt.min || got > tt.max {
+				t.Errorf("%d heartbeats in %v, want %d to %d", got, window, tt.min, tt.max)
+			}
+			for _, beat := range beats[:got] {
+				if beat.ScanID != "scan-1" || beat.Phase != "scanning" {
+					t.Errorf("heartbeat = %+v, want scan-1 in phase scanning", beat)
+				}
+			}
+
+			// Stopping waits for an in-flight heartbeat and ends the ticker
+			time.Sleep(2 * tt.interval)
+			mu.Lock()
+			defer mu.Unlock()
+			if len(beats) != got {
+				t.Errorf("%d heartbeats sent after stop", len(beats)-got)
+			}
+		})
+	}
+}
@@ -0,0 +1,158 @@
+package scanner
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+	"golang.org/x/time/rate"
+
+	"github.com/aiforce-discovery-agent/collectors/network-scanner/internal/callback"
+	"github.com/aiforce-discovery-agent/collectors/network-scanner/internal/config"
+	"github.com/aiforce-discovery-agent/collectors/network-scanner/internal/publisher"
+)
+
+// newTestScanner returns a Scanner for cfg with an unlimited probe rate and
+// the real dialer. Tests override fields (dial, secrets, ...) as needed.
+func newTestScanner(t *testing.T, cfg config.ScannerConfig) *Scanner {
+	t.Helper()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	feedCtx, stopFeed := context.WithCancel(ctx)
+	t.Cleanup(cancel)
+
+	return &Scanner{
+		config:        cfg,
+		logger:        zap.NewNop().Sugar(),
+		limiter:       rate.NewLimiter(rate.Inf, 1),
+		dial:          net.DialTimeout,
+		fdPausedUntil: new(atomic.Int64),
+		fingerprinter: NewFingerprinter(),
+		cloudDetector: NewCloudDetector(),
+		results:       NewMemoryResultStore(10, 0, time.Hour),
+		progressHub:   newProgressHub(10),
+		publisher:     &recordingPublisher{},
+		ctx:           ctx,
+		cancel:        cancel,
+		feedCtx:       feedCtx,
+		stopFeed:      stopFeed,
+		sessions:      make(map[string]*Scanner),
+	}
+}
+
+// observeLogs replaces s's logger with one recording every entry.
+func observeLogs(s *Scanner) *observer.ObservedLogs {
+	core, logs := observer.New(zap.DebugLevel)
+	s.logger = zap.New(core).Sugar()
+	return logs
+}
+
+// pipeDial returns a dialFunc handing each dial one end of a net.Pipe and
+// serve the other, counting dials in n.
+func pipeDial(n *int32, serve func(net.Conn)) dialFunc {
+	return func(_, _ string, _ time.Duration) (net.Conn, error) {
+		atomic.AddInt32(n, 1)
+		client, server := net.Pipe()
+		go func() {
+			defer func() { _ = server.Close() }()
+			serve(server)
+		}()
+		return client, nil
+	}
+}
+
+// recordingPublisher records published events.
+type recordingPublisher struct {
+	mu       sync.Mutex
+	servers  []publisher.ServerDiscoveredData
+	services []interface{}
+	hosts    int
+	flushes  int
+	err      error
+}
+
+func (p *recordingPublisher) PublishServerDiscovered(data publisher.ServerDiscoveredData) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.servers = append(p.servers, data)
+	return p.err
+}
+
+func (p *recordingPublisher) PublishServiceDiscovered(result interface{}) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.services = append(p.services, result)
+	return p.err
+}
+
+func (p *recordingPublisher) PublishHostDiscovered(data publisher.ServerDiscoveredData, results []interface{}) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.hosts++
+	p.servers = append(p.servers, data)
+	p.services = append(p.services, results...)
+	return p.err
+}
+
+func (p *recordingPublisher) Flush() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.flushes++
+	return nil
+}
+
+func (p *recordingPublisher) SetScanID(string)                        {}
+func (p *recordingPublisher) GetScanID() string                       { return "" }
+func (p *recordingPublisher) SetVantagePoint(string)                  {}
+func (p *recordingPublisher) SetCategoryRouting(bool)                 {}
+func (p *recordingPublisher) Close() error                            { return nil }
+func (p *recordingPublisher) IsConnected() bool                       { return true }
+func (p *recordingPublisher) ForScan(string) publisher.EventPublisher { return p }
+
+// runAutonomous runs cfg as an autonomous scan on s, with callbacks to a
+// test server, and returns the completion it reported. ScanID defaults to
+// a fixed UUID.
+func runAutonomous(t *testing.T, s *Scanner, cfg AutonomousScanConfig) callback.Completion {
+	t.Helper()
+
+	var mu sync.Mutex
+	var completion callback.Completion
+	callbacks := httptest.NewServer(http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/complete" {
+			return
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		if err := json.NewDecoder(r.Body).Decode(&completion); err != nil {
+			t.Errorf("decoding completion: %v", err)
+		}
+	}))
+	t.Cleanup(callbacks.Close)
+
+	if cfg.ScanID == "" {
+		cfg.ScanID = "9f8e7d6c-5b4a-4392-8170-6f5e4d3c2b1a"
+	}
+	cfg.ProgressURL = callbacks.URL + "/progress"
+	cfg.CompleteURL = callbacks.URL + "/complete"
+	if err := s.StartAutonomous(cfg); err != nil {
+		t.Fatalf("StartAutonomous() error = %v", err)
+	}
+	session := s.lastSession
+	select {
+	case <-session.done:
+	case <-time.After(10 * time.Second):
+		t.Fatal("scan did not finish")
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	return completion
+}
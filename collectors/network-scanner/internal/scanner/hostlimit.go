@@ -0,0 +1,102 @@
+package scanner
+
+import (
+	"context"
+	"net"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// hostLimiterIdle is how long a per-host limiter may go unused before it
+// is dropped.
+const hostLimiterIdle = time.Minute
+
+// hostLimiters caps the probe rate against each host (MaxPPSPerHost), so
+// workers can't all pile onto one slow target. Limiters are created on
+// demand and dropped once idle. Shared by the root Scanner and its
+// sessions, so the cap holds across concurrent scans.
+type hostLimiters struct {
+	mu        sync.Mutex
+	pps       int
+	limiters  map[string]*hostLimiter
+	lastSweep time.Time
+}
+
+type hostLimiter struct {
+	limiter  *rate.Limiter
+	lastUsed time.Time
+}
+
+// newHostLimiters returns per-host limiters at pps, or nil when pps is not
+// positive.
+func newHostLimiters(pps int) *hostLimiters {
+	if pps <= 0 {
+		return nil
+	}
+	return &hostLimiters{
+		pps:       pps,
+		limiters:  make(map[string]*hostLimiter),
+		lastSweep: time.Now(),
+	}
+}
+
+// get returns the limiter for ip, sweeping idle ones at most once per
+// hostLimiterIdle.
+func (h *hostLimiters) get(ip string) *rate.Limiter {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	now := time.Now()
+	if now.Sub(h.lastSweep) >= hostLimiterIdle {
+		for key, limiter := range h.limiters {
+			if now.Sub(limiter.lastUsed) >= hostLimiterIdle {
+				delete(h.limiters, key)
+			}
+		}
+		h.lastSweep = now
+	}
+
+	limiter, ok := h.limiters[ip]
+	if !ok {
+		limiter = &hostLimiter{limiter: rate.NewLimiter(rate.Limit(h.pps), 1)}
+		h.limiters[ip] = limiter
+	}
+	limiter.lastUsed = now
+	return limiter.limiter
+}
+
+// waitProbe waits until a probe to ip is allowed by the per-host cap, if
+// any, and the scan's rate limit. The host's turn comes first so a probe
+// doesn't hold a global token while waiting on a slow host.
+func (s *Scanner) waitProbe(ctx context.Context, ip string) error {
+	if s.hostLimiters != nil {
+		if err := s.hostLimiters.get(ip).Wait(ctx); err != nil {
+			return err
+		}
+	}
+	return s.limiter.Wait(ctx)
+}
+
+// waitProbeAddr is waitProbe for a host:port address.
+func (s *Scanner) waitProbeAddr(ctx context.Context, address string) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		host = address
+	}
+	return s.waitProbe(ctx, host)
+}
+
+// dialProbe opens a probe connection once the probe limiters allow it.
+// Every dial the scanner makes goes through here, so each one counts
+// against the scan's and the host's probe rate. The returned latency is
+// that of the dial alone, not the wait.
+func (s *Scanner) dialProbe(ctx context.Context, network, address string, timeout time.Duration) (net.Conn, time.Duration, error) {
+	if err := s.waitProbeAddr(ctx, address); err != nil {
+		return nil, 0, err
+	}
+	dialStart := time.Now()
+	conn, err := s.dial(network, address, timeout)
+	return conn, time.Since(dialStart), err
+}
@@ -0,0 +1,119 @@
+package scanner
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// httpDatabaseProbe identifies a database with an HTTP JSON API from its
+// response to GET /. parse reports whether the response is that database,
+// and its product and version when the response carries them.
+type httpDatabaseProbe struct {
+	name    string // active_probes name
+	service string // wellKnownPorts name
+	parse   func(header http.Header, body []byte) (product, version string, ok bool)
+}
+
+// httpDatabaseProbes are tried on their port, when enabled in ActiveProbes.
+// Any other HTTP server answering there clears the database candidate flag.
+var httpDatabaseProbes = map[int]httpDatabaseProbe{
+	9200: {name: "elasticsearch", service: "Elasticsearch", parse: parseElasticsearchRoot},
+	5984: {name: "couchdb", service: "CouchDB", parse: parseCouchDBRoot},
+}
+
+// probeHTTPDatabase fetches / from the port's HTTP database, if it has an
+// enabled probe, and applies the outcome to result. Responses that aren't
+// HTTP leave the result as it was. The connection waits on the probe rate
+// limiter and the request stops with ctx.
+func (s *Scanner) probeHTTPDatabase(ctx context.Context, result *ScanResult, timeout time.Duration) {
+	probe, ok := httpDatabaseProbes[result.Port]
+	if !ok || !s.activeProbeEnabled(probe.name) {
+		return
+	}
+	scheme := "http"
+	if s.isTLSPort(result.Port) {
+		scheme = "https"
+	}
+	url := fmt.Sprintf("%s://%s/", scheme, net.JoinHostPort(result.IP, strconv.Itoa(result.Port)))
+
+	client := &http.Client{
+		Timeout:   time.Duration(s.config.ActiveProbeTimeoutMS)*time.Millisecond + timeout,
+		Transport: s.probeTransport(timeout),
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		s.log(ctx).Debugw("HTTP database probe failed", "url", url, "error", err)
+		return
+	}
+	defer func() { _ = resp.Body.Close() }()
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, httpMaxBodyBytes))
+
+	product, version, confirmed := probe.parse(resp.Header, body)
+	if !confirmed && result.Service == probe.service {
+		// Some other web server; the port alone had named the database
+		result.Service = "HTTP"
+	}
+	applyHandshake(result, handshakeResult{
+		name:      probe.name,
+		service:   probe.service,
+		confirmed: confirmed,
+		product:   product,
+		version:   version,
+	})
+}
+
+// elasticsearchRoot is the part of the Elasticsearch (or OpenSearch) root
+// document used for identification.
+type elasticsearchRoot struct {
+	ClusterName string `json:"cluster_name"`
+	Tagline     string `json:"tagline"`
+	Version     struct {
+		Number       string `json:"number"`
+		Distribution string `json:"distribution"`
+	} `json:"version"`
+}
+
+// parseElasticsearchRoot recognises an Elasticsearch or OpenSearch node by
+// its root document, or by the X-Elastic-Product header that secured nodes
+// also send with an authentication error.
+func parseElasticsearchRoot(header http.Header, body []byte) (string, string, bool) {
+	var root elasticsearchRoot
+	if err := json.Unmarshal(body, &root); err == nil && root.Version.Number != "" &&
+		(root.ClusterName != "" || strings.Contains(root.Tagline, "for Search")) {
+		if root.Version.Distribution == "opensearch" {
+			return "OpenSearch", root.Version.Number, true
+		}
+		return "Elasticsearch", root.Version.Number, true
+	}
+	if header.Get("X-Elastic-Product") == "Elasticsearch" {
+		return "Elasticsearch", "", true
+	}
+	return "", "", false
+}
+
+// parseCouchDBRoot recognises CouchDB by its {"couchdb": "Welcome"} root
+// document.
+func parseCouchDBRoot(_ http.Header, body []byte) (string, string, bool) {
+	var root struct {
+		CouchDB string `json:"couchdb"`
+		Version string `json:"version"`
+	}
+	if err := json.Unmarshal(body, &root); err != nil || root.CouchDB != "Welcome" {
+		return "", "", false
+	}
+	return "CouchDB", root.Version, true
+}
@@ -0,0 +1,118 @@
+package scanner
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/aiforce-discovery-agent/collectors/network-scanner/internal/config"
+)
+
+const (
+	elasticsearchRootDoc = `{
+  "name" : "es01",
+  "cluster_name" : "docker-cluster",
+  "cluster_uuid" : "5sK7Nq0gQnO3l8ZrJp2W8A",
+  "version" : {
+    "number" : "8.11.1",
+    "build_flavor" : "default",
+    "lucene_version" : "9.8.0"
+  },
+  "tagline" : "You Know, for Search"
+}`
+	openSearchRootDoc = `{
+  "name" : "opensearch-node1",
+  "cluster_name" : "opensearch-cluster",
+  "version" : {
+    "distribution" : "opensearch",
+    "number" : "2.11.0"
+  },
+  "tagline" : "The OpenSearch Project: https://opensearch.org/"
+}`
+	couchDBRootDoc = `{"couchdb":"Welcome","version":"3.3.2","git_sha":"11a234070","uuid":"f0e1c8a7","features":["access-ready","partitioned"],"vendor":{"name":"The Apache Software Foundation"}}`
+)
+
+func TestParseHTTPDatabaseRoots(t *testing.T) {
+	securedHeader := http.Header{"X-Elastic-Product": {"Elasticsearch"}}
+
+	tests := []struct {
+		name        string
+		parse       func(http.Header, []byte) (string, string, bool)
+		header      http.Header
+		body        string
+		wantProduct string
+		wantVersion string
+		wantOK      bool
+	}{
+		{name: "elasticsearch", parse: parseElasticsearchRoot, body: elasticsearchRootDoc, wantProduct: "Elasticsearch", wantVersion: "8.11.1", wantOK: true},
+		{name: "opensearch", parse: parseElasticsearchRoot, body: openSearchRootDoc, wantProduct: "OpenSearch", wantVersion: "2.11.0", wantOK: true},
+		{
+			name:        "secured elasticsearch",
+			parse:       parseElasticsearchRoot,
+			header:      securedHeader,
+			body:        `{"error":{"type":"security_exception","reason":"missing authentication credentials"},"status":401}`,
+			wantProduct: "Elasticsearch",
+			wantOK:      true,
+		},
+		{name: "json api with a version", parse: parseElasticsearchRoot, body: `{"version":{"number":"1.0"}}`},
+		{name: "couchdb as elasticsearch", parse: parseElasticsearchRoot, body: couchDBRootDoc},
+		{name: "couchdb", parse: parseCouchDBRoot, body: couchDBRootDoc, wantProduct: "CouchDB", wantVersion: "3.3.2", wantOK: true},
+		{name: "elasticsearch as couchdb", parse: parseCouchDBRoot, body: elasticsearchRootDoc},
+		{name: "html page", parse: parseCouchDBRoot, body: "<html><title>Welcome</title></html>"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			product, version, ok := tt.parse(tt.header, []byte(tt.body))
+			if product != tt.wantProduct || version != tt.wantVersion || ok != tt.wantOK {
+				t.Errorf("parse() = %q, %q, %v; want %q, %q, %v",
+					product, version, ok, tt.wantProduct, tt.wantVersion, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestProbeHTTPDatabase(t *testing.T) {
+	tests := []struct {
+		name          string
+		port          int
+		body          string
+		wantService   string
+		wantProduct   string
+		wantVersion   string
+		wantCandidate interface{}
+	}{
+		{name: "elasticsearch", port: 9200, body: elasticsearchRootDoc, wantService: "Elasticsearch", wantProduct: "Elasticsearch", wantVersion: "8.11.1"},
+		{name: "couchdb", port: 5984, body: couchDBRootDoc, wantService: "CouchDB", wantProduct: "CouchDB", wantVersion: "3.3.2"},
+		{name: "other web server on 9200", port: 9200, body: "<html>It works!</html>", wantService: "HTTP", wantCandidate: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+				_, _ = w.Write([]byte(tt.body))
+			}))
+			defer srv.Close()
+
+			s := newTestScanner(t, config.ScannerConfig{
+				ActiveProbes:         []string{"elasticsearch", "couchdb"},
+				ActiveProbeTimeoutMS: 1000,
+			})
+			s.dial = func(network, _ string, timeout time.Duration) (net.Conn, error) {
+				return net.DialTimeout(network, srv.Listener.Addr().String(), timeout)
+			}
+
+			result := ScanResult{IP: "192.0.2.1", Port: tt.port, Service: httpDatabaseProbes[tt.port].service}
+			s.probeHTTPDatabase(context.Background(), &result, time.Second)
+
+			if result.Service != tt.wantService || result.Product != tt.wantProduct || result.Version != tt.wantVersion {
+				t.Errorf("service %q product %q version %q, want %q %q %q",
+					result.Service, result.Product, result.Version, tt.wantService, tt.wantProduct, tt.wantVersion)
+			}
+			if got := result.Metadata["database_candidate"]; got != tt.wantCandidate {
+				t.Errorf("database_candidate = %v, want %v", got, tt.wantCandidate)
+			}
+		})
+	}
+}
@@ -0,0 +1,122 @@
+package scanner
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"html"
+	"io"
+	"net"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// HTTP enrichment limits: redirects followed, body bytes read looking for
+// a title, and the title length kept.
+const (
+	httpMaxRedirects = 3
+	httpMaxBodyBytes = 64 * 1024
+	httpMaxTitleLen  = 256
+)
+
+// webServices are the fingerprinted services enriched with HTTP metadata.
+var webServices = map[string]bool{
+	"HTTP":      true,
+	"HTTP-Alt":  true,
+	"HTTPS":     true,
+	"HTTPS-Alt": true,
+}
+
+var htmlTitlePattern = regexp.MustCompile(`(?is)<title[^>]*>(.*?)</title>`)
+
+// enrichHTTP issues a GET / against a web service and records the status,
+// response headers, page title and final URL after redirects as metadata,
+// along with the application stack the headers suggest. Only redirects to
+// the same host:port are followed, so a scanned host can't point the
+// scanner at other URLs.
+// TLS is used on TLS ports and for HTTPS services; certificates are not
+// verified. Each connection, redirects included, waits on the probe rate
+// limiter, and the request stops with ctx.
+func (s *Scanner) enrichHTTP(ctx context.Context, result *ScanResult, timeout time.Duration) {
+	if !s.config.HTTPEnrichment || !webServices[result.Service] {
+		return
+	}
+	scheme := "http"
+	if strings.HasPrefix(result.Service, "HTTPS") || s.isTLSPort(result.Port) {
+		scheme = "https"
+	}
+	url := fmt.Sprintf("%s://%s/", scheme, net.JoinHostPort(result.IP, strconv.Itoa(result.Port)))
+
+	client := &http.Client{
+		Timeout:   timeout,
+		Transport: s.probeTransport(timeout),
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) > httpMaxRedirects || req.URL.Host != via[0].URL.Host {
+				return http.ErrUseLastResponse
+			}
+			return nil
+		},
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		s.log(ctx).Debugw("HTTP enrichment failed", "url", url, "error", err)
+		return
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if result.Metadata == nil {
+		result.Metadata = make(map[string]interface{})
+	}
+	result.Metadata["http_status"] = resp.StatusCode
+	result.Metadata["http_final_url"] = resp.Request.URL.String()
+	if server := resp.Header.Get("Server"); server != "" {
+		result.Metadata["http_server"] = server
+	}
+	result.Metadata["http_headers"] = responseHeaders(resp.Header)
+	if stack, confidence := inferAppStack(resp.Header); stack != "" {
+		result.Metadata["app_stack"] = stack
+		result.Metadata["app_stack_confidence"] = confidence
+	}
+
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, httpMaxBodyBytes))
+	if title := htmlTitle(body); title != "" {
+		result.Metadata["http_title"] = title
+	}
+}
+
+// probeTransport returns an HTTP transport for one probe request, dialing
+// through dialProbe. Certificates are not verified.
+func (s *Scanner) probeTransport(timeout time.Duration) *http.Transport {
+	return &http.Transport{
+		DialContext: func(ctx context.Context, network, address string) (net.Conn, error) {
+			conn, _, err := s.dialProbe(ctx, network, address, timeout)
+			return conn, err
+		},
+		TLSClientConfig:   &tls.Config{InsecureSkipVerify: true},
+		DisableKeepAlives: true,
+	}
+}
+
+// htmlTitle extracts the page title with whitespace collapsed and entities
+// decoded, truncated to httpMaxTitleLen bytes.
+func htmlTitle(body []byte) string {
+	m := htmlTitlePattern.FindSubmatch(body)
+	if m == nil {
+		return ""
+	}
+	title := strings.Join(strings.Fields(html.UnescapeString(string(m[1]))), " ")
+	if len(title) > httpMaxTitleLen {
+		title = title[:httpMaxTitleLen]
+	}
+	return title
+}
@@ -0,0 +1,88 @@
+package scanner
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/aiforce-discovery-agent/collectors/network-scanner/internal/config"
+)
+
+func TestEnrichHTTPRedirects(t *testing.T) {
+	var otherHits int
+	other := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		otherHits++
+		_, _ = w.Write([]byte("<title>Internal admin</title>"))
+	}))
+	defer other.Close()
+
+	tests := []struct {
+		name       string
+		location   func(self string) string
+		wantStatus int
+		wantTitle  string
+		wantOther  int
+	}{
+		{
+			name:       "same host:port is followed",
+			location:   func(self string) string { return self + "/login" },
+			wantStatus: http.StatusOK,
+			wantTitle:  "Login",
+		},
+		{
+			name:       "other host is not followed",
+			location:   func(string) string { return other.URL + "/" },
+			wantStatus: http.StatusFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			otherHits = 0
+			var self string
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path == "/login" {
+					_, _ = w.Write([]byte("<title>Login</title>"))
+					return
+				}
+				http.Redirect(w, r, tt.location(self), http.StatusFound)
+			}))
+			defer srv.Close()
+			self = srv.URL
+
+			host, portStr, _ := net.SplitHostPort(srv.Listener.Addr().String())
+			port, _ := strconv.Atoi(portStr)
+
+			s := newTestScanner(t, config.ScannerConfig{HTTPEnrichment: true})
+			result := ScanResult{IP: host, Port: port, Service: "HTTP"}
+			s.enrichHTTP(context.Background(), &result, 2*time.Second)
+
+			if got := result.Metadata["http_status"]; got != tt.wantStatus {
+				t.Errorf("http_status = %v, want %d", got, tt.wantStatus)
+			}
+			if got, _ := result.Metadata["http_title"].(string); got != tt.wantTitle {
+				t.Errorf("http_title = %q, want %q", got, tt.wantTitle)
+			}
+			if otherHits != tt.wantOther {
+				t.Errorf("requests to other host = %d, want %d", otherHits, tt.wantOther)
+			}
+		})
+	}
+}
+
+func TestEnrichHTTPOffByDefault(t *testing.T) {
+	s := newTestScanner(t, config.ScannerConfig{})
+	result := ScanResult{IP: "192.0.2.1", Port: 80, Service: "HTTP"}
+	s.dial = func(string, string, time.Duration) (net.Conn, error) {
+		t.Fatal("enrichment dialed with http_enrichment off")
+		return nil, nil
+	}
+	s.enrichHTTP(context.Background(), &result, time.Second)
+	if result.Metadata != nil {
+		t.Errorf("Metadata = %v, want none", result.Metadata)
+	}
+}
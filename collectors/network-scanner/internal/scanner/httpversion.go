@@ -0,0 +1,116 @@
+package scanner
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// httpsPorts are probed for ALPN and Alt-Svc after a successful dial.
+var httpsPorts = map[int]bool{
+	443:  true,
+	8443: true,
+}
+
+// alpnCandidates are offered one at a time so the result lists every
+// protocol the server accepts, not just its preferred one.
+var alpnCandidates = []string{"h2", "http/1.1"}
+
+// probeHTTPVersions negotiates ALPN against a TLS service and checks the
+// Alt-Svc response header for HTTP/3. It returns metadata to attach to the
+// discovery, or nil if the service didn't complete a TLS handshake.
+func (s *Scanner) probeHTTPVersions(ctx context.Context, address string, timeout time.Duration) map[string]interface{} {
+	var alpn []string
+	http3 := false
+
+	for _, proto := range alpnCandidates {
+		conn, err := s.dialTLS(ctx, address, timeout, []string{proto})
+		if err != nil {
+			continue
+		}
+
+		negotiated := conn.ConnectionState().NegotiatedProtocol
+		// Servers without ALPN still speak HTTP/1.1 over TLS
+		if negotiated == proto || (negotiated == "" && proto == "http/1.1") {
+			alpn = append(alpn, proto)
+		}
+		if proto == "http/1.1" {
+			http3 = advertisesHTTP3(conn, address, timeout)
+		}
+		_ = conn.Close()
+	}
+
+	if len(alpn) == 0 {
+		return nil
+	}
+
+	return map[string]interface{}{
+		"alpn":             alpn,
+		"http3_advertised": http3,
+	}
+}
+
+// dialTLS performs a TLS handshake offering only the given ALPN protocols.
+// Certificates are not verified; the probe only observes what the server
+// offers.
+func (s *Scanner) dialTLS(ctx context.Context, address string, timeout time.Duration, nextProtos []string) (*tls.Conn, error) {
+	raw, _, err := s.dialProbe(ctx, "tcp", address, timeout)
+	if err != nil {
+		return nil, err
+	}
+	if err := raw.SetDeadline(time.Now().Add(timeout)); err != nil {
+		_ = raw.Close()
+		return nil, err
+	}
+
+	host, _, _ := net.SplitHostPort(address)
+	conn := tls.Client(raw, &tls.Config{
+		InsecureSkipVerify: true,
+		NextProtos:         nextProtos,
+		ServerName:         host,
+	})
+	if err := conn.Handshake(); err != nil {
+		_ = raw.Close()
+		return nil, err
+	}
+	return conn, nil
+}
+
+// advertisesHTTP3 sends a HEAD request and reports whether the Alt-Svc
+// response header advertises an h3 endpoint.
+func advertisesHTTP3(conn net.Conn, address string, timeout time.Duration) bool {
+	host, _, _ := net.SplitHostPort(address)
+	if err := conn.SetDeadline(time.Now().Add(timeout)); err != nil {
+		return false
+	}
+	if _, err := fmt.Fprintf(conn, "HEAD / HTTP/1.1\r\nHost: %s\r\nConnection: close\r\n\r\n", host); err != nil {
+		return false
+	}
+
+	resp, err := http.ReadResponse(bufio.NewReader(conn), nil)
+	if err != nil {
+		return false
+	}
+	_ = resp.Body.Close()
+
+	return altSvcHasHTTP3(resp.Header.Values("Alt-Svc"))
+}
+
+// altSvcHasHTTP3 parses Alt-Svc values (e.g. `h3=":443"; ma=86400,
+// h3-29=":443"`) and reports whether any alternative is HTTP/3.
+func altSvcHasHTTP3(values []string) bool {
+	for _, value := range values {
+		for _, alt := range strings.Split(value, ",") {
+			protocolID := strings.TrimSpace(strings.SplitN(alt, "=", 2)[0])
+			if protocolID == "h3" || strings.HasPrefix(protocolID, "h3-") {
+				return true
+			}
+		}
+	}
+	return false
+}
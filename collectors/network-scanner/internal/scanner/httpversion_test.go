@@ -0,0 +1,82 @@
+package scanner
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/aiforce-discovery-agent/collectors/network-scanner/internal/config"
+)
+
+func TestAltSvcHasHTTP3(t *testing.T) {
+	tests := []struct {
+		values []string
+		want   bool
+	}{
+		{values: []string{`h3=":443"; ma=86400`}, want: true},
+		{values: []string{`h2=":443", h3-29=":443"; ma=3600`}, want: true},
+		{values: []string{`h2="alt.example.com:443"`, `h3=":8443"`}, want: true},
+		{values: []string{`h2=":443"; ma=86400`}},
+		{values: []string{"clear"}},
+		{},
+	}
+	for _, tt := range tests {
+		if got := altSvcHasHTTP3(tt.values); got != tt.want {
+			t.Errorf("altSvcHasHTTP3(%q) = %v, want %v", tt.values, got, tt.want)
+		}
+	}
+}
+
+func TestProbeHTTPVersions(t *testing.T) {
+	tests := []struct {
+		name      string
+		tls       bool
+		http2     bool
+		altSvc    string
+		wantALPN  []string
+		wantHTTP3 bool
+	}{
+		{name: "h2 and h3", tls: true, http2: true, altSvc: `h3=":443"; ma=86400`, wantALPN: []string{"h2", "http/1.1"}, wantHTTP3: true},
+		{name: "h2", tls: true, http2: true, wantALPN: []string{"h2", "http/1.1"}},
+		{name: "http/1.1 only", tls: true, wantALPN: []string{"http/1.1"}},
+		{name: "plaintext"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+				if tt.altSvc != "" {
+					w.Header().Set("Alt-Svc", tt.altSvc)
+				}
+			}))
+			srv.EnableHTTP2 = tt.http2
+			if tt.tls {
+				srv.StartTLS()
+			} else {
+				srv.Start()
+			}
+			defer srv.Close()
+
+			s := newTestScanner(t, config.ScannerConfig{})
+			addr := srv.Listener.Addr().String()
+			s.dial = func(network, _ string, timeout time.Duration) (net.Conn, error) {
+				return net.DialTimeout(network, addr, timeout)
+			}
+
+			got := s.probeHTTPVersions(context.Background(), "192.0.2.1:443", time.Second)
+			if tt.wantALPN == nil {
+				if got != nil {
+					t.Errorf("probeHTTPVersions() = %v, want nil", got)
+				}
+				return
+			}
+			want := map[string]interface{}{"alpn": tt.wantALPN, "http3_advertised": tt.wantHTTP3}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("probeHTTPVersions() = %v, want %v", got, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,102 @@
+package scanner
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Known asset handling modes.
+const (
+	// KnownAssetsFlag publishes known assets with metadata known=true.
+	KnownAssetsFlag = "flag"
+	// KnownAssetsSuppress counts known assets but does not publish them.
+	KnownAssetsSuppress = "suppress"
+)
+
+// knownAssets is a set of ip:port keys already present in the inventory.
+type knownAssets map[string]struct{}
+
+func (k knownAssets) contains(ip string, port int) bool {
+	if len(k) == 0 {
+		return false
+	}
+	_, ok := k[net.JoinHostPort(ip, strconv.Itoa(port))]
+	return ok
+}
+
+// loadKnownAssets reads known ip:port entries from a file path or an
+// http(s) URL. The format is one entry per line; blank lines and lines
+// starting with # are ignored.
+func loadKnownAssets(source string) (knownAssets, error) {
+	var r io.ReadCloser
+
+	if strings.HasPrefix(source, "http://") || strings.HasPrefix(source, "https://") {
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, source, nil)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create known assets request: %w", err)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			return nil, fmt.Errorf("failed to fetch known assets: %w", err)
+		}
+		if resp.StatusCode >= 400 {
+			_ = resp.Body.Close()
+			return nil, fmt.Errorf("known assets endpoint returned status %d", resp.StatusCode)
+		}
+		r = resp.Body
+	} else {
+		f, err := os.Open(source)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open known assets file: %w", err)
+		}
+		r = f
+	}
+	defer func() { _ = r.Close() }()
+
+	assets := make(knownAssets)
+	scanner := bufio.NewScanner(r)
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		host, portStr, err := net.SplitHostPort(line)
+		if err != nil {
+			return nil, fmt.Errorf("known assets line %d: %w", lineNo, err)
+		}
+		port, err := strconv.Atoi(portStr)
+		if err != nil || net.ParseIP(host) == nil {
+			return nil, fmt.Errorf("known assets line %d: expected ip:port, got %q", lineNo, line)
+		}
+		assets[net.JoinHostPort(net.ParseIP(host).String(), strconv.Itoa(port))] = struct{}{}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read known assets: %w", err)
+	}
+
+	return assets, nil
+}
+
+// shouldPublish reports whether a result should be published. Known assets
+// are suppressed in suppress mode; they are still counted in the scan stats.
+func (s *Scanner) shouldPublish(result ScanResult) bool {
+	if !result.Known {
+		return true
+	}
+	if st := s.stats.Load(); st != nil {
+		st.recordKnown()
+	}
+	return s.config.KnownAssetsMode != KnownAssetsSuppress
+}
@@ -0,0 +1,115 @@
+package scanner
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/aiforce-discovery-agent/collectors/network-scanner/internal/config"
+)
+
+func TestLoadKnownAssets(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    knownAssets
+		wantErr string
+	}{
+		{
+			name:    "entries",
+			content: "# inventory export\n10.0.0.5:22\n\n  10.0.0.6:443  \n[fd00:0::1]:5432\n",
+			want: knownAssets{
+				"10.0.0.5:22": {}, "10.0.0.6:443": {}, "[fd00::1]:5432": {},
+			},
+		},
+		{name: "missing port", content: "10.0.0.5:22\n10.0.0.6\n", wantErr: "line 2"},
+		{name: "hostname", content: "db.internal:5432\n", wantErr: "line 1"},
+		{name: "bad port", content: "10.0.0.5:ssh\n", wantErr: "line 1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "known.txt")
+			if err := os.WriteFile(path, []byte(tt.content), 0o600); err != nil {
+				t.Fatal(err)
+			}
+
+			got, err := loadKnownAssets(path)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("loadKnownAssets() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("loadKnownAssets() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("loadKnownAssets() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadKnownAssetsURL(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/known" {
+			http.NotFound(w, r)
+			return
+		}
+		_, _ = w.Write([]byte("10.0.0.5:22\n"))
+	}))
+	defer srv.Close()
+
+	known, err := loadKnownAssets(srv.URL + "/known")
+	if err != nil {
+		t.Fatalf("loadKnownAssets() error = %v", err)
+	}
+	if !known.contains("10.0.0.5", 22) || known.contains("10.0.0.5", 80) {
+		t.Errorf("loadKnownAssets() = %v, want only 10.0.0.5:22", known)
+	}
+
+	if _, err := loadKnownAssets(srv.URL + "/missing"); err == nil || !strings.Contains(err.Error(), "404") {
+		t.Errorf("loadKnownAssets() error = %v, want the 404 status", err)
+	}
+}
+
+func TestKnownAssetsModes(t *testing.T) {
+	tests := []struct {
+		mode          string
+		wantPublished map[string]bool // published IP -> known
+	}{
+		{mode: KnownAssetsFlag, wantPublished: map[string]bool{"192.0.2.10": true, "192.0.2.11": false}},
+		{mode: KnownAssetsSuppress, wantPublished: map[string]bool{"192.0.2.11": false}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.mode, func(t *testing.T) {
+			s := newTestScanner(t, config.ScannerConfig{MaxConcurrentScans: 1, RateLimit: 100000, KnownAssetsMode: tt.mode})
+			var dials int32
+			s.dial = pipeDial(&dials, func(net.Conn) {})
+			s.knownAssets = knownAssets{"192.0.2.10:80": {}}
+			pub := &recordingPublisher{}
+			s.publisher = pub
+
+			completion := runAutonomous(t, s, AutonomousScanConfig{
+				Targets: []string{"192.0.2.10", "192.0.2.11"}, PortRanges: []string{"80"},
+			})
+
+			published := make(map[string]bool)
+			for _, service := range pub.services {
+				result := service.(ScanResult)
+				published[result.IP] = result.Known
+			}
+			if !reflect.DeepEqual(published, tt.wantPublished) {
+				t.Errorf("published = %v, want %v", published, tt.wantPublished)
+			}
+			if completion.Summary == nil || completion.Summary.KnownAssets != 1 {
+				t.Errorf("summary = %+v, want 1 known asset", completion.Summary)
+			}
+		})
+	}
+}
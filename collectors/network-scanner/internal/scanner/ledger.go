@@ -0,0 +1,62 @@
+package scanner
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// ErrScanIDActive is returned when a scan ID is already claimed by a
+// running scan, possibly in another process sharing the ledger.
+var ErrScanIDActive = errors.New("scan ID already active")
+
+// scanLedger is a best-effort record of active scan IDs shared between
+// scanner processes, so a reused ID doesn't mix two scans' events under
+// one CloudEvent subject.
+type scanLedger struct {
+	dir        string
+	staleAfter time.Duration
+}
+
+func newScanLedger(dir string, staleAfter time.Duration) (*scanLedger, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create scan ID ledger: %w", err)
+	}
+	return &scanLedger{dir: dir, staleAfter: staleAfter}, nil
+}
+
+func (l *scanLedger) path(scanID string) string {
+	return filepath.Join(l.dir, filepath.Base(scanID)+".active")
+}
+
+// claim records scanID as active. Claims older than staleAfter are assumed
+// to belong to a crashed process and are taken over.
+func (l *scanLedger) claim(scanID string) error {
+	path := l.path(scanID)
+
+	for attempt := 0; attempt < 2; attempt++ {
+		f, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o644)
+		if err == nil {
+			host, _ := os.Hostname()
+			_, _ = fmt.Fprintf(f, "%s %d %s\n", host, os.Getpid(), time.Now().UTC().Format(time.RFC3339))
+			return f.Close()
+		}
+		if !errors.Is(err, os.ErrExist) {
+			return fmt.Errorf("failed to claim scan ID: %w", err)
+		}
+
+		info, statErr := os.Stat(path)
+		if statErr != nil || l.staleAfter <= 0 || time.Since(info.ModTime()) < l.staleAfter {
+			return fmt.Errorf("%w: %s", ErrScanIDActive, scanID)
+		}
+		_ = os.Remove(path)
+	}
+	return fmt.Errorf("%w: %s", ErrScanIDActive, scanID)
+}
+
+// release removes the claim on scanID.
+func (l *scanLedger) release(scanID string) {
+	_ = os.Remove(l.path(scanID))
+}
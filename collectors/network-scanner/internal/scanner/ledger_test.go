@@ -0,0 +1,102 @@
+package scanner
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/aiforce-discovery-agent/collectors/network-scanner/internal/config"
+)
+
+func TestScanLedgerClaim(t *testing.T) {
+	const scanID = "4a3b2c1d-0e9f-4a8b-9c7d-6e5f4a3b2c1d"
+
+	l, err := newScanLedger(filepath.Join(t.TempDir(), "ledger"), time.Hour)
+	if err != nil {
+		t.Fatalf("newScanLedger() error = %v", err)
+	}
+	if err := l.claim(scanID); err != nil {
+		t.Fatalf("claim() error = %v", err)
+	}
+	if err := l.claim(scanID); !errors.Is(err, ErrScanIDActive) {
+		t.Errorf("second claim() error = %v, want ErrScanIDActive", err)
+	}
+
+	l.release(scanID)
+	if err := l.claim(scanID); err != nil {
+		t.Fatalf("claim() after release error = %v", err)
+	}
+
+	// A claim left behind by a crashed process is taken over once stale
+	old := time.Now().Add(-2 * time.Hour)
+	if err := os.Chtimes(l.path(scanID), old, old); err != nil {
+		t.Fatal(err)
+	}
+	if err := l.claim(scanID); err != nil {
+		t.Errorf("claim() over a stale claim error = %v", err)
+	}
+	if err := os.Chtimes(l.path(scanID), old, old); err != nil {
+		t.Fatal(err)
+	}
+	l.staleAfter = 0
+	if err := l.claim(scanID); !errors.Is(err, ErrScanIDActive) {
+		t.Errorf("claim() with no staleness limit error = %v, want ErrScanIDActive", err)
+	}
+}
+
+func TestScanLedgerSharedBetweenScanners(t *testing.T) {
+	const scanID = "4a3b2c1d-0e9f-4a8b-9c7d-6e5f4a3b2c1d"
+
+	cfg := config.ScannerConfig{
+		MaxConcurrentScans: 1, RateLimit: 100000,
+		ScanIDLedgerDir: t.TempDir(), ScanIDLedgerStaleHours: 24,
+	}
+	newScanner := func() *Scanner {
+		s, err := New(cfg, &recordingPublisher{}, zap.NewNop().Sugar())
+		if err != nil {
+			t.Fatalf("New() error = %v", err)
+		}
+		return s
+	}
+	first, second := newScanner(), newScanner()
+
+	callbacks := httptest.NewServer(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}))
+	defer callbacks.Close()
+	var mu sync.Mutex
+	started, release := make(chan struct{}), make(chan struct{})
+	first.dial = blockingDial(started, release, &mu, make(map[string]bool))
+	if err := first.StartAutonomous(AutonomousScanConfig{
+		ScanID: scanID, Targets: []string{"192.0.2.10"}, PortRanges: []string{"22"},
+		ProgressURL: callbacks.URL, CompleteURL: callbacks.URL,
+	}); err != nil {
+		t.Fatalf("StartAutonomous() error = %v", err)
+	}
+	session := first.lastSession
+	<-started
+
+	second.dial = first.dial
+	err := second.StartAutonomous(AutonomousScanConfig{
+		ScanID: scanID, Targets: []string{"192.0.2.10"}, PortRanges: []string{"22"},
+		ProgressURL: callbacks.URL, CompleteURL: callbacks.URL,
+	})
+	if !errors.Is(err, ErrScanIDActive) {
+		t.Errorf("StartAutonomous() in another scanner error = %v, want ErrScanIDActive", err)
+	}
+
+	close(release)
+	select {
+	case <-session.done:
+	case <-time.After(10 * time.Second):
+		t.Fatal("scan did not finish")
+	}
+
+	// Finishing releases the ID for reuse
+	runAutonomous(t, second, AutonomousScanConfig{ScanID: scanID, Targets: []string{"192.0.2.10"}, PortRanges: []string{"22"}})
+}
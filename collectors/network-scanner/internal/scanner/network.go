@@ -2,8 +2,12 @@ package scanner
 
 import (
 	"fmt"
+	"math/big"
 	"net"
 	"sort"
+	"strings"
+
+	"github.com/aiforce-discovery-agent/collectors/network-scanner/internal/config"
 )
 
 func (s *Scanner) expandPortRanges() []int {
@@ -40,20 +44,59 @@ func (s *Scanner) expandPortRanges() []int {
 	sort.Ints(priority)
 	sort.Ints(rest)
 
+	// Shuffle within each tier so database ports still go first
+	if s.config.RandomizeOrder {
+		rng := s.newRand("ports")
+		shufflePorts(priority, rng)
+		shufflePorts(rest, rng)
+	}
+
 	return append(priority, rest...)
 }
 
+// parseExcludeSubnets parses the exclusion list once. Entries are
+// validated at config load, so a parse failure here is reported rather than
+// silently ignored.
+func parseExcludeSubnets(subnets []string) ([]*net.IPNet, error) {
+	nets := make([]*net.IPNet, 0, len(subnets))
+	for _, subnet := range subnets {
+		_, ipNet, err := net.ParseCIDR(subnet)
+		if err != nil {
+			return nil, fmt.Errorf("invalid exclude subnet %q: %w", subnet, err)
+		}
+		nets = append(nets, ipNet)
+	}
+	return nets, nil
+}
+
+// parseExcludeIPs builds a set of excluded addresses in canonical form, so
+// equivalent spellings (e.g. ::1 and 0:0:0:0:0:0:0:1) match.
+func parseExcludeIPs(ips []string) (map[string]struct{}, error) {
+	set := make(map[string]struct{}, len(ips))
+	for _, ip := range ips {
+		parsed := net.ParseIP(ip)
+		if parsed == nil {
+			return nil, fmt.Errorf("invalid exclude IP %q", ip)
+		}
+		set[parsed.String()] = struct{}{}
+	}
+	return set, nil
+}
+
 func (s *Scanner) isExcluded(ip string) bool {
 	parsedIP := net.ParseIP(ip)
 	if parsedIP == nil {
 		return false
 	}
 
-	for _, subnet := range s.config.ExcludeSubnets {
-		_, ipNet, err := net.ParseCIDR(subnet)
-		if err != nil {
-			continue
-		}
+	s.reloadMu.RLock()
+	defer s.reloadMu.RUnlock()
+
+	if _, ok := s.excludeIPs[parsedIP.String()]; ok {
+		return true
+	}
+
+	for _, ipNet := range s.excludeNets {
 		if ipNet.Contains(parsedIP) {
 			return true
 		}
@@ -62,6 +105,93 @@ func (s *Scanner) isExcluded(ip string) bool {
 	return false
 }
 
+// minIPv6PrefixLen is the loosest IPv6 prefix that is enumerated without
+// AllowLargeIPv6; a /112 holds 65,536 addresses.
+const minIPv6PrefixLen = 112
+
+// maxAddressCount saturates address counts so huge IPv6 ranges don't
+// overflow progress arithmetic.
+const maxAddressCount = int64(1) << 62
+
+// addressRange is the span of addresses a subnet entry covers: a CIDR
+// block, or an inclusive start-end range that needn't be CIDR-aligned.
+type addressRange struct {
+	first net.IP
+	size  int64 // saturates at maxAddressCount
+}
+
+// parseScanSubnet parses a CIDR or start-end range and rejects IPv6 ranges
+// too large to enumerate unless AllowLargeIPv6 is set.
+func (s *Scanner) parseScanSubnet(subnet string) (addressRange, error) {
+	if isIPRange(subnet) {
+		first, last, err := config.ParseIPRange(subnet)
+		if err != nil {
+			return addressRange{}, fmt.Errorf("invalid subnet %q: %w", subnet, err)
+		}
+		r := addressRange{first: first, size: rangeSize(first, last)}
+		if first.To4() == nil && r.size > 1<<(128-minIPv6PrefixLen) && !s.config.AllowLargeIPv6 {
+			return addressRange{}, fmt.Errorf("IPv6 range %s is too large to enumerate: at most %d addresses "+
+				"(set allow_large_ipv6 to override)", subnet, 1<<(128-minIPv6PrefixLen))
+		}
+		return r, nil
+	}
+
+	_, ipNet, err := net.ParseCIDR(subnet)
+	if err != nil {
+		return addressRange{}, fmt.Errorf("invalid subnet %q: %w", subnet, err)
+	}
+
+	if ipNet.IP.To4() == nil {
+		ones, _ := ipNet.Mask.Size()
+		if ones < minIPv6PrefixLen && !s.config.AllowLargeIPv6 {
+			return addressRange{}, fmt.Errorf("IPv6 subnet %s is too large to enumerate: prefix must be /%d or longer "+
+				"(set allow_large_ipv6 to override)", subnet, minIPv6PrefixLen)
+		}
+	}
+
+	return addressRange{first: ipNet.IP.Mask(ipNet.Mask), size: addressCount(ipNet)}, nil
+}
+
+// ValidateSubnets checks that every subnet parses and is small enough to scan.
+func (s *Scanner) ValidateSubnets(subnets []string) error {
+	for _, subnet := range subnets {
+		if _, err := s.parseScanSubnet(subnet); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// isIPRange reports whether a subnet or target entry is a start-end range
+// rather than a CIDR, address or hostname.
+func isIPRange(entry string) bool {
+	first, _, ok := strings.Cut(entry, "-")
+	return ok && !strings.Contains(entry, "/") && net.ParseIP(strings.TrimSpace(first)) != nil
+}
+
+// rangeSize returns the number of addresses from first to last inclusive,
+// saturating at maxAddressCount.
+func rangeSize(first, last net.IP) int64 {
+	size := new(big.Int).Sub(new(big.Int).SetBytes(last), new(big.Int).SetBytes(first))
+	size.Add(size, big.NewInt(1))
+	if !size.IsInt64() || size.Int64() > maxAddressCount {
+		return maxAddressCount
+	}
+	return size.Int64()
+}
+
+// addressCount returns the number of addresses in ipNet, saturating at
+// maxAddressCount.
+func addressCount(ipNet *net.IPNet) int64 {
+	ones, bits := ipNet.Mask.Size()
+	if bits-ones >= 62 {
+		return maxAddressCount
+	}
+	return int64(1) << uint(bits-ones)
+}
+
+// incrementIP advances ip to the next address in place. It works for both
+// 4-byte IPv4 and 16-byte IPv6 representations.
 func incrementIP(ip net.IP) {
 	for j := len(ip) - 1; j >= 0; j-- {
 		ip[j]++
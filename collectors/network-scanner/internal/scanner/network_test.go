@@ -0,0 +1,243 @@
+package scanner
+
+import (
+	"fmt"
+	"net"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/aiforce-discovery-agent/collectors/network-scanner/internal/config"
+)
+
+func TestParseScanSubnet(t *testing.T) {
+	tests := []struct {
+		subnet    string
+		wantFirst string
+		wantSize  int64
+		wantErr   bool
+	}{
+		{subnet: "10.0.0.0/29", wantFirst: "10.0.0.0", wantSize: 8},
+		{subnet: "10.0.0.250-10.0.1.5", wantFirst: "10.0.0.250", wantSize: 12},
+		{subnet: "10.0.0.7-10.0.0.7", wantFirst: "10.0.0.7", wantSize: 1},
+		{subnet: "fd00::1-fd00::10", wantFirst: "fd00::1", wantSize: 16},
+		{subnet: "fd00::/120", wantFirst: "fd00::", wantSize: 256},
+		{subnet: "fd00::5/112", wantFirst: "fd00::", wantSize: 65536},
+		{subnet: "10.0.1.5-10.0.0.250", wantErr: true},
+		{subnet: "fd00::/111", wantErr: true},
+		{subnet: "fd00::/64", wantErr: true},
+		{subnet: "fd00::-fd00::1:0", wantErr: true},
+	}
+
+	s := newTestScanner(t, config.ScannerConfig{})
+	for _, tt := range tests {
+		t.Run(tt.subnet, func(t *testing.T) {
+			r, err := s.parseScanSubnet(tt.subnet)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("parseScanSubnet() = %+v, want an error", r)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseScanSubnet() error = %v", err)
+			}
+			if r.first.String() != tt.wantFirst || r.size != tt.wantSize {
+				t.Errorf("parseScanSubnet() = %s +%d, want %s +%d", r.first, r.size, tt.wantFirst, tt.wantSize)
+			}
+		})
+	}
+}
+
+func TestForEachSubnetIPAcrossOctets(t *testing.T) {
+	s := newTestScanner(t, config.ScannerConfig{})
+	r, err := s.parseScanSubnet("10.0.0.254-10.0.1.1")
+	if err != nil {
+		t.Fatalf("parseScanSubnet() error = %v", err)
+	}
+
+	var got []string
+	s.forEachSubnetIP("10.0.0.254-10.0.1.1", r, func(ip string) bool {
+		got = append(got, ip)
+		return true
+	})
+	want := []string{"10.0.0.254", "10.0.0.255", "10.0.1.0", "10.0.1.1"}
+	if len(got) != len(want) {
+		t.Fatalf("visited %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("visited %v, want %v", got, want)
+		}
+	}
+}
+
+func TestParseScanSubnetAllowLargeIPv6(t *testing.T) {
+	s := newTestScanner(t, config.ScannerConfig{AllowLargeIPv6: true})
+
+	tests := []struct {
+		subnet   string
+		wantSize int64
+	}{
+		{subnet: "fd00::/111", wantSize: 1 << 17},
+		{subnet: "fd00::/64", wantSize: maxAddressCount},
+		{subnet: "fd00::-fd00::1:0", wantSize: 65537},
+	}
+	for _, tt := range tests {
+		r, err := s.parseScanSubnet(tt.subnet)
+		if err != nil {
+			t.Errorf("parseScanSubnet(%q) error = %v", tt.subnet, err)
+			continue
+		}
+		if r.size != tt.wantSize {
+			t.Errorf("parseScanSubnet(%q) size = %d, want %d", tt.subnet, r.size, tt.wantSize)
+		}
+	}
+}
+
+func TestForEachSubnetIPv6(t *testing.T) {
+	s := newTestScanner(t, config.ScannerConfig{})
+	r, err := s.parseScanSubnet("fd00::/120")
+	if err != nil {
+		t.Fatalf("parseScanSubnet() error = %v", err)
+	}
+
+	var got []string
+	s.forEachSubnetIP("fd00::/120", r, func(ip string) bool {
+		got = append(got, ip)
+		return true
+	})
+	if len(got) != 256 {
+		t.Fatalf("visited %d addresses, want 256", len(got))
+	}
+	if got[0] != "fd00::" {
+		t.Errorf("first address = %s, want fd00::", got[0])
+	}
+	for i := 1; i < len(got); i++ {
+		if want := fmt.Sprintf("fd00::%x", i); got[i] != want {
+			t.Fatalf("address %d = %s, want %s", i, got[i], want)
+		}
+	}
+}
+
+func TestAutonomousScanIPv6Subnet(t *testing.T) {
+	s := newTestScanner(t, config.ScannerConfig{MaxConcurrentScans: 1, RateLimit: 1000000})
+	var mu sync.Mutex
+	dialed := make(map[string]bool)
+	s.dial = func(_, address string, _ time.Duration) (net.Conn, error) {
+		host, _, err := net.SplitHostPort(address)
+		if err != nil {
+			t.Errorf("dial address %q: %v", address, err)
+		}
+		mu.Lock()
+		dialed[host] = true
+		mu.Unlock()
+		return nil, &net.OpError{Op: "dial", Net: "tcp", Err: syscall.ECONNREFUSED}
+	}
+
+	completion := runAutonomous(t, s, AutonomousScanConfig{Subnets: []string{"fd00::/120"}, PortRanges: []string{"22"}})
+	if completion.Status != "completed" {
+		t.Errorf("status = %q, want completed", completion.Status)
+	}
+	if len(dialed) != 256 || !dialed["fd00::"] || !dialed["fd00::ff"] {
+		t.Errorf("dialed %d hosts, want all 256 of fd00::/120", len(dialed))
+	}
+}
+
+func TestNewRejectsBadExcludeSubnet(t *testing.T) {
+	_, err := New(config.ScannerConfig{ExcludeSubnets: []string{"10.0.0.0/8", "10.0.0.0/33"}}, nil, zap.NewNop().Sugar())
+	if err == nil || !strings.Contains(err.Error(), "10.0.0.0/33") {
+		t.Errorf("New() error = %v, want the bad subnet named", err)
+	}
+}
+
+func TestAutonomousScanSkipsExcludedSubnets(t *testing.T) {
+	s := newTestScanner(t, config.ScannerConfig{MaxConcurrentScans: 1, RateLimit: 100000})
+	nets, err := parseExcludeSubnets([]string{"10.9.0.0/30", "10.9.0.6/32"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.excludeNets = nets
+	var mu sync.Mutex
+	var dialed []string
+	s.dial = func(_, address string, _ time.Duration) (net.Conn, error) {
+		host, _, _ := net.SplitHostPort(address)
+		mu.Lock()
+		dialed = append(dialed, host)
+		mu.Unlock()
+		return nil, &net.OpError{Op: "dial", Net: "tcp", Err: syscall.ECONNREFUSED}
+	}
+
+	runAutonomous(t, s, AutonomousScanConfig{Subnets: []string{"10.9.0.0/29"}, PortRanges: []string{"22"}})
+
+	sort.Strings(dialed)
+	if want := []string{"10.9.0.4", "10.9.0.5", "10.9.0.7"}; !reflect.DeepEqual(dialed, want) {
+		t.Errorf("dialed %v, want %v", dialed, want)
+	}
+	// Excluded hosts still count towards progress
+	if status := s.lastSession.status(); status.ScannedHosts != 8 {
+		t.Errorf("scanned hosts = %d, want 8", status.ScannedHosts)
+	}
+}
+
+func TestIsExcludedIP(t *testing.T) {
+	s := newTestScanner(t, config.ScannerConfig{})
+	ips, err := parseExcludeIPs([]string{"192.0.2.7", "fd00:0:0:0:0:0:0:1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.excludeIPs = ips
+
+	tests := []struct {
+		ip   string
+		want bool
+	}{
+		{ip: "192.0.2.7", want: true},
+		{ip: "192.0.2.8"},
+		{ip: "fd00::1", want: true}, // another spelling of an excluded address
+		{ip: "fd00::2"},
+		{ip: "not-an-ip"},
+	}
+	for _, tt := range tests {
+		if got := s.isExcluded(tt.ip); got != tt.want {
+			t.Errorf("isExcluded(%q) = %v, want %v", tt.ip, got, tt.want)
+		}
+	}
+
+	if _, err := New(config.ScannerConfig{ExcludeIPs: []string{"192.0.2.0/24"}}, nil, zap.NewNop().Sugar()); err == nil {
+		t.Error("New() accepted a CIDR in exclude_ips")
+	}
+}
+
+func TestAutonomousScanSkipsExcludedIPs(t *testing.T) {
+	s := newTestScanner(t, config.ScannerConfig{MaxConcurrentScans: 1, RateLimit: 100000})
+	ips, err := parseExcludeIPs([]string{"10.9.0.1", "192.0.2.10"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.excludeIPs = ips
+	var mu sync.Mutex
+	var dialed []string
+	s.dial = func(_, address string, _ time.Duration) (net.Conn, error) {
+		host, _, _ := net.SplitHostPort(address)
+		mu.Lock()
+		dialed = append(dialed, host)
+		mu.Unlock()
+		return nil, &net.OpError{Op: "dial", Net: "tcp", Err: syscall.ECONNREFUSED}
+	}
+
+	runAutonomous(t, s, AutonomousScanConfig{
+		Subnets: []string{"10.9.0.0/30"}, Targets: []string{"192.0.2.10", "192.0.2.11"}, PortRanges: []string{"22"},
+	})
+
+	sort.Strings(dialed)
+	if want := []string{"10.9.0.0", "10.9.0.2", "10.9.0.3", "192.0.2.11"}; !reflect.DeepEqual(dialed, want) {
+		t.Errorf("dialed %v, want %v", dialed, want)
+	}
+}
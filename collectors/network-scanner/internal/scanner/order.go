@@ -0,0 +1,98 @@
+package scanner
+
+import (
+	"hash/fnv"
+	"math/bits"
+	"math/rand"
+	"net"
+)
+
+// newRand returns a source seeded from the scan seed and a salt, so each
+// subnet gets its own order while runs with the same RandomSeed repeat.
+func (s *Scanner) newRand(salt string) *rand.Rand {
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(salt))
+	return rand.New(rand.NewSource(s.randSeed ^ int64(h.Sum64())))
+}
+
+// shufflePorts shuffles ports in place.
+func shufflePorts(ports []int, rng *rand.Rand) {
+	rng.Shuffle(len(ports), func(i, j int) {
+		ports[i], ports[j] = ports[j], ports[i]
+	})
+}
+
+// forEachSubnetIP calls fn with every address in r until fn returns
+// false. Addresses are visited in ascending order unless RandomizeOrder is
+// set, in which case they follow a seeded permutation of the subnet.
+func (s *Scanner) forEachSubnetIP(subnet string, r addressRange, fn func(ip string) bool) {
+	size := uint64(r.size)
+	if !s.config.RandomizeOrder {
+		ip := make(net.IP, len(r.first))
+		copy(ip, r.first)
+		for i := uint64(0); i < size; i++ {
+			// Copy IP string before calling — incrementIP mutates the underlying bytes
+			if !fn(ip.String()) {
+				return
+			}
+			incrementIP(ip)
+		}
+		return
+	}
+
+	// Ranges that aren't CIDR-aligned are permuted over the next power of
+	// two, skipping offsets past the end
+	n := size
+	if n&(n-1) != 0 {
+		n = 1 << bits.Len64(n)
+	}
+	perm := newAddressPermutation(n, s.newRand(subnet))
+	for i := uint64(0); i < perm.n; i++ {
+		offset := perm.at(i)
+		if offset >= size {
+			continue
+		}
+		if !fn(offsetIP(r.first, offset).String()) {
+			return
+		}
+	}
+}
+
+// addressPermutation is a bijection over [0, n) for power-of-two n:
+// i -> ((a*i + c) mod n) xor m with odd a. It visits every offset exactly
+// once without materialising the subnet.
+type addressPermutation struct {
+	n, a, c, m uint64
+}
+
+func newAddressPermutation(n uint64, rng *rand.Rand) addressPermutation {
+	mask := n - 1
+	return addressPermutation{
+		n: n,
+		a: (rng.Uint64() | 1) & mask,
+		c: rng.Uint64() & mask,
+		m: rng.Uint64() & mask,
+	}
+}
+
+// at returns the offset visited at step i. Arithmetic wraps at 2^64, which
+// is a multiple of n, so masking yields the correct residue.
+func (p addressPermutation) at(i uint64) uint64 {
+	mask := p.n - 1
+	return ((p.a*i + p.c) & mask) ^ p.m
+}
+
+// offsetIP returns base + offset as a new address.
+func offsetIP(base net.IP, offset uint64) net.IP {
+	ip := make(net.IP, len(base))
+	copy(ip, base)
+
+	var carry uint64
+	for j := len(ip) - 1; j >= 0 && (offset > 0 || carry > 0); j-- {
+		sum := uint64(ip[j]) + offset&0xff + carry
+		ip[j] = byte(sum)
+		carry = sum >> 8
+		offset >>= 8
+	}
+	return ip
+}
@@ -0,0 +1,99 @@
+package scanner
+
+import (
+	"math/rand"
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/aiforce-discovery-agent/collectors/network-scanner/internal/config"
+)
+
+func TestAddressPermutationVisitsEveryOffset(t *testing.T) {
+	for _, n := range []uint64{1, 2, 16, 256, 4096} {
+		perm := newAddressPermutation(n, rand.New(rand.NewSource(int64(n))))
+		seen := make(map[uint64]bool, n)
+		for i := uint64(0); i < n; i++ {
+			offset := perm.at(i)
+			if offset >= n || seen[offset] {
+				t.Fatalf("n=%d: step %d visits offset %d again or out of range", n, i, offset)
+			}
+			seen[offset] = true
+		}
+	}
+}
+
+// subnetOrder returns the addresses forEachSubnetIP visits for subnet.
+func subnetOrder(t *testing.T, s *Scanner, subnet string) []string {
+	t.Helper()
+	r, err := s.parseScanSubnet(subnet)
+	if err != nil {
+		t.Fatalf("parseScanSubnet(%q) error = %v", subnet, err)
+	}
+	var got []string
+	s.forEachSubnetIP(subnet, r, func(ip string) bool {
+		got = append(got, ip)
+		return true
+	})
+	return got
+}
+
+func TestRandomizedSubnetOrder(t *testing.T) {
+	ordered := subnetOrder(t, newTestScanner(t, config.ScannerConfig{}), "10.0.0.0-10.0.0.99")
+
+	newRandomized := func(seed int64) *Scanner {
+		s := newTestScanner(t, config.ScannerConfig{RandomizeOrder: true})
+		s.randSeed = seed
+		return s
+	}
+	first := subnetOrder(t, newRandomized(42), "10.0.0.0-10.0.0.99")
+	again := subnetOrder(t, newRandomized(42), "10.0.0.0-10.0.0.99")
+	other := subnetOrder(t, newRandomized(43), "10.0.0.0-10.0.0.99")
+
+	// A range that isn't CIDR-aligned still visits each address once
+	sorted := append([]string(nil), first...)
+	sort.Strings(sorted)
+	want := append([]string(nil), ordered...)
+	sort.Strings(want)
+	if !reflect.DeepEqual(sorted, want) {
+		t.Fatalf("randomized order visits %d addresses, want the same %d as ascending order", len(first), len(ordered))
+	}
+	if reflect.DeepEqual(first, ordered) {
+		t.Error("randomized order is ascending")
+	}
+	if !reflect.DeepEqual(first, again) {
+		t.Error("the same seed gave a different order")
+	}
+	if reflect.DeepEqual(first, other) {
+		t.Error("a different seed gave the same order")
+	}
+}
+
+func TestRandomizedPortOrder(t *testing.T) {
+	cfg := config.ScannerConfig{CommonPorts: []int{3306, 5432}, PortRanges: []string{"1-20"}}
+	ordered := newTestScanner(t, cfg).expandPortRanges()
+
+	cfg.RandomizeOrder = true
+	s := newTestScanner(t, cfg)
+	s.randSeed = 42
+	got := s.expandPortRanges()
+	again := s.expandPortRanges()
+
+	if !reflect.DeepEqual(got, again) {
+		t.Errorf("the same seed gave orders %v and %v", got, again)
+	}
+	if reflect.DeepEqual(got, ordered) {
+		t.Error("randomized port order is ascending")
+	}
+	// Database ports still lead, in some order
+	lead := append([]int(nil), got[:2]...)
+	sort.Ints(lead)
+	if !reflect.DeepEqual(lead, []int{3306, 5432}) {
+		t.Errorf("ports = %v, want the database ports first", got)
+	}
+	rest := append([]int(nil), got[2:]...)
+	sort.Ints(rest)
+	if !reflect.DeepEqual(rest, ordered[2:]) {
+		t.Errorf("ports = %v, want a permutation of %v", got, ordered)
+	}
+}
@@ -0,0 +1,156 @@
+package scanner
+
+import (
+	"errors"
+	"net"
+	"os"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/aiforce-discovery-agent/collectors/network-scanner/internal/metrics"
+	"golang.org/x/net/icmp"
+	"golang.org/x/net/ipv4"
+)
+
+// pingMode describes how host liveness is checked.
+type pingMode string
+
+const (
+	// pingModeICMP uses a raw ICMP socket (requires CAP_NET_RAW).
+	pingModeICMP pingMode = "icmp"
+	// pingModeICMPUnprivileged uses a Linux datagram ICMP socket, allowed
+	// when the process GID is within net.ipv4.ping_group_range.
+	pingModeICMPUnprivileged pingMode = "icmp-unprivileged"
+	// pingModeTCP connects to common ports; a refused connection still
+	// proves the host is up.
+	pingModeTCP pingMode = "tcp"
+)
+
+// tcpPingPorts are tried in order by the TCP ping fallback.
+var tcpPingPorts = []string{"443", "80"}
+
+// pingSeq distinguishes echo requests sent concurrently by workers.
+var pingSeq uint32
+
+// detectPingMode picks the most capable liveness check available to the
+// process, degrading to a TCP ping when ICMP sockets can't be opened.
+func detectPingMode() pingMode {
+	if conn, err := icmp.ListenPacket("ip4:icmp", "0.0.0.0"); err == nil {
+		_ = conn.Close()
+		return pingModeICMP
+	}
+	if conn, err := icmp.ListenPacket("udp4", "0.0.0.0"); err == nil {
+		_ = conn.Close()
+		return pingModeICMPUnprivileged
+	}
+	return pingModeTCP
+}
+
+// isAlive reports whether ip responds to an ICMP echo or TCP ping within
+// PingTimeoutMS. ICMP silence falls back to a TCP ping, since many hosts
+// drop echo requests while still serving traffic.
+func (s *Scanner) isAlive(ip string) bool {
+	timeout := time.Duration(s.config.PingTimeoutMS) * time.Millisecond
+	if timeout <= 0 {
+		timeout = time.Second
+	}
+
+	parsed := net.ParseIP(ip)
+	if parsed != nil && parsed.To4() != nil && s.pingMode != pingModeTCP {
+		if icmpEcho(parsed, s.pingMode, timeout) {
+			return true
+		}
+	}
+
+	if !s.tcpPing(ip, timeout) {
+		metrics.DeadHosts.Inc()
+		return false
+	}
+	return true
+}
+
+// icmpEcho sends a single echo request and waits for the matching reply.
+func icmpEcho(ip net.IP, mode pingMode, timeout time.Duration) bool {
+	network := "ip4:icmp"
+	var dst net.Addr = &net.IPAddr{IP: ip}
+	if mode == pingModeICMPUnprivileged {
+		network = "udp4"
+		dst = &net.UDPAddr{IP: ip}
+	}
+
+	conn, err := icmp.ListenPacket(network, "0.0.0.0")
+	if err != nil {
+		return false
+	}
+	defer func() { _ = conn.Close() }()
+
+	id := os.Getpid() & 0xffff
+	seq := int(atomic.AddUint32(&pingSeq, 1) & 0xffff)
+	msg := icmp.Message{
+		Type: ipv4.ICMPTypeEcho,
+		Body: &icmp.Echo{ID: id, Seq: seq, Data: []byte("aiforce-discovery")},
+	}
+	wb, err := msg.Marshal(nil)
+	if err != nil {
+		return false
+	}
+
+	deadline := time.Now().Add(timeout)
+	if err := conn.SetDeadline(deadline); err != nil {
+		return false
+	}
+	if _, err := conn.WriteTo(wb, dst); err != nil {
+		return false
+	}
+
+	rb := make([]byte, 1500)
+	for time.Now().Before(deadline) {
+		n, peer, err := conn.ReadFrom(rb)
+		if err != nil {
+			return false
+		}
+		if !peerIP(peer).Equal(ip) {
+			continue
+		}
+
+		reply, err := icmp.ParseMessage(1, rb[:n]) // 1 = ICMPv4
+		if err != nil || reply.Type != ipv4.ICMPTypeEchoReply {
+			continue
+		}
+		// Datagram sockets have their ID rewritten by the kernel, so only
+		// the sequence number can be matched there
+		if echo, ok := reply.Body.(*icmp.Echo); ok && echo.Seq == seq &&
+			(mode == pingModeICMPUnprivileged || echo.ID == id) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func peerIP(addr net.Addr) net.IP {
+	switch a := addr.(type) {
+	case *net.IPAddr:
+		return a.IP
+	case *net.UDPAddr:
+		return a.IP
+	}
+	return nil
+}
+
+// tcpPing connects to common ports; either an accepted or refused
+// connection means the host is up. Each dial waits on the probe limiters.
+func (s *Scanner) tcpPing(ip string, timeout time.Duration) bool {
+	for _, port := range tcpPingPorts {
+		conn, _, err := s.dialProbe(s.ctx, "tcp", net.JoinHostPort(ip, port), timeout)
+		if err == nil {
+			_ = conn.Close()
+			return true
+		}
+		if errors.Is(err, syscall.ECONNREFUSED) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,97 @@
+package scanner
+
+import (
+	"net"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/aiforce-discovery-agent/collectors/network-scanner/internal/config"
+)
+
+func TestTCPPing(t *testing.T) {
+	refused := &net.OpError{Op: "dial", Net: "tcp", Err: syscall.ECONNREFUSED}
+	tests := []struct {
+		name      string
+		errs      map[string]error // by port; ports not listed accept
+		wantAlive bool
+		wantDials []string
+	}{
+		{name: "accepted", wantAlive: true, wantDials: []string{"443"}},
+		{name: "refused", errs: map[string]error{"443": refused}, wantAlive: true, wantDials: []string{"443"}},
+		{name: "second port", errs: map[string]error{"443": timeoutErr{}}, wantAlive: true, wantDials: []string{"443", "80"}},
+		{name: "silent", errs: map[string]error{"443": timeoutErr{}, "80": timeoutErr{}}, wantDials: []string{"443", "80"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestScanner(t, config.ScannerConfig{PingTimeoutMS: 50})
+			s.pingMode = pingModeTCP
+			var dials []string
+			s.dial = func(_, address string, _ time.Duration) (net.Conn, error) {
+				_, port, _ := net.SplitHostPort(address)
+				dials = append(dials, port)
+				if err := tt.errs[port]; err != nil {
+					return nil, err
+				}
+				client, server := net.Pipe()
+				_ = server.Close()
+				return client, nil
+			}
+
+			if got := s.isAlive("192.0.2.1"); got != tt.wantAlive {
+				t.Errorf("isAlive() = %v, want %v", got, tt.wantAlive)
+			}
+			if len(dials) != len(tt.wantDials) {
+				t.Fatalf("dialed ports %v, want %v", dials, tt.wantDials)
+			}
+			for i := range dials {
+				if dials[i] != tt.wantDials[i] {
+					t.Fatalf("dialed ports %v, want %v", dials, tt.wantDials)
+				}
+			}
+		})
+	}
+}
+
+func TestPingSkipsDeadHosts(t *testing.T) {
+	for _, enablePing := range []bool{false, true} {
+		s := newTestScanner(t, config.ScannerConfig{
+			MaxConcurrentScans: 1, RateLimit: 100000, EnablePing: enablePing, PingTimeoutMS: 50,
+		})
+		s.pingMode = pingModeTCP
+		var mu sync.Mutex
+		scanned := make(map[string]bool)
+		s.dial = func(_, address string, _ time.Duration) (net.Conn, error) {
+			host, port, _ := net.SplitHostPort(address)
+			if port == "22" {
+				mu.Lock()
+				scanned[host] = true
+				mu.Unlock()
+			}
+			if host == "192.0.2.11" {
+				return nil, timeoutErr{}
+			}
+			return nil, &net.OpError{Op: "dial", Net: "tcp", Err: syscall.ECONNREFUSED}
+		}
+
+		runAutonomous(t, s, AutonomousScanConfig{Targets: []string{"192.0.2.10", "192.0.2.11"}, PortRanges: []string{"22"}})
+
+		if !scanned["192.0.2.10"] {
+			t.Errorf("enable_ping=%v: live host not scanned", enablePing)
+		}
+		if scanned["192.0.2.11"] == enablePing {
+			t.Errorf("enable_ping=%v: dead host scanned = %v", enablePing, scanned["192.0.2.11"])
+		}
+	}
+}
+
+func TestICMPEchoLoopback(t *testing.T) {
+	mode := detectPingMode()
+	if mode == pingModeTCP {
+		t.Skip("no ICMP socket available to this process")
+	}
+	if !icmpEcho(net.ParseIP("127.0.0.1"), mode, time.Second) {
+		t.Errorf("icmpEcho(127.0.0.1) in %s mode = false, want an echo reply", mode)
+	}
+}
@@ -0,0 +1,93 @@
+package scanner
+
+import "net"
+
+// prefixTrie is a binary radix tree keyed by address bits, giving a
+// longest-prefix match in at most 32 (IPv4) or 128 (IPv6) steps regardless
+// of how many ranges are loaded.
+type prefixTrie struct {
+	v4 *trieNode
+	v6 *trieNode
+}
+
+type trieNode struct {
+	children [2]*trieNode
+	entry    *prefixEntry // set when a range ends at this node
+}
+
+// prefixEntry is what a matched range resolves to.
+type prefixEntry struct {
+	provider CloudProvider
+	region   string
+}
+
+func newPrefixTrie() *prefixTrie {
+	return &prefixTrie{v4: &trieNode{}, v6: &trieNode{}}
+}
+
+// insert adds ipnet. If the same prefix is already present the existing
+// entry is kept.
+func (t *prefixTrie) insert(ipnet *net.IPNet, entry prefixEntry) {
+	root, addr := t.root(ipnet.IP)
+	if root == nil {
+		return
+	}
+	ones, bits := ipnet.Mask.Size()
+	if bits != len(addr)*8 {
+		return
+	}
+
+	node := root
+	for i := 0; i < ones; i++ {
+		b := addressBit(addr, i)
+		if node.children[b] == nil {
+			node.children[b] = &trieNode{}
+		}
+		node = node.children[b]
+	}
+	if node.entry == nil {
+		node.entry = &entry
+	}
+}
+
+// lookup returns the entry of the longest prefix containing ip.
+func (t *prefixTrie) lookup(ip net.IP) (prefixEntry, bool) {
+	node, addr := t.root(ip)
+	if node == nil {
+		return prefixEntry{}, false
+	}
+
+	var match *prefixEntry
+	for i := 0; node != nil; i++ {
+		if node.entry != nil {
+			match = node.entry
+		}
+		if i == len(addr)*8 {
+			break
+		}
+		node = node.children[addressBit(addr, i)]
+	}
+	if match == nil {
+		return prefixEntry{}, false
+	}
+	return *match, true
+}
+
+// root returns the tree for ip's family and ip in that family's length.
+func (t *prefixTrie) root(ip net.IP) (*trieNode, net.IP) {
+	if t == nil {
+		return nil, nil
+	}
+	if v4 := ip.To4(); v4 != nil {
+		return t.v4, v4
+	}
+	if v6 := ip.To16(); v6 != nil {
+		return t.v6, v6
+	}
+	return nil, nil
+}
+
+// addressBit returns bit i of addr, counting from the most significant.
+func addressBit(addr net.IP, i int) int {
+	return int(addr[i/8]>>(7-uint(i%8))) & 1
+}
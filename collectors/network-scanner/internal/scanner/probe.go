@@ -0,0 +1,115 @@
+package scanner
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"time"
+)
+
+// activeProbes are payloads sent to open ports that stayed silent during the
+// passive banner read. Each elicits a short response that the banner
+// signatures can identify.
+var activeProbes = map[string]string{
+	"http":      "GET / HTTP/1.0\r\n\r\n",
+	"redis":     "PING\r\n",
+	"memcached": "stats\r\n",
+}
+
+// serviceProbes maps services from wellKnownPorts to the probe tried first
+// on their port.
+var serviceProbes = map[string]string{
+	"HTTP":          "http",
+	"HTTP-Alt":      "http",
+	"Elasticsearch": "http",
+	"CouchDB":       "http",
+	"Redis":         "redis",
+	"Memcached":     "memcached",
+}
+
+// validateActiveProbes rejects unknown probe names in the configuration.
+func validateActiveProbes(names []string) error {
+	handshakes := make(map[string]bool, len(handshakeProbes)+len(httpDatabaseProbes))
+	for _, probe := range handshakeProbes {
+		handshakes[probe.name] = true
+	}
+	for _, probe := range httpDatabaseProbes {
+		handshakes[probe.name] = true
+	}
+	handshakes["dns"] = true
+	for _, name := range names {
+		if _, ok := activeProbes[name]; !ok && !handshakes[name] {
+			return fmt.Errorf("unknown active probe %q", name)
+		}
+	}
+	return nil
+}
+
+// probeOrder returns the configured payload probes with the one matching
+// the port's well-known service first. Handshake probes are left out; they
+// only go to their own port.
+func (s *Scanner) probeOrder(port int) []string {
+	preferred := serviceProbes[wellKnownPorts[port].Name]
+
+	order := make([]string, 0, len(s.config.ActiveProbes))
+	for _, name := range s.config.ActiveProbes {
+		if _, ok := activeProbes[name]; !ok {
+			continue
+		}
+		if name == preferred {
+			order = append([]string{name}, order...)
+		} else {
+			order = append(order, name)
+		}
+	}
+	return order
+}
+
+// activeProbe sends the configured probes to a silent port and returns the
+// first response a banner signature identifies, or else the first non-empty
+// response. The first probe reuses conn; a probe can leave a service in an
+// error state, so later probes dial afresh under the rate limit. Responses
+// are capped at maxBannerSize and each probe at ActiveProbeTimeoutMS.
+func (s *Scanner) activeProbe(ctx context.Context, conn net.Conn, address string, port int) string {
+	timeout := time.Duration(s.config.ActiveProbeTimeoutMS) * time.Millisecond
+	dialTimeout := s.timeout()
+
+	var fallback string
+	for i, name := range s.probeOrder(port) {
+		probeConn := conn
+		if i > 0 {
+			c, _, err := s.dialProbe(ctx, "tcp", address, dialTimeout)
+			if err != nil {
+				break
+			}
+			defer func() { _ = c.Close() }()
+			probeConn = c
+		}
+
+		response := sendProbe(probeConn, activeProbes[name], timeout)
+		if response == "" {
+			continue
+		}
+		if fp := s.identify(port, response); fp.Source != "port" {
+			return response
+		}
+		if fallback == "" {
+			fallback = response
+		}
+	}
+	return fallback
+}
+
+// sendProbe writes probe to conn and reads the response within timeout.
+func sendProbe(conn net.Conn, probe string, timeout time.Duration) string {
+	if err := conn.SetDeadline(time.Now().Add(timeout)); err != nil {
+		return ""
+	}
+	if _, err := conn.Write([]byte(probe)); err != nil {
+		return ""
+	}
+
+	buffer := make([]byte, maxBannerSize)
+	n, _ := conn.Read(buffer)
+	return string(buffer[:n])
+}
@@ -0,0 +1,254 @@
+package scanner
+
+import (
+	"context"
+	"errors"
+	"net"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/aiforce-discovery-agent/collectors/network-scanner/internal/config"
+	"github.com/aiforce-discovery-agent/collectors/network-scanner/internal/publisher"
+)
+
+// timedPublisher records the kind of each service and server event
+// published, and when.
+type timedPublisher struct {
+	*recordingPublisher
+
+	mu    sync.Mutex
+	at    []time.Time
+	kinds []string
+}
+
+func (p *timedPublisher) stamp(kind string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.at = append(p.at, time.Now())
+	p.kinds = append(p.kinds, kind)
+}
+
+func (p *timedPublisher) PublishServiceDiscovered(result interface{}) error {
+	p.stamp("service")
+	return p.recordingPublisher.PublishServiceDiscovered(result)
+}
+
+func (p *timedPublisher) PublishServerDiscovered(data publisher.ServerDiscoveredData) error {
+	p.stamp("server")
+	return p.recordingPublisher.PublishServerDiscovered(data)
+}
+
+func TestPublishInterval(t *testing.T) {
+	const interval = 30 * time.Millisecond
+
+	tests := []struct {
+		name       string
+		intervalMS int
+		wantGap    time.Duration
+	}{
+		{name: "unpaced", intervalMS: 0},
+		{name: "paced", intervalMS: int(interval / time.Millisecond), wantGap: interval},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pub := &timedPublisher{recordingPublisher: &recordingPublisher{}}
+			s, err := New(config.ScannerConfig{PublishIntervalMS: tt.intervalMS}, pub, zap.NewNop().Sugar())
+			if err != nil {
+				t.Fatalf("New() error = %v", err)
+			}
+
+			results := []ScanResult{
+				{IP: "192.0.2.1", Port: 22, Open: true},
+				{IP: "192.0.2.1", Port: 80, Open: true},
+				{IP: "192.0.2.1", Port: 443, Open: true},
+			}
+			start := time.Now()
+			if published, failed := s.publishResults(context.Background(), results); len(published) != 3 || failed != 0 {
+				t.Fatalf("publishResults() = %d published, %d failed; want 3 and 0", len(published), failed)
+			}
+			elapsed := time.Since(start)
+
+			// Three services, then the server
+			if len(pub.at) != 4 {
+				t.Fatalf("%d events published, want 4", len(pub.at))
+			}
+			for i := 1; i < len(pub.at); i++ {
+				if gap := pub.at[i].Sub(pub.at[i-1]); gap < tt.wantGap-time.Millisecond {
+					t.Errorf("event %d published %v after the previous, want at least %v", i, gap, tt.wantGap)
+				}
+			}
+			if tt.wantGap == 0 && elapsed > 3*interval {
+				t.Errorf("unpaced publishing took %v", elapsed)
+			}
+		})
+	}
+}
+
+// flakyPublisher fails every failEvery-th service and server publish.
+type flakyPublisher struct {
+	*recordingPublisher
+	failEvery int
+
+	mu    sync.Mutex
+	calls int
+}
+
+func (p *flakyPublisher) fail() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.calls++
+	if p.failEvery > 0 && p.calls%p.failEvery == 0 {
+		return errors.New("broker unavailable")
+	}
+	return nil
+}
+
+func (p *flakyPublisher) ForScan(string) publisher.EventPublisher { return p }
+
+func (p *flakyPublisher) PublishServiceDiscovered(result interface{}) error {
+	if err := p.fail(); err != nil {
+		return err
+	}
+	return p.recordingPublisher.PublishServiceDiscovered(result)
+}
+
+func (p *flakyPublisher) PublishServerDiscovered(data publisher.ServerDiscoveredData) error {
+	if err := p.fail(); err != nil {
+		return err
+	}
+	return p.recordingPublisher.PublishServerDiscovered(data)
+}
+
+func TestAbortAfterConsecutivePublishFailures(t *testing.T) {
+	tests := []struct {
+		name       string
+		limit      int
+		failEvery  int // 1 fails every publish
+		wantStatus string
+	}{
+		{name: "aborted", limit: 3, failEvery: 1, wantStatus: "failed"},
+		{name: "no limit", failEvery: 1, wantStatus: "completed"},
+		{name: "failures interrupted by successes", limit: 2, failEvery: 2, wantStatus: "completed"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestScanner(t, config.ScannerConfig{
+				MaxConcurrentScans: 1, RateLimit: 100000, MaxConsecutivePublishFailures: tt.limit,
+			})
+			var dials int32
+			s.dial = pipeDial(&dials, func(net.Conn) {})
+			s.publisher = &flakyPublisher{recordingPublisher: &recordingPublisher{}, failEvery: tt.failEvery}
+
+			// One host at a time, so failures count in publish order
+			completion := runAutonomous(t, s, AutonomousScanConfig{
+				Subnets: []string{"10.9.0.0/26"}, PortRanges: []string{"80"}, MaxConcurrentHosts: 1,
+			})
+
+			if completion.Status != tt.wantStatus {
+				t.Fatalf("status = %q (%s), want %q", completion.Status, completion.ErrorMessage, tt.wantStatus)
+			}
+			if tt.wantStatus != "failed" {
+				if dials != 64 {
+					t.Errorf("dialed %d hosts, want all 64", dials)
+				}
+				return
+			}
+			if !strings.Contains(completion.ErrorMessage, "aborted after 3 consecutive publish failures") {
+				t.Errorf("error message = %q, want the abort reason", completion.ErrorMessage)
+			}
+			if dials >= 64 {
+				t.Errorf("dialed %d hosts, want the scan stopped early", dials)
+			}
+		})
+	}
+}
+
+func TestHostEventsPerHost(t *testing.T) {
+	for _, hostEvents := range []bool{false, true} {
+		s := newTestScanner(t, config.ScannerConfig{MaxConcurrentScans: 1, RateLimit: 100000, HostEvents: hostEvents})
+		var dials int32
+		s.dial = pipeDial(&dials, func(net.Conn) {})
+		pub := &recordingPublisher{}
+		s.publisher = pub
+
+		runAutonomous(t, s, AutonomousScanConfig{
+			Targets: []string{"192.0.2.10", "192.0.2.11"}, PortRanges: []string{"22", "80"},
+		})
+
+		// Either way each host's services and server are published; host
+		// events carry them in one event per host
+		wantHosts := 0
+		if hostEvents {
+			wantHosts = 2
+		}
+		if pub.hosts != wantHosts || len(pub.servers) != 2 || len(pub.services) != 4 {
+			t.Errorf("host_events=%v: %d host events, %d servers, %d services; want %d, 2, 4",
+				hostEvents, pub.hosts, len(pub.servers), len(pub.services), wantHosts)
+		}
+	}
+}
+
+func TestScanFlushesPendingBatch(t *testing.T) {
+	s := newTestScanner(t, config.ScannerConfig{MaxConcurrentScans: 1, RateLimit: 100000})
+	var dials int32
+	s.dial = pipeDial(&dials, func(net.Conn) {})
+	pub := &recordingPublisher{}
+	s.publisher = pub
+
+	runAutonomous(t, s, AutonomousScanConfig{Targets: []string{"192.0.2.10"}, PortRanges: []string{"80"}})
+
+	// A partial batch goes out when the scan finishes, not a window later
+	if pub.flushes != 1 {
+		t.Errorf("publisher flushed %d times, want once as the scan finished", pub.flushes)
+	}
+}
+
+func TestServerEventFollowsServices(t *testing.T) {
+	results := []ScanResult{
+		{IP: "192.0.2.1", Port: 22, Open: true, Service: "SSH"},
+		{IP: "192.0.2.1", Port: 80, Open: true, Service: "HTTP"},
+	}
+
+	t.Run("published", func(t *testing.T) {
+		s := newTestScanner(t, config.ScannerConfig{})
+		pub := &timedPublisher{recordingPublisher: &recordingPublisher{}}
+		s.publisher = pub
+
+		published, _ := s.publishResults(context.Background(), results)
+		if len(published) != 2 || len(pub.servers) != 1 {
+			t.Fatalf("published %d services and %d servers, want 2 and 1", len(published), len(pub.servers))
+		}
+		// The server event is the last of the host's events
+		if got := strings.Join(pub.kinds, " "); got != "service service server" {
+			t.Errorf("published %s, want the services then the server", got)
+		}
+		server := pub.servers[0]
+		if server.ServerID == "" || !reflect.DeepEqual(server.OpenPorts, []int{22, 80}) {
+			t.Errorf("server = %+v, want an ID and ports 22 and 80", server)
+		}
+		for _, service := range pub.services {
+			if id := service.(ScanResult).ServerID; id != server.ServerID {
+				t.Errorf("service server_id = %q, want %q", id, server.ServerID)
+			}
+		}
+	})
+
+	t.Run("services failed", func(t *testing.T) {
+		s := newTestScanner(t, config.ScannerConfig{})
+		pub := &flakyPublisher{recordingPublisher: &recordingPublisher{}, failEvery: 1}
+		s.publisher = pub
+
+		if published, failed := s.publishResults(context.Background(), results); len(published) != 0 || failed != 2 {
+			t.Fatalf("published %d, failed %d; want 0 and 2", len(published), failed)
+		}
+		// No server event for a host none of whose services got out
+		if pub.calls != 2 {
+			t.Errorf("%d publishes attempted, want only the 2 services", pub.calls)
+		}
+	})
+}
@@ -0,0 +1,144 @@
+package scanner
+
+import (
+	"reflect"
+	"time"
+
+	"github.com/aiforce-discovery-agent/collectors/network-scanner/internal/config"
+)
+
+// reloadableFields are the ScannerConfig fields Reload applies without a
+// restart, by mapstructure key.
+var reloadableFields = map[string]bool{
+	"rate_limit":      true,
+	"rate_burst":      true,
+	"exclude_subnets": true,
+	"exclude_ips":     true,
+	"timeout":         true,
+	"port_timeouts":   true,
+	"signature_file":  true,
+}
+
+// Reload applies the reloadable fields of a reloaded configuration and
+// returns those that changed. Changes to any other field are logged and
+// ignored until a restart. If a reloadable field is invalid nothing is
+// applied.
+//
+// Scans already running pick up the rate limit and burst, and skip hosts
+// not yet dispatched that the new exclusions cover; an autonomous scan
+// that requested its own rate keeps it. The legacy scan also picks up
+// timeouts and signatures; autonomous scans keep the ones they started
+// with.
+func (s *Scanner) Reload(cfg config.ScannerConfig) ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var changed, restart []string
+	for _, field := range changedFields(s.config, cfg) {
+		if reloadableFields[field] {
+			changed = append(changed, field)
+		} else {
+			restart = append(restart, field)
+		}
+	}
+	if len(restart) > 0 {
+		s.logger.Warnw("Configuration changes need a restart to apply", "fields", restart)
+	}
+	if len(changed) == 0 {
+		return nil, nil
+	}
+
+	excludeNets, err := parseExcludeSubnets(cfg.ExcludeSubnets)
+	if err != nil {
+		return nil, err
+	}
+	excludeIPs, err := parseExcludeIPs(cfg.ExcludeIPs)
+	if err != nil {
+		return nil, err
+	}
+
+	fingerprinter := s.fingerprinter
+	if cfg.SignatureFile != s.config.SignatureFile {
+		fingerprinter = NewFingerprinter()
+		if cfg.SignatureFile != "" {
+			if err := fingerprinter.LoadSignatureFile(cfg.SignatureFile, s.logger); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	// Only changed fields are written, so the legacy scan's workers never
+	// see config fields change that they read without reloadMu. Ad-hoc
+	// scans and sessions work on a copy taken when they start.
+	s.reloadMu.Lock()
+	for _, field := range changed {
+		switch field {
+		case "rate_limit":
+			s.config.RateLimit = cfg.RateLimit
+		case "rate_burst":
+			s.config.RateBurst = cfg.RateBurst
+		case "exclude_subnets":
+			s.excludeNets = excludeNets
+			s.config.ExcludeSubnets = cfg.ExcludeSubnets
+		case "exclude_ips":
+			s.excludeIPs = excludeIPs
+			s.config.ExcludeIPs = cfg.ExcludeIPs
+		case "timeout":
+			s.config.Timeout = cfg.Timeout
+		case "port_timeouts":
+			s.config.PortTimeouts = cfg.PortTimeouts
+		case "signature_file":
+			s.fingerprinter = fingerprinter
+			s.config.SignatureFile = cfg.SignatureFile
+		}
+	}
+	s.reloadMu.Unlock()
+
+	// Adjust limiters in place: running scans' workers wait on them
+	limiter := newProbeLimiter(cfg.RateLimit, cfg.RateBurst)
+	s.limiter.SetLimit(limiter.Limit())
+	s.limiter.SetBurst(limiter.Burst())
+
+	for _, session := range s.sessions {
+		if session.request.RateLimitPPS == 0 {
+			session.limiter.SetLimit(limiter.Limit())
+		}
+		session.limiter.SetBurst(limiter.Burst())
+
+		session.reloadMu.Lock()
+		session.excludeNets = excludeNets
+		session.excludeIPs = excludeIPs
+		session.reloadMu.Unlock()
+	}
+
+	s.logger.Infow("Scanner configuration reloaded", "changed", changed)
+	return changed, nil
+}
+
+// timeout returns the global connection timeout.
+func (s *Scanner) timeout() time.Duration {
+	s.reloadMu.RLock()
+	defer s.reloadMu.RUnlock()
+	return time.Duration(s.config.Timeout) * time.Millisecond
+}
+
+// identify fingerprints a banner with the current signatures.
+func (s *Scanner) identify(port int, banner string) ServiceFingerprint {
+	s.reloadMu.RLock()
+	f := s.fingerprinter
+	s.reloadMu.RUnlock()
+	return f.Identify(port, banner)
+}
+
+// changedFields returns the mapstructure keys of the fields that differ
+// between a and b.
+func changedFields(a, b config.ScannerConfig) []string {
+	var changed []string
+	va, vb := reflect.ValueOf(a), reflect.ValueOf(b)
+	for i := 0; i < va.NumField(); i++ {
+		if !reflect.DeepEqual(va.Field(i).Interface(), vb.Field(i).Interface()) {
+			changed = append(changed, va.Type().Field(i).Tag.Get("mapstructure"))
+		}
+	}
+	return changed
+}
@@ -0,0 +1,282 @@
+package scanner
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+
+	"golang.org/x/time/rate"
+
+	"github.com/aiforce-discovery-agent/collectors/network-scanner/internal/config"
+)
+
+func TestReload(t *testing.T) {
+	base := config.ScannerConfig{Timeout: 1000, RateLimit: 100, PortRanges: []string{"22"}}
+
+	tests := []struct {
+		name        string
+		running     bool
+		change      func(*config.ScannerConfig)
+		wantChanged []string
+		// wantIgnored lists fields left unapplied; nil means every change applies
+		wantIgnored []string
+		wantErr     string
+	}{
+		{name: "unchanged", change: func(*config.ScannerConfig) {}},
+		{
+			name:        "reloadable",
+			change:      func(c *config.ScannerConfig) { c.Timeout = 500; c.ExcludeIPs = []string{"10.0.0.1"} },
+			wantChanged: []string{"exclude_ips", "timeout"},
+		},
+		{
+			name:        "restart only",
+			change:      func(c *config.ScannerConfig) { c.PortRanges = []string{"80"} },
+			wantIgnored: []string{"port_ranges"},
+		},
+		{
+			name:        "reloadable subset",
+			change:      func(c *config.ScannerConfig) { c.PortRanges = []string{"80"}; c.Concurrency = 8; c.Timeout = 500 },
+			wantChanged: []string{"timeout"},
+			wantIgnored: []string{"port_ranges", "concurrency"},
+		},
+		{
+			name:        "rate limit while running",
+			running:     true,
+			change:      func(c *config.ScannerConfig) { c.RateLimit = 10 },
+			wantChanged: []string{"rate_limit"},
+		},
+		{
+			name:        "timeout while running",
+			running:     true,
+			change:      func(c *config.ScannerConfig) { c.Timeout = 500 },
+			wantChanged: []string{"timeout"},
+		},
+		{
+			name:        "exclusions while running",
+			running:     true,
+			change:      func(c *config.ScannerConfig) { c.ExcludeSubnets = []string{"10.0.0.0/8"} },
+			wantChanged: []string{"exclude_subnets"},
+		},
+		{
+			name:    "bad exclusion",
+			change:  func(c *config.ScannerConfig) { c.ExcludeSubnets = []string{"10.0.0.0/33"} },
+			wantErr: "10.0.0.0/33",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestScanner(t, base)
+			logs := observeLogs(s)
+			s.running = tt.running
+			cfg := base
+			tt.change(&cfg)
+
+			changed, err := s.Reload(cfg)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Reload() error = %v, want %q", err, tt.wantErr)
+				}
+				if !reflect.DeepEqual(s.config, base) {
+					t.Errorf("config changed by a rejected reload: %+v", s.config)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Reload() error = %v", err)
+			}
+			if !reflect.DeepEqual(changed, tt.wantChanged) {
+				t.Errorf("Reload() changed = %v, want %v", changed, tt.wantChanged)
+			}
+
+			ignored := logs.FilterMessage("Configuration changes need a restart to apply").All()
+			if tt.wantIgnored == nil {
+				if len(ignored) != 0 {
+					t.Errorf("restart warning logged for %v", ignored[0].ContextMap()["fields"])
+				}
+				if !reflect.DeepEqual(s.config, cfg) {
+					t.Errorf("config = %+v, want %+v", s.config, cfg)
+				}
+				return
+			}
+			if len(ignored) != 1 || fmt.Sprint(ignored[0].ContextMap()["fields"]) != fmt.Sprint(tt.wantIgnored) {
+				t.Errorf("restart warnings = %v, want one for %v", ignored, tt.wantIgnored)
+			}
+			// The ignored fields keep their old values
+			want := cfg
+			want.PortRanges, want.Concurrency = base.PortRanges, base.Concurrency
+			if !reflect.DeepEqual(s.config, want) {
+				t.Errorf("config = %+v, want %+v", s.config, want)
+			}
+		})
+	}
+}
+
+// Run with -race: ad-hoc scans read the config while Reload rewrites it.
+func TestReloadDuringAdHocScan(t *testing.T) {
+	s := newTestScanner(t, config.ScannerConfig{Timeout: 1000, RateLimit: 1000000, PortRanges: []string{"22,80,443"}})
+	s.dial = func(_, _ string, _ time.Duration) (net.Conn, error) {
+		return nil, &net.OpError{Op: "dial", Net: "tcp", Err: syscall.ECONNREFUSED}
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		cfg := s.config
+		for i := 0; i < 200; i++ {
+			cfg.Timeout = 500 + i
+			cfg.PortTimeouts = map[int]int{22: 100 + i}
+			cfg.ExcludeIPs = []string{fmt.Sprintf("192.0.2.%d", 100+i%2)}
+			if _, err := s.Reload(cfg); err != nil {
+				t.Errorf("Reload() error = %v", err)
+				return
+			}
+		}
+	}()
+
+	for {
+		select {
+		case <-done:
+			return
+		default:
+		}
+		if _, err := s.ScanTargetContext(context.Background(), "192.0.2.10"); err != nil {
+			t.Fatalf("ScanTargetContext() error = %v", err)
+		}
+	}
+}
+
+func TestReloadAdjustsSessionLimiters(t *testing.T) {
+	s := newTestScanner(t, config.ScannerConfig{RateLimit: 100, RateBurst: 1})
+	s.limiter = newProbeLimiter(100, 1)
+
+	inherited := s.newSession("inherited")
+	requested := s.newSession("requested")
+	requested.request.RateLimitPPS = 5
+	requested.limiter.SetLimit(5)
+	s.sessions[inherited.scanID] = inherited
+	s.sessions[requested.scanID] = requested
+
+	cfg := s.config
+	cfg.RateLimit, cfg.RateBurst = 10, 20
+	if _, err := s.Reload(cfg); err != nil {
+		t.Fatalf("Reload() error = %v", err)
+	}
+
+	tests := []struct {
+		name      string
+		limiter   *rate.Limiter
+		wantLimit rate.Limit
+	}{
+		{name: "root", limiter: s.limiter, wantLimit: 10},
+		{name: "session", limiter: inherited.limiter, wantLimit: 10},
+		{name: "session with requested rate", limiter: requested.limiter, wantLimit: 5},
+	}
+	for _, tt := range tests {
+		if got := tt.limiter.Limit(); got != tt.wantLimit {
+			t.Errorf("%s: limit = %v, want %v", tt.name, got, tt.wantLimit)
+		}
+		if got := tt.limiter.Burst(); got != 20 {
+			t.Errorf("%s: burst = %d, want 20", tt.name, got)
+		}
+	}
+}
+
+// blockingDial returns a dialFunc recording each dialed host that refuses
+// every connection, holding the first dial until release is closed.
+// started is closed once the first dial is in.
+func blockingDial(started, release chan struct{}, mu *sync.Mutex, hosts map[string]bool) dialFunc {
+	var first sync.Once
+	return func(_, address string, _ time.Duration) (net.Conn, error) {
+		host, _, _ := net.SplitHostPort(address)
+		mu.Lock()
+		hosts[host] = true
+		mu.Unlock()
+		first.Do(func() {
+			close(started)
+			<-release
+		})
+		return nil, &net.OpError{Op: "dial", Net: "tcp", Err: syscall.ECONNREFUSED}
+	}
+}
+
+// Run with -race: the legacy scan reads exclusions and timeouts while
+// Reload rewrites them.
+func TestReloadExcludesDuringLegacyScan(t *testing.T) {
+	s := newTestScanner(t, config.ScannerConfig{
+		Subnets: []string{"10.9.0.0/27"}, PortRanges: []string{"80"}, Timeout: 1000, Concurrency: 1,
+	})
+	var mu sync.Mutex
+	hosts := make(map[string]bool)
+	started, release := make(chan struct{}), make(chan struct{})
+	s.dial = blockingDial(started, release, &mu, hosts)
+
+	if err := s.Start(); err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+	<-started
+
+	cfg := s.config
+	cfg.ExcludeSubnets = []string{"10.9.0.0/27"}
+	cfg.Timeout = 500
+	cfg.PortTimeouts = map[int]int{80: 200}
+	if _, err := s.Reload(cfg); err != nil {
+		t.Fatalf("Reload() error = %v", err)
+	}
+	close(release)
+	s.wg.Wait()
+
+	// Only the host in flight at the reload is scanned
+	if len(hosts) != 1 {
+		t.Errorf("dialed %d hosts, want 1: %v", len(hosts), hosts)
+	}
+}
+
+func TestReloadExcludesDuringAutonomousScan(t *testing.T) {
+	s := newTestScanner(t, config.ScannerConfig{MaxConcurrentScans: 1, Concurrency: 1, RateLimit: 100000})
+	var mu sync.Mutex
+	hosts := make(map[string]bool)
+	started, release := make(chan struct{}), make(chan struct{})
+	s.dial = blockingDial(started, release, &mu, hosts)
+
+	callbacks := httptest.NewServer(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}))
+	defer callbacks.Close()
+
+	if err := s.StartAutonomous(AutonomousScanConfig{
+		ScanID:      "5c1d9e2a-7b4f-4e83-9a6d-2f0b8c3e1d47",
+		Subnets:     []string{"10.9.0.0/27"},
+		PortRanges:  []string{"80"},
+		ProgressURL: callbacks.URL,
+		CompleteURL: callbacks.URL,
+	}); err != nil {
+		t.Fatalf("StartAutonomous() error = %v", err)
+	}
+	session := s.lastSession
+	<-started
+
+	cfg := s.config
+	cfg.ExcludeSubnets = []string{"10.9.0.0/27"}
+	if _, err := s.Reload(cfg); err != nil {
+		t.Fatalf("Reload() error = %v", err)
+	}
+	close(release)
+	select {
+	case <-session.done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("scan did not finish")
+	}
+
+	// The host in flight and those already queued for the one worker are
+	// scanned; the rest of the subnet is skipped
+	if len(hosts) > 4 {
+		t.Errorf("dialed %d hosts after excluding the subnet, want at most 4: %v", len(hosts), hosts)
+	}
+}
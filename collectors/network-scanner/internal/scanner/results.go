@@ -0,0 +1,296 @@
+package scanner
+
+import (
+	"container/list"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Status of a retained scan until it finishes; afterwards the completion
+// status (completed, cancelled or failed).
+const scanStatusRunning = "running"
+
+// ResultStore retains published results per scan ID for the results API.
+// Implementations must be safe for concurrent use. The default keeps
+// results in memory; SetResultStore installs another.
+type ResultStore interface {
+	// Start begins retaining results for scanID.
+	Start(scanID string)
+	// Add records a published result. Results for a scan that wasn't
+	// started, or was since evicted, are dropped.
+	Add(scanID string, result ScanResult)
+	// Finish records a scan's final status.
+	Finish(scanID, status string)
+	// Page returns up to limit results from offset (all of them when limit
+	// is 0) and the scan's total. It reports false for unknown or evicted
+	// scans.
+	Page(scanID string, offset, limit int) ([]ScanResult, int, bool)
+	// Scans lists the retained scans, most recently started first.
+	Scans() []ScanRecord
+}
+
+// ScanRecord summarises a retained scan.
+type ScanRecord struct {
+	ScanID      string
+	Status      string
+	StartedAt   time.Time
+	FinishedAt  time.Time // zero while running
+	ResultCount int
+	Truncated   bool // results beyond the per-scan cap were dropped
+}
+
+// retainedScan is the results buffer for a single scan. It has its own lock
+// so readers of one scan never block workers writing to another.
+type retainedScan struct {
+	mu         sync.RWMutex
+	id         string
+	status     string
+	startedAt  time.Time
+	finishedAt time.Time
+	results    []ScanResult
+	maxResults int // 0 = unbounded
+	truncated  bool
+}
+
+// add appends result unless the scan already holds maxResults.
+func (r *retainedScan) add(result ScanResult) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.maxResults > 0 && len(r.results) >= r.maxResults {
+		r.truncated = true
+		return
+	}
+	r.results = append(r.results, result)
+}
+
+// page returns a copy of a window of the results so callers can't observe
+// later appends or mutate the buffer.
+func (r *retainedScan) page(offset, limit int) ([]ScanResult, int) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	total := len(r.results)
+	if offset > total {
+		offset = total
+	}
+	end := total
+	if limit > 0 && offset+limit < total {
+		end = offset + limit
+	}
+
+	out := make([]ScanResult, end-offset)
+	copy(out, r.results[offset:end])
+	return out, total
+}
+
+func (r *retainedScan) record() ScanRecord {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return ScanRecord{
+		ScanID:      r.id,
+		Status:      r.status,
+		StartedAt:   r.startedAt,
+		FinishedAt:  r.finishedAt,
+		ResultCount: len(r.results),
+		Truncated:   r.truncated,
+	}
+}
+
+func (r *retainedScan) finished() bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return !r.finishedAt.IsZero()
+}
+
+// finishedBefore reports whether the scan finished before cutoff.
+func (r *retainedScan) finishedBefore(cutoff time.Time) bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return !r.finishedAt.IsZero() && r.finishedAt.Before(cutoff)
+}
+
+// memoryResultStore keeps up to maxScans scans in memory, evicting the
+// least recently used finished scan beyond that, and drops finished scans
+// once retention has passed. Running scans are never evicted, so the store
+// may briefly hold more than maxScans while they run. The store-level lock
+// only guards the index: Add takes it shared, and per-scan buffers carry
+// their own locks.
+type memoryResultStore struct {
+	mu         sync.RWMutex
+	scans      map[string]*list.Element // of *retainedScan
+	lru        *list.List               // most recently used first
+	maxScans   int
+	maxResults int           // per scan; 0 = unbounded
+	retention  time.Duration // 0 = keep until evicted
+}
+
+// NewMemoryResultStore returns an in-memory ResultStore holding at most
+// maxScans scans of up to maxResults results each (0 = unbounded), each
+// kept for retention after it finishes.
+func NewMemoryResultStore(maxScans, maxResults int, retention time.Duration) ResultStore {
+	if maxScans < 1 {
+		maxScans = 1
+	}
+	return &memoryResultStore{
+		scans:      make(map[string]*list.Element),
+		lru:        list.New(),
+		maxScans:   maxScans,
+		maxResults: maxResults,
+		retention:  retention,
+	}
+}
+
+// Start begins retaining results for scanID.
+func (rs *memoryResultStore) Start(scanID string) {
+	rs.scan(scanID)
+}
+
+// Add records a published result for a started scan.
+func (rs *memoryResultStore) Add(scanID string, result ScanResult) {
+	rs.mu.RLock()
+	elem, ok := rs.scans[scanID]
+	rs.mu.RUnlock()
+	if !ok {
+		return
+	}
+	elem.Value.(*retainedScan).add(result)
+}
+
+// Finish records a scan's final status, starting its retention window.
+func (rs *memoryResultStore) Finish(scanID, status string) {
+	rs.mu.RLock()
+	elem, ok := rs.scans[scanID]
+	rs.mu.RUnlock()
+	if !ok {
+		return
+	}
+
+	scan := elem.Value.(*retainedScan)
+	scan.mu.Lock()
+	scan.status = status
+	scan.finishedAt = time.Now()
+	scan.mu.Unlock()
+}
+
+// Page returns a window of a scan's results and its total.
+func (rs *memoryResultStore) Page(scanID string, offset, limit int) ([]ScanResult, int, bool) {
+	rs.mu.Lock()
+	rs.expire()
+	elem, ok := rs.scans[scanID]
+	if ok {
+		rs.lru.MoveToFront(elem)
+	}
+	rs.mu.Unlock()
+	if !ok {
+		return nil, 0, false
+	}
+
+	results, total := elem.Value.(*retainedScan).page(offset, limit)
+	return results, total, true
+}
+
+// Scans lists the retained scans, most recently started first.
+func (rs *memoryResultStore) Scans() []ScanRecord {
+	rs.mu.Lock()
+	rs.expire()
+	scans := make([]*retainedScan, 0, len(rs.scans))
+	for elem := rs.lru.Front(); elem != nil; elem = elem.Next() {
+		scans = append(scans, elem.Value.(*retainedScan))
+	}
+	rs.mu.Unlock()
+
+	records := make([]ScanRecord, 0, len(scans))
+	for _, scan := range scans {
+		records = append(records, scan.record())
+	}
+	sort.Slice(records, func(i, j int) bool {
+		return records[i].StartedAt.After(records[j].StartedAt)
+	})
+	return records
+}
+
+// scan returns the buffer for scanID, creating it if needed and marking it
+// most recently used.
+func (rs *memoryResultStore) scan(scanID string) *retainedScan {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+
+	if elem, ok := rs.scans[scanID]; ok {
+		rs.lru.MoveToFront(elem)
+		return elem.Value.(*retainedScan)
+	}
+
+	rs.expire()
+	scan := &retainedScan{id: scanID, status: scanStatusRunning, startedAt: time.Now(), maxResults: rs.maxResults}
+	rs.scans[scanID] = rs.lru.PushFront(scan)
+	for len(rs.scans) > rs.maxScans {
+		elem := rs.evictionCandidate()
+		if elem == nil {
+			break
+		}
+		rs.remove(elem)
+	}
+	return scan
+}
+
+// evictionCandidate returns the least recently used finished scan, or nil
+// if all are running. Callers must hold mu.
+func (rs *memoryResultStore) evictionCandidate() *list.Element {
+	for elem := rs.lru.Back(); elem != nil; elem = elem.Prev() {
+		if elem.Value.(*retainedScan).finished() {
+			return elem
+		}
+	}
+	return nil
+}
+
+// expire drops finished scans older than the retention window. Callers
+// must hold mu.
+func (rs *memoryResultStore) expire() {
+	if rs.retention <= 0 {
+		return
+	}
+	cutoff := time.Now().Add(-rs.retention)
+	for elem := rs.lru.Back(); elem != nil; {
+		prev := elem.Prev()
+		if elem.Value.(*retainedScan).finishedBefore(cutoff) {
+			rs.remove(elem)
+		}
+		elem = prev
+	}
+}
+
+// remove drops a scan. Callers must hold mu.
+func (rs *memoryResultStore) remove(elem *list.Element) {
+	rs.lru.Remove(elem)
+	delete(rs.scans, elem.Value.(*retainedScan).id)
+}
+
+// SetResultStore replaces the in-memory result store, e.g. with one backed
+// by a database. Call it before starting scans.
+func (s *Scanner) SetResultStore(store ResultStore) {
+	s.results = store
+}
+
+// Results returns a consistent snapshot of the results published so far for
+// scanID. The second return value is false if the scan is unknown or has
+// been evicted.
+func (s *Scanner) Results(scanID string) ([]ScanResult, bool) {
+	results, _, ok := s.results.Page(scanID, 0, 0)
+	return results, ok
+}
+
+// ResultsPage returns up to limit results for scanID starting at offset,
+// together with the total number retained.
+func (s *Scanner) ResultsPage(scanID string, offset, limit int) ([]ScanResult, int, bool) {
+	return s.results.Page(scanID, offset, limit)
+}
+
+// RecentScans lists the scans whose results are retained, most recently
+// started first.
+func (s *Scanner) RecentScans() []ScanRecord {
+	return s.results.Scans()
+}
@@ -0,0 +1,202 @@
+package scanner
+
+import (
+	"fmt"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aiforce-discovery-agent/collectors/network-scanner/internal/config"
+)
+
+func TestMemoryResultStorePage(t *testing.T) {
+	rs := NewMemoryResultStore(10, 0, time.Hour)
+	rs.Start("scan")
+	for port := 1; port <= 5; port++ {
+		rs.Add("scan", ScanResult{IP: "10.0.0.1", Port: port})
+	}
+
+	tests := []struct {
+		name          string
+		offset, limit int
+		wantPorts     []int
+	}{
+		{name: "all", wantPorts: []int{1, 2, 3, 4, 5}},
+		{name: "first page", limit: 2, wantPorts: []int{1, 2}},
+		{name: "middle page", offset: 2, limit: 2, wantPorts: []int{3, 4}},
+		{name: "last page", offset: 4, limit: 2, wantPorts: []int{5}},
+		{name: "past the end", offset: 9, limit: 2, wantPorts: []int{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			results, total, ok := rs.Page("scan", tt.offset, tt.limit)
+			if !ok || total != 5 {
+				t.Fatalf("Page() total = %d, ok = %v, want 5, true", total, ok)
+			}
+			ports := make([]int, len(results))
+			for i, result := range results {
+				ports[i] = result.Port
+			}
+			if fmt.Sprint(ports) != fmt.Sprint(tt.wantPorts) {
+				t.Errorf("Page() ports = %v, want %v", ports, tt.wantPorts)
+			}
+		})
+	}
+
+	if _, _, ok := rs.Page("unknown", 0, 0); ok {
+		t.Error("Page() found an unknown scan")
+	}
+}
+
+func TestMemoryResultStoreEviction(t *testing.T) {
+	tests := []struct {
+		name        string
+		finished    []string
+		wantKept    []string
+		wantEvicted []string
+	}{
+		{name: "least recently used finished scan", finished: []string{"a", "b"}, wantKept: []string{"b", "c"}, wantEvicted: []string{"a"}},
+		{name: "running scans kept", finished: []string{"b"}, wantKept: []string{"a", "c"}, wantEvicted: []string{"b"}},
+		{name: "all running", wantKept: []string{"a", "b", "c"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rs := NewMemoryResultStore(2, 0, 0)
+			rs.Start("a")
+			rs.Start("b")
+			for _, id := range tt.finished {
+				rs.Finish(id, "completed")
+			}
+			rs.Start("c")
+
+			for _, id := range tt.wantKept {
+				if _, _, ok := rs.Page(id, 0, 0); !ok {
+					t.Errorf("scan %s evicted", id)
+				}
+			}
+			for _, id := range tt.wantEvicted {
+				if _, _, ok := rs.Page(id, 0, 0); ok {
+					t.Errorf("scan %s kept", id)
+				}
+			}
+		})
+	}
+}
+
+func TestMemoryResultStoreAdd(t *testing.T) {
+	rs := NewMemoryResultStore(10, 3, time.Hour)
+	rs.Start("scan")
+	for port := 1; port <= 5; port++ {
+		rs.Add("scan", ScanResult{Port: port})
+	}
+	rs.Add("never started", ScanResult{Port: 1})
+
+	if _, total, _ := rs.Page("scan", 0, 0); total != 3 {
+		t.Errorf("retained %d results, want the cap of 3", total)
+	}
+	if records := rs.Scans(); len(records) != 1 || !records[0].Truncated || records[0].ResultCount != 3 {
+		t.Errorf("Scans() = %+v, want one truncated scan of 3 results", records)
+	}
+	if _, _, ok := rs.Page("never started", 0, 0); ok {
+		t.Error("Add() created a scan that was never started")
+	}
+}
+
+func TestMemoryResultStoreExpiry(t *testing.T) {
+	rs := NewMemoryResultStore(10, 0, time.Millisecond)
+	rs.Start("done")
+	rs.Start("running")
+	rs.Finish("done", "completed")
+	time.Sleep(5 * time.Millisecond)
+
+	if _, _, ok := rs.Page("done", 0, 0); ok {
+		t.Error("finished scan kept past retention")
+	}
+	if _, _, ok := rs.Page("running", 0, 0); !ok {
+		t.Error("running scan expired")
+	}
+}
+
+func TestMemoryResultStorePageIsSnapshot(t *testing.T) {
+	rs := NewMemoryResultStore(10, 0, time.Hour)
+	rs.Start("scan")
+	rs.Add("scan", ScanResult{Port: 22})
+
+	page, _, _ := rs.Page("scan", 0, 0)
+	rs.Add("scan", ScanResult{Port: 80})
+	page[0].Port = 443
+
+	if len(page) != 1 {
+		t.Errorf("page grew to %d results after a later Add", len(page))
+	}
+	if again, _, _ := rs.Page("scan", 0, 1); again[0].Port != 22 {
+		t.Errorf("stored result changed to port %d through a returned page", again[0].Port)
+	}
+}
+
+// Run with -race: workers add to several scans while the API reads them.
+func TestMemoryResultStoreConcurrent(t *testing.T) {
+	rs := NewMemoryResultStore(2, 100, time.Hour)
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		id := fmt.Sprintf("scan-%d", i)
+		rs.Start(id)
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			for port := 0; port < 200; port++ {
+				rs.Add(id, ScanResult{Port: port})
+			}
+			rs.Finish(id, "completed")
+		}()
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 50; j++ {
+				rs.Page(id, 0, 10)
+				rs.Scans()
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func TestStreamingOnlyRetainsNothing(t *testing.T) {
+	for _, streaming := range []bool{false, true} {
+		t.Run(fmt.Sprintf("streaming_only=%v", streaming), func(t *testing.T) {
+			s := newTestScanner(t, config.ScannerConfig{MaxConcurrentScans: 1, RateLimit: 100000, StreamingOnly: streaming})
+			var dials int32
+			s.dial = pipeDial(&dials, func(net.Conn) {})
+			pub := &recordingPublisher{}
+			s.publisher = pub
+
+			scanID := "3e4f5a6b-7c8d-4e9f-a0b1-c2d3e4f5a6b7"
+			completion := runAutonomous(t, s, AutonomousScanConfig{
+				ScanID: scanID, Targets: []string{"192.0.2.10", "192.0.2.11"}, PortRanges: []string{"80"},
+			})
+
+			// Discoveries are published and counted either way
+			if len(pub.services) != 2 || completion.DiscoveryCount != 2 {
+				t.Errorf("published %d, discovery count %d; want 2 and 2", len(pub.services), completion.DiscoveryCount)
+			}
+
+			results, ok := s.Results(scanID)
+			breakdowns := len(completion.Summary.TopServices) + len(completion.Summary.Subnets)
+			if streaming {
+				if ok || len(results) != 0 {
+					t.Errorf("retained %d results for a streaming-only scan", len(results))
+				}
+				if breakdowns != 0 {
+					t.Errorf("summary breakdowns = %+v, %+v; want none", completion.Summary.TopServices, completion.Summary.Subnets)
+				}
+				return
+			}
+			if !ok || len(results) != 2 {
+				t.Errorf("retained %d results, want 2", len(results))
+			}
+			if breakdowns == 0 {
+				t.Error("summary has no breakdowns")
+			}
+		})
+	}
+}
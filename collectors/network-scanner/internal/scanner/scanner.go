@@ -3,52 +3,293 @@ package scanner
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"net"
+	"sort"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/aiforce-discovery-agent/collectors/network-scanner/internal/callback"
 	"github.com/aiforce-discovery-agent/collectors/network-scanner/internal/config"
 	"github.com/aiforce-discovery-agent/collectors/network-scanner/internal/publisher"
+	"github.com/aiforce-discovery-agent/collectors/network-scanner/internal/secrets"
+	"github.com/google/uuid"
 	"go.uber.org/zap"
 	"golang.org/x/time/rate"
 )
 
-// Scanner performs network discovery operations.
+// Scanner performs network discovery operations. The Scanner returned by
+// New runs legacy scans and ad-hoc target scans itself; each autonomous
+// scan runs on a session Scanner sharing its publisher connection,
+// fingerprints and caches but with its own config, context, rate limiter,
+// reporter and counters.
 type Scanner struct {
-	config        config.ScannerConfig
-	publisher     *publisher.Publisher
-	logger        *zap.SugaredLogger
-	limiter       *rate.Limiter
-	fingerprinter *Fingerprinter
-	ctx           context.Context
-	cancel        context.CancelFunc
-	wg            sync.WaitGroup
-	running       bool
-	mu            sync.RWMutex
+	config    config.ScannerConfig
+	publisher publisher.EventPublisher
+	logger    *zap.SugaredLogger
+	limiter   *rate.Limiter
+	// publishLimiter paces discovery egress independently of scan speed;
+	// nil when PublishIntervalMS is unset
+	publishLimiter *rate.Limiter
+	fingerprinter  *Fingerprinter
+	cloudDetector  *CloudDetector
+	ctx            context.Context
+	cancel         context.CancelFunc
+
+	// feedCtx stops feeding new hosts to workers. It is derived from ctx,
+	// so cancelling ctx also stops feeding; cancelling only feedCtx lets
+	// in-flight hosts finish (graceful cancellation).
+	feedCtx  context.Context
+	stopFeed context.CancelFunc
+
+	wg      sync.WaitGroup
+	running bool
+	mu      sync.RWMutex
 
 	// ADR-007: Autonomous scan support
 	reporter *callback.Reporter
+
+	// stats collects per-probe statistics for the active autonomous scan
+	stats atomic.Pointer[scanStats]
+
+	// errorReporter records coded errors for the active autonomous scan's
+	// completion callback; swapped out at completion like stats
+	errorReporter atomic.Pointer[callback.Reporter]
+
+	// results retains published results per scan ID for the results API
+	results ResultStore
+
+	// knownAssets are ip:port pairs already in the inventory
+	knownAssets knownAssets
+
+	// randSeed seeds scan order randomisation (RandomSeed, or the clock)
+	randSeed int64
+
+	// ledger records active scan IDs across processes; nil when disabled
+	ledger *scanLedger
+
+	// checkpoints persists autonomous scan progress; nil when disabled
+	checkpoints CheckpointStore
+
+	// schedule starts recurring scans; nil unless Schedule is configured.
+	// Only set on the root Scanner.
+	schedule *scanSchedule
+
+	// progressHub streams live progress to subscribers; progressKick asks
+	// the session's progress stream to send an update and lastProgress is
+	// what it last sent
+	progressHub  *progressHub
+	progressKick chan struct{}
+	lastProgress atomic.Pointer[progressKey]
+
+	// secrets supplies credentials for authenticated probes; nil unless
+	// AuthenticatedProbes is enabled
+	secrets secrets.Provider
+
+	// pingMode is the host liveness check used when EnablePing is set
+	pingMode pingMode
+
+	// excludeNets are the parsed ExcludeSubnets; excludeIPs holds
+	// ExcludeIPs in canonical form for O(1) lookups
+	excludeNets []*net.IPNet
+	excludeIPs  map[string]struct{}
+
+	// reloadMu guards what Reload changes under a running scan: the
+	// exclusions, fingerprinter, config.Timeout and config.PortTimeouts.
+	// It is never held while taking another lock.
+	reloadMu sync.RWMutex
+
+	// Live progress of the current autonomous scan. scannedIPs is updated
+	// atomically by feeders; the rest is guarded by mu.
+	scanID     string
+	phase      string
+	startedAt  time.Time
+	totalIPs   int64
+	scannedIPs int64
+
+	// consecutivePublishFailures counts publish failures since the last
+	// success; abortErr is set when the scan is aborted for exceeding
+	// MaxConsecutivePublishFailures.
+	consecutivePublishFailures int64
+	abortMu                    sync.Mutex
+	abortErr                   error
+
+	// hostLimiters caps the probe rate per host; nil unless MaxPPSPerHost
+	// is set. Shared with sessions.
+	hostLimiters *hostLimiters
+
+	// dial opens probe connections (net.DialTimeout); probes call it
+	// through dialProbe or dialWithRetry so every dial is rate limited
+	// and tests can substitute it
+	dial dialFunc
+
+	// fdPausedUntil (Unix nanos) holds all dials back after the process
+	// runs out of file descriptors; shared with sessions
+	fdPausedUntil *atomic.Int64
+
+	// Active autonomous scan sessions by scan ID and the most recently
+	// started one (kept for status once finished), guarded by mu. Only set
+	// on the root Scanner; parent links a session back to it.
+	sessions    map[string]*Scanner
+	lastSession *Scanner
+	parent      *Scanner
+
+	// Checkpoint state of an autonomous scan: the request it runs, the
+	// progress it resumed from, per-source progress (guarded by
+	// progressMu) and whether a stop kept the checkpoint for resuming.
+	request        AutonomousScanConfig
+	resumeFrom     map[string]SourceProgress
+	progressMu     sync.Mutex
+	progress       map[string]*sourceProgress
+	keepCheckpoint bool
+
+	// interrupted is set (under mu) when a shutdown drains the scan; done
+	// is closed once the session has finished and sent its completion
+	interrupted bool
+	done        chan struct{}
+
+	// draining is set (under mu) on the root Scanner once Drain starts, so
+	// no scan can start after the set of scans to drain is taken
+	draining bool
+
+	// stopHeartbeats stops the session's heartbeat callbacks
+	stopHeartbeats func()
+
+	// Final values of the last finished scan, once its reporter is detached
+	finishedAt       time.Time
+	finalDiscoveries int
 }
 
 // New creates a new Scanner instance.
-func New(cfg config.ScannerConfig, pub *publisher.Publisher, logger *zap.SugaredLogger) *Scanner {
+func New(cfg config.ScannerConfig, pub publisher.EventPublisher, logger *zap.SugaredLogger) (*Scanner, error) {
+	excludeNets, err := parseExcludeSubnets(cfg.ExcludeSubnets)
+	if err != nil {
+		return nil, err
+	}
+	excludeIPs, err := parseExcludeIPs(cfg.ExcludeIPs)
+	if err != nil {
+		return nil, err
+	}
+
+	var provider secrets.Provider
+	if cfg.AuthenticatedProbes {
+		if provider, err = secrets.NewProvider(cfg.SecretsSource, cfg.SecretsDir); err != nil {
+			return nil, err
+		}
+		logger.Infow("Authenticated probes enabled", "secrets_source", cfg.SecretsSource)
+	}
+
+	if err := validateActiveProbes(cfg.ActiveProbes); err != nil {
+		return nil, err
+	}
+
+	fingerprinter := NewFingerprinter()
+	if cfg.SignatureFile != "" {
+		if err := fingerprinter.LoadSignatureFile(cfg.SignatureFile, logger); err != nil {
+			return nil, err
+		}
+	}
+
+	var ledger *scanLedger
+	if cfg.ScanIDLedgerDir != "" {
+		staleAfter := time.Duration(cfg.ScanIDLedgerStaleHours) * time.Hour
+		if ledger, err = newScanLedger(cfg.ScanIDLedgerDir, staleAfter); err != nil {
+			return nil, err
+		}
+	}
+
+	var checkpoints CheckpointStore
+	if cfg.CheckpointDir != "" {
+		if checkpoints, err = NewFileCheckpointStore(cfg.CheckpointDir); err != nil {
+			return nil, err
+		}
+	}
+
 	ctx, cancel := context.WithCancel(context.Background())
+	feedCtx, stopFeed := context.WithCancel(ctx)
+
+	var known knownAssets
+	if cfg.KnownAssetsSource != "" {
+		if known, err = loadKnownAssets(cfg.KnownAssetsSource); err != nil {
+			logger.Warnw("Failed to load known assets, publishing all discoveries",
+				"source", cfg.KnownAssetsSource, "error", err)
+		} else {
+			logger.Infow("Loaded known assets", "count", len(known), "mode", cfg.KnownAssetsMode)
+		}
+	}
+
+	var mode pingMode
+	if cfg.EnablePing {
+		mode = detectPingMode()
+		logger.Infow("Host liveness pre-check enabled", "mode", mode)
+	}
+
+	seed := cfg.RandomSeed
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
 
-	return &Scanner{
-		config:        cfg,
-		publisher:     pub,
-		logger:        logger,
-		limiter:       rate.NewLimiter(rate.Limit(cfg.RateLimit), cfg.RateLimit),
-		fingerprinter: NewFingerprinter(),
-		ctx:           ctx,
-		cancel:        cancel,
+	var publishLimiter *rate.Limiter
+	if cfg.PublishIntervalMS > 0 {
+		publishLimiter = rate.NewLimiter(rate.Every(time.Duration(cfg.PublishIntervalMS)*time.Millisecond), 1)
 	}
+
+	cloudDetector := NewCloudDetector()
+	if cfg.CloudRangesRefreshHours > 0 {
+		cloudDetector.SetSources(CloudRangeSources{
+			AWS:   cfg.CloudRangesAWSURL,
+			Azure: cfg.CloudRangesAzureURL,
+			GCP:   cfg.CloudRangesGCPURL,
+		})
+		cloudDetector.StartRefresh(time.Duration(cfg.CloudRangesRefreshHours)*time.Hour, logger)
+	}
+
+	s := &Scanner{
+		config:         cfg,
+		publisher:      pub,
+		logger:         logger,
+		limiter:        newProbeLimiter(cfg.RateLimit, cfg.RateBurst),
+		publishLimiter: publishLimiter,
+		hostLimiters:   newHostLimiters(cfg.MaxPPSPerHost),
+		dial:           net.DialTimeout,
+		fdPausedUntil:  new(atomic.Int64),
+		fingerprinter:  fingerprinter,
+		cloudDetector:  cloudDetector,
+		results:        NewMemoryResultStore(cfg.ResultRetentionScans, cfg.ResultRetentionMaxResults, time.Duration(cfg.ResultRetentionMinutes)*time.Minute),
+		knownAssets:    known,
+		pingMode:       mode,
+		ledger:         ledger,
+		checkpoints:    checkpoints,
+		progressHub:    newProgressHub(cfg.MaxProgressSubscribers),
+		secrets:        provider,
+		randSeed:       seed,
+		excludeNets:    excludeNets,
+		excludeIPs:     excludeIPs,
+		ctx:            ctx,
+		cancel:         cancel,
+		feedCtx:        feedCtx,
+		stopFeed:       stopFeed,
+		sessions:       make(map[string]*Scanner),
+	}
+
+	if cfg.Schedule.Cron != "" {
+		if err := s.startSchedule(); err != nil {
+			return nil, err
+		}
+	}
+	return s, nil
 }
 
 // Start begins scanning the configured subnets.
 func (s *Scanner) Start() error {
 	s.mu.Lock()
-	if s.running {
+	if s.draining {
+		s.mu.Unlock()
+		return ErrDraining
+	}
+	if s.running || len(s.sessions) > 0 {
 		s.mu.Unlock()
 		return fmt.Errorf("scanner already running")
 	}
@@ -62,11 +303,69 @@ func (s *Scanner) Start() error {
 		go s.scanSubnet(subnet)
 	}
 
+	if len(s.config.Targets) > 0 {
+		s.wg.Add(1)
+		go s.scanTargets(s.config.Targets)
+	}
+
 	return nil
 }
 
-// Stop gracefully stops the scanner.
-func (s *Scanner) Stop() {
+// Errors returned when looking up a running scan.
+var (
+	// ErrNoScanRunning is returned when no scan is running.
+	ErrNoScanRunning = errors.New("no scan running")
+	// ErrScanIDMismatch is returned when no running scan has the ID.
+	ErrScanIDMismatch = errors.New("no running scan has this scan ID")
+	// ErrScanIDRequired is returned when several scans are running and
+	// the request doesn't say which.
+	ErrScanIDRequired = errors.New("scan ID required while several scans are running")
+	// ErrTooManyScans is returned when MaxConcurrentScans are running.
+	ErrTooManyScans = errors.New("too many concurrent scans")
+	// ErrDraining is returned when a scan is started during shutdown.
+	ErrDraining = errors.New("scanner is shutting down")
+	// ErrNoSigningKey is returned when a scan would send its callbacks
+	// unsigned although SignCallbacks is on.
+	ErrNoSigningKey = errors.New("sign_callbacks requires a callback_signing_secret or a request API key")
+	// ErrUnknownCancelMode is returned by Cancel for a mode other than
+	// CancelImmediate or CancelGraceful.
+	ErrUnknownCancelMode = errors.New("unknown cancel mode")
+)
+
+// Stop gracefully stops the scan with the given ID, so a stale request
+// can't stop a newer scan. An empty scanID stops every running scan (e.g.
+// on shutdown).
+func (s *Scanner) Stop(scanID string) error {
+	if scanID != "" {
+		scan, err := s.findScan(scanID)
+		if err != nil {
+			return err
+		}
+		scan.stopScan()
+		return nil
+	}
+
+	s.mu.RLock()
+	scans := make([]*Scanner, 0, len(s.sessions)+1)
+	if s.running {
+		scans = append(scans, s)
+	}
+	for _, session := range s.sessions {
+		scans = append(scans, session)
+	}
+	s.mu.RUnlock()
+
+	if len(scans) == 0 {
+		return ErrNoScanRunning
+	}
+	for _, scan := range scans {
+		scan.stopScan()
+	}
+	return nil
+}
+
+// stopScan cancels this Scanner's own scan and waits for its workers.
+func (s *Scanner) stopScan() {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
@@ -77,13 +376,533 @@ func (s *Scanner) Stop() {
 	s.logger.Info("Stopping scanner")
 	s.cancel()
 	s.wg.Wait()
+	// Hosts aborted by the stop aren't marked done, so the checkpoint
+	// resumes from exactly where workers left off
+	if s.checkpoints != nil && s.reporter != nil {
+		s.saveCheckpoint(s.reporter)
+		s.keepCheckpoint = true
+	}
+	if err := s.publisher.Flush(); err != nil {
+		s.logger.Errorw("Failed to flush pending discoveries", "error", err)
+	}
 	s.running = false
 	s.logger.Info("Scanner stopped")
 }
 
-// IsRunning returns whether the scanner is currently running.
-func (s *Scanner) IsRunning() bool {
+// drainAbortWait bounds how long Drain waits for scans to report
+// completion after aborting hosts still in flight at the deadline.
+const drainAbortWait = 5 * time.Second
+
+// Drain stops every running scan for shutdown. No new hosts are started;
+// in-flight hosts have until ctx is done to finish and publish, after which
+// they are aborted. Autonomous scans complete with status "interrupted" and
+// keep their checkpoint for resuming. It returns ctx's error if the grace
+// period ran out. The scan schedule, if any, is stopped first, and scans
+// can no longer be started or resumed.
+func (s *Scanner) Drain(ctx context.Context) error {
+	s.stopSchedule()
+
+	s.mu.Lock()
+	s.draining = true
+	legacy := s.running
+	scans := make([]*Scanner, 0, len(s.sessions))
+	for _, session := range s.sessions {
+		scans = append(scans, session)
+	}
+	s.mu.Unlock()
+
+	if !legacy && len(scans) == 0 {
+		return ErrNoScanRunning
+	}
+
+	var wg sync.WaitGroup
+	if legacy {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			s.drainLegacy(ctx)
+		}()
+	}
+	for _, scan := range scans {
+		wg.Add(1)
+		go func(scan *Scanner) {
+			defer wg.Done()
+			scan.drainScan(ctx)
+		}(scan)
+	}
+	wg.Wait()
+	return ctx.Err()
+}
+
+// drainScan drains an autonomous scan session and waits for its
+// completion callback.
+func (s *Scanner) drainScan(ctx context.Context) {
+	s.mu.Lock()
+	if !s.running {
+		s.mu.Unlock()
+		return
+	}
+	s.interrupted = true
+	s.keepCheckpoint = true
+	if st := s.stats.Load(); st != nil {
+		st.setCancelMode(CancelGraceful)
+	}
+	s.stopFeed()
+	s.mu.Unlock()
+
+	s.logger.Info("Draining in-flight hosts before shutdown")
+	select {
+	case <-s.done:
+		return
+	case <-ctx.Done():
+	}
+
+	s.logger.Warn("Shutdown grace period expired, aborting in-flight hosts")
+	s.cancel()
+	select {
+	case <-s.done:
+	case <-time.After(drainAbortWait):
+		s.logger.Warn("Scan did not report completion before shutdown")
+	}
+}
+
+// drainLegacy drains the legacy scan, which has no completion callback.
+func (s *Scanner) drainLegacy(ctx context.Context) {
+	s.stopFeed()
+
+	finished := make(chan struct{})
+	go func() {
+		s.wg.Wait()
+		close(finished)
+	}()
+	select {
+	case <-finished:
+	case <-ctx.Done():
+		s.logger.Warn("Shutdown grace period expired, aborting in-flight hosts")
+		s.cancel()
+		<-finished
+	}
+
+	if err := s.publisher.Flush(); err != nil {
+		s.logger.Errorw("Failed to flush pending discoveries", "error", err)
+	}
+	s.mu.Lock()
+	s.running = false
+	s.mu.Unlock()
+}
+
+// findScan returns the Scanner running scanID. An empty scanID selects the
+// legacy scan or the only autonomous scan, if exactly one is running. The
+// root's lock is released before the result is used, so callers may lock
+// the session.
+func (s *Scanner) findScan(scanID string) (*Scanner, error) {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
-	return s.running
+
+	if !s.running && len(s.sessions) == 0 {
+		return nil, ErrNoScanRunning
+	}
+	if scanID == "" {
+		switch {
+		case s.running:
+			return s, nil
+		case len(s.sessions) > 1:
+			return nil, ErrScanIDRequired
+		}
+		for _, session := range s.sessions {
+			return session, nil
+		}
+	}
+	session, ok := s.sessions[scanID]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrScanIDMismatch, scanID)
+	}
+	return session, nil
+}
+
+// Cancellation modes accepted by Cancel.
+const (
+	// CancelImmediate aborts in-flight hosts; their partial results are dropped.
+	CancelImmediate = "immediate"
+	// CancelGraceful stops feeding new hosts but lets in-flight hosts finish
+	// and publish.
+	CancelGraceful = "graceful"
+)
+
+// Cancel stops the scan with the given ID (see findScan for an empty ID)
+// without blocking. In graceful mode no new hosts are started but
+// in-flight hosts finish and publish; in immediate mode in-flight work is
+// aborted. The completion callback reports the scan as cancelled along
+// with how many in-flight results were preserved or dropped.
+func (s *Scanner) Cancel(scanID, mode string) error {
+	if mode != CancelGraceful && mode != CancelImmediate {
+		return fmt.Errorf("%w %q", ErrUnknownCancelMode, mode)
+	}
+	scan, err := s.findScan(scanID)
+	if err != nil {
+		return err
+	}
+	return scan.cancelScan(mode)
+}
+
+func (s *Scanner) cancelScan(mode string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.running {
+		return ErrNoScanRunning
+	}
+
+	if st := s.stats.Load(); st != nil {
+		st.setCancelMode(mode)
+	}
+
+	switch mode {
+	case CancelGraceful:
+		s.logger.Infow("Cancelling scan gracefully, draining in-flight hosts")
+		s.stopFeed()
+	case CancelImmediate:
+		s.logger.Infow("Cancelling scan immediately")
+		s.cancel()
+	}
+
+	return nil
+}
+
+// loggerKey is the context key for the logger withLogger attaches.
+type loggerKey struct{}
+
+// withLogger returns ctx carrying logger, so the hosts scanned under ctx
+// log with its fields (the subnet) as well as the scan's.
+func withLogger(ctx context.Context, logger *zap.SugaredLogger) context.Context {
+	return context.WithValue(ctx, loggerKey{}, logger)
+}
+
+// log returns the logger ctx carries, or s.logger.
+func (s *Scanner) log(ctx context.Context) *zap.SugaredLogger {
+	if logger, ok := ctx.Value(loggerKey{}).(*zap.SugaredLogger); ok {
+		return logger
+	}
+	return s.logger
+}
+
+// newProbeLimiter returns the probe rate limiter. Burst is configured
+// separately from the rate so a high PPS doesn't allow a spike of that many
+// probes the moment a scan starts.
+func newProbeLimiter(pps, burst int) *rate.Limiter {
+	if burst < 1 {
+		burst = 1
+	}
+	return rate.NewLimiter(rate.Limit(pps), burst)
+}
+
+// publishResults publishes a host's publishable results, either one event
+// per service followed by a server event for the host, or, with
+// HostEvents, a single consolidated host event. Services carry the server
+// event's server_id. It returns the results that were published and how
+// many failed. Failures are logged with ctx's logger.
+func (s *Scanner) publishResults(ctx context.Context, results []ScanResult) ([]ScanResult, int) {
+	var publishable []ScanResult
+	serverID := uuid.New().String()
+	if len(results) > 0 && results[0].ServerID != "" {
+		serverID = results[0].ServerID // shared with the host's other addresses
+	}
+	for _, result := range results {
+		if s.shouldPublish(result) {
+			result.ServerID = serverID
+			publishable = append(publishable, result)
+		}
+	}
+	if len(publishable) == 0 {
+		return nil, 0
+	}
+
+	if s.config.HostEvents {
+		if err := s.publishHost(publishable); err != nil {
+			s.log(ctx).Errorw("Failed to publish host", "ip", publishable[0].IP, "error", err)
+			return nil, len(publishable)
+		}
+		return publishable, 0
+	}
+
+	published := publishable[:0]
+	failed := 0
+	for _, result := range publishable {
+		if err := s.publishService(result); err != nil {
+			failed++
+			s.log(ctx).Errorw("Failed to publish result", "error", err)
+			continue
+		}
+		published = append(published, result)
+	}
+
+	if len(published) > 0 {
+		server := s.serverData(publishable)
+		if err := s.publishPaced(func() error {
+			return s.publisher.PublishServerDiscovered(server)
+		}); err != nil {
+			s.log(ctx).Errorw("Failed to publish server", "ip", server.IPAddresses[0], "error", err)
+		}
+	}
+	return published, failed
+}
+
+// publishService publishes a single service discovery.
+func (s *Scanner) publishService(result ScanResult) error {
+	return s.publishPaced(func() error {
+		return s.publisher.PublishServiceDiscovered(result)
+	})
+}
+
+// publishHost publishes one event for a host with all its services nested.
+func (s *Scanner) publishHost(results []ScanResult) error {
+	server := s.serverData(results)
+	services := make([]interface{}, 0, len(results))
+	for _, result := range results {
+		services = append(services, result)
+	}
+
+	return s.publishPaced(func() error {
+		return s.publisher.PublishHostDiscovered(server, services)
+	})
+}
+
+// serverData aggregates a host's results into server event data: its open
+// ports, the OS guessed during the scan and cloud detection for the IP. A
+// hostname's results span all of its addresses; ports open on several of
+// them are listed once and the most confident OS guess is kept.
+func (s *Scanner) serverData(results []ScanResult) publisher.ServerDiscoveredData {
+	ip := results[0].IP
+	server := publisher.ServerDiscoveredData{
+		ServerID:    results[0].ServerID,
+		Hostname:    results[0].hostname,
+		IPAddresses: []string{ip},
+		OpenPorts:   make([]int, 0, len(results)),
+		Metadata:    make(map[string]interface{}),
+	}
+
+	seen := make(map[int]bool, len(results))
+	var guess OSGuess
+	for _, result := range results {
+		if !seen[result.Port] {
+			seen[result.Port] = true
+			server.OpenPorts = append(server.OpenPorts, result.Port)
+		}
+		server.Summary.Add(result.Port, result.Service)
+		if result.OS.Confidence > guess.Confidence {
+			guess = result.OS
+		}
+	}
+	sort.Ints(server.OpenPorts)
+
+	if guess.Confidence > 0 {
+		server.OS = &publisher.OSInfo{Name: guess.Name, Family: guess.Family}
+		server.Metadata["os_confidence"] = guess.Confidence
+	}
+
+	cloud := s.cloudDetector.Detect(ip)
+	if len(results[0].hostAddresses) > 1 {
+		server.IPAddresses = results[0].hostAddresses
+		cloud = s.cloudDetector.DetectMultiple(server.IPAddresses)
+	}
+	server.Metadata["cloud_provider"] = cloud.Provider
+	server.Metadata["hosting_model"] = cloud.HostingModel
+	if cloud.Region != "" {
+		server.Metadata["region"] = cloud.Region
+	}
+	if cloud.CDNFronted {
+		server.Metadata["cdn_fronted"] = true
+		server.Metadata["cdn"] = cloud.CDN
+	}
+
+	return server
+}
+
+// publishPaced runs publish, first waiting out the minimum inter-publish
+// interval when pacing is enabled so hosts with many open ports don't
+// publish in a burst.
+func (s *Scanner) publishPaced(publish func() error) error {
+	if s.publishLimiter != nil {
+		if err := s.publishLimiter.Wait(s.ctx); err != nil {
+			return err
+		}
+	}
+
+	if err := publish(); err != nil {
+		s.recordError(callback.ErrorPublishFailed, err)
+		failures := atomic.AddInt64(&s.consecutivePublishFailures, 1)
+		if limit := s.config.MaxConsecutivePublishFailures; limit > 0 && failures >= int64(limit) {
+			s.abort(fmt.Errorf("aborted after %d consecutive publish failures: %w", failures, err))
+		}
+		return err
+	}
+	atomic.StoreInt64(&s.consecutivePublishFailures, 0)
+	return nil
+}
+
+// recordError reports err under code in the active autonomous scan's
+// completion callback, if any.
+func (s *Scanner) recordError(code string, err error) {
+	if reporter := s.errorReporter.Load(); reporter != nil {
+		reporter.RecordError(code, err.Error())
+	}
+}
+
+// abort stops the current scan immediately because continuing would only
+// produce undeliverable results. The first reason is kept and reported.
+func (s *Scanner) abort(reason error) {
+	s.abortMu.Lock()
+	defer s.abortMu.Unlock()
+
+	if s.abortErr != nil {
+		return
+	}
+	s.abortErr = reason
+	s.logger.Errorw("Aborting scan", "reason", reason)
+	s.cancel()
+}
+
+// abortReason returns why the current scan was aborted, if it was.
+func (s *Scanner) abortReason() error {
+	s.abortMu.Lock()
+	defer s.abortMu.Unlock()
+	return s.abortErr
+}
+
+// AutoScanID reports whether autonomous scans without a caller-supplied
+// scan ID should get a generated one.
+func (s *Scanner) AutoScanID() bool {
+	return s.config.AutoScanID
+}
+
+// PublisherConnected reports whether discoveries can currently be
+// published to the message broker.
+func (s *Scanner) PublisherConnected() bool {
+	return s.publisher.IsConnected()
+}
+
+// MaxSubnetsPerScan returns the admission limit on subnets and targets,
+// counted together, in a single scan request. Zero or negative means
+// unlimited.
+func (s *Scanner) MaxSubnetsPerScan() int {
+	return s.config.MaxSubnetsPerScan
+}
+
+// etaMinProgress is the fraction of hosts that must be scanned before an ETA
+// is estimated; earlier estimates swing too wildly to be useful.
+const etaMinProgress = 0.05
+
+// ScanStatus is a point-in-time view of the current or most recent
+// autonomous scan.
+type ScanStatus struct {
+	Running      bool
+	ScanID       string
+	Phase        string
+	StartedAt    time.Time
+	ScannedHosts int64
+	TotalHosts   int64
+	OpenPorts    int
+	PercentDone  float64
+	Elapsed      time.Duration
+	ETA          time.Duration
+	ETAAvailable bool
+}
+
+// ScanStatus returns live progress for the most recently started
+// autonomous scan. While a legacy scan runs only Running is set.
+func (s *Scanner) ScanStatus() ScanStatus {
+	s.mu.RLock()
+	running, last := s.running, s.lastSession
+	s.mu.RUnlock()
+
+	if running || last == nil {
+		return ScanStatus{Running: running}
+	}
+	return last.status()
+}
+
+// ScanStatusByID returns live progress for a running scan, or for the most
+// recently started one after it finished. It reports false for other IDs.
+func (s *Scanner) ScanStatusByID(scanID string) (ScanStatus, bool) {
+	s.mu.RLock()
+	session, ok := s.sessions[scanID]
+	if !ok && s.lastSession != nil && s.lastSession.scanID == scanID {
+		session, ok = s.lastSession, true
+	}
+	s.mu.RUnlock()
+
+	if !ok {
+		return ScanStatus{}, false
+	}
+	return session.status(), true
+}
+
+// ActiveScanIDs returns the IDs of the running autonomous scans, sorted.
+func (s *Scanner) ActiveScanIDs() []string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	ids := make([]string, 0, len(s.sessions))
+	for id := range s.sessions {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// status returns a session's live progress, including an ETA derived from
+// the scan rate so far once enough hosts have been scanned.
+func (s *Scanner) status() ScanStatus {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	status := ScanStatus{
+		Running:      s.running,
+		ScanID:       s.scanID,
+		Phase:        s.phase,
+		StartedAt:    s.startedAt,
+		ScannedHosts: atomic.LoadInt64(&s.scannedIPs),
+		TotalHosts:   s.totalIPs,
+	}
+	if s.reporter != nil {
+		status.OpenPorts = s.reporter.GetDiscoveryCount()
+	} else {
+		status.OpenPorts = s.finalDiscoveries
+	}
+	if s.startedAt.IsZero() {
+		return status
+	}
+
+	status.Elapsed = time.Since(s.startedAt)
+	if !s.running {
+		status.Elapsed = s.finishedAt.Sub(s.startedAt)
+	}
+	if status.TotalHosts > 0 {
+		fraction := float64(status.ScannedHosts) / float64(status.TotalHosts)
+		if fraction > 1 {
+			fraction = 1
+		}
+		status.PercentDone = fraction * 100
+
+		if s.running && fraction >= etaMinProgress {
+			remaining := float64(status.Elapsed) * (1 - fraction) / fraction
+			status.ETA = time.Duration(remaining)
+			status.ETAAvailable = true
+		}
+	}
+
+	return status
+}
+
+// IsRunning returns whether the scan with the given ID is running. An
+// empty scanID asks whether any scan is running.
+func (s *Scanner) IsRunning(scanID string) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if scanID == "" {
+		return s.running || len(s.sessions) > 0
+	}
+	_, ok := s.sessions[scanID]
+	return ok
 }
@@ -0,0 +1,115 @@
+package scanner
+
+import (
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/robfig/cron/v3"
+)
+
+// scanSchedule starts an autonomous scan at every tick of a cron schedule.
+type scanSchedule struct {
+	schedule cron.Schedule
+	request  AutonomousScanConfig
+
+	mu   sync.Mutex
+	next time.Time
+
+	stop     chan struct{}
+	stopOnce sync.Once
+	done     chan struct{}
+}
+
+// startSchedule parses the configured schedule and starts running it.
+func (s *Scanner) startSchedule() error {
+	cfg := s.config.Schedule
+	schedule, err := cron.ParseStandard(cfg.Cron)
+	if err != nil {
+		return err
+	}
+
+	// Without its own subnets the schedule sweeps the configured ones
+	request := AutonomousScanConfig{Subnets: cfg.Subnets, PortRanges: cfg.PortRanges}
+	if len(request.Subnets) == 0 {
+		request.Subnets = s.config.Subnets
+		request.Targets = s.config.Targets
+	}
+
+	s.schedule = &scanSchedule{
+		schedule: schedule,
+		request:  request,
+		next:     schedule.Next(time.Now()),
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+	go s.runSchedule(s.schedule)
+	s.logger.Infow("Scan schedule enabled", "cron", cfg.Cron)
+	return nil
+}
+
+// runSchedule waits for each tick and starts a scan at it, until stopped.
+func (s *Scanner) runSchedule(sched *scanSchedule) {
+	defer close(sched.done)
+	for {
+		next := sched.schedule.Next(time.Now())
+		sched.mu.Lock()
+		sched.next = next
+		sched.mu.Unlock()
+
+		timer := time.NewTimer(time.Until(next))
+		select {
+		case <-sched.stop:
+			timer.Stop()
+			sched.mu.Lock()
+			sched.next = time.Time{}
+			sched.mu.Unlock()
+			return
+		case <-timer.C:
+		}
+		s.startScheduledScan(sched, next)
+	}
+}
+
+// startScheduledScan starts the scan due at tick, skipping it if any scan
+// is still running.
+func (s *Scanner) startScheduledScan(sched *scanSchedule, tick time.Time) {
+	s.mu.RLock()
+	busy := s.running || len(s.sessions) > 0
+	s.mu.RUnlock()
+	if busy {
+		s.logger.Infow("Skipping scheduled scan, a scan is already running", "tick", tick)
+		return
+	}
+
+	// A UUID like any other scan, so the API can stop or cancel it by ID;
+	// the schedule tick is logged to trace where it came from
+	request := sched.request
+	request.ScanID = uuid.New().String()
+	if err := s.StartAutonomous(request); err != nil {
+		s.logger.Warnw("Failed to start scheduled scan", "scan_id", request.ScanID, "tick", tick, "error", err)
+		return
+	}
+	s.logger.Infow("Started scheduled scan", "scan_id", request.ScanID, "tick", tick)
+}
+
+// stopSchedule stops the schedule, if any, and waits for it to exit. A
+// scan it already started is left running.
+func (s *Scanner) stopSchedule() {
+	if s.schedule == nil {
+		return
+	}
+	s.schedule.stopOnce.Do(func() { close(s.schedule.stop) })
+	<-s.schedule.done
+}
+
+// NextScheduledScan returns when the schedule starts its next scan. It
+// reports false when no schedule is configured.
+func (s *Scanner) NextScheduledScan() (time.Time, bool) {
+	if s.schedule == nil {
+		return time.Time{}, false
+	}
+	s.schedule.mu.Lock()
+	defer s.schedule.mu.Unlock()
+	return s.schedule.next, !s.schedule.next.IsZero()
+}
@@ -0,0 +1,42 @@
+package scanner
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/aiforce-discovery-agent/collectors/network-scanner/internal/config"
+)
+
+func TestScheduledScanIDIsUUID(t *testing.T) {
+	callbacks := httptest.NewServer(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}))
+	defer callbacks.Close()
+
+	s := newTestScanner(t, config.ScannerConfig{MaxConcurrentScans: 1, Concurrency: 1, ProgressIntervalSeconds: 1})
+	sched := &scanSchedule{request: AutonomousScanConfig{
+		ProgressURL: callbacks.URL,
+		CompleteURL: callbacks.URL,
+	}}
+
+	s.startScheduledScan(sched, time.Now())
+
+	s.mu.RLock()
+	session := s.lastSession
+	s.mu.RUnlock()
+	if session == nil {
+		t.Fatal("scheduled scan didn't start")
+	}
+	select {
+	case <-session.done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("scheduled scan didn't finish")
+	}
+
+	// The API validates scan IDs as UUIDs for stop and cancel
+	if _, err := uuid.Parse(session.scanID); err != nil {
+		t.Errorf("scan ID %q is not a UUID: %v", session.scanID, err)
+	}
+}
@@ -0,0 +1,92 @@
+package scanner
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+
+	"go.uber.org/zap"
+	"gopkg.in/yaml.v3"
+)
+
+// signatureFile is the on-disk format for custom fingerprint signatures.
+// YAML is a superset of JSON, so either format is accepted.
+type signatureFile struct {
+	Signatures []signatureEntry `yaml:"signatures"`
+}
+
+// signatureEntry describes one custom signature. VersionGroup and
+// ProductGroup are regex capture group indexes (0 = unused); a captured
+// product overrides the static Product.
+type signatureEntry struct {
+	Name         string `yaml:"name"`
+	Pattern      string `yaml:"pattern"`
+	Service      string `yaml:"service"`
+	Product      string `yaml:"product"`
+	VersionGroup int    `yaml:"version_group"`
+	ProductGroup int    `yaml:"product_group"`
+}
+
+// LoadSignatureFile adds signatures from a JSON or YAML file. They are tried
+// before the built-in signatures, so a custom entry wins when both match.
+// Entries with an invalid pattern or capture group are logged and skipped.
+func (f *Fingerprinter) LoadSignatureFile(path string, logger *zap.SugaredLogger) error {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read signature file: %w", err)
+	}
+
+	var file signatureFile
+	if err := yaml.Unmarshal(raw, &file); err != nil {
+		return fmt.Errorf("failed to parse signature file %s: %w", path, err)
+	}
+
+	custom := make([]signature, 0, len(file.Signatures))
+	for i, entry := range file.Signatures {
+		sig, err := entry.compile()
+		if err != nil {
+			logger.Warnw("Skipping invalid signature", "file", path, "index", i, "name", entry.Name, "error", err)
+			continue
+		}
+		custom = append(custom, sig)
+	}
+
+	f.signatures = append(custom, f.signatures...)
+	logger.Infow("Loaded custom signatures", "file", path, "count", len(custom))
+	return nil
+}
+
+// compile validates the entry and builds a signature from it.
+func (e signatureEntry) compile() (signature, error) {
+	if e.Pattern == "" || e.Service == "" {
+		return signature{}, fmt.Errorf("pattern and service are required")
+	}
+	pattern, err := regexp.Compile(e.Pattern)
+	if err != nil {
+		return signature{}, fmt.Errorf("invalid pattern: %w", err)
+	}
+	groups := pattern.NumSubexp()
+	if e.VersionGroup < 0 || e.VersionGroup > groups || e.ProductGroup < 0 || e.ProductGroup > groups {
+		return signature{}, fmt.Errorf("capture group out of range: pattern has %d groups", groups)
+	}
+
+	name := e.Name
+	if name == "" {
+		name = e.Service
+	}
+	return signature{
+		name:    name,
+		pattern: pattern,
+		service: e.Service,
+		extract: func(m []string) ServiceFingerprint {
+			fp := ServiceFingerprint{Name: e.Service, Product: e.Product}
+			if e.VersionGroup > 0 {
+				fp.Version = m[e.VersionGroup]
+			}
+			if e.ProductGroup > 0 && m[e.ProductGroup] != "" {
+				fp.Product = m[e.ProductGroup]
+			}
+			return fp
+		},
+	}, nil
+}
@@ -0,0 +1,139 @@
+package scanner
+
+import (
+	"bytes"
+	"errors"
+)
+
+// defaultSNMPCommunity is the factory read community of most agents.
+// Answering to it is reported as a finding.
+const defaultSNMPCommunity = "public"
+
+// BER tags used by SNMP messages.
+const (
+	berInteger      = 0x02
+	berOctetString  = 0x04
+	berNull         = 0x05
+	berOID          = 0x06
+	berSequence     = 0x30
+	snmpGetRequest  = 0xa0
+	snmpGetResponse = 0xa2
+	snmpVersion2c   = 1
+)
+
+// sysDescrOID is 1.3.6.1.2.1.1.1.0 in BER encoding.
+var sysDescrOID = []byte{0x2b, 0x06, 0x01, 0x02, 0x01, 0x01, 0x01, 0x00}
+
+// snmpRequestID identifies our GetRequest in the response.
+var snmpRequestID = []byte{0x12, 0x34, 0x56, 0x78}
+
+// snmpSysDescrRequest builds an SNMPv2c GetRequest for sysDescr.0.
+func snmpSysDescrRequest(community string) []byte {
+	varbind := berTLV(berSequence, append(berTLV(berOID, sysDescrOID), berNull, 0x00))
+	pdu := berTLV(snmpGetRequest, concat(
+		berTLV(berInteger, snmpRequestID),
+		berTLV(berInteger, []byte{0}), // error-status
+		berTLV(berInteger, []byte{0}), // error-index
+		berTLV(berSequence, varbind),
+	))
+	return berTLV(berSequence, concat(
+		berTLV(berInteger, []byte{snmpVersion2c}),
+		berTLV(berOctetString, []byte(community)),
+		pdu,
+	))
+}
+
+// parseSNMPSysDescr parses the GetResponse to snmpSysDescrRequest and
+// returns sysDescr. It reports false for anything else, including error
+// responses.
+func parseSNMPSysDescr(packet []byte) (string, bool) {
+	message, rest, err := berRead(packet, berSequence)
+	if err != nil || len(rest) != 0 {
+		return "", false
+	}
+	if _, message, err = berRead(message, berInteger); err != nil { // version
+		return "", false
+	}
+	if _, message, err = berRead(message, berOctetString); err != nil { // community
+		return "", false
+	}
+	pdu, _, err := berRead(message, snmpGetResponse)
+	if err != nil {
+		return "", false
+	}
+
+	requestID, pdu, err := berRead(pdu, berInteger)
+	if err != nil || !bytes.Equal(requestID, snmpRequestID) {
+		return "", false
+	}
+	errorStatus, pdu, err := berRead(pdu, berInteger)
+	if err != nil || len(bytes.Trim(errorStatus, "\x00")) != 0 {
+		return "", false
+	}
+	if _, pdu, err = berRead(pdu, berInteger); err != nil { // error-index
+		return "", false
+	}
+	varbinds, _, err := berRead(pdu, berSequence)
+	if err != nil {
+		return "", false
+	}
+	varbind, _, err := berRead(varbinds, berSequence)
+	if err != nil {
+		return "", false
+	}
+	oid, varbind, err := berRead(varbind, berOID)
+	if err != nil || !bytes.Equal(oid, sysDescrOID) {
+		return "", false
+	}
+	// noSuchObject and friends are context tags, not an OCTET STRING
+	value, _, err := berRead(varbind, berOctetString)
+	if err != nil {
+		return "", false
+	}
+	return string(value), true
+}
+
+var errBER = errors.New("malformed BER")
+
+// berRead reads one TLV with the given tag from b, returning its value and
+// the bytes after it.
+func berRead(b []byte, tag byte) ([]byte, []byte, error) {
+	if len(b) < 2 || b[0] != tag {
+		return nil, nil, errBER
+	}
+	length := int(b[1])
+	offset := 2
+	if length&0x80 != 0 {
+		n := length & 0x7f
+		if n == 0 || n > 3 || len(b) < 2+n {
+			return nil, nil, errBER
+		}
+		length = 0
+		for _, c := range b[2 : 2+n] {
+			length = length<<8 | int(c)
+		}
+		offset += n
+	}
+	if len(b)-offset < length {
+		return nil, nil, errBER
+	}
+	return b[offset : offset+length], b[offset+length:], nil
+}
+
+// berTLV encodes value with tag, using the long length form past 127 bytes.
+func berTLV(tag byte, value []byte) []byte {
+	var header []byte
+	switch n := len(value); {
+	case n < 0x80:
+		header = []byte{tag, byte(n)}
+	case n <= 0xff:
+		header = []byte{tag, 0x81, byte(n)}
+	default:
+		header = []byte{tag, 0x82, byte(n >> 8), byte(n)}
+	}
+	return append(header, value...)
+}
+
+func concat(parts ...[]byte) []byte {
+	return bytes.Join(parts, nil)
+}
@@ -0,0 +1,139 @@
+package scanner
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/aiforce-discovery-agent/collectors/network-scanner/internal/config"
+)
+
+// linuxSysDescrResponse is a net-snmp agent's GetResponse, community
+// public, to snmpSysDescrRequest.
+const linuxSysDescrResponse = "305c02010104067075626c6963a24f0204123456780201000201003041303f06082b" +
+	"0601020101010004334c696e7578206777303120352e31352e302d39312d67656e6572" +
+	"696320233130312d5562756e747520534d50207838365f3634"
+
+const linuxSysDescr = "Linux gw01 5.15.0-91-generic #101-Ubuntu SMP x86_64"
+
+// snmpResponse builds a GetResponse for requestID with the given error
+// status and sysDescr.0 value TLV.
+func snmpResponse(requestID []byte, errorStatus byte, value []byte) []byte {
+	varbind := berTLV(berSequence, append(berTLV(berOID, sysDescrOID), value...))
+	pdu := berTLV(snmpGetResponse, concat(
+		berTLV(berInteger, requestID),
+		berTLV(berInteger, []byte{errorStatus}),
+		berTLV(berInteger, []byte{0}),
+		berTLV(berSequence, varbind),
+	))
+	return berTLV(berSequence, concat(
+		berTLV(berInteger, []byte{snmpVersion2c}),
+		berTLV(berOctetString, []byte(defaultSNMPCommunity)),
+		pdu,
+	))
+}
+
+func TestParseSNMPSysDescr(t *testing.T) {
+	canned, err := hex.DecodeString(linuxSysDescrResponse)
+	if err != nil {
+		t.Fatal(err)
+	}
+	long := bytes.Repeat([]byte("x"), 300)
+
+	tests := []struct {
+		name   string
+		packet []byte
+		want   string
+		wantOK bool
+	}{
+		{name: "net-snmp agent", packet: canned, want: linuxSysDescr, wantOK: true},
+		{name: "long-form length", packet: snmpResponse(snmpRequestID, 0, berTLV(berOctetString, long)), want: string(long), wantOK: true},
+		{name: "noSuchObject", packet: snmpResponse(snmpRequestID, 0, []byte{0x80, 0x00})},
+		{name: "noSuchName error", packet: snmpResponse(snmpRequestID, 2, berTLV(berOctetString, []byte("x")))},
+		{name: "another request", packet: snmpResponse([]byte{1, 2, 3, 4}, 0, berTLV(berOctetString, []byte("x")))},
+		{name: "trailing bytes", packet: append(append([]byte(nil), canned...), 0x00)},
+		{name: "truncated", packet: canned[:len(canned)-10]},
+		{name: "our own request", packet: snmpSysDescrRequest(defaultSNMPCommunity)},
+		{name: "empty", packet: nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := parseSNMPSysDescr(tt.packet)
+			if got != tt.want || ok != tt.wantOK {
+				t.Errorf("parseSNMPSysDescr() = %q, %v; want %q, %v", got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestScanUDPPortSNMP(t *testing.T) {
+	canned, err := hex.DecodeString(linuxSysDescrResponse)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name          string
+		community     string
+		wantCommunity string
+		wantDefault   interface{}
+		wantSeverity  interface{}
+	}{
+		{name: "default community", wantCommunity: "public", wantDefault: true, wantSeverity: "medium"},
+		{name: "configured community", community: "n0t-public", wantCommunity: "n0t-public"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			agent, err := net.ListenPacket("udp", "127.0.0.1:0")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer func() { _ = agent.Close() }()
+			requests := make(chan []byte, 1)
+			go func() {
+				buffer := make([]byte, 1500)
+				n, addr, err := agent.ReadFrom(buffer)
+				if err != nil {
+					return
+				}
+				requests <- buffer[:n]
+				_, _ = agent.WriteTo(canned, addr)
+			}()
+
+			s := newTestScanner(t, config.ScannerConfig{Timeout: 1000, SNMPCommunity: tt.community})
+			s.dial = func(network, _ string, timeout time.Duration) (net.Conn, error) {
+				return net.DialTimeout(network, agent.LocalAddr().String(), timeout)
+			}
+
+			result := s.scanUDPPort(context.Background(), "192.0.2.1", snmpPort)
+
+			select {
+			case request := <-requests:
+				if !bytes.Equal(request, snmpSysDescrRequest(tt.wantCommunity)) {
+					t.Errorf("request = %x, want a GetRequest with community %q", request, tt.wantCommunity)
+				}
+			default:
+				t.Fatal("agent got no request")
+			}
+			if !result.Open || result.Service != "SNMP" || result.Banner != linuxSysDescr {
+				t.Errorf("open %v, service %q, banner %q; want open SNMP with banner %q",
+					result.Open, result.Service, result.Banner, linuxSysDescr)
+			}
+			if got := result.Metadata["snmp_sysdescr"]; got != linuxSysDescr {
+				t.Errorf("snmp_sysdescr = %v, want %q", got, linuxSysDescr)
+			}
+			if got := result.Metadata["protocol_version"]; got != "v2c" {
+				t.Errorf("protocol_version = %v, want v2c", got)
+			}
+			if got := result.Metadata["snmp_default_community"]; got != tt.wantDefault {
+				t.Errorf("snmp_default_community = %v, want %v", got, tt.wantDefault)
+			}
+			if got := result.Metadata["finding_severity"]; got != tt.wantSeverity {
+				t.Errorf("finding_severity = %v, want %v", got, tt.wantSeverity)
+			}
+		})
+	}
+}
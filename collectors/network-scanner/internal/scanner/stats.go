@@ -0,0 +1,203 @@
+package scanner
+
+import (
+	"sort"
+	"strconv"
+	"sync"
+	"sync/atomic"
+
+	"github.com/aiforce-discovery-agent/collectors/network-scanner/internal/callback"
+)
+
+// maxTopServices caps the top services list in the completion summary.
+const maxTopServices = 10
+
+// dialLatencyBucketsMS are the upper bounds (inclusive) of the dial latency
+// histogram, in milliseconds. A final +Inf bucket is implied.
+var dialLatencyBucketsMS = []int64{1, 5, 10, 25, 50, 100, 250, 500, 1000, 2500, 5000}
+
+// scanStats accumulates per-probe statistics for a single scan. All fields
+// are updated atomically so workers can record without locking.
+type scanStats struct {
+	buckets  []int64 // non-cumulative counts; len(dialLatencyBucketsMS)+1
+	sumMS    int64
+	count    int64
+	open     int64
+	closed   int64
+	filtered int64
+	known    int64
+
+	// Cancellation accounting: results from in-flight hosts published after
+	// cancellation (preserved) or discarded by an immediate cancel (dropped)
+	cancelMode atomic.Value // string
+	preserved  int64
+	dropped    int64
+
+	// Discovery breakdowns, keyed by service name and subnet. Bounded by
+	// the number of distinct services and subnets; not kept in
+	// streaming-only mode.
+	breakdownMu sync.Mutex
+	services    map[string]int64
+	subnets     map[string]int64
+	skipped     []callback.SkippedSubnet
+}
+
+func newScanStats() *scanStats {
+	return &scanStats{
+		buckets:  make([]int64, len(dialLatencyBucketsMS)+1),
+		services: make(map[string]int64),
+		subnets:  make(map[string]int64),
+	}
+}
+
+// record adds a single port probe to the statistics.
+func (st *scanStats) record(result ScanResult) {
+	ms := result.Latency.Milliseconds()
+	idx := len(dialLatencyBucketsMS)
+	for i, bound := range dialLatencyBucketsMS {
+		if ms <= bound {
+			idx = i
+			break
+		}
+	}
+	atomic.AddInt64(&st.buckets[idx], 1)
+	atomic.AddInt64(&st.sumMS, ms)
+	atomic.AddInt64(&st.count, 1)
+
+	switch {
+	case result.Open:
+		atomic.AddInt64(&st.open, 1)
+	case result.TimedOut:
+		atomic.AddInt64(&st.filtered, 1)
+	default:
+		atomic.AddInt64(&st.closed, 1)
+	}
+}
+
+// recordKnown counts a discovery that matched the known assets inventory.
+func (st *scanStats) recordKnown() {
+	atomic.AddInt64(&st.known, 1)
+}
+
+// recordDiscovery counts a published discovery by service and subnet.
+func (st *scanStats) recordDiscovery(subnet, service string) {
+	st.breakdownMu.Lock()
+	st.services[service]++
+	st.subnets[subnet]++
+	st.breakdownMu.Unlock()
+}
+
+// sortedCounts converts a count map into a slice ordered by count
+// descending, then name ascending, so summaries are reproducible regardless
+// of the order in which workers published.
+func sortedCounts(counts map[string]int64, limit int) []callback.NamedCount {
+	out := make([]callback.NamedCount, 0, len(counts))
+	for name, count := range counts {
+		out = append(out, callback.NamedCount{Name: name, Count: count})
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Count != out[j].Count {
+			return out[i].Count > out[j].Count
+		}
+		return out[i].Name < out[j].Name
+	})
+	if limit > 0 && len(out) > limit {
+		out = out[:limit]
+	}
+	return out
+}
+
+func (st *scanStats) setCancelMode(mode string) {
+	st.cancelMode.Store(mode)
+}
+
+// recordDiscovery records a published discovery in the scan breakdowns,
+// unless running in streaming-only mode.
+func (s *Scanner) recordDiscovery(subnet string, result ScanResult) {
+	if s.config.StreamingOnly {
+		return
+	}
+	if st := s.stats.Load(); st != nil {
+		st.recordDiscovery(subnet, result.Service)
+	}
+}
+
+// recordSkippedSubnet notes a requested subnet that was never scanned.
+func (s *Scanner) recordSkippedSubnet(subnet, reason string) {
+	s.logger.Warnw("Subnet skipped", "subnet", subnet, "reason", reason)
+	if st := s.stats.Load(); st != nil {
+		st.breakdownMu.Lock()
+		st.skipped = append(st.skipped, callback.SkippedSubnet{Subnet: subnet, Reason: reason})
+		st.breakdownMu.Unlock()
+	}
+}
+
+// recordPreserved counts a result published after cancellation was requested.
+func (s *Scanner) recordPreserved() {
+	if st := s.stats.Load(); st != nil {
+		atomic.AddInt64(&st.preserved, 1)
+	}
+}
+
+// recordDropped counts results discarded because their host was aborted.
+func (s *Scanner) recordDropped(n int) {
+	if st := s.stats.Load(); st != nil && n > 0 {
+		atomic.AddInt64(&st.dropped, int64(n))
+	}
+}
+
+// summary converts the statistics into a completion summary. Histogram
+// buckets are cumulative in the Prometheus style, so the +Inf bucket equals
+// the total number of probed ports.
+func (st *scanStats) summary() *callback.ScanSummary {
+	buckets := make([]callback.HistogramBucket, 0, len(st.buckets))
+	var cumulative int64
+	for i := range st.buckets {
+		cumulative += atomic.LoadInt64(&st.buckets[i])
+		le := "+Inf"
+		if i < len(dialLatencyBucketsMS) {
+			le = strconv.FormatInt(dialLatencyBucketsMS[i], 10)
+		}
+		buckets = append(buckets, callback.HistogramBucket{LE: le, Count: cumulative})
+	}
+
+	var cancellation *callback.Cancellation
+	if mode, ok := st.cancelMode.Load().(string); ok {
+		cancellation = &callback.Cancellation{
+			Mode:      mode,
+			Preserved: atomic.LoadInt64(&st.preserved),
+			Dropped:   atomic.LoadInt64(&st.dropped),
+		}
+	}
+
+	st.breakdownMu.Lock()
+	topServices := sortedCounts(st.services, maxTopServices)
+	subnets := sortedCounts(st.subnets, 0)
+	skipped := append([]callback.SkippedSubnet(nil), st.skipped...)
+	st.breakdownMu.Unlock()
+
+	return &callback.ScanSummary{
+		DialLatencyMS: callback.Histogram{
+			Buckets: buckets,
+			Count:   atomic.LoadInt64(&st.count),
+			Sum:     atomic.LoadInt64(&st.sumMS),
+		},
+		PortStates: callback.PortStates{
+			Open:     atomic.LoadInt64(&st.open),
+			Closed:   atomic.LoadInt64(&st.closed),
+			Filtered: atomic.LoadInt64(&st.filtered),
+		},
+		KnownAssets:    atomic.LoadInt64(&st.known),
+		TopServices:    topServices,
+		Subnets:        subnets,
+		SkippedSubnets: skipped,
+		Cancellation:   cancellation,
+	}
+}
+
+// recordProbe records a probe against the active scan's statistics, if any.
+func (s *Scanner) recordProbe(result ScanResult) {
+	if st := s.stats.Load(); st != nil {
+		st.record(result)
+	}
+}
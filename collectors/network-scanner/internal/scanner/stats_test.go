@@ -0,0 +1,136 @@
+package scanner
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aiforce-discovery-agent/collectors/network-scanner/internal/callback"
+	"github.com/aiforce-discovery-agent/collectors/network-scanner/internal/config"
+)
+
+func TestScanStatsSummary(t *testing.T) {
+	st := newScanStats()
+	for _, result := range []ScanResult{
+		{Latency: 0, Open: true},
+		{Latency: 3 * time.Millisecond},
+		{Latency: 5 * time.Millisecond},
+		{Latency: 30 * time.Millisecond, Open: true},
+		{Latency: 2 * time.Second, TimedOut: true},
+		{Latency: 7 * time.Second, TimedOut: true},
+	} {
+		st.record(result)
+	}
+	summary := st.summary()
+
+	want := map[string]int64{"1": 1, "5": 3, "10": 3, "25": 3, "50": 4, "1000": 4, "2500": 5, "5000": 5, "+Inf": 6}
+	for _, bucket := range summary.DialLatencyMS.Buckets {
+		if count, ok := want[bucket.LE]; ok && bucket.Count != count {
+			t.Errorf("bucket le=%s = %d, want %d", bucket.LE, bucket.Count, count)
+		}
+	}
+	if n := len(summary.DialLatencyMS.Buckets); n != len(dialLatencyBucketsMS)+1 {
+		t.Errorf("%d buckets, want %d", n, len(dialLatencyBucketsMS)+1)
+	}
+	if summary.DialLatencyMS.Count != 6 || summary.DialLatencyMS.Sum != 9038 {
+		t.Errorf("count %d sum %d, want 6 and 9038", summary.DialLatencyMS.Count, summary.DialLatencyMS.Sum)
+	}
+	if want := (callback.PortStates{Open: 2, Closed: 2, Filtered: 2}); summary.PortStates != want {
+		t.Errorf("port states = %+v, want %+v", summary.PortStates, want)
+	}
+}
+
+// The histogram covers every probed port and places each at its measured
+// dial latency.
+func TestScanStatsFromScan(t *testing.T) {
+	const delay = 60 * time.Millisecond
+
+	s := newTestScanner(t, config.ScannerConfig{Timeout: 1000, PortConcurrency: 8})
+	var dials, peak int32
+	s.dial = portDial(portState(8003, "open", "refused"), delay, &dials, &peak)
+	s.stats.Store(newScanStats())
+
+	ports := []int{8000, 8001, 8002, 8003, 8004, 8005}
+	if _, err := s.scanHost(context.Background(), "192.0.2.10", ports, false); err != nil {
+		t.Fatalf("scanHost() error = %v", err)
+	}
+	summary := s.stats.Load().summary()
+
+	buckets := make(map[string]int64)
+	for _, bucket := range summary.DialLatencyMS.Buckets {
+		buckets[bucket.LE] = bucket.Count
+	}
+	if buckets["+Inf"] != int64(len(ports)) || summary.DialLatencyMS.Count != int64(len(ports)) {
+		t.Errorf("+Inf bucket %d count %d, want %d probed ports", buckets["+Inf"], summary.DialLatencyMS.Count, len(ports))
+	}
+	if buckets["50"] != 0 || buckets["1000"] != int64(len(ports)) {
+		t.Errorf("buckets le=50 %d le=1000 %d, want every %v dial between them", buckets["50"], buckets["1000"], delay)
+	}
+	if want := (callback.PortStates{Open: 1, Closed: 5}); summary.PortStates != want {
+		t.Errorf("port states = %+v, want %+v", summary.PortStates, want)
+	}
+}
+
+func TestSummaryBreakdownsAreOrdered(t *testing.T) {
+	discoveries := [][2]string{ // subnet, service
+		{"10.0.1.0/24", "SSH"}, {"10.0.0.0/24", "HTTP"}, {"10.0.0.0/24", "SSH"},
+		{"10.0.2.0/24", "MySQL"}, {"10.0.1.0/24", "HTTP"}, {"10.0.0.0/24", "HTTPS"},
+		{"10.0.2.0/24", "SSH"}, {"10.0.0.0/24", "Redis"},
+	}
+
+	// Workers publish in arbitrary order; the summary must not depend on it
+	var summaries []*callback.ScanSummary
+	for _, reverse := range []bool{false, true} {
+		st := newScanStats()
+		var wg sync.WaitGroup
+		for i := range discoveries {
+			d := discoveries[i]
+			if reverse {
+				d = discoveries[len(discoveries)-1-i]
+			}
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				st.recordDiscovery(d[0], d[1])
+			}()
+		}
+		wg.Wait()
+		summaries = append(summaries, st.summary())
+	}
+
+	wantServices := []callback.NamedCount{
+		{Name: "SSH", Count: 3}, {Name: "HTTP", Count: 2}, {Name: "HTTPS", Count: 1}, {Name: "MySQL", Count: 1}, {Name: "Redis", Count: 1},
+	}
+	wantSubnets := []callback.NamedCount{
+		{Name: "10.0.0.0/24", Count: 4}, {Name: "10.0.1.0/24", Count: 2}, {Name: "10.0.2.0/24", Count: 2},
+	}
+	for _, summary := range summaries {
+		if !reflect.DeepEqual(summary.TopServices, wantServices) {
+			t.Errorf("top services = %v, want %v", summary.TopServices, wantServices)
+		}
+		if !reflect.DeepEqual(summary.Subnets, wantSubnets) {
+			t.Errorf("subnets = %v, want %v", summary.Subnets, wantSubnets)
+		}
+	}
+}
+
+func TestTopServicesLimit(t *testing.T) {
+	st := newScanStats()
+	for i := 0; i < maxTopServices+5; i++ {
+		for j := 0; j <= i; j++ {
+			st.recordDiscovery("10.0.0.0/24", fmt.Sprintf("svc-%02d", i))
+		}
+	}
+
+	top := st.summary().TopServices
+	if len(top) != maxTopServices {
+		t.Fatalf("%d top services, want %d", len(top), maxTopServices)
+	}
+	// The most common services are kept
+	if want := fmt.Sprintf("svc-%02d", maxTopServices+4); top[0].Name != want {
+		t.Errorf("top service = %s, want %s", top[0].Name, want)
+	}
+}
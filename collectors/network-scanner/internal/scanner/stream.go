@@ -0,0 +1,189 @@
+package scanner
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sync"
+)
+
+// ErrTooManySubscribers is returned when MaxProgressSubscribers progress
+// streams are already open.
+var ErrTooManySubscribers = errors.New("too many progress subscribers")
+
+// progressBuffer is how many updates a subscriber may fall behind by
+// before the oldest are dropped.
+const progressBuffer = 16
+
+// ProgressUpdate is a live progress frame of an autonomous scan. The last
+// frame of a scan has Final set and Status.Phase holds its completion
+// status; the channel is closed after it.
+type ProgressUpdate struct {
+	Status ScanStatus
+	Final  bool
+}
+
+// progressHub fans progress updates out to subscribers by scan ID. It is
+// shared by the root Scanner and its sessions.
+type progressHub struct {
+	mu    sync.Mutex
+	subs  map[string]map[chan ProgressUpdate]struct{}
+	count int
+	max   int
+}
+
+func newProgressHub(max int) *progressHub {
+	return &progressHub{
+		subs: make(map[string]map[chan ProgressUpdate]struct{}),
+		max:  max,
+	}
+}
+
+func (h *progressHub) subscribe(scanID string) (chan ProgressUpdate, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.max > 0 && h.count >= h.max {
+		return nil, fmt.Errorf("%w: %d open", ErrTooManySubscribers, h.count)
+	}
+	ch := make(chan ProgressUpdate, progressBuffer)
+	if h.subs[scanID] == nil {
+		h.subs[scanID] = make(map[chan ProgressUpdate]struct{})
+	}
+	h.subs[scanID][ch] = struct{}{}
+	h.count++
+	return ch, nil
+}
+
+// unsubscribe removes ch, unless the scan's final frame already did.
+func (h *progressHub) unsubscribe(scanID string, ch chan ProgressUpdate) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if _, ok := h.subs[scanID][ch]; !ok {
+		return
+	}
+	delete(h.subs[scanID], ch)
+	if len(h.subs[scanID]) == 0 {
+		delete(h.subs, scanID)
+	}
+	h.count--
+	close(ch)
+}
+
+func (h *progressHub) hasSubscribers(scanID string) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return len(h.subs[scanID]) > 0
+}
+
+// publish sends update to the scan's subscribers without blocking: a
+// subscriber that has fallen behind loses its oldest update instead. The
+// final update closes and removes the subscribers.
+func (h *progressHub) publish(scanID string, update ProgressUpdate) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for ch := range h.subs[scanID] {
+		for {
+			select {
+			case ch <- update:
+			default:
+				select {
+				case <-ch:
+				default:
+				}
+				continue
+			}
+			break
+		}
+		if update.Final {
+			close(ch)
+			h.count--
+		}
+	}
+	if update.Final {
+		delete(h.subs, scanID)
+	}
+}
+
+// SubscribeProgress streams progress of the running scan scanID. Updates
+// are sent when the phase, whole percent or discovery count changes, and
+// the final update when the scan finishes. Call unsubscribe once done
+// reading; it is safe after the final update.
+func (s *Scanner) SubscribeProgress(scanID string) (<-chan ProgressUpdate, func(), error) {
+	// Subscribing under the root lock means the scan can't end in between,
+	// so every subscriber sees the final update
+	s.mu.RLock()
+	session, ok := s.sessions[scanID]
+	if !ok {
+		s.mu.RUnlock()
+		return nil, nil, fmt.Errorf("%w: %s", ErrScanIDMismatch, scanID)
+	}
+	ch, err := s.progressHub.subscribe(scanID)
+	s.mu.RUnlock()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// Start the stream from the current status
+	session.lastProgress.Store(nil)
+	session.notifyProgress()
+
+	return ch, func() { s.progressHub.unsubscribe(scanID, ch) }, nil
+}
+
+// progressKey is what makes an update worth sending.
+type progressKey struct {
+	phase     string
+	percent   int
+	openPorts int
+}
+
+// notifyProgress asks the scan's progress stream to send its status. It
+// never blocks, so workers can call it for every host: requests made while
+// one is pending are coalesced into it.
+func (s *Scanner) notifyProgress() {
+	select {
+	case s.progressKick <- struct{}{}:
+	default:
+	}
+}
+
+// runProgressStream sends the scan's status to its subscribers on each
+// notifyProgress, until stop is closed. It is the only sender of non-final
+// updates, so subscribers receive them in order.
+func (s *Scanner) runProgressStream(stop <-chan struct{}) {
+	for {
+		select {
+		case <-s.progressKick:
+			s.sendProgress()
+		case <-stop:
+			return
+		}
+	}
+}
+
+// sendProgress sends the scan's status to its subscribers if it changed
+// meaningfully since the last update.
+func (s *Scanner) sendProgress() {
+	if !s.progressHub.hasSubscribers(s.scanID) {
+		return
+	}
+
+	status := s.status()
+	key := &progressKey{
+		phase:     status.Phase,
+		percent:   int(math.Floor(status.PercentDone)),
+		openPorts: status.OpenPorts,
+	}
+	if last := s.lastProgress.Swap(key); last != nil && *last == *key {
+		return
+	}
+	s.progressHub.publish(s.scanID, ProgressUpdate{Status: status})
+}
+
+// streamFinal sends the final update of a finished scan.
+func (s *Scanner) streamFinal() {
+	s.progressHub.publish(s.scanID, ProgressUpdate{Status: s.status(), Final: true})
+}
@@ -0,0 +1,134 @@
+package scanner
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/aiforce-discovery-agent/collectors/network-scanner/internal/config"
+)
+
+// newStreamingSession returns a running session of totalHosts hosts with
+// its progress stream started, and a subscriber to it.
+func newStreamingSession(t *testing.T, totalHosts int64) (*Scanner, chan ProgressUpdate, func()) {
+	t.Helper()
+
+	s := newTestScanner(t, config.ScannerConfig{})
+	s.scanID = "scan"
+	s.phase = "port_scanning"
+	s.running = true
+	s.startedAt = time.Now()
+	s.totalIPs = totalHosts
+	s.progressKick = make(chan struct{}, 1)
+
+	ch, err := s.progressHub.subscribe(s.scanID)
+	if err != nil {
+		t.Fatalf("subscribe() error = %v", err)
+	}
+
+	stop, done := make(chan struct{}), make(chan struct{})
+	go func() {
+		defer close(done)
+		s.runProgressStream(stop)
+	}()
+	return s, ch, func() {
+		close(stop)
+		<-done
+	}
+}
+
+func TestProgressStreamInOrder(t *testing.T) {
+	const hosts = 2000
+	s, ch, stopStream := newStreamingSession(t, hosts)
+
+	var wg sync.WaitGroup
+	for w := 0; w < 8; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < hosts/8; i++ {
+				atomic.AddInt64(&s.scannedIPs, 1)
+				s.notifyProgress()
+			}
+		}()
+	}
+
+	var got []int64
+	collected := make(chan struct{})
+	go func() {
+		defer close(collected)
+		for update := range ch {
+			got = append(got, update.Status.ScannedHosts)
+		}
+	}()
+
+	wg.Wait()
+	s.notifyProgress()
+	stopStream()
+	s.streamFinal()
+	<-collected
+
+	if len(got) < 2 {
+		t.Fatalf("got %d updates, want progress and a final frame", len(got))
+	}
+	for i := 1; i < len(got); i++ {
+		if got[i] < got[i-1] {
+			t.Fatalf("update %d has %d hosts scanned after %d", i, got[i], got[i-1])
+		}
+	}
+	if last := got[len(got)-1]; last != hosts {
+		t.Errorf("final frame has %d hosts scanned, want %d", last, hosts)
+	}
+}
+
+func TestProgressStreamSkipsUnchanged(t *testing.T) {
+	s, ch, stopStream := newStreamingSession(t, 1000)
+	defer stopStream()
+
+	tests := []struct {
+		name    string
+		scanned int64
+		want    bool
+	}{
+		{name: "first update", scanned: 0, want: true},
+		{name: "less than a percent", scanned: 5, want: false},
+		{name: "next percent", scanned: 10, want: true},
+		{name: "same percent again", scanned: 11, want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			atomic.StoreInt64(&s.scannedIPs, tt.scanned)
+			s.notifyProgress()
+
+			select {
+			case update := <-ch:
+				if !tt.want {
+					t.Errorf("got an update at %d hosts, want none", update.Status.ScannedHosts)
+				}
+			case <-time.After(50 * time.Millisecond):
+				if tt.want {
+					t.Error("no update sent")
+				}
+			}
+		})
+	}
+}
+
+func TestNotifyProgressNeverBlocks(t *testing.T) {
+	s := newTestScanner(t, config.ScannerConfig{})
+	s.progressKick = make(chan struct{}, 1)
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 100; i++ {
+			s.notifyProgress()
+		}
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("notifyProgress blocked with no progress stream running")
+	}
+}
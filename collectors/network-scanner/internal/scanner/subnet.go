@@ -2,28 +2,58 @@ package scanner
 
 import (
 	"context"
-	"net"
 	"sync"
 	"sync/atomic"
+
+	"github.com/aiforce-discovery-agent/collectors/network-scanner/internal/callback"
+	"github.com/aiforce-discovery-agent/collectors/network-scanner/internal/publisher"
 )
 
-func (s *Scanner) scanSubnetAutonomous(subnet string, scannedIPs *int64) {
+// scanSubnetAutonomous scans a subnet with a worker pool. The reporter is
+// passed in rather than read from s.reporter so that workers finishing a
+// late publish never observe the field being cleared by finishAutonomousScan.
+func (s *Scanner) scanSubnetAutonomous(subnet string, reporter *callback.Reporter) {
 	defer s.wg.Done()
 
 	s.logger.Infow("Scanning subnet", "subnet", subnet)
 
-	_, ipNet, err := net.ParseCIDR(subnet)
+	r, err := s.parseScanSubnet(subnet)
 	if err != nil {
-		s.logger.Errorw("Invalid subnet", "subnet", subnet, "error", err)
+		reporter.RecordError(callback.ErrorInvalidSubnet, err.Error())
+		s.recordSkippedSubnet(subnet, err.Error())
 		return
 	}
 
+	s.scanHostsAutonomous(subnet, reporter, func(emit func(hostTarget) bool) {
+		s.forEachSubnetIP(subnet, r, func(ipStr string) bool {
+			return emit(hostTarget{ip: ipStr})
+		})
+	})
+}
+
+// scanHostsAutonomous runs a worker pool over the hosts produced by feed and
+// publishes their results. label identifies the host source (a subnet or
+// the explicit target list) in logs and breakdowns. feed calls emit for each
+// host and must stop when emit returns false.
+func (s *Scanner) scanHostsAutonomous(label string, reporter *callback.Reporter, feed func(emit func(hostTarget) bool)) {
+	// Streaming-only scans keep nothing in memory beyond fixed-size counters
+	retain := !s.config.StreamingOnly
+	scanID := reporter.GetScanID()
+	logger := s.logger.With("subnet", label)
+	ctx := withLogger(s.ctx, logger)
+
 	numWorkers := s.config.Concurrency
 	if numWorkers <= 0 {
 		numWorkers = 100
 	}
 
-	ipChan := make(chan string, numWorkers*2)
+	progress := s.sourceProgress(label)
+	hostDone := func(host hostTarget) {
+		progress.done(host)
+		s.notifyProgress()
+	}
+
+	hostChan := make(chan hostTarget, numWorkers*2)
 	var workerWg sync.WaitGroup
 	var publishFailures int64
 	var openPortsFound int64
@@ -33,63 +63,92 @@ func (s *Scanner) scanSubnetAutonomous(subnet string, scannedIPs *int64) {
 		workerWg.Add(1)
 		go func() {
 			defer workerWg.Done()
-			for ipStr := range ipChan {
-				results, err := s.ScanTarget(ipStr)
+			for host := range hostChan {
+				// Hosts still queued when feeding stops are not in flight;
+				// skip them so a graceful cancel only drains started hosts
+				if s.feedCtx.Err() != nil {
+					continue
+				}
+
+				if s.config.EnablePing {
+					if !s.hostAlive(host) {
+						reporter.IncrementSkippedDead()
+						hostDone(host)
+						continue
+					}
+					reporter.IncrementAlive()
+				}
+
+				results, err := s.scanHostTarget(ctx, host)
 				if err != nil {
 					if err == context.Canceled {
+						s.recordDropped(len(results))
 						return
 					}
-					s.logger.Warnw("Scan error", "ip", ipStr, "error", err)
+					logger.Warnw("Scan error", "ip", host.ip, "error", err)
+					reporter.RecordError(callback.ErrorHostScanFailed, err.Error())
+					hostDone(host)
 					continue
 				}
 
 				// Publish results and track discovery count
-				for _, result := range results {
-					atomic.AddInt64(&openPortsFound, 1)
-					if err := s.publisher.PublishServiceDiscovered(result); err != nil {
-						atomic.AddInt64(&publishFailures, 1)
-						s.logger.Errorw("Failed to publish result", "error", err)
-					} else if s.reporter != nil {
-						s.reporter.IncrementDiscoveryCount()
+				published, failed := s.publishResults(ctx, results)
+				atomic.AddInt64(&openPortsFound, int64(len(published)+failed))
+				atomic.AddInt64(&publishFailures, int64(failed))
+				for _, result := range published {
+					reporter.IncrementDiscoveryCount(publisher.ServiceCategory(result.Port, result.Service))
+					if retain {
+						s.results.Add(scanID, result)
+					}
+					s.recordDiscovery(label, result)
+					if s.feedCtx.Err() != nil {
+						s.recordPreserved()
 					}
 				}
+				hostDone(host)
 			}
 		}()
 	}
 
-	// Feed IPs into the worker channel
-feedLoop:
-	for ip := ipNet.IP.Mask(ipNet.Mask); ipNet.Contains(ip); incrementIP(ip) {
-		select {
-		case <-s.ctx.Done():
-			break feedLoop
-		default:
+	// Feed hosts into the worker channel, numbering them in scan order.
+	// Excluded hosts count as scanned but get no position. Hosts completed
+	// before a resume are skipped; the scan was seeded with their count.
+	var pos int64
+	feed(func(host hostTarget) bool {
+		if s.hostExcluded(host) {
+			atomic.AddInt64(&s.scannedIPs, int64(len(host.addrs())))
+			return true
 		}
+		host.pos = pos
+		pos++
+		if host.pos < progress.resumed {
+			return true
+		}
+		atomic.AddInt64(&s.scannedIPs, int64(len(host.addrs())))
 
-		// Copy IP string before sending — incrementIP mutates the underlying bytes
-		ipStr := ip.String()
-		atomic.AddInt64(scannedIPs, 1)
-
-		if s.isExcluded(ipStr) {
-			continue
+		select {
+		case <-s.feedCtx.Done():
+			return false
+		default:
 		}
 
 		select {
-		case ipChan <- ipStr:
-		case <-s.ctx.Done():
-			break feedLoop
+		case hostChan <- host:
+			return true
+		case <-s.feedCtx.Done():
+			return false
 		}
-	}
+	})
 
-	close(ipChan)
+	close(hostChan)
 	workerWg.Wait()
 
 	// Log if all publishes failed (indicates a systemic issue)
 	found := atomic.LoadInt64(&openPortsFound)
 	failed := atomic.LoadInt64(&publishFailures)
 	if found > 0 && failed == found {
-		s.logger.Errorw("All publish attempts failed for subnet",
-			"subnet", subnet, "open_ports", found, "failures", failed)
+		logger.Errorw("All publish attempts failed for subnet",
+			"open_ports", found, "failures", failed)
 	}
 }
 
@@ -98,41 +157,40 @@ func (s *Scanner) scanSubnet(subnet string) {
 
 	s.logger.Infow("Scanning subnet", "subnet", subnet)
 
-	_, ipNet, err := net.ParseCIDR(subnet)
+	r, err := s.parseScanSubnet(subnet)
 	if err != nil {
 		s.logger.Errorw("Invalid subnet", "subnet", subnet, "error", err)
 		return
 	}
 
 	// Iterate through all IPs in subnet
-	for ip := ipNet.IP.Mask(ipNet.Mask); ipNet.Contains(ip); incrementIP(ip) {
+	s.forEachSubnetIP(subnet, r, func(ipStr string) bool {
 		select {
-		case <-s.ctx.Done():
-			return
+		case <-s.feedCtx.Done():
+			return false
 		default:
 		}
 
-		ipStr := ip.String()
-
 		// Skip excluded subnets
 		if s.isExcluded(ipStr) {
-			continue
+			return true
+		}
+
+		if s.config.EnablePing && !s.isAlive(ipStr) {
+			return true
 		}
 
 		results, err := s.ScanTarget(ipStr)
 		if err != nil {
 			if err == context.Canceled {
-				return
+				return false
 			}
 			s.logger.Warnw("Scan error", "ip", ipStr, "error", err)
-			continue
+			return true
 		}
 
 		// Publish results
-		for _, result := range results {
-			if err := s.publisher.PublishServiceDiscovered(result); err != nil {
-				s.logger.Errorw("Failed to publish result", "error", err)
-			}
-		}
-	}
+		s.publishResults(s.ctx, results)
+		return true
+	})
 }
@@ -0,0 +1,159 @@
+package scanner
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/aiforce-discovery-agent/collectors/network-scanner/internal/config"
+)
+
+func TestWorkerLogsCarryScanAndSubnet(t *testing.T) {
+	const (
+		scanID = "0c6f1e2a-8d4b-4f7e-9a3c-5b2d1e0f9a84"
+		subnet = "10.9.0.0/30"
+	)
+
+	s := newTestScanner(t, config.ScannerConfig{MaxConcurrentScans: 1, Concurrency: 2, RateLimit: 100000})
+	logs := observeLogs(s)
+	var dials int32
+	s.dial = pipeDial(&dials, func(net.Conn) {})
+	s.publisher = &recordingPublisher{err: errors.New("broker unavailable")}
+
+	callbacks := httptest.NewServer(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}))
+	defer callbacks.Close()
+
+	if err := s.StartAutonomous(AutonomousScanConfig{
+		ScanID:      scanID,
+		Subnets:     []string{subnet},
+		PortRanges:  []string{"80"},
+		ProgressURL: callbacks.URL,
+		CompleteURL: callbacks.URL,
+	}); err != nil {
+		t.Fatalf("StartAutonomous() error = %v", err)
+	}
+	select {
+	case <-s.lastSession.done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("scan did not finish")
+	}
+
+	failures := logs.FilterMessage("Failed to publish result").All()
+	if len(failures) == 0 {
+		t.Fatal("no publish failures logged")
+	}
+	for _, entry := range failures {
+		fields := entry.ContextMap()
+		if fields["scan_id"] != scanID || fields["subnet"] != subnet {
+			t.Errorf("%q logged with %v, want scan_id %s and subnet %s", entry.Message, fields, scanID, subnet)
+		}
+	}
+}
+
+func TestMaxConcurrentSubnets(t *testing.T) {
+	for _, maxSubnets := range []int{1, 2, 4} {
+		t.Run(fmt.Sprintf("max_concurrent_subnets=%d", maxSubnets), func(t *testing.T) {
+			s := newTestScanner(t, config.ScannerConfig{
+				MaxConcurrentScans: 1, MaxConcurrentSubnets: maxSubnets, Concurrency: 1, RateLimit: 100000,
+			})
+			var mu sync.Mutex
+			active := make(map[string]int) // in-flight dials by subnet
+			peak := 0
+			s.dial = func(_, address string, _ time.Duration) (net.Conn, error) {
+				host, _, _ := net.SplitHostPort(address)
+				subnet := host[:strings.LastIndex(host, ".")]
+				mu.Lock()
+				active[subnet]++
+				if len(active) > peak {
+					peak = len(active)
+				}
+				mu.Unlock()
+
+				time.Sleep(5 * time.Millisecond)
+
+				mu.Lock()
+				if active[subnet]--; active[subnet] == 0 {
+					delete(active, subnet)
+				}
+				mu.Unlock()
+				return nil, &net.OpError{Op: "dial", Net: "tcp", Err: syscall.ECONNREFUSED}
+			}
+
+			completion := runAutonomous(t, s, AutonomousScanConfig{
+				Subnets:    []string{"10.9.0.0/30", "10.9.1.0/30", "10.9.2.0/30", "10.9.3.0/30"},
+				PortRanges: []string{"80"},
+			})
+			if completion.Status != "completed" {
+				t.Errorf("status = %q, want completed", completion.Status)
+			}
+			if peak != maxSubnets {
+				t.Errorf("peak concurrent subnets = %d, want %d", peak, maxSubnets)
+			}
+		})
+	}
+}
+
+func TestSkippedSubnetsInSummary(t *testing.T) {
+	const scanID = "3e1d5c7a-9b2f-4a6e-8c0d-1f4b7a9e2d5c"
+	refused := &net.OpError{Op: "dial", Net: "tcp", Err: syscall.ECONNREFUSED}
+
+	t.Run("invalid", func(t *testing.T) {
+		s := newTestScanner(t, config.ScannerConfig{MaxConcurrentScans: 1, RateLimit: 100000})
+		var mu sync.Mutex
+		scanned := make(map[string]bool)
+		s.dial = func(_, address string, _ time.Duration) (net.Conn, error) {
+			host, _, _ := net.SplitHostPort(address)
+			mu.Lock()
+			scanned[host] = true
+			mu.Unlock()
+			return nil, refused
+		}
+
+		completion := runAutonomous(t, s, AutonomousScanConfig{
+			Subnets: []string{"10.9.1.0/30", "10.9.300.0/30", "10.9.2.0/30"}, PortRanges: []string{"22"},
+		})
+
+		if !scanned["10.9.1.1"] || !scanned["10.9.2.1"] {
+			t.Errorf("scanned %v, want the valid subnets scanned", scanned)
+		}
+		skipped := completion.Summary.SkippedSubnets
+		if len(skipped) != 1 || skipped[0].Subnet != "10.9.300.0/30" || !strings.Contains(skipped[0].Reason, "invalid subnet") {
+			t.Errorf("skipped = %+v, want 10.9.300.0/30 as invalid", skipped)
+		}
+	})
+
+	t.Run("cancelled", func(t *testing.T) {
+		s := newTestScanner(t, config.ScannerConfig{MaxConcurrentScans: 1, MaxConcurrentSubnets: 1, RateLimit: 100000})
+		var once sync.Once
+		s.dial = func(_, _ string, _ time.Duration) (net.Conn, error) {
+			once.Do(func() {
+				if err := s.Cancel(scanID, CancelGraceful); err != nil {
+					t.Errorf("Cancel() error = %v", err)
+				}
+			})
+			return nil, refused
+		}
+
+		completion := runAutonomous(t, s, AutonomousScanConfig{
+			ScanID: scanID, Subnets: []string{"10.9.1.0/30", "10.9.2.0/30", "10.9.3.0/30"}, PortRanges: []string{"22"},
+		})
+
+		var got []string
+		for _, skipped := range completion.Summary.SkippedSubnets {
+			if !strings.Contains(skipped.Reason, "cancelled") {
+				t.Errorf("subnet %s skipped for %q, want cancellation", skipped.Subnet, skipped.Reason)
+			}
+			got = append(got, skipped.Subnet)
+		}
+		if strings.Join(got, " ") != "10.9.2.0/30 10.9.3.0/30" {
+			t.Errorf("skipped subnets = %v, want the two never started", got)
+		}
+	})
+}
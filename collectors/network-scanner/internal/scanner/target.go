@@ -1,26 +1,63 @@
 package scanner
 
 import (
+	"context"
 	"fmt"
 	"net"
+	"sync"
 	"time"
+
+	"github.com/aiforce-discovery-agent/collectors/network-scanner/internal/metrics"
 )
 
 // ScanResult represents the result of scanning a single target.
 type ScanResult struct {
 	IP        string
+	ServerID  string // shared by every service of the host's server event
 	Port      int
 	Protocol  string
 	Open      bool
 	TimedOut  bool
+	State     string // UDP only: open, open|filtered or closed
 	Service   string
+	Version   string // from the matching banner signature, if any
+	Product   string
 	Banner    string
+	Latency   time.Duration // time taken by the TCP dial
+	CDN       string        // CDN name when the IP is a CDN edge address
+	Known     bool          // ip:port is already in the known assets inventory
+	Metadata  map[string]interface{}
 	Timestamp time.Time
+
+	// FingerprintSource is the signature that identified Service
+	// (banner:<name>) or "port" for the well-known port fallback
+	FingerprintSource string
+
+	// OS guessed from all of the host's banners once its scan is done
+	OS OSGuess
+
+	// fdExhausted is set when the port couldn't be probed for lack of
+	// file descriptors, so it neither counts as closed nor as a timeout
+	fdExhausted bool
+
+	// hostname and all of its addresses when the target resolved to
+	// several, so the server event lists every address
+	hostname      string
+	hostAddresses []string
+
+	// Leaf certificate details for TLS ports
+	TLSSubject  string
+	TLSIssuer   string
+	TLSSANs     []string
+	TLSNotAfter time.Time
 }
 
 // GetIP returns the IP address.
 func (r ScanResult) GetIP() string { return r.IP }
 
+// GetServerID returns the ID linking the service to its server event.
+func (r ScanResult) GetServerID() string { return r.ServerID }
+
 // GetPort returns the port number.
 func (r ScanResult) GetPort() int { return r.Port }
 
@@ -33,56 +70,306 @@ func (r ScanResult) GetService() string { return r.Service }
 // GetBanner returns the service banner.
 func (r ScanResult) GetBanner() string { return r.Banner }
 
+// GetVersion returns the fingerprinted service version.
+func (r ScanResult) GetVersion() string { return r.Version }
+
+// GetProduct returns the fingerprinted product, e.g. nginx or MariaDB.
+func (r ScanResult) GetProduct() string { return r.Product }
+
+// GetFingerprintSource returns how the service was identified.
+func (r ScanResult) GetFingerprintSource() string { return r.FingerprintSource }
+
+// GetCDN returns the CDN fronting the IP, if any.
+func (r ScanResult) GetCDN() string { return r.CDN }
+
+// GetKnown returns whether the service is already a known asset.
+func (r ScanResult) GetKnown() bool { return r.Known }
+
+// GetMetadata returns probe-specific metadata for the service.
+func (r ScanResult) GetMetadata() map[string]interface{} { return r.Metadata }
+
+// GetTLSSubject returns the certificate subject, if one was grabbed.
+func (r ScanResult) GetTLSSubject() string { return r.TLSSubject }
+
+// GetTLSIssuer returns the certificate issuer.
+func (r ScanResult) GetTLSIssuer() string { return r.TLSIssuer }
+
+// GetTLSSANs returns the certificate subject alternative names.
+func (r ScanResult) GetTLSSANs() []string { return r.TLSSANs }
+
+// GetTLSNotAfter returns the certificate expiry.
+func (r ScanResult) GetTLSNotAfter() time.Time { return r.TLSNotAfter }
+
 // ScanTarget scans a single IP address for open ports.
 // Uses dead host detection: after consecutive timeouts exceed the threshold,
 // the host is assumed unreachable and remaining ports are skipped.
 func (s *Scanner) ScanTarget(ip string) ([]ScanResult, error) {
+	return s.scanHost(s.ctx, ip, s.expandPortRanges(), s.config.EnableUDP)
+}
+
+// ScanTargetContext scans a single IP address bound to ctx rather than the
+// scanner's base context, which stays cancelled after Stop. While a scan is
+// running, stopping it also cancels this scan.
+func (s *Scanner) ScanTargetContext(ctx context.Context, ip string) ([]ScanResult, error) {
+	s.mu.RLock()
+	running, base := s.running, s.ctx
+	scan := s.adHocScanner()
+	s.mu.RUnlock()
+
+	if running {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithCancel(ctx)
+		defer cancel()
+		stop := context.AfterFunc(base, cancel)
+		defer stop()
+	}
+	return scan.scanHost(ctx, ip, scan.expandPortRanges(), scan.config.EnableUDP)
+}
+
+// adHocScanner returns a Scanner for one ad-hoc target scan. It shares the
+// root's limiters, dialer and caches but holds its own copy of the config,
+// exclusions and fingerprints, so a Reload during the scan doesn't race
+// its reads. Called with mu held.
+func (s *Scanner) adHocScanner() *Scanner {
+	return &Scanner{
+		config:         s.config,
+		publisher:      s.publisher,
+		logger:         s.logger,
+		limiter:        s.limiter,
+		publishLimiter: s.publishLimiter,
+		hostLimiters:   s.hostLimiters,
+		dial:           s.dial,
+		fdPausedUntil:  s.fdPausedUntil,
+		fingerprinter:  s.fingerprinter,
+		cloudDetector:  s.cloudDetector,
+		results:        s.results,
+		knownAssets:    s.knownAssets,
+		pingMode:       s.pingMode,
+		progressHub:    s.progressHub,
+		secrets:        s.secrets,
+		randSeed:       s.randSeed,
+		excludeNets:    s.excludeNets,
+		excludeIPs:     s.excludeIPs,
+		ctx:            s.ctx,
+		parent:         s,
+	}
+}
+
+// scanHost scans the given TCP ports on ip, followed by the configured UDP
+// ports when includeUDP is set.
+func (s *Scanner) scanHost(ctx context.Context, ip string, ports []int, includeUDP bool) ([]ScanResult, error) {
 	var results []ScanResult
-	ports := s.expandPortRanges()
 
 	deadHostThreshold := s.config.DeadHostThreshold
 	if deadHostThreshold <= 0 {
 		deadHostThreshold = 5
 	}
 
-	consecutiveTimeouts := 0
+	// Ports are probed in batches of PortConcurrency. Dead host detection
+	// is applied to each batch in port order, so the threshold keeps its
+	// sequential meaning. Until the host answers on some port, a batch is
+	// no larger than the timeouts left before it would be declared dead,
+	// so a dead host costs no more probes than a sequential scan.
+	//
+	// Database ports go first and are the ones most often firewalled, so a
+	// streak of timeouts only marks the host dead once it includes a
+	// non-priority port; a host dropping just its database ports still has
+	// its web ports scanned.
+	batchSize := s.config.PortConcurrency
+	if batchSize <= 1 {
+		batchSize = 1
+	}
 
+	hasOtherPorts := false
 	for _, port := range ports {
+		if !databasePriorityPorts[port] {
+			hasOtherPorts = true
+			break
+		}
+	}
+
+	consecutiveTimeouts := 0
+	streakHasOtherPort := !hasOtherPorts
+	hostDead := false
+	hostAnswered := false
+
+	for start := 0; start < len(ports) && !hostDead; {
+		size := batchSize
+		if remaining := max(deadHostThreshold-consecutiveTimeouts, 1); !hostAnswered && size > remaining {
+			size = remaining
+		}
+		end := start + size
+		if end > len(ports) {
+			end = len(ports)
+		}
+
+		batch, err := s.scanPortBatch(ctx, ip, ports[start:end])
+		if err != nil {
+			return results, err
+		}
+		start = end
+
+		for _, result := range batch {
+			if result.Open {
+				consecutiveTimeouts = 0
+				streakHasOtherPort = !hasOtherPorts
+				hostAnswered = true
+				results = append(results, result)
+			} else if result.TimedOut {
+				consecutiveTimeouts++
+				if !databasePriorityPorts[result.Port] {
+					streakHasOtherPort = true
+				}
+				if consecutiveTimeouts >= deadHostThreshold && streakHasOtherPort {
+					s.log(ctx).Debugw("Host appears dead, skipping remaining ports",
+						"ip", ip,
+						"consecutive_timeouts", consecutiveTimeouts,
+						"ports_scanned", result.Port,
+					)
+					hostDead = true
+					metrics.DeadHosts.Inc()
+					break
+				}
+			} else if !result.fdExhausted {
+				// Connection refused (RST) — host is alive, port is closed
+				consecutiveTimeouts = 0
+				streakHasOtherPort = !hasOtherPorts
+				hostAnswered = true
+			}
+		}
+	}
+
+	// UDP probes run after TCP and don't feed dead host detection, since
+	// silence is the normal response from an open UDP port
+	if includeUDP && !hostDead {
+		for _, port := range s.config.UDPPorts {
+			if err := ctx.Err(); err != nil {
+				return results, err
+			}
+
+			if result := s.scanPort(ctx, ip, port, "udp"); result.Open {
+				results = append(results, result)
+			}
+		}
+	}
+
+	// DNS servers, their version and whether they resolve for anyone
+	s.probeDNS(ctx, ip, results)
+
+	for i := range results {
+		results[i].Known = s.knownAssets.contains(ip, results[i].Port)
+	}
+
+	// OS from the host's SSH/HTTP banners and Server headers, guessed once
+	// all ports are in
+	if len(results) > 0 {
+		banners := make(map[int]string)
+		for _, result := range results {
+			clues := result.Banner
+			if server, ok := result.Metadata["http_server"].(string); ok {
+				clues += "\n" + server
+			}
+			if clues != "" {
+				banners[result.Port] = clues
+			}
+		}
+		guess := IdentifyOS(banners)
+		for i := range results {
+			results[i].OS = guess
+			if guess.Confidence > 0 {
+				if results[i].Metadata == nil {
+					results[i].Metadata = make(map[string]interface{})
+				}
+				results[i].Metadata["os_family"] = guess.Family
+			}
+		}
+	}
+
+	// Cloud provider and hosting model, detected once for the host
+	if len(results) > 0 && s.config.EnableCloudDetection {
+		cloud := s.cloudDetector.Detect(ip)
+		for i := range results {
+			if results[i].Metadata == nil {
+				results[i].Metadata = make(map[string]interface{})
+			}
+			results[i].Metadata["cloud_provider"] = string(cloud.Provider)
+			results[i].Metadata["hosting_model"] = string(cloud.HostingModel)
+			results[i].Metadata["cloud_confidence"] = cloud.Confidence
+			if cloud.Region != "" {
+				results[i].Metadata["cloud_region"] = cloud.Region
+			}
+		}
+	}
+
+	// Tag services on CDN edge addresses as fronted rather than origin assets
+	if len(results) > 0 {
+		if cdn, ok := s.cloudDetector.DetectCDN(ip); ok {
+			for i := range results {
+				results[i].CDN = cdn
+			}
+		}
+	}
+
+	return results, nil
+}
+
+// scanPortBatch probes TCP ports on a host concurrently, each waiting on
+// the shared rate limiter, and returns the results in port order.
+func (s *Scanner) scanPortBatch(ctx context.Context, ip string, ports []int) ([]ScanResult, error) {
+	results := make([]ScanResult, len(ports))
+
+	if len(ports) == 1 {
 		select {
-		case <-s.ctx.Done():
-			return results, s.ctx.Err()
+		case <-ctx.Done():
+			return nil, ctx.Err()
 		default:
 		}
 
 		// Wait for rate limiter
-		if err := s.limiter.Wait(s.ctx); err != nil {
-			return results, err
+		if err := s.waitProbe(ctx, ip); err != nil {
+			return nil, err
 		}
 
-		result := s.scanPort(ip, port, "tcp")
-		if result.Open {
-			consecutiveTimeouts = 0
-			results = append(results, result)
-		} else if result.TimedOut {
-			consecutiveTimeouts++
-			if consecutiveTimeouts >= deadHostThreshold {
-				s.logger.Debugw("Host appears dead, skipping remaining ports",
-					"ip", ip,
-					"consecutive_timeouts", consecutiveTimeouts,
-					"ports_scanned", port,
-				)
-				break
+		results[0] = s.scanPort(ctx, ip, ports[0], "tcp")
+		return results, nil
+	}
+
+	var wg sync.WaitGroup
+	var firstErr error
+	var errOnce sync.Once
+
+	for i, port := range ports {
+		wg.Add(1)
+		go func(i, port int) {
+			defer wg.Done()
+
+			// Wait for rate limiter
+			if err := s.waitProbe(ctx, ip); err != nil {
+				errOnce.Do(func() { firstErr = err })
+				return
 			}
-		} else {
-			// Connection refused (RST) — host is alive, port is closed
-			consecutiveTimeouts = 0
-		}
+			results[i] = s.scanPort(ctx, ip, port, "tcp")
+		}(i, port)
 	}
+	wg.Wait()
 
+	if firstErr != nil {
+		return nil, firstErr
+	}
 	return results, nil
 }
 
-func (s *Scanner) scanPort(ip string, port int, protocol string) ScanResult {
+func (s *Scanner) scanPort(ctx context.Context, ip string, port int, protocol string) ScanResult {
+	metrics.PortsScanned.Inc()
+	if protocol == "udp" {
+		result := s.scanUDPPort(ctx, ip, port)
+		if result.Open {
+			metrics.OpenPorts.Inc()
+		}
+		s.sanitizeBanner(&result)
+		return result
+	}
+
 	result := ScanResult{
 		IP:        ip,
 		Port:      port,
@@ -92,36 +379,153 @@ func (s *Scanner) scanPort(ip string, port int, protocol string) ScanResult {
 	}
 
 	address := net.JoinHostPort(ip, fmt.Sprintf("%d", port))
-	timeout := time.Duration(s.config.Timeout) * time.Millisecond
+	timeout := s.portTimeout(port)
 
-	conn, err := net.DialTimeout(protocol, address, timeout)
+	conn, latency, err := s.dialWithRetry(ctx, protocol, address, timeout)
+	result.Latency = latency
+	if isFDExhausted(err) {
+		// Port state unknown; don't count it as closed or as a timeout
+		s.log(ctx).Warnw("Out of file descriptors, port not probed", "address", address)
+		result.fdExhausted = true
+		return result
+	}
 	if err != nil {
 		if netErr, ok := err.(net.Error); ok && netErr.Timeout() {
 			result.TimedOut = true
 		}
+		s.recordProbe(result)
 		return result
 	}
 	defer func() { _ = conn.Close() }()
 
 	result.Open = true
+	s.recordProbe(result)
+	metrics.OpenPorts.Inc()
 
-	// Try to grab banner
-	if err := conn.SetReadDeadline(time.Now().Add(timeout)); err != nil {
-		return result
+	// Try to grab banner; ports outside the allowlist fall back to
+	// port-based identification
+	var handshake handshakeResult
+	var handshakeOK bool
+	if s.shouldGrabBanner(port) {
+		deadline := time.Now().Add(s.bannerReadTimeout(result.Latency, timeout))
+		if err := conn.SetDeadline(deadline); err != nil {
+			return result
+		}
+		result.Banner = grabBanner(conn, port, s.bannerMaxBytes(), deadline)
+
+		// Databases that only answer their own handshake (MSSQL, Oracle)
+		if result.Banner == "" {
+			if handshake, handshakeOK = s.handshake(conn, port); handshakeOK && !handshake.confirmed {
+				result.Banner = string(handshake.response)
+			}
+		}
+
+		// Silent services (HTTP on odd ports, Redis, memcached) only answer
+		// a request. Known client-first ports already had their probe.
+		if result.Banner == "" && !handshakeOK && len(s.config.ActiveProbes) > 0 && speakerForPort(port) != speakerClient {
+			result.Banner = s.activeProbe(ctx, conn, address, port)
+		}
+
+		if httpsPorts[port] {
+			result.Metadata = s.probeHTTPVersions(ctx, address, timeout)
+		}
 	}
-	buffer := make([]byte, 1024)
-	n, _ := conn.Read(buffer)
-	if n > 0 {
-		result.Banner = string(buffer[:n])
+
+	// Certificate details for the asset inventory
+	if s.isTLSPort(port) {
+		if cert, err := s.grabTLS(ctx, address, port, timeout); err == nil {
+			result.TLSSubject = cert.Subject.String()
+			result.TLSIssuer = cert.Issuer.String()
+			result.TLSSANs = certSANs(cert)
+			result.TLSNotAfter = cert.NotAfter
+		}
 	}
 
 	// Identify service using fingerprinter
-	fp := s.fingerprinter.Identify(port, result.Banner)
-	result.Service = fp.Name
+	fp := s.identify(port, result.Banner)
+	fp.applyTo(&result)
+	if handshakeOK {
+		applyHandshake(&result, handshake)
+	}
+	s.probeHTTPDatabase(ctx, &result, timeout)
+
+	// Whether Redis and memcached answer without authentication
+	s.checkAccess(ctx, &result, timeout)
+
+	// Binary greetings are fingerprinted raw but published printable
+	s.sanitizeBanner(&result)
+
+	// Status, Server header and title for web services
+	s.enrichHTTP(ctx, &result, timeout)
+
+	// Opt-in read-only login to confirm details such as the version
+	s.authenticatedProbe(ctx, &result, timeout)
 
 	return result
 }
 
+// portTimeout returns the connection timeout for port: its PortTimeouts
+// override if positive, else the global Timeout.
+func (s *Scanner) portTimeout(port int) time.Duration {
+	s.reloadMu.RLock()
+	defer s.reloadMu.RUnlock()
+
+	if ms := s.config.PortTimeouts[port]; ms > 0 {
+		return time.Duration(ms) * time.Millisecond
+	}
+	return time.Duration(s.config.Timeout) * time.Millisecond
+}
+
+// dialWithRetry dials address, retrying timed-out attempts up to
+// RetryCount times with exponential backoff. Only timeouts are retried; a
+// refused connection is a definitive answer. Running out of file
+// descriptors says nothing about the port, so those dials back off and are
+// retried separately. The returned latency is that of the final attempt.
+// Backoff waits are cut short when the scanner stops. The caller waits on
+// the probe limiters for the first dial; every later dial, whether a
+// timeout retry or a descriptor retry, goes through dialProbe, so it counts
+// against the scan's and the host's probe rate.
+func (s *Scanner) dialWithRetry(ctx context.Context, protocol, address string, timeout time.Duration) (net.Conn, time.Duration, error) {
+	backoff := time.Duration(s.config.RetryBackoffMS) * time.Millisecond
+	fdRetries := 0
+
+	for attempt, dials := 0, 0; ; attempt, dials = attempt+1, dials+1 {
+		if err := s.waitForFDs(ctx); err != nil {
+			return nil, 0, err
+		}
+		var conn net.Conn
+		var latency time.Duration
+		var err error
+		if dials == 0 {
+			dialStart := time.Now()
+			conn, err = s.dial(protocol, address, timeout)
+			latency = time.Since(dialStart)
+		} else {
+			conn, latency, err = s.dialProbe(ctx, protocol, address, timeout)
+		}
+
+		if isFDExhausted(err) && fdRetries < fdMaxRetries {
+			s.noteFDExhausted(fdRetries)
+			fdRetries++
+			attempt--
+			continue
+		}
+
+		netErr, ok := err.(net.Error)
+		if err == nil || !ok || !netErr.Timeout() || attempt >= s.config.RetryCount {
+			return conn, latency, err
+		}
+
+		if backoff > 0 {
+			select {
+			case <-time.After(backoff << uint(attempt)):
+			case <-ctx.Done():
+				return nil, latency, err
+			}
+		}
+	}
+}
+
 // databasePriorityPorts are scanned first to quickly identify database services
 // and to trigger dead host detection on high-value ports.
 var databasePriorityPorts = map[int]bool{
@@ -0,0 +1,309 @@
+package scanner
+
+import (
+	"context"
+	"errors"
+	"net"
+	"reflect"
+	"strconv"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+
+	"golang.org/x/time/rate"
+
+	"github.com/aiforce-discovery-agent/collectors/network-scanner/internal/config"
+)
+
+// portDial returns a dialFunc answering each port as state says: "open",
+// "refused" or "timeout". Each dial takes delay, and the dials in flight
+// peak at *peak.
+func portDial(state func(port int) string, delay time.Duration, dials, peak *int32) dialFunc {
+	var inFlight int32
+	return func(_, address string, _ time.Duration) (net.Conn, error) {
+		atomic.AddInt32(dials, 1)
+		n := atomic.AddInt32(&inFlight, 1)
+		defer atomic.AddInt32(&inFlight, -1)
+		for {
+			p := atomic.LoadInt32(peak)
+			if n <= p || atomic.CompareAndSwapInt32(peak, p, n) {
+				break
+			}
+		}
+		time.Sleep(delay)
+
+		_, portStr, _ := net.SplitHostPort(address)
+		port, _ := strconv.Atoi(portStr)
+		switch state(port) {
+		case "open":
+			client, server := net.Pipe()
+			_ = server.Close()
+			return client, nil
+		case "timeout":
+			return nil, timeoutErr{}
+		default:
+			return nil, &net.OpError{Op: "dial", Net: "tcp", Err: syscall.ECONNREFUSED}
+		}
+	}
+}
+
+// portState answers port as match and every other port as rest.
+func portState(port int, match, rest string) func(int) string {
+	return func(p int) string {
+		if p == port {
+			return match
+		}
+		return rest
+	}
+}
+
+func TestScanHostPortConcurrency(t *testing.T) {
+	const delay = 20 * time.Millisecond
+	ports := make([]int, 16)
+	for i := range ports {
+		ports[i] = 8000 + i
+	}
+
+	tests := []struct {
+		name        string
+		concurrency int
+		state       func(port int) string
+		wantDials   int32
+		wantPeak    int32
+		wantOpen    int
+		maxElapsed  time.Duration
+	}{
+		{
+			name:        "sequential",
+			concurrency: 1,
+			state:       portState(8003, "open", "refused"),
+			wantDials:   16,
+			wantPeak:    1,
+			wantOpen:    1,
+		},
+		{
+			name:        "eight at a time",
+			concurrency: 8,
+			state:       portState(8003, "open", "refused"),
+			wantDials:   16,
+			wantPeak:    8,
+			wantOpen:    1,
+			maxElapsed:  16 * delay / 2,
+		},
+		{
+			name:        "dead host costs the threshold",
+			concurrency: 8,
+			state:       func(int) string { return "timeout" },
+			wantDials:   3,
+			wantPeak:    3,
+		},
+		{
+			name:        "host that answered gets full batches",
+			concurrency: 4,
+			state:       portState(8000, "refused", "timeout"),
+			wantDials:   7, // a batch of 3 within the threshold, then one of 4
+			wantPeak:    4,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestScanner(t, config.ScannerConfig{
+				Timeout:           100,
+				PortConcurrency:   tt.concurrency,
+				DeadHostThreshold: 3,
+			})
+			var dials, peak int32
+			s.dial = portDial(tt.state, delay, &dials, &peak)
+
+			start := time.Now()
+			results, err := s.scanHost(context.Background(), "192.0.2.1", ports, false)
+			elapsed := time.Since(start)
+			if err != nil {
+				t.Fatalf("scanHost() error = %v", err)
+			}
+
+			if dials != tt.wantDials {
+				t.Errorf("dials = %d, want %d", dials, tt.wantDials)
+			}
+			if peak != tt.wantPeak {
+				t.Errorf("dials in flight peaked at %d, want %d", peak, tt.wantPeak)
+			}
+			if len(results) != tt.wantOpen {
+				t.Errorf("%d open ports, want %d", len(results), tt.wantOpen)
+			}
+			if tt.maxElapsed > 0 && elapsed > tt.maxElapsed {
+				t.Errorf("scan took %v, want under %v", elapsed, tt.maxElapsed)
+			}
+		})
+	}
+}
+
+// Ports probed together are reported in port order, whichever answers
+// first.
+func TestScanHostPortConcurrencyKeepsOrder(t *testing.T) {
+	s := newTestScanner(t, config.ScannerConfig{Timeout: 1000, PortConcurrency: 4})
+	s.dial = func(_, address string, _ time.Duration) (net.Conn, error) {
+		_, portStr, _ := net.SplitHostPort(address)
+		port, _ := strconv.Atoi(portStr)
+		// Higher ports answer first
+		time.Sleep(time.Duration(8004-port) * 5 * time.Millisecond)
+		client, server := net.Pipe()
+		_ = server.Close()
+		return client, nil
+	}
+
+	results, err := s.scanHost(context.Background(), "192.0.2.1", []int{8000, 8001, 8002, 8003}, false)
+	if err != nil {
+		t.Fatalf("scanHost() error = %v", err)
+	}
+	var got []int
+	for _, result := range results {
+		got = append(got, result.Port)
+	}
+	if want := []int{8000, 8001, 8002, 8003}; !reflect.DeepEqual(got, want) {
+		t.Errorf("open ports = %v, want %v", got, want)
+	}
+}
+
+// Ports probed in parallel still wait on the shared rate limit.
+func TestPortConcurrencySharesRateLimit(t *testing.T) {
+	const interval = 20 * time.Millisecond
+
+	s := newTestScanner(t, config.ScannerConfig{Timeout: 1000, PortConcurrency: 8})
+	s.limiter = rate.NewLimiter(rate.Every(interval), 1)
+	var dials, peak int32
+	s.dial = portDial(portState(0, "open", "refused"), 0, &dials, &peak)
+
+	ports := []int{8000, 8001, 8002, 8003, 8004, 8005, 8006, 8007}
+	start := time.Now()
+	if _, err := s.scanHost(context.Background(), "192.0.2.1", ports, false); err != nil {
+		t.Fatalf("scanHost() error = %v", err)
+	}
+	// The first probe uses the burst; each of the other seven waits its turn
+	if elapsed, want := time.Since(start), 7*interval; elapsed < want-interval/2 {
+		t.Errorf("8 ports took %v, want at least %v at the rate limit", elapsed, want)
+	}
+	if dials != 8 {
+		t.Errorf("dials = %d, want 8", dials)
+	}
+}
+
+func TestScanHostFilteredDatabasePorts(t *testing.T) {
+	tests := []struct {
+		name      string
+		ports     []int
+		state     func(port int) string
+		wantOpen  []int
+		wantDials int32
+	}{
+		{
+			name:      "database ports dropped, web open",
+			ports:     []int{1433, 3306, 5432, 80},
+			state:     portState(80, "open", "timeout"),
+			wantOpen:  []int{80},
+			wantDials: 4,
+		},
+		{
+			name:      "more database timeouts than the threshold",
+			ports:     []int{1433, 1521, 3306, 5432, 6379, 27017, 80, 443},
+			state:     portState(80, "open", "timeout"),
+			wantOpen:  []int{80},
+			wantDials: 8,
+		},
+		{
+			name:      "dead host still stops at a non-database port",
+			ports:     []int{1433, 3306, 5432, 80, 443, 8080},
+			state:     func(int) string { return "timeout" },
+			wantDials: 4,
+		},
+		{
+			name:      "database-only scan of a dead host",
+			ports:     []int{1433, 1521, 3306, 5432, 6379},
+			state:     func(int) string { return "timeout" },
+			wantDials: 3,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestScanner(t, config.ScannerConfig{Timeout: 100, DeadHostThreshold: 3})
+			var dials, peak int32
+			s.dial = portDial(tt.state, 0, &dials, &peak)
+
+			results, err := s.scanHost(context.Background(), "192.0.2.1", tt.ports, false)
+			if err != nil {
+				t.Fatalf("scanHost() error = %v", err)
+			}
+
+			var open []int
+			for _, result := range results {
+				open = append(open, result.Port)
+			}
+			if !reflect.DeepEqual(open, tt.wantOpen) {
+				t.Errorf("open ports = %v, want %v", open, tt.wantOpen)
+			}
+			if dials != tt.wantDials {
+				t.Errorf("dials = %d, want %d", dials, tt.wantDials)
+			}
+		})
+	}
+}
+
+func TestScanTargetContext(t *testing.T) {
+	cfg := config.ScannerConfig{PortRanges: []string{"20-29"}, Timeout: 1000}
+
+	t.Run("after stop", func(t *testing.T) {
+		s := newTestScanner(t, cfg)
+		var dials, peak int32
+		s.dial = portDial(portState(22, "open", "refused"), 0, &dials, &peak)
+		// As after Stop: the base context stays cancelled
+		s.cancel()
+
+		if _, err := s.ScanTarget("192.0.2.10"); !errors.Is(err, context.Canceled) {
+			t.Errorf("ScanTarget() error = %v, want the base context cancelled", err)
+		}
+		results, err := s.ScanTargetContext(context.Background(), "192.0.2.10")
+		if err != nil {
+			t.Fatalf("ScanTargetContext() error = %v", err)
+		}
+		if len(results) != 1 || results[0].Port != 22 {
+			t.Errorf("results = %+v, want port 22 open", results)
+		}
+	})
+
+	t.Run("request cancelled", func(t *testing.T) {
+		s := newTestScanner(t, cfg)
+		var dials, peak int32
+		s.dial = portDial(portState(22, "open", "refused"), 0, &dials, &peak)
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		if _, err := s.ScanTargetContext(ctx, "192.0.2.10"); !errors.Is(err, context.Canceled) {
+			t.Errorf("ScanTargetContext() error = %v, want context.Canceled", err)
+		}
+		if dials != 0 {
+			t.Errorf("dialed %d ports after the request was cancelled", dials)
+		}
+	})
+
+	t.Run("running scan stopped", func(t *testing.T) {
+		s := newTestScanner(t, cfg)
+		s.running = true
+		var dials int32
+		s.dial = func(_, _ string, _ time.Duration) (net.Conn, error) {
+			if atomic.AddInt32(&dials, 1) == 1 {
+				// Stopping reaches the ad-hoc scan asynchronously
+				s.cancel()
+				time.Sleep(50 * time.Millisecond)
+			}
+			return nil, &net.OpError{Op: "dial", Net: "tcp", Err: syscall.ECONNREFUSED}
+		}
+
+		if _, err := s.ScanTargetContext(context.Background(), "192.0.2.10"); !errors.Is(err, context.Canceled) {
+			t.Errorf("ScanTargetContext() error = %v, want the scan cancelled with the running one", err)
+		}
+		if dials >= 10 {
+			t.Errorf("dialed all %d ports, want the scan stopped early", dials)
+		}
+	})
+}
@@ -0,0 +1,266 @@
+package scanner
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strconv"
+	"time"
+
+	"github.com/aiforce-discovery-agent/collectors/network-scanner/internal/callback"
+	"github.com/aiforce-discovery-agent/collectors/network-scanner/internal/config"
+)
+
+// targetsLabel identifies the explicit target list in logs and breakdowns.
+const targetsLabel = "targets"
+
+// maxTargetRange bounds a start-end range in the target list, which is
+// expanded up front; larger ranges belong in subnets, which are streamed.
+const maxTargetRange = 65536
+
+// hostTarget is a host to scan: an address, or a hostname with all of its
+// addresses. A non-zero port restricts the scan to that TCP port;
+// otherwise all configured ports are scanned. pos is the host's position
+// in its source's scan order, for checkpoints.
+type hostTarget struct {
+	ip   string
+	port int
+	pos  int64
+
+	// Set for hostname targets. A hostname resolving to several addresses,
+	// e.g. a dual-stack host, is one target: every address is scanned by
+	// the same worker and published as one server.
+	hostname  string
+	addresses []string
+}
+
+// addrs returns every address of the host.
+func (h hostTarget) addrs() []string {
+	if len(h.addresses) > 0 {
+		return h.addresses
+	}
+	return []string{h.ip}
+}
+
+// countAddresses returns the number of addresses across hosts.
+func countAddresses(hosts []hostTarget) int64 {
+	var n int64
+	for _, host := range hosts {
+		n += int64(len(host.addrs()))
+	}
+	return n
+}
+
+// parseTargetRange parses a start-end target range, enforcing
+// maxTargetRange.
+func parseTargetRange(target string) (net.IP, int64, error) {
+	first, last, err := config.ParseIPRange(target)
+	if err != nil {
+		return nil, 0, err
+	}
+	size := rangeSize(first, last)
+	if size > maxTargetRange {
+		return nil, 0, fmt.Errorf("target range %q has %d addresses, more than %d; list it under subnets instead",
+			target, size, maxTargetRange)
+	}
+	return first, size, nil
+}
+
+// parseTarget splits a target entry into host and optional port. Accepted
+// forms are IP, hostname, host:port and [IPv6]:port; a bare IPv6 address
+// is treated as a host.
+func parseTarget(target string) (string, int, error) {
+	if net.ParseIP(target) != nil {
+		return target, 0, nil
+	}
+
+	host, portStr, err := net.SplitHostPort(target)
+	if err != nil {
+		// No port component
+		return target, 0, nil
+	}
+
+	port, err := strconv.Atoi(portStr)
+	if err != nil || port < 1 || port > 65535 {
+		return "", 0, fmt.Errorf("invalid port in target %q", target)
+	}
+	return host, port, nil
+}
+
+// resolveTargets expands target entries into hosts, resolving hostnames via
+// DNS and listing start-end ranges inclusively. Entries that fail to parse
+// or resolve are logged and skipped. A hostname is one host covering every
+// address it resolves to.
+func (s *Scanner) resolveTargets(targets []string) []hostTarget {
+	var hosts []hostTarget
+
+	for _, target := range targets {
+		if isIPRange(target) {
+			first, size, err := parseTargetRange(target)
+			if err != nil {
+				s.logger.Warnw("Invalid target", "target", target, "error", err)
+				s.recordError(callback.ErrorInvalidTarget, err)
+				continue
+			}
+			for i := int64(0); i < size; i++ {
+				hosts = append(hosts, hostTarget{ip: offsetIP(first, uint64(i)).String()})
+			}
+			continue
+		}
+
+		host, port, err := parseTarget(target)
+		if err != nil {
+			s.logger.Warnw("Invalid target", "target", target, "error", err)
+			s.recordError(callback.ErrorInvalidTarget, err)
+			continue
+		}
+
+		if ip := net.ParseIP(host); ip != nil {
+			hosts = append(hosts, hostTarget{ip: ip.String(), port: port})
+			continue
+		}
+
+		ctx, cancel := context.WithTimeout(s.ctx, 5*time.Second)
+		addrs, err := net.DefaultResolver.LookupHost(ctx, host)
+		cancel()
+		if err != nil {
+			s.logger.Warnw("Failed to resolve target", "target", target, "error", err)
+			s.recordError(callback.ErrorResolveFailed, err)
+			continue
+		}
+		resolved := hostTarget{ip: addrs[0], port: port, hostname: host}
+		if len(addrs) > 1 {
+			resolved.addresses = addrs
+		}
+		hosts = append(hosts, resolved)
+	}
+
+	return hosts
+}
+
+// ValidateTargets checks that every target entry is well-formed. Hostnames
+// are not resolved here.
+func (s *Scanner) ValidateTargets(targets []string) error {
+	for _, target := range targets {
+		if isIPRange(target) {
+			if _, _, err := parseTargetRange(target); err != nil {
+				return err
+			}
+			continue
+		}
+		if _, _, err := parseTarget(target); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// scanHostTarget scans each non-excluded address of a host on its
+// configured ports, or on the single port given with the target. Results
+// of all addresses are returned together so they publish as one server. A
+// failed address is logged and skipped; its error is returned only when no
+// address produced results.
+func (s *Scanner) scanHostTarget(ctx context.Context, host hostTarget) ([]ScanResult, error) {
+	var results []ScanResult
+	var firstErr error
+	for _, addr := range host.addrs() {
+		if len(host.addresses) > 0 && s.isExcluded(addr) {
+			continue
+		}
+
+		var found []ScanResult
+		var err error
+		if host.port == 0 {
+			found, err = s.scanHost(ctx, addr, s.expandPortRanges(), s.config.EnableUDP)
+		} else {
+			found, err = s.scanHost(ctx, addr, []int{host.port}, false)
+		}
+		results = append(results, found...)
+		if err == context.Canceled {
+			return results, err
+		}
+		if err != nil {
+			if firstErr == nil {
+				firstErr = err
+			}
+			if len(host.addresses) > 0 {
+				s.log(ctx).Warnw("Scan error", "ip", addr, "hostname", host.hostname, "error", err)
+			}
+		}
+	}
+
+	for i := range results {
+		results[i].hostname = host.hostname
+		results[i].hostAddresses = host.addresses
+	}
+	if len(results) == 0 && firstErr != nil {
+		return nil, firstErr
+	}
+	return results, nil
+}
+
+// hostExcluded reports whether every address of host is excluded.
+func (s *Scanner) hostExcluded(host hostTarget) bool {
+	for _, addr := range host.addrs() {
+		if !s.isExcluded(addr) {
+			return false
+		}
+	}
+	return true
+}
+
+// hostAlive reports whether any address of host passes the liveness check.
+func (s *Scanner) hostAlive(host hostTarget) bool {
+	for _, addr := range host.addrs() {
+		if s.isAlive(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// scanTargetsAutonomous scans resolved explicit targets with the worker pool.
+func (s *Scanner) scanTargetsAutonomous(hosts []hostTarget, reporter *callback.Reporter) {
+	defer s.wg.Done()
+
+	s.logger.Infow("Scanning explicit targets", "count", len(hosts))
+
+	s.scanHostsAutonomous(targetsLabel, reporter, func(emit func(hostTarget) bool) {
+		for _, host := range hosts {
+			if !emit(host) {
+				return
+			}
+		}
+	})
+}
+
+// scanTargets scans explicit targets sequentially (legacy mode).
+func (s *Scanner) scanTargets(targets []string) {
+	defer s.wg.Done()
+
+	for _, host := range s.resolveTargets(targets) {
+		select {
+		case <-s.feedCtx.Done():
+			return
+		default:
+		}
+
+		if s.hostExcluded(host) {
+			continue
+		}
+		if s.config.EnablePing && !s.hostAlive(host) {
+			continue
+		}
+
+		results, err := s.scanHostTarget(s.ctx, host)
+		if err != nil {
+			if err == context.Canceled {
+				return
+			}
+			s.logger.Warnw("Scan error", "ip", host.ip, "error", err)
+			continue
+		}
+
+		s.publishResults(s.ctx, results)
+	}
+}
@@ -0,0 +1,270 @@
+package scanner
+
+import (
+	"net"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/aiforce-discovery-agent/collectors/network-scanner/internal/config"
+)
+
+func TestPublishResultsDualStackHost(t *testing.T) {
+	addresses := []string{"192.0.2.10", "2001:db8::10"}
+	dualStack := func(ip string, port int, service string, os OSGuess) ScanResult {
+		return ScanResult{
+			IP: ip, Port: port, Protocol: "tcp", Open: true, Service: service, OS: os,
+			hostname: "app.example.com", hostAddresses: addresses,
+		}
+	}
+	ubuntu := OSGuess{Name: "Ubuntu", Family: "Linux", Confidence: 0.9}
+	linux := OSGuess{Name: "Linux", Family: "Linux", Confidence: 0.4}
+
+	tests := []struct {
+		name         string
+		hostEvents   bool
+		results      []ScanResult
+		wantPorts    []int
+		wantIPs      []string
+		wantHostname string
+		wantOS       string
+		wantSSH      bool
+		wantWeb      bool
+	}{
+		{
+			name: "ports from every address in one server event",
+			results: []ScanResult{
+				dualStack("192.0.2.10", 443, "HTTPS", linux),
+				dualStack("2001:db8::10", 22, "SSH", ubuntu),
+				dualStack("2001:db8::10", 443, "HTTPS", ubuntu),
+			},
+			wantPorts:    []int{22, 443},
+			wantIPs:      addresses,
+			wantHostname: "app.example.com",
+			wantOS:       "Ubuntu",
+			wantSSH:      true,
+			wantWeb:      true,
+		},
+		{
+			name:       "consolidated host event",
+			hostEvents: true,
+			results: []ScanResult{
+				dualStack("192.0.2.10", 22, "SSH", OSGuess{}),
+				dualStack("2001:db8::10", 80, "HTTP", linux),
+			},
+			wantPorts:    []int{22, 80},
+			wantIPs:      addresses,
+			wantHostname: "app.example.com",
+			wantOS:       "Linux",
+			wantSSH:      true,
+			wantWeb:      true,
+		},
+		{
+			name: "single-address hostname",
+			results: []ScanResult{
+				{IP: "192.0.2.20", Port: 80, Protocol: "tcp", Open: true, Service: "HTTP", hostname: "web.example.com"},
+			},
+			wantPorts:    []int{80},
+			wantIPs:      []string{"192.0.2.20"},
+			wantHostname: "web.example.com",
+			wantWeb:      true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestScanner(t, config.ScannerConfig{HostEvents: tt.hostEvents})
+			pub := &recordingPublisher{}
+			s.publisher = pub
+
+			published, failed := s.publishResults(s.ctx, tt.results)
+			if failed != 0 || len(published) != len(tt.results) {
+				t.Fatalf("published %d, failed %d; want %d, 0", len(published), failed, len(tt.results))
+			}
+			if len(pub.servers) != 1 {
+				t.Fatalf("server events = %d, want 1", len(pub.servers))
+			}
+
+			server := pub.servers[0]
+			if !reflect.DeepEqual(server.OpenPorts, tt.wantPorts) {
+				t.Errorf("OpenPorts = %v, want %v", server.OpenPorts, tt.wantPorts)
+			}
+			if !reflect.DeepEqual(server.IPAddresses, tt.wantIPs) {
+				t.Errorf("IPAddresses = %v, want %v", server.IPAddresses, tt.wantIPs)
+			}
+			if server.Hostname != tt.wantHostname {
+				t.Errorf("Hostname = %q, want %q", server.Hostname, tt.wantHostname)
+			}
+			gotOS := ""
+			if server.OS != nil {
+				gotOS = server.OS.Name
+			}
+			if gotOS != tt.wantOS {
+				t.Errorf("OS = %q, want %q", gotOS, tt.wantOS)
+			}
+			if server.Summary.HasSSH != tt.wantSSH || server.Summary.HasWeb != tt.wantWeb {
+				t.Errorf("Summary = %+v, want ssh %v web %v", server.Summary, tt.wantSSH, tt.wantWeb)
+			}
+			for _, result := range published {
+				if result.ServerID != server.ServerID {
+					t.Errorf("service %s:%d server_id = %q, want %q", result.IP, result.Port, result.ServerID, server.ServerID)
+				}
+			}
+		})
+	}
+}
+
+func TestHostTargetAddresses(t *testing.T) {
+	tests := []struct {
+		name         string
+		host         hostTarget
+		exclude      []string
+		wantAddrs    int
+		wantExcluded bool
+	}{
+		{name: "single address", host: hostTarget{ip: "192.0.2.1"}, wantAddrs: 1},
+		{name: "excluded address", host: hostTarget{ip: "192.0.2.1"}, exclude: []string{"192.0.2.1"}, wantAddrs: 1, wantExcluded: true},
+		{
+			name:      "dual-stack with one address excluded",
+			host:      hostTarget{ip: "192.0.2.1", hostname: "h", addresses: []string{"192.0.2.1", "2001:db8::1"}},
+			exclude:   []string{"192.0.2.1"},
+			wantAddrs: 2,
+		},
+		{
+			name:         "dual-stack with every address excluded",
+			host:         hostTarget{ip: "192.0.2.1", hostname: "h", addresses: []string{"192.0.2.1", "2001:db8::1"}},
+			exclude:      []string{"192.0.2.1", "2001:db8::1"},
+			wantAddrs:    2,
+			wantExcluded: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestScanner(t, config.ScannerConfig{})
+			excludeIPs, err := parseExcludeIPs(tt.exclude)
+			if err != nil {
+				t.Fatal(err)
+			}
+			s.excludeIPs = excludeIPs
+
+			if got := countAddresses([]hostTarget{tt.host}); got != int64(tt.wantAddrs) {
+				t.Errorf("countAddresses() = %d, want %d", got, tt.wantAddrs)
+			}
+			if got := s.hostExcluded(tt.host); got != tt.wantExcluded {
+				t.Errorf("hostExcluded() = %v, want %v", got, tt.wantExcluded)
+			}
+		})
+	}
+}
+
+func TestParseTarget(t *testing.T) {
+	tests := []struct {
+		target   string
+		wantHost string
+		wantPort int
+		wantErr  bool
+	}{
+		{target: "192.0.2.1", wantHost: "192.0.2.1"},
+		{target: "2001:db8::1", wantHost: "2001:db8::1"},
+		{target: "192.0.2.1:8443", wantHost: "192.0.2.1", wantPort: 8443},
+		{target: "[2001:db8::1]:22", wantHost: "2001:db8::1", wantPort: 22},
+		{target: "db.example.com", wantHost: "db.example.com"},
+		{target: "db.example.com:5432", wantHost: "db.example.com", wantPort: 5432},
+		{target: "192.0.2.1:0", wantErr: true},
+		{target: "192.0.2.1:65536", wantErr: true},
+		{target: "db.example.com:pg", wantErr: true},
+	}
+	for _, tt := range tests {
+		host, port, err := parseTarget(tt.target)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("parseTarget(%q) = %s, %d; want an error", tt.target, host, port)
+			}
+			continue
+		}
+		if err != nil || host != tt.wantHost || port != tt.wantPort {
+			t.Errorf("parseTarget(%q) = %s, %d, %v; want %s, %d", tt.target, host, port, err, tt.wantHost, tt.wantPort)
+		}
+	}
+}
+
+func TestValidateTargets(t *testing.T) {
+	s := newTestScanner(t, config.ScannerConfig{})
+	tests := []struct {
+		name    string
+		targets []string
+		wantErr string
+	}{
+		{name: "valid", targets: []string{"192.0.2.1", "192.0.2.2:443", "db.example.com", "192.0.2.10-192.0.2.20"}},
+		{name: "bad port", targets: []string{"192.0.2.1", "192.0.2.2:99999"}, wantErr: "192.0.2.2:99999"},
+		{name: "range too large", targets: []string{"10.0.0.0-10.1.0.0"}, wantErr: "list it under subnets"},
+		{name: "reversed range", targets: []string{"192.0.2.20-192.0.2.10"}, wantErr: "192.0.2.20"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := s.ValidateTargets(tt.targets)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("ValidateTargets() error = %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("ValidateTargets() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestResolveTargets(t *testing.T) {
+	s := newTestScanner(t, config.ScannerConfig{})
+	hosts := s.resolveTargets([]string{"192.0.2.1", "192.0.2.2:8443", "192.0.2.254-192.0.2.255", "192.0.2.3:0", "[2001:db8::1]:22"})
+
+	want := []hostTarget{
+		{ip: "192.0.2.1"},
+		{ip: "192.0.2.2", port: 8443},
+		{ip: "192.0.2.254"},
+		{ip: "192.0.2.255"},
+		{ip: "2001:db8::1", port: 22},
+	}
+	if !reflect.DeepEqual(hosts, want) {
+		t.Errorf("resolveTargets() = %+v, want %+v", hosts, want)
+	}
+}
+
+// Targets are scanned alongside subnets; a target with a port is scanned on
+// that port only.
+func TestAutonomousScanTargetsAndSubnets(t *testing.T) {
+	s := newTestScanner(t, config.ScannerConfig{MaxConcurrentScans: 1, RateLimit: 100000})
+	var mu sync.Mutex
+	var dialed []string
+	s.dial = func(_, address string, _ time.Duration) (net.Conn, error) {
+		mu.Lock()
+		dialed = append(dialed, address)
+		mu.Unlock()
+		return nil, &net.OpError{Op: "dial", Net: "tcp", Err: syscall.ECONNREFUSED}
+	}
+
+	completion := runAutonomous(t, s, AutonomousScanConfig{
+		Subnets:    []string{"10.9.0.0/31"},
+		Targets:    []string{"192.0.2.10", "192.0.2.20:8443"},
+		PortRanges: []string{"22"},
+	})
+	if completion.Status != "completed" {
+		t.Errorf("status = %q, want completed", completion.Status)
+	}
+
+	sort.Strings(dialed)
+	want := []string{"10.9.0.0:22", "10.9.0.1:22", "192.0.2.10:22", "192.0.2.20:8443"}
+	if !reflect.DeepEqual(dialed, want) {
+		t.Errorf("dialed %v, want %v", dialed, want)
+	}
+	if status := s.lastSession.status(); status.TotalHosts != 4 || status.ScannedHosts != 4 {
+		t.Errorf("hosts scanned %d of %d, want 4 of 4", status.ScannedHosts, status.TotalHosts)
+	}
+}
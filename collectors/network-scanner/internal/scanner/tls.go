@@ -0,0 +1,159 @@
+package scanner
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"time"
+)
+
+// starttlsProtocols maps ports whose services upgrade a plaintext connection
+// to TLS. These get their own connection so the plain banner grab is
+// unaffected.
+var starttlsProtocols = map[int]string{
+	25:   "smtp",
+	587:  "smtp",
+	5432: "postgres",
+}
+
+// postgresSSLRequest is the SSLRequest message: length 8, code 80877103.
+var postgresSSLRequest = []byte{0x00, 0x00, 0x00, 0x08, 0x04, 0xd2, 0x16, 0x2f}
+
+// grabTLS completes a TLS handshake with the service at address, negotiating
+// STARTTLS first where the port requires it, and returns the leaf
+// certificate. Certificates are not verified.
+func (s *Scanner) grabTLS(ctx context.Context, address string, port int, timeout time.Duration) (*x509.Certificate, error) {
+	var conn *tls.Conn
+	var err error
+
+	if proto, ok := starttlsProtocols[port]; ok {
+		conn, err = s.dialSTARTTLS(ctx, address, proto, timeout)
+	} else {
+		conn, err = s.dialTLS(ctx, address, timeout, nil)
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = conn.Close() }()
+
+	certs := conn.ConnectionState().PeerCertificates
+	if len(certs) == 0 {
+		return nil, fmt.Errorf("no peer certificate")
+	}
+	return certs[0], nil
+}
+
+// dialSTARTTLS connects in plaintext, asks the server to upgrade using the
+// given protocol's mechanism, and performs the TLS handshake.
+func (s *Scanner) dialSTARTTLS(ctx context.Context, address, proto string, timeout time.Duration) (*tls.Conn, error) {
+	raw, _, err := s.dialProbe(ctx, "tcp", address, timeout)
+	if err != nil {
+		return nil, err
+	}
+	if err := raw.SetDeadline(time.Now().Add(timeout)); err != nil {
+		_ = raw.Close()
+		return nil, err
+	}
+
+	switch proto {
+	case "postgres":
+		err = startPostgresTLS(raw)
+	case "smtp":
+		err = startSMTPTLS(raw)
+	default:
+		err = fmt.Errorf("unsupported STARTTLS protocol %q", proto)
+	}
+	if err != nil {
+		_ = raw.Close()
+		return nil, err
+	}
+
+	host, _, _ := net.SplitHostPort(address)
+	conn := tls.Client(raw, &tls.Config{
+		InsecureSkipVerify: true,
+		ServerName:         host,
+	})
+	if err := conn.Handshake(); err != nil {
+		_ = raw.Close()
+		return nil, err
+	}
+	return conn, nil
+}
+
+// startPostgresTLS sends an SSLRequest; the server answers 'S' if it will
+// accept a TLS handshake.
+func startPostgresTLS(conn net.Conn) error {
+	if _, err := conn.Write(postgresSSLRequest); err != nil {
+		return err
+	}
+	reply := make([]byte, 1)
+	if _, err := io.ReadFull(conn, reply); err != nil {
+		return err
+	}
+	if reply[0] != 'S' {
+		return fmt.Errorf("postgres server refused SSL")
+	}
+	return nil
+}
+
+// startSMTPTLS reads the greeting, sends EHLO and STARTTLS, and waits for
+// the 220 go-ahead.
+func startSMTPTLS(conn net.Conn) error {
+	reader := bufio.NewReader(conn)
+	if err := readSMTPReply(reader, "220"); err != nil {
+		return err
+	}
+	if _, err := fmt.Fprintf(conn, "EHLO network-scanner\r\n"); err != nil {
+		return err
+	}
+	if err := readSMTPReply(reader, "250"); err != nil {
+		return err
+	}
+	if _, err := fmt.Fprintf(conn, "STARTTLS\r\n"); err != nil {
+		return err
+	}
+	return readSMTPReply(reader, "220")
+}
+
+// readSMTPReply reads a possibly multi-line SMTP reply and checks its code.
+func readSMTPReply(reader *bufio.Reader, code string) error {
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return err
+		}
+		if !strings.HasPrefix(line, code) {
+			return fmt.Errorf("unexpected SMTP reply: %s", strings.TrimSpace(line))
+		}
+		// "250-..." continues, "250 ..." ends the reply
+		if len(line) < 4 || line[3] != '-' {
+			return nil
+		}
+	}
+}
+
+// certSANs lists the DNS names and IP addresses in a certificate's SAN
+// extension.
+func certSANs(cert *x509.Certificate) []string {
+	sans := make([]string, 0, len(cert.DNSNames)+len(cert.IPAddresses))
+	sans = append(sans, cert.DNSNames...)
+	for _, ip := range cert.IPAddresses {
+		sans = append(sans, ip.String())
+	}
+	return sans
+}
+
+// isTLSPort reports whether certificates should be grabbed from port.
+func (s *Scanner) isTLSPort(port int) bool {
+	for _, p := range s.config.TLSPorts {
+		if p == port {
+			return true
+		}
+	}
+	return false
+}
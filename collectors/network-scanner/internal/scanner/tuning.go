@@ -0,0 +1,97 @@
+package scanner
+
+import (
+	"math"
+
+	"github.com/aiforce-discovery-agent/collectors/network-scanner/internal/config"
+)
+
+// TuningAuto asks the scanner to derive concurrency and rate from the size
+// of the scan.
+const TuningAuto = "auto"
+
+// ScanTuning is the concurrency and rate applied to a scan.
+type ScanTuning struct {
+	Hosts              int64 `json:"hosts"`
+	MaxConcurrentHosts int   `json:"max_concurrent_hosts"`
+	RateLimitPPS       int   `json:"rate_limit_pps"`
+}
+
+// autoTuningTiers map total host count to tuning: small scans go wide and
+// fast, large ones are rate-capped to stay polite to the network.
+var autoTuningTiers = []struct {
+	maxHosts     int64
+	concurrency  int
+	rateLimitPPS int
+}{
+	{16, 16, 1000},    // up to a /28
+	{256, 64, 1000},   // up to a /24
+	{4096, 128, 500},  // up to a /20
+	{65536, 256, 300}, // up to a /16
+	{math.MaxInt64, 256, 200},
+}
+
+// AutoTune fills in the concurrency and rate the caller left unset,
+// derived from the number of hosts in the scan and bounded by the
+// configured ceilings. It returns the tuning the scan will run with.
+func (s *Scanner) AutoTune(cfg *AutonomousScanConfig) ScanTuning {
+	hosts := s.countHosts(cfg.Subnets, cfg.Targets)
+
+	tier := autoTuningTiers[len(autoTuningTiers)-1]
+	for _, t := range autoTuningTiers {
+		if hosts <= t.maxHosts {
+			tier = t
+			break
+		}
+	}
+
+	concurrency := tier.concurrency
+	if hosts > 0 && hosts < int64(concurrency) {
+		concurrency = int(hosts)
+	}
+	if limit := s.config.AutoTuneMaxConcurrency; limit > 0 && concurrency > limit {
+		concurrency = limit
+	}
+	pps := tier.rateLimitPPS
+	if limit := s.config.AutoTuneMaxRatePPS; limit > 0 && pps > limit {
+		pps = limit
+	}
+
+	if cfg.MaxConcurrentHosts <= 0 {
+		cfg.MaxConcurrentHosts = concurrency
+	}
+	if cfg.RateLimitPPS <= 0 {
+		cfg.RateLimitPPS = pps
+	}
+
+	return ScanTuning{
+		Hosts:              hosts,
+		MaxConcurrentHosts: cfg.MaxConcurrentHosts,
+		RateLimitPPS:       cfg.RateLimitPPS,
+	}
+}
+
+// countHosts returns the number of addresses in subnets plus one per
+// target, or the size of a target range. Targets are not resolved, so a
+// hostname counts as one host.
+func (s *Scanner) countHosts(subnets, targets []string) int64 {
+	var hosts int64
+	for _, subnet := range subnets {
+		r, err := s.parseScanSubnet(subnet)
+		if err != nil {
+			continue
+		}
+		hosts += r.size
+		if hosts > maxAddressCount {
+			hosts = maxAddressCount
+		}
+	}
+	for _, target := range targets {
+		if first, last, err := config.ParseIPRange(target); isIPRange(target) && err == nil {
+			hosts += rangeSize(first, last)
+		} else {
+			hosts++
+		}
+	}
+	return hosts
+}
@@ -0,0 +1,62 @@
+package scanner
+
+import (
+	"testing"
+
+	"github.com/aiforce-discovery-agent/collectors/network-scanner/internal/config"
+)
+
+func TestAutoTune(t *testing.T) {
+	tests := []struct {
+		name    string
+		ceiling config.ScannerConfig
+		scan    AutonomousScanConfig
+		want    ScanTuning
+	}{
+		{
+			name: "few targets",
+			scan: AutonomousScanConfig{Targets: []string{"192.0.2.1", "192.0.2.2", "db.internal"}},
+			want: ScanTuning{Hosts: 3, MaxConcurrentHosts: 3, RateLimitPPS: 1000},
+		},
+		{
+			name: "a /24 and a range",
+			scan: AutonomousScanConfig{Subnets: []string{"10.0.0.0/25"}, Targets: []string{"10.1.0.0-10.1.0.127"}},
+			want: ScanTuning{Hosts: 256, MaxConcurrentHosts: 64, RateLimitPPS: 1000},
+		},
+		{
+			name: "a /20",
+			scan: AutonomousScanConfig{Subnets: []string{"10.0.0.0/20"}},
+			want: ScanTuning{Hosts: 4096, MaxConcurrentHosts: 128, RateLimitPPS: 500},
+		},
+		{
+			name: "a /8",
+			scan: AutonomousScanConfig{Subnets: []string{"10.0.0.0/8"}},
+			want: ScanTuning{Hosts: 1 << 24, MaxConcurrentHosts: 256, RateLimitPPS: 200},
+		},
+		{
+			name:    "capped by the ceilings",
+			ceiling: config.ScannerConfig{AutoTuneMaxConcurrency: 32, AutoTuneMaxRatePPS: 100},
+			scan:    AutonomousScanConfig{Subnets: []string{"10.0.0.0/20"}},
+			want:    ScanTuning{Hosts: 4096, MaxConcurrentHosts: 32, RateLimitPPS: 100},
+		},
+		{
+			name: "caller's settings kept",
+			scan: AutonomousScanConfig{Subnets: []string{"10.0.0.0/20"}, MaxConcurrentHosts: 8, RateLimitPPS: 50},
+			want: ScanTuning{Hosts: 4096, MaxConcurrentHosts: 8, RateLimitPPS: 50},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestScanner(t, tt.ceiling)
+			cfg := tt.scan
+
+			got := s.AutoTune(&cfg)
+			if got != tt.want {
+				t.Errorf("AutoTune() = %+v, want %+v", got, tt.want)
+			}
+			if cfg.MaxConcurrentHosts != tt.want.MaxConcurrentHosts || cfg.RateLimitPPS != tt.want.RateLimitPPS {
+				t.Errorf("scan config = %d hosts at %d pps, want the tuning applied", cfg.MaxConcurrentHosts, cfg.RateLimitPPS)
+			}
+		})
+	}
+}
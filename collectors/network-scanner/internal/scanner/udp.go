@@ -0,0 +1,140 @@
+package scanner
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"syscall"
+	"time"
+)
+
+// UDP port states. UDP has no handshake, so a silent port may be open or
+// filtered; only an ICMP port-unreachable proves it closed.
+const (
+	udpStateOpen         = "open"
+	udpStateOpenFiltered = "open|filtered"
+	udpStateClosed       = "closed"
+)
+
+// udpProbes holds service-specific payloads for UDP ports that ignore empty
+// datagrams. Ports not listed here receive an empty datagram.
+var udpProbes = map[int][]byte{
+	// DNS: standard query for the root NS records
+	53: {
+		0x12, 0x34, // transaction id
+		0x01, 0x00, // flags: recursion desired
+		0x00, 0x01, // QDCOUNT
+		0x00, 0x00, // ANCOUNT
+		0x00, 0x00, // NSCOUNT
+		0x00, 0x00, // ARCOUNT
+		0x00,       // root name
+		0x00, 0x02, // QTYPE NS
+		0x00, 0x01, // QCLASS IN
+	},
+	// NTP: client mode (3), version 3 request
+	123: append([]byte{0x1b}, make([]byte, 47)...),
+}
+
+// snmpPort is sent a v2c GetRequest for sysDescr.0 with the configured
+// community in place of a fixed payload.
+const snmpPort = 161
+
+// udpProbe returns the datagram sent to port.
+func (s *Scanner) udpProbe(port int) []byte {
+	if port == snmpPort {
+		return snmpSysDescrRequest(s.snmpCommunity())
+	}
+	return udpProbes[port]
+}
+
+func (s *Scanner) snmpCommunity() string {
+	if s.config.SNMPCommunity == "" {
+		return defaultSNMPCommunity
+	}
+	return s.config.SNMPCommunity
+}
+
+// scanUDPPort sends a probe datagram and classifies the port from the
+// response. Only ports that answer are reported as open; silence is
+// open|filtered and an ICMP port-unreachable is closed.
+func (s *Scanner) scanUDPPort(ctx context.Context, ip string, port int) ScanResult {
+	result := ScanResult{
+		IP:        ip,
+		Port:      port,
+		Protocol:  "udp",
+		Open:      false,
+		Timestamp: time.Now(),
+	}
+
+	address := net.JoinHostPort(ip, fmt.Sprintf("%d", port))
+	timeout := s.timeout()
+
+	conn, _, err := s.dialProbe(ctx, "udp", address, timeout)
+	if err != nil {
+		result.State = udpStateClosed
+		return result
+	}
+	defer func() { _ = conn.Close() }()
+
+	sent := time.Now()
+	if _, err := conn.Write(s.udpProbe(port)); err != nil {
+		result.State = udpStateClosed
+		return result
+	}
+
+	if err := conn.SetReadDeadline(time.Now().Add(timeout)); err != nil {
+		result.State = udpStateOpenFiltered
+		return result
+	}
+
+	buffer := make([]byte, maxBannerSize)
+	n, err := conn.Read(buffer)
+	result.Latency = time.Since(sent)
+	switch {
+	case err == nil || n > 0:
+		result.Open = true
+		result.State = udpStateOpen
+		result.Banner = string(buffer[:n])
+	case errors.Is(err, syscall.ECONNREFUSED):
+		// ICMP port unreachable surfaces as ECONNREFUSED on a connected socket
+		result.State = udpStateClosed
+	default:
+		result.TimedOut = true
+		result.State = udpStateOpenFiltered
+	}
+	s.recordProbe(result)
+
+	if result.Open {
+		s.identify(port, result.Banner).applyTo(&result)
+	}
+	if result.Open && port == snmpPort {
+		s.applySNMP(&result, buffer[:n])
+	}
+
+	return result
+}
+
+// applySNMP records a sysDescr answer on result. sysDescr becomes the
+// banner, so it feeds OS inference, and an agent answering to the default
+// community is flagged as a finding.
+func (s *Scanner) applySNMP(result *ScanResult, response []byte) {
+	sysDescr, ok := parseSNMPSysDescr(response)
+	if !ok {
+		return
+	}
+	sysDescr, _ = printableBanner(sysDescr)
+
+	result.Service = "SNMP"
+	result.Banner = sysDescr
+	result.FingerprintSource = "probe:snmp"
+	if result.Metadata == nil {
+		result.Metadata = make(map[string]interface{})
+	}
+	result.Metadata["protocol_version"] = "v2c"
+	result.Metadata["snmp_sysdescr"] = sysDescr
+	if s.snmpCommunity() == defaultSNMPCommunity {
+		result.Metadata["snmp_default_community"] = true
+		result.Metadata["finding_severity"] = "medium"
+	}
+}
@@ -0,0 +1,148 @@
+package scanner
+
+import (
+	"bytes"
+	"context"
+	"net"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/aiforce-discovery-agent/collectors/network-scanner/internal/config"
+)
+
+// udpListener listens on a loopback UDP port and forwards each datagram it
+// receives to requests, answering it with reply unless reply is nil.
+func udpListener(t *testing.T, reply []byte, requests chan<- []byte) net.PacketConn {
+	t.Helper()
+
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = conn.Close() })
+	go func() {
+		buffer := make([]byte, 1500)
+		for {
+			n, addr, err := conn.ReadFrom(buffer)
+			if err != nil {
+				return
+			}
+			requests <- append([]byte(nil), buffer[:n]...)
+			if reply != nil {
+				_, _ = conn.WriteTo(reply, addr)
+			}
+		}
+	}()
+	return conn
+}
+
+func TestScanUDPPort(t *testing.T) {
+	// A port nothing listens on answers with ICMP port unreachable
+	closed, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	closedAddr := closed.LocalAddr().String()
+	_ = closed.Close()
+
+	tests := []struct {
+		name         string
+		reply        []byte
+		closed       bool
+		wantOpen     bool
+		wantState    string
+		wantTimedOut bool
+	}{
+		{name: "answers", reply: []byte("dns answer"), wantOpen: true, wantState: udpStateOpen},
+		{name: "silent", wantState: udpStateOpenFiltered, wantTimedOut: true},
+		{name: "unreachable", closed: true, wantState: udpStateClosed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			requests := make(chan []byte, 1)
+			address := closedAddr
+			if !tt.closed {
+				address = udpListener(t, tt.reply, requests).LocalAddr().String()
+			}
+
+			s := newTestScanner(t, config.ScannerConfig{Timeout: 200})
+			s.dial = func(network, _ string, timeout time.Duration) (net.Conn, error) {
+				return net.DialTimeout(network, address, timeout)
+			}
+
+			result := s.scanUDPPort(context.Background(), "192.0.2.1", 53)
+			if result.Protocol != "udp" || result.Open != tt.wantOpen || result.State != tt.wantState {
+				t.Errorf("result = %s open %v state %q, want udp open %v state %q",
+					result.Protocol, result.Open, result.State, tt.wantOpen, tt.wantState)
+			}
+			if result.TimedOut != tt.wantTimedOut {
+				t.Errorf("timed out = %v, want %v", result.TimedOut, tt.wantTimedOut)
+			}
+			if tt.wantOpen && result.Banner != string(tt.reply) {
+				t.Errorf("banner = %q, want %q", result.Banner, tt.reply)
+			}
+			if tt.closed {
+				return
+			}
+			select {
+			case request := <-requests:
+				if !bytes.Equal(request, udpProbes[53]) {
+					t.Errorf("request = %x, want the DNS probe", request)
+				}
+			case <-time.After(time.Second):
+				t.Fatal("listener got no request")
+			}
+		})
+	}
+}
+
+func TestScanTargetUDP(t *testing.T) {
+	requests := make(chan []byte, 2)
+	ntp := udpListener(t, append([]byte{0x1c}, make([]byte, 47)...), requests)
+
+	tests := []struct {
+		name      string
+		enableUDP bool
+		wantUDP   bool
+	}{
+		{name: "disabled"},
+		{name: "enabled", enableUDP: true, wantUDP: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestScanner(t, config.ScannerConfig{
+				Timeout: 200, PortRanges: []string{"22"}, EnableUDP: tt.enableUDP, UDPPorts: []int{123},
+			})
+			s.dial = func(network, _ string, timeout time.Duration) (net.Conn, error) {
+				if network == "udp" {
+					return net.DialTimeout(network, ntp.LocalAddr().String(), timeout)
+				}
+				return nil, &net.OpError{Op: "dial", Net: network, Err: syscall.ECONNREFUSED}
+			}
+
+			results, err := s.ScanTarget("192.0.2.1")
+			if err != nil {
+				t.Fatalf("ScanTarget() error = %v", err)
+			}
+			var udp []ScanResult
+			for _, r := range results {
+				if r.Protocol == "udp" {
+					udp = append(udp, r)
+				}
+			}
+			if !tt.wantUDP {
+				if len(udp) != 0 {
+					t.Errorf("UDP results with enable_udp off: %+v", udp)
+				}
+				return
+			}
+			if len(udp) != 1 || udp[0].Port != 123 || udp[0].State != udpStateOpen {
+				t.Fatalf("UDP results = %+v, want open 123/udp", udp)
+			}
+			if request := <-requests; !bytes.Equal(request, udpProbes[123]) {
+				t.Errorf("request = %x, want the NTP probe", request)
+			}
+		})
+	}
+}
@@ -0,0 +1,139 @@
+// Package secrets supplies credentials for authenticated probes without
+// them passing through config files or logs.
+package secrets
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ErrNotFound is returned when no credentials exist for a target.
+var ErrNotFound = errors.New("no credentials for target")
+
+// redacted replaces secret values wherever they are formatted or encoded.
+const redacted = "[REDACTED]"
+
+// Secret is a string that never formats, logs or marshals its value. Use
+// Reveal at the point of use.
+type Secret string
+
+// Reveal returns the secret value.
+func (s Secret) Reveal() string { return string(s) }
+
+// String implements fmt.Stringer.
+func (s Secret) String() string { return redacted }
+
+// GoString implements fmt.GoStringer so %#v is redacted too.
+func (s Secret) GoString() string { return redacted }
+
+// MarshalText implements encoding.TextMarshaler for JSON and zap encoders.
+func (s Secret) MarshalText() ([]byte, error) { return []byte(redacted), nil }
+
+// Credentials are read-only login details for a single target.
+type Credentials struct {
+	Username string
+	Password Secret
+}
+
+// Target identifies the service credentials are requested for.
+type Target struct {
+	Service string // lower-case service name, e.g. redis
+	IP      string
+	Port    int
+}
+
+// keys returns the lookup keys for the target. Only the exact target is
+// looked up: a service-wide key would hand the password to any host whose
+// port merely fingerprints as that service, honeypots included.
+func (t Target) keys() []string {
+	return []string{
+		fmt.Sprintf("%s_%s_%d", t.Service, t.IP, t.Port),
+	}
+}
+
+// Provider fetches credentials at scan time. Implementations must not log
+// secret values.
+type Provider interface {
+	Credentials(ctx context.Context, target Target) (Credentials, error)
+}
+
+// EnvProvider reads SCANNER_CRED_<KEY>_USERNAME and _PASSWORD, where KEY is
+// <SERVICE>_<IP>_<PORT> with non-alphanumerics as underscores.
+type EnvProvider struct{}
+
+// Credentials implements Provider.
+func (EnvProvider) Credentials(_ context.Context, target Target) (Credentials, error) {
+	for _, key := range target.keys() {
+		prefix := "SCANNER_CRED_" + envKey(key)
+		password, ok := os.LookupEnv(prefix + "_PASSWORD")
+		if !ok {
+			continue
+		}
+		return Credentials{
+			Username: os.Getenv(prefix + "_USERNAME"),
+			Password: Secret(password),
+		}, nil
+	}
+	return Credentials{}, ErrNotFound
+}
+
+func envKey(key string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z':
+			return r - 'a' + 'A'
+		case r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+			return r
+		default:
+			return '_'
+		}
+	}, key)
+}
+
+// FileProvider reads <Dir>/<key>/username and <Dir>/<key>/password, the
+// layout of a mounted Kubernetes secret per key.
+type FileProvider struct {
+	Dir string
+}
+
+// Credentials implements Provider.
+func (p FileProvider) Credentials(_ context.Context, target Target) (Credentials, error) {
+	for _, key := range target.keys() {
+		dir := filepath.Join(p.Dir, strings.ReplaceAll(key, string(filepath.Separator), "_"))
+		password, err := os.ReadFile(filepath.Join(dir, "password"))
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return Credentials{}, fmt.Errorf("failed to read credentials for %s: %w", target.Service, err)
+		}
+		username, err := os.ReadFile(filepath.Join(dir, "username"))
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return Credentials{}, fmt.Errorf("failed to read credentials for %s: %w", target.Service, err)
+		}
+		return Credentials{
+			Username: strings.TrimSpace(string(username)),
+			Password: Secret(strings.TrimRight(string(password), "\r\n")),
+		}, nil
+	}
+	return Credentials{}, ErrNotFound
+}
+
+// NewProvider returns the provider for a configured source: env or file.
+func NewProvider(source, dir string) (Provider, error) {
+	switch source {
+	case "env":
+		return EnvProvider{}, nil
+	case "file":
+		if dir == "" {
+			return nil, fmt.Errorf("secrets directory is required for the file provider")
+		}
+		return FileProvider{Dir: dir}, nil
+	default:
+		return nil, fmt.Errorf("unknown secrets source %q", source)
+	}
+}
@@ -0,0 +1,107 @@
+package secrets
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestEnvProviderLooksUpExactTargetOnly(t *testing.T) {
+	t.Setenv("SCANNER_CRED_REDIS_10_0_0_5_6379_USERNAME", "reader")
+	t.Setenv("SCANNER_CRED_REDIS_10_0_0_5_6379_PASSWORD", "s3cret")
+	t.Setenv("SCANNER_CRED_REDIS_PASSWORD", "service-wide")
+
+	tests := []struct {
+		name     string
+		target   Target
+		wantUser string
+		wantPass string
+		wantErr  error
+	}{
+		{
+			name:     "exact target",
+			target:   Target{Service: "redis", IP: "10.0.0.5", Port: 6379},
+			wantUser: "reader",
+			wantPass: "s3cret",
+		},
+		{
+			name:    "service-wide key is not a fallback",
+			target:  Target{Service: "redis", IP: "192.0.2.1", Port: 6379},
+			wantErr: ErrNotFound,
+		},
+		{
+			name:    "other port",
+			target:  Target{Service: "redis", IP: "10.0.0.5", Port: 6380},
+			wantErr: ErrNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			creds, err := EnvProvider{}.Credentials(context.Background(), tt.target)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Credentials() error = %v, want %v", err, tt.wantErr)
+			}
+			if creds.Username != tt.wantUser || creds.Password.Reveal() != tt.wantPass {
+				t.Errorf("Credentials() = %q/%q, want %q/%q",
+					creds.Username, creds.Password.Reveal(), tt.wantUser, tt.wantPass)
+			}
+		})
+	}
+}
+
+func TestFileProvider(t *testing.T) {
+	dir := t.TempDir()
+	key := filepath.Join(dir, "redis_10.0.0.5_6379")
+	if err := os.Mkdir(key, 0o700); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(key, "password"), []byte("s3cret\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Mkdir(filepath.Join(dir, "redis"), 0o700); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "redis", "password"), []byte("service-wide"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	p := FileProvider{Dir: dir}
+	creds, err := p.Credentials(context.Background(), Target{Service: "redis", IP: "10.0.0.5", Port: 6379})
+	if err != nil {
+		t.Fatalf("Credentials() error = %v", err)
+	}
+	if creds.Password.Reveal() != "s3cret" {
+		t.Errorf("password = %q, want trailing newline trimmed", creds.Password.Reveal())
+	}
+
+	_, err = p.Credentials(context.Background(), Target{Service: "redis", IP: "192.0.2.1", Port: 6379})
+	if !errors.Is(err, ErrNotFound) {
+		t.Errorf("Credentials() for unknown host error = %v, want ErrNotFound", err)
+	}
+}
+
+func TestSecretIsRedacted(t *testing.T) {
+	creds := Credentials{Username: "reader", Password: Secret("s3cret")}
+
+	encoded, err := json.Marshal(creds)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, out := range []string{
+		fmt.Sprint(creds.Password),
+		fmt.Sprintf("%v", creds),
+		fmt.Sprintf("%+v", creds),
+		fmt.Sprintf("%#v", creds),
+		string(encoded),
+	} {
+		if strings.Contains(out, "s3cret") {
+			t.Errorf("secret value leaked: %s", out)
+		}
+	}
+}